@@ -19,6 +19,7 @@ import (
 	"strings"
 
 	"github.com/docker/docker/api"
+	"github.com/docker/docker/builder/dockerfile/check"
 	Cli "github.com/docker/docker/cli"
 	"github.com/docker/docker/graph/tags"
 	"github.com/docker/docker/opts"
@@ -30,6 +31,7 @@ import (
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/pkg/progressreader"
 	"github.com/docker/docker/pkg/streamformatter"
+	"github.com/docker/docker/pkg/symlink"
 	"github.com/docker/docker/pkg/ulimit"
 	"github.com/docker/docker/pkg/units"
 	"github.com/docker/docker/pkg/urlutil"
@@ -40,6 +42,10 @@ import (
 
 const (
 	tarHeaderSize = 512
+	// maxBuildSecretsSize is the size of the encoded build secrets the
+	// client sends, which must fit in the headers of the request the daemon
+	// accepts, 1MB with the other headers.
+	maxBuildSecretsSize = 768 * 1024
 )
 
 // CmdBuild builds a new image from the source code at a given path.
@@ -68,6 +74,19 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 	flBuildArg := opts.NewListOpts(opts.ValidateEnv)
 	cmd.Var(&flBuildArg, []string{"-build-arg"}, "Set build-time variables")
 	isolation := cmd.String([]string{"-isolation"}, "", "Container isolation level")
+	target := cmd.String([]string{"-target"}, "", "Set the target build stage to build")
+	squash := cmd.Bool([]string{"-squash"}, false, "Squash the layers of the build into a single new layer")
+	reproducible := cmd.Bool([]string{"-reproducible"}, false, "Build images which only depend on their content")
+	epoch := cmd.Int64([]string{"-epoch"}, 0, "Creation time of the images of a reproducible build, in seconds since the Unix epoch")
+	flNetworkMode := cmd.String([]string{"-network"}, "default", "Set the networking mode for the RUN instructions during build")
+	flExtraHosts := opts.NewListOpts(opts.ValidateExtraHost)
+	cmd.Var(&flExtraHosts, []string{"-add-host"}, "Add a custom host-to-IP mapping (host:ip)")
+	flCacheFrom := opts.NewListOpts(nil)
+	cmd.Var(&flCacheFrom, []string{"-cache-from"}, "Images to consider as cache sources")
+	checkOnly := cmd.Bool([]string{"-check"}, false, "Check the Dockerfile for errors without building it")
+	progress := cmd.String([]string{"-progress"}, "plain", "Set the type of progress output (plain, json)")
+	flSecrets := opts.NewListOpts(opts.ValidateSecret)
+	cmd.Var(&flSecrets, []string{"-secret"}, "Secret file to expose to the RUN instructions (id=<id>,src=<path>)")
 
 	ulimits := make(map[string]*ulimit.Ulimit)
 	flUlimits := opts.NewUlimitOpt(&ulimits)
@@ -80,6 +99,31 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 
 	cmd.ParseFlags(args, true)
 
+	if cmd.IsSet("-epoch") {
+		if !*reproducible {
+			return fmt.Errorf("--epoch can only be used with --reproducible")
+		}
+	} else if sourceDateEpoch := os.Getenv("SOURCE_DATE_EPOCH"); sourceDateEpoch != "" && *reproducible {
+		sourceEpoch, err := strconv.ParseInt(sourceDateEpoch, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: %v", sourceDateEpoch, err)
+		}
+		*epoch = sourceEpoch
+	}
+	if *epoch < 0 {
+		return fmt.Errorf("invalid epoch %d, must not be negative", *epoch)
+	}
+
+	// the output of the client is kept out of the JSON progress stream
+	progressOut := cli.out
+	switch *progress {
+	case "plain":
+	case "json":
+		progressOut = cli.err
+	default:
+		return fmt.Errorf("invalid progress type %q, must be plain or json", *progress)
+	}
+
 	var (
 		context  io.ReadCloser
 		isRemote bool
@@ -103,7 +147,7 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 	case urlutil.IsGitURL(specifiedContext) && hasGit:
 		tempDir, relDockerfile, err = getContextFromGitURL(specifiedContext, *dockerfileName)
 	case urlutil.IsURL(specifiedContext):
-		tempDir, relDockerfile, err = getContextFromURL(cli.out, specifiedContext, *dockerfileName)
+		tempDir, relDockerfile, err = getContextFromURL(progressOut, specifiedContext, *dockerfileName)
 	default:
 		contextDir, relDockerfile, err = getContextFromLocalDir(specifiedContext, *dockerfileName)
 	}
@@ -117,14 +161,18 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 		contextDir = tempDir
 	}
 
+	if *checkOnly {
+		return cli.checkDockerfile(contextDir, relDockerfile, *target)
+	}
+
 	// Resolve the FROM lines in the Dockerfile to trusted digest references
 	// using Notary. On a successful build, we must tag the resolved digests
 	// to the original name specified in the Dockerfile.
-	newDockerfile, resolvedTags, err := rewriteDockerfileFrom(filepath.Join(contextDir, relDockerfile), cli.trustedReference)
+	newDockerfiles, resolvedTags, err := rewriteDockerfileFrom(contextDir, relDockerfile, cli.trustedReference)
 	if err != nil {
 		return fmt.Errorf("unable to process Dockerfile: %v", err)
 	}
-	defer newDockerfile.Close()
+	defer newDockerfiles.Close()
 
 	// And canonicalize dockerfile name to a platform-independent one
 	relDockerfile, err = archive.CanonicalTarNameForPath(relDockerfile)
@@ -172,16 +220,16 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 		return err
 	}
 
-	// Wrap the tar archive to replace the Dockerfile entry with the rewritten
-	// Dockerfile which uses trusted pulls.
-	context = replaceDockerfileTarWrapper(context, newDockerfile, relDockerfile)
+	// Wrap the tar archive to replace the Dockerfile and fragment entries
+	// with the rewritten ones which use trusted pulls.
+	context = replaceDockerfileTarWrapper(context, newDockerfiles)
 
 	// Setup an upload progress bar
 	// FIXME: ProgressReader shouldn't be this annoying to use
 	sf := streamformatter.NewStreamFormatter()
 	var body io.Reader = progressreader.New(progressreader.Config{
 		In:        context,
-		Out:       cli.out,
+		Out:       progressOut,
 		Formatter: sf,
 		NewLines:  true,
 		ID:        "",
@@ -214,6 +262,9 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 	v := url.Values{
 		"t": flTags.GetAll(),
 	}
+	if *progress == "json" {
+		v.Set("progress", "json")
+	}
 	if *suppressOutput {
 		v.Set("q", "1")
 	}
@@ -237,6 +288,15 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 		v.Set("pull", "1")
 	}
 
+	if *squash {
+		v.Set("squash", "1")
+	}
+
+	if *reproducible {
+		v.Set("reproducible", "1")
+		v.Set("epoch", strconv.FormatInt(*epoch, 10))
+	}
+
 	if !runconfig.IsolationLevel.IsDefault(runconfig.IsolationLevel(*isolation)) {
 		v.Set("isolation", *isolation)
 	}
@@ -252,6 +312,10 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 
 	v.Set("dockerfile", relDockerfile)
 
+	if *target != "" {
+		v.Set("target", *target)
+	}
+
 	ulimitsVar := flUlimits.GetList()
 	ulimitsJSON, err := json.Marshal(ulimitsVar)
 	if err != nil {
@@ -267,16 +331,64 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 	}
 	v.Set("buildargs", string(buildArgsJSON))
 
+	if *flNetworkMode != "" && *flNetworkMode != "default" {
+		v.Set("networkmode", *flNetworkMode)
+	}
+	if flExtraHosts.Len() > 0 {
+		extraHostsJSON, err := json.Marshal(flExtraHosts.GetAll())
+		if err != nil {
+			return err
+		}
+		v.Set("extrahosts", string(extraHostsJSON))
+	}
+
+	if flCacheFrom.Len() > 0 {
+		var cacheFrom []string
+		for _, value := range flCacheFrom.GetAll() {
+			cacheFrom = append(cacheFrom, strings.Split(value, ",")...)
+		}
+		cacheFromJSON, err := json.Marshal(cacheFrom)
+		if err != nil {
+			return err
+		}
+		v.Set("cachefrom", string(cacheFromJSON))
+	}
+
 	headers := http.Header(make(map[string][]string))
 	buf, err := json.Marshal(cli.configFile.AuthConfigs)
 	if err != nil {
 		return err
 	}
 	headers.Add("X-Registry-Config", base64.URLEncoding.EncodeToString(buf))
+	if flSecrets.Len() > 0 {
+		secrets := make(map[string][]byte)
+		for _, value := range flSecrets.GetAll() {
+			id, src, err := opts.ParseSecret(value)
+			if err != nil {
+				return err
+			}
+			if _, exists := secrets[id]; exists {
+				return fmt.Errorf("duplicate secret id %s", id)
+			}
+			if secrets[id], err = ioutil.ReadFile(src); err != nil {
+				return fmt.Errorf("Error reading secret %s: %v", id, err)
+			}
+		}
+		buf, err := json.Marshal(secrets)
+		if err != nil {
+			return err
+		}
+		encoded := base64.URLEncoding.EncodeToString(buf)
+		if len(encoded) > maxBuildSecretsSize {
+			return fmt.Errorf("build secrets are too large: %d bytes once encoded, the limit is %d", len(encoded), maxBuildSecretsSize)
+		}
+		headers.Add("X-Build-Secrets", encoded)
+	}
 	headers.Set("Content-Type", "application/tar")
 
 	sopts := &streamOpts{
 		rawTerminal: true,
+		rawJSON:     *progress == "json",
 		in:          body,
 		out:         cli.out,
 		headers:     headers,
@@ -317,6 +429,49 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 	return nil
 }
 
+// checkDockerfile checks the Dockerfile relDockerfile of the context at
+// contextDir for errors, without building it nor contacting the daemon, and
+// prints each of them along with its file and line.
+func (cli *DockerCli) checkDockerfile(contextDir, relDockerfile, target string) error {
+	f, err := os.Open(filepath.Join(contextDir, relDockerfile))
+	if err != nil {
+		return fmt.Errorf("unable to open Dockerfile: %v", err)
+	}
+	defer f.Close()
+
+	include := func(path string) (io.ReadCloser, error) {
+		fullPath, err := symlink.FollowSymlinkInScope(filepath.Join(contextDir, path), contextDir)
+		if err != nil {
+			return nil, fmt.Errorf("Forbidden path outside the build context: %s", path)
+		}
+		f, err := os.Open(fullPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("Cannot locate included Dockerfile fragment: %s", path)
+			}
+			return nil, err
+		}
+		return f, nil
+	}
+
+	errs := check.Dockerfile(f, target, include)
+	for _, e := range errs {
+		file := relDockerfile
+		if e.File != "" {
+			file = e.File
+		}
+		if e.Line == 0 {
+			fmt.Fprintf(cli.out, "%s: %s\n", file, e.Message)
+		} else {
+			fmt.Fprintf(cli.out, "%s:%d: %s\n", file, e.Line, e.Message)
+		}
+	}
+	if len(errs) > 0 {
+		return Cli.StatusError{StatusCode: 1}
+	}
+	return nil
+}
+
 // validateTag checks if the given image name can be resolved.
 func validateTag(rawRepo string) (string, error) {
 	repository, tag := parsers.ParseRepositoryTag(rawRepo)
@@ -538,6 +693,14 @@ func getContextFromLocalDir(localDir, dockerfileName string) (absContextDir, rel
 
 var dockerfileFromLinePattern = regexp.MustCompile(`(?i)^[\s]*FROM[ \f\r\t\v]+(?P<image>[^ \f\r\t\v\n#]+)`)
 
+// dockerfileFromStagePattern matches the "FROM <image> AS <name>" lines which
+// name a build stage.
+var dockerfileFromStagePattern = regexp.MustCompile(`(?i)^[\s]*FROM[ \f\r\t\v]+[^ \f\r\t\v\n#]+[ \f\r\t\v]+AS[ \f\r\t\v]+(?P<name>[^ \f\r\t\v\n#]+)`)
+
+// dockerfileIncludePattern matches the "INCLUDE <path>..." lines which
+// include Dockerfile fragments of the context.
+var dockerfileIncludePattern = regexp.MustCompile(`(?i)^[\s]*INCLUDE[ \f\r\t\v]+(?P<paths>[^#\n]+)`)
+
 type trustedDockerfile struct {
 	*os.File
 	size int64
@@ -548,6 +711,21 @@ func (td *trustedDockerfile) Close() error {
 	return os.Remove(td.File.Name())
 }
 
+// trustedDockerfiles are the rewritten Dockerfile and fragments of a build,
+// by their name in the context archive.
+type trustedDockerfiles map[string]*trustedDockerfile
+
+// Close removes all the rewritten files.
+func (tds trustedDockerfiles) Close() error {
+	var err error
+	for _, td := range tds {
+		if e := td.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
 // resolvedTag records the repository, tag, and resolved digest reference
 // from a Dockerfile rewrite.
 type resolvedTag struct {
@@ -555,43 +733,81 @@ type resolvedTag struct {
 	digestRef, tagRef registry.Reference
 }
 
-// rewriteDockerfileFrom rewrites the given Dockerfile by resolving images in
-// "FROM <image>" instructions to a digest reference. `translator` is a
-// function that takes a repository name and tag reference and returns a
+// rewriteDockerfileFrom rewrites the Dockerfile relDockerfile of the context
+// at contextDir by resolving images in "FROM <image>" instructions to a
+// digest reference. The Dockerfile fragments it INCLUDEs are rewritten as
+// well, since their FROM instructions are built the same way. `translator`
+// is a function that takes a repository name and tag reference and returns a
 // trusted digest reference.
-func rewriteDockerfileFrom(dockerfileName string, translator func(string, registry.Reference) (registry.Reference, error)) (newDockerfile *trustedDockerfile, resolvedTags []*resolvedTag, err error) {
-	dockerfile, err := os.Open(dockerfileName)
+func rewriteDockerfileFrom(contextDir, relDockerfile string, translator func(string, registry.Reference) (registry.Reference, error)) (newDockerfiles trustedDockerfiles, resolvedTags []*resolvedTag, err error) {
+	rw := &dockerfileRewriter{
+		contextDir: contextDir,
+		translator: translator,
+		files:      make(trustedDockerfiles),
+		stages:     make(map[string]bool),
+	}
+
+	defer func() {
+		if err != nil {
+			// Close the tempfiles if there was an error during Notary
+			// lookups. Otherwise the caller should close them.
+			rw.files.Close()
+		}
+	}()
+
+	dockerfile, err := os.Open(filepath.Join(contextDir, relDockerfile))
 	if err != nil {
 		return nil, nil, fmt.Errorf("unable to open Dockerfile: %v", err)
 	}
 	defer dockerfile.Close()
 
-	scanner := bufio.NewScanner(dockerfile)
+	if err := rw.rewrite(dockerfile, relDockerfile); err != nil {
+		return nil, nil, err
+	}
+	return rw.files, rw.resolvedTags, nil
+}
+
+// dockerfileRewriter rewrites the FROM instructions of a Dockerfile and of
+// the fragments it includes.
+type dockerfileRewriter struct {
+	contextDir   string
+	translator   func(string, registry.Reference) (registry.Reference, error)
+	files        trustedDockerfiles
+	resolvedTags []*resolvedTag
+	// names of the build stages, which are not images to resolve
+	stages map[string]bool
+}
+
+// rewrite rewrites the file r, whose path relative to the context is
+// relPath, and then the fragments it includes, in the order the builder
+// evaluates them.
+func (rw *dockerfileRewriter) rewrite(r io.Reader, relPath string) error {
+	name, err := archive.CanonicalTarNameForPath(filepath.Clean(relPath))
+	if err != nil {
+		return fmt.Errorf("cannot canonicalize dockerfile path %s: %v", relPath, err)
+	}
 
 	// Make a tempfile to store the rewritten Dockerfile.
 	tempFile, err := ioutil.TempFile("", "trusted-dockerfile-")
 	if err != nil {
-		return nil, nil, fmt.Errorf("unable to make temporary trusted Dockerfile: %v", err)
+		return fmt.Errorf("unable to make temporary trusted Dockerfile: %v", err)
 	}
 
 	trustedFile := &trustedDockerfile{
 		File: tempFile,
 	}
+	// Record the file before rewriting the fragments, so that it is closed
+	// on error and not rewritten again if a fragment includes it.
+	rw.files[name] = trustedFile
 
-	defer func() {
-		if err != nil {
-			// Close the tempfile if there was an error during Notary lookups.
-			// Otherwise the caller should close it.
-			trustedFile.Close()
-		}
-	}()
+	scanner := bufio.NewScanner(r)
 
 	// Scan the lines of the Dockerfile, looking for a "FROM" line.
 	for scanner.Scan() {
 		line := scanner.Text()
 
 		matches := dockerfileFromLinePattern.FindStringSubmatch(line)
-		if matches != nil && matches[1] != "scratch" {
+		if matches != nil && matches[1] != "scratch" && !rw.stages[strings.ToLower(matches[1])] {
 			// Replace the line with a resolved "FROM repo@digest"
 			repo, tag := parsers.ParseRepositoryTag(matches[1])
 			if tag == "" {
@@ -600,44 +816,87 @@ func rewriteDockerfileFrom(dockerfileName string, translator func(string, regist
 
 			repoInfo, err := registry.ParseRepositoryInfo(repo)
 			if err != nil {
-				return nil, nil, fmt.Errorf("unable to parse repository info %q: %v", repo, err)
+				return fmt.Errorf("unable to parse repository info %q: %v", repo, err)
 			}
 
 			ref := registry.ParseReference(tag)
 
 			if !ref.HasDigest() && isTrusted() {
-				trustedRef, err := translator(repo, ref)
+				trustedRef, err := rw.translator(repo, ref)
 				if err != nil {
-					return nil, nil, err
+					return err
 				}
 
 				line = dockerfileFromLinePattern.ReplaceAllLiteralString(line, fmt.Sprintf("FROM %s", trustedRef.ImageName(repo)))
-				resolvedTags = append(resolvedTags, &resolvedTag{
+				rw.resolvedTags = append(rw.resolvedTags, &resolvedTag{
 					repoInfo:  repoInfo,
 					digestRef: trustedRef,
 					tagRef:    ref,
 				})
 			}
 		}
+		if matches := dockerfileFromStagePattern.FindStringSubmatch(line); matches != nil {
+			rw.stages[strings.ToLower(matches[1])] = true
+		}
+		if matches := dockerfileIncludePattern.FindStringSubmatch(line); matches != nil && isTrusted() {
+			for _, path := range strings.Fields(matches[1]) {
+				if err := rw.include(path); err != nil {
+					return err
+				}
+			}
+		}
 
 		n, err := fmt.Fprintln(tempFile, line)
 		if err != nil {
-			return nil, nil, err
+			return err
 		}
 
 		trustedFile.size += int64(n)
 	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
 
 	tempFile.Seek(0, os.SEEK_SET)
+	return nil
+}
+
+// include rewrites the Dockerfile fragment path of an INCLUDE instruction,
+// unless it has already been.
+func (rw *dockerfileRewriter) include(path string) error {
+	fullPath, err := symlink.FollowSymlinkInScope(filepath.Join(rw.contextDir, path), rw.contextDir)
+	if err != nil {
+		return fmt.Errorf("Forbidden path outside the build context: %s", path)
+	}
+	relPath, err := filepath.Rel(rw.contextDir, fullPath)
+	if err != nil {
+		return err
+	}
+	name, err := archive.CanonicalTarNameForPath(relPath)
+	if err != nil {
+		return fmt.Errorf("cannot canonicalize dockerfile path %s: %v", relPath, err)
+	}
+	if _, ok := rw.files[name]; ok {
+		return nil
+	}
+
+	f, err := os.Open(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("Cannot locate included Dockerfile fragment: %s", path)
+		}
+		return err
+	}
+	defer f.Close()
 
-	return trustedFile, resolvedTags, scanner.Err()
+	return rw.rewrite(f, relPath)
 }
 
 // replaceDockerfileTarWrapper wraps the given input tar archive stream and
-// replaces the entry with the given Dockerfile name with the contents of the
-// new Dockerfile. Returns a new tar archive stream with the replaced
-// Dockerfile.
-func replaceDockerfileTarWrapper(inputTarStream io.ReadCloser, newDockerfile *trustedDockerfile, dockerfileName string) io.ReadCloser {
+// replaces the entries of the given Dockerfiles with the contents of the
+// new Dockerfiles. Returns a new tar archive stream with the replaced
+// Dockerfiles.
+func replaceDockerfileTarWrapper(inputTarStream io.ReadCloser, newDockerfiles trustedDockerfiles) io.ReadCloser {
 	pipeReader, pipeWriter := io.Pipe()
 
 	go func() {
@@ -661,10 +920,11 @@ func replaceDockerfileTarWrapper(inputTarStream io.ReadCloser, newDockerfile *tr
 
 			var content io.Reader = tarReader
 
-			if hdr.Name == dockerfileName {
-				// This entry is the Dockerfile. Since the tar archive was
-				// generated from a directory on the local filesystem, the
-				// Dockerfile will only appear once in the archive.
+			if newDockerfile, ok := newDockerfiles[hdr.Name]; ok && hdr.Typeflag == tar.TypeReg {
+				// This entry is the Dockerfile or one of its fragments.
+				// Since the tar archive was generated from a directory on
+				// the local filesystem, it will only appear once in the
+				// archive.
 				hdr.Size = newDockerfile.size
 				content = newDockerfile
 			}
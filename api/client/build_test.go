@@ -0,0 +1,90 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/registry"
+)
+
+func TestRewriteDockerfileFromIncludes(t *testing.T) {
+	contextDir, err := ioutil.TempDir("", "docker-build-rewrite-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(contextDir)
+
+	files := map[string]string{
+		"Dockerfile":       "FROM busybox AS base\nINCLUDE inc/one inc/two\n",
+		"inc/one":          "FROM base\nINCLUDE inc/two\n",
+		"inc/two":          "FROM debian:jessie\nRUN true\n",
+		"inc/untouched.in": "FROM ubuntu\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(contextDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dgst := digest.Digest("sha256:" + strings.Repeat("a", 64))
+	translator := func(repo string, ref registry.Reference) (registry.Reference, error) {
+		return registry.DigestReference(dgst), nil
+	}
+
+	newDockerfiles, resolvedTags, err := rewriteDockerfileFrom(contextDir, "Dockerfile", translator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer newDockerfiles.Close()
+
+	expected := map[string]string{
+		"Dockerfile": "FROM busybox@" + dgst.String() + " AS base\nINCLUDE inc/one inc/two\n",
+		"inc/one":    "FROM base\nINCLUDE inc/two\n",
+		"inc/two":    "FROM debian@" + dgst.String() + "\nRUN true\n",
+	}
+	if len(newDockerfiles) != len(expected) {
+		t.Fatalf("Expected %d rewritten files, got %d", len(expected), len(newDockerfiles))
+	}
+	for name, content := range expected {
+		td, ok := newDockerfiles[name]
+		if !ok {
+			t.Fatalf("Expected %s to be rewritten", name)
+		}
+		data, err := ioutil.ReadAll(td)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content || td.size != int64(len(content)) {
+			t.Fatalf("Expected %s to be rewritten as %q, got %q", name, content, data)
+		}
+	}
+	if len(resolvedTags) != 2 {
+		t.Fatalf("Expected 2 resolved tags, got %d", len(resolvedTags))
+	}
+}
+
+func TestRewriteDockerfileFromMissingInclude(t *testing.T) {
+	contextDir, err := ioutil.TempDir("", "docker-build-rewrite-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(contextDir)
+
+	if err := ioutil.WriteFile(filepath.Join(contextDir, "Dockerfile"), []byte("INCLUDE missing\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	translator := func(repo string, ref registry.Reference) (registry.Reference, error) {
+		return ref, nil
+	}
+	if _, _, err := rewriteDockerfileFrom(contextDir, "Dockerfile", translator); err == nil || !strings.Contains(err.Error(), "Cannot locate included Dockerfile fragment: missing") {
+		t.Fatalf("Expected a missing fragment error, got %v", err)
+	}
+}
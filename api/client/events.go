@@ -1,11 +1,18 @@
 package client
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/url"
+	"sort"
+	"strings"
 	"time"
 
+	eventtypes "github.com/docker/docker/api/types/events"
 	Cli "github.com/docker/docker/cli"
 	"github.com/docker/docker/opts"
+	"github.com/docker/docker/pkg/jsonmessage"
 	flag "github.com/docker/docker/pkg/mflag"
 	"github.com/docker/docker/pkg/parsers/filters"
 	"github.com/docker/docker/pkg/timeutils"
@@ -52,12 +59,68 @@ func (cli *DockerCli) CmdEvents(args ...string) error {
 		}
 		v.Set("filters", filterJSON)
 	}
-	sopts := &streamOpts{
-		rawTerminal: true,
-		out:         cli.out,
-	}
-	if _, err := cli.stream("GET", "/events?"+v.Encode(), sopts); err != nil {
+	serverResp, err := cli.call("GET", "/events?"+v.Encode(), nil, nil)
+	if err != nil {
 		return err
 	}
+	defer serverResp.body.Close()
+
+	return streamEvents(serverResp.body, cli.out)
+}
+
+// streamEvents decodes and prints the incoming events in the provided output.
+func streamEvents(input io.Reader, output io.Writer) error {
+	dec := json.NewDecoder(input)
+	for {
+		var event eventtypes.Message
+		if err := dec.Decode(&event); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		printOutput(event, output)
+	}
 	return nil
 }
+
+// printOutput prints all types of event information.
+// Each output includes the event type, actor id, name and action.
+// Actor attributes are printed at the end if the actor has any.
+// Container and image events keep the format of older versions.
+func printOutput(event eventtypes.Message, output io.Writer) {
+	if event.Status != "" {
+		jm := &jsonmessage.JSONMessage{
+			Status:   event.Status,
+			ID:       event.ID,
+			From:     event.From,
+			Time:     event.Time,
+			TimeNano: event.TimeNano,
+		}
+		jm.Display(output, false)
+		return
+	}
+
+	if event.TimeNano != 0 {
+		fmt.Fprintf(output, "%s ", time.Unix(0, event.TimeNano).Format(timeutils.RFC3339NanoFixed))
+	} else if event.Time != 0 {
+		fmt.Fprintf(output, "%s ", time.Unix(event.Time, 0).Format(timeutils.RFC3339NanoFixed))
+	}
+
+	fmt.Fprintf(output, "%s %s %s", event.Type, event.Action, event.Actor.ID)
+
+	if len(event.Actor.Attributes) > 0 {
+		var attrs []string
+		var keys []string
+		for k := range event.Actor.Attributes {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := event.Actor.Attributes[k]
+			attrs = append(attrs, fmt.Sprintf("%s=%s", k, v))
+		}
+		fmt.Fprintf(output, " (%s)", strings.Join(attrs, ", "))
+	}
+	fmt.Fprint(output, "\n")
+}
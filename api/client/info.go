@@ -44,6 +44,7 @@ func (cli *DockerCli) CmdInfo(args ...string) error {
 	}
 	ioutils.FprintfIfNotEmpty(cli.out, "Execution Driver: %s\n", info.ExecutionDriver)
 	ioutils.FprintfIfNotEmpty(cli.out, "Logging Driver: %s\n", info.LoggingDriver)
+	ioutils.FprintfIfTrue(cli.out, "Seccomp: %v\n", info.Seccomp)
 	ioutils.FprintfIfNotEmpty(cli.out, "Kernel Version: %s\n", info.KernelVersion)
 	ioutils.FprintfIfNotEmpty(cli.out, "Operating System: %s\n", info.OperatingSystem)
 	fmt.Fprintf(cli.out, "CPUs: %d\n", info.NCPU)
@@ -98,6 +99,9 @@ func (cli *DockerCli) CmdInfo(args ...string) error {
 			if !info.CPUSet {
 				fmt.Fprintf(cli.err, "WARNING: No cpuset support\n")
 			}
+			if !info.PidsLimit {
+				fmt.Fprintf(cli.err, "WARNING: No pids limit support\n")
+			}
 			if !info.IPv4Forwarding {
 				fmt.Fprintf(cli.err, "WARNING: IPv4 forwarding is disabled\n")
 			}
@@ -125,5 +129,6 @@ func (cli *DockerCli) CmdInfo(args ...string) error {
 	if info.ClusterAdvertise != "" {
 		fmt.Fprintf(cli.out, "Cluster advertise: %s\n", info.ClusterAdvertise)
 	}
+	ioutils.FprintfIfTrue(cli.out, "Live Restore Enabled: %v\n", info.LiveRestoreEnabled)
 	return nil
 }
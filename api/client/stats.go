@@ -12,8 +12,8 @@ import (
 	"time"
 
 	"github.com/docker/docker/api/types"
+	eventtypes "github.com/docker/docker/api/types/events"
 	Cli "github.com/docker/docker/cli"
-	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/docker/docker/pkg/units"
 )
 
@@ -211,12 +211,15 @@ func (cli *DockerCli) CmdStats(args ...string) error {
 
 			dec := json.NewDecoder(res.body)
 			for {
-				var j *jsonmessage.JSONMessage
+				var j eventtypes.Message
 				if err := dec.Decode(&j); err != nil {
 					c <- watch{err: err}
 					return
 				}
-				c <- watch{j.ID[:12], j.Status, nil}
+				if j.Type != eventtypes.ContainerEventType {
+					continue
+				}
+				c <- watch{j.Actor.ID[:12], j.Action, nil}
 			}
 		}
 		go func(stopChan chan<- error) {
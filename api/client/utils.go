@@ -205,6 +205,7 @@ func (cli *DockerCli) call(method, path string, data interface{}, headers map[st
 
 type streamOpts struct {
 	rawTerminal bool
+	rawJSON     bool // copy the JSON messages of the stream as they are, one per line
 	in          io.Reader
 	out         io.Writer
 	err         io.Writer
@@ -216,6 +217,10 @@ func (cli *DockerCli) stream(method, path string, opts *streamOpts) (*serverResp
 	if err != nil {
 		return serverResp, err
 	}
+	if opts.rawJSON {
+		defer serverResp.body.Close()
+		return serverResp, jsonmessage.CopyJSONMessagesStream(serverResp.body, opts.out)
+	}
 	return serverResp, cli.streamBody(serverResp.body, serverResp.header.Get("Content-Type"), opts.rawTerminal, opts.out, opts.err)
 }
 
@@ -13,6 +13,7 @@ import (
 	"github.com/docker/docker/api/server/httputils"
 	"github.com/docker/docker/dockerversion"
 	"github.com/docker/docker/errors"
+	"github.com/docker/docker/pkg/authorization"
 	"github.com/docker/docker/pkg/version"
 	"golang.org/x/net/context"
 )
@@ -57,6 +58,52 @@ func (s *Server) debugRequestMiddleware(handler httputils.APIFunc) httputils.API
 	}
 }
 
+// authorizationMiddleware asks the authorization plugins whether the request,
+// and then its response, are allowed. Responses which are streamed or
+// hijacked are only authorized on the request.
+func (s *Server) authorizationMiddleware(handler httputils.APIFunc) httputils.APIFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+		// User and UserAuthNMethod are taken from the TLS client
+		// certificate, when there is one
+		user := ""
+		userAuthNMethod := ""
+		if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			user = r.TLS.PeerCertificates[0].Subject.CommonName
+			userAuthNMethod = "TLS"
+		}
+
+		authCtx := authorization.NewCtx(s.authZPlugins, user, userAuthNMethod, r.Method, r.RequestURI)
+		if err := authCtx.AuthZRequest(w, r); err != nil {
+			logrus.Errorf("AuthZRequest for %s %s returned error: %s", r.Method, r.RequestURI, err)
+			return authorizationError(err)
+		}
+
+		rw := authorization.NewResponseModifier(w)
+		if err := handler(ctx, rw, r, vars); err != nil {
+			logrus.Errorf("Handler for %s %s returned error: %s", r.Method, r.RequestURI, err)
+			return err
+		}
+		if rw.Streamed() {
+			return nil
+		}
+
+		if err := authCtx.AuthZResponse(rw, r); err != nil {
+			logrus.Errorf("AuthZResponse for %s %s returned error: %s", r.Method, r.RequestURI, err)
+			return authorizationError(err)
+		}
+		return nil
+	}
+}
+
+// authorizationError converts a denial from an authorization plugin into an
+// API error, so that it is returned to the client with a 403 status code.
+func authorizationError(err error) error {
+	if denied, ok := err.(*authorization.DeniedError); ok {
+		return errors.ErrorCodeAuthorizationDenied.WithArgs(denied.Plugin, denied.Msg)
+	}
+	return err
+}
+
 // userAgentMiddleware checks the User-Agent header looking for a valid docker client spec.
 func (s *Server) userAgentMiddleware(handler httputils.APIFunc) httputils.APIFunc {
 	return func(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
@@ -146,6 +193,10 @@ func (s *Server) handleWithGlobalMiddlewares(handler httputils.APIFunc) httputil
 		middlewares = append(middlewares, s.debugRequestMiddleware)
 	}
 
+	if len(s.authZPlugins) > 0 {
+		middlewares = append(middlewares, s.authorizationMiddleware)
+	}
+
 	h := handler
 	for _, m := range middlewares {
 		h = m(h)
@@ -321,6 +321,29 @@ func (s *router) postContainerRename(ctx context.Context, w http.ResponseWriter,
 	return nil
 }
 
+func (s *router) postContainerUpdate(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+	if err := httputils.CheckForJSON(r); err != nil {
+		return err
+	}
+
+	updateConfig, err := runconfig.DecodeUpdateConfig(r.Body)
+	if err != nil {
+		return err
+	}
+
+	warnings, err := s.daemon.ContainerUpdate(vars["name"], updateConfig)
+	if err != nil {
+		return err
+	}
+
+	return httputils.WriteJSON(w, http.StatusOK, &types.ContainerUpdateResponse{
+		Warnings: warnings,
+	})
+}
+
 func (s *router) postContainersCreate(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := httputils.ParseForm(r); err != nil {
 		return err
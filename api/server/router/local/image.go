@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/api/server/httputils"
@@ -19,9 +20,11 @@ import (
 	derr "github.com/docker/docker/errors"
 	"github.com/docker/docker/graph"
 	"github.com/docker/docker/graph/tags"
+	"github.com/docker/docker/opts"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/chrootarchive"
 	"github.com/docker/docker/pkg/ioutils"
+	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/pkg/progressreader"
 	"github.com/docker/docker/pkg/streamformatter"
@@ -329,6 +332,19 @@ func (s *router) postBuild(ctx context.Context, w http.ResponseWriter, r *http.R
 	buildConfig.CPUSetCpus = r.FormValue("cpusetcpus")
 	buildConfig.CPUSetMems = r.FormValue("cpusetmems")
 	buildConfig.CgroupParent = r.FormValue("cgroupparent")
+	buildConfig.Target = r.FormValue("target")
+	buildConfig.Squash = httputils.BoolValue(r, "squash")
+	if httputils.BoolValue(r, "reproducible") {
+		epoch, err := httputils.Int64ValueOrDefault(r, "epoch", 0)
+		if err != nil {
+			return errf(err)
+		}
+		if epoch < 0 {
+			return errf(fmt.Errorf("invalid epoch %d, must not be negative", epoch))
+		}
+		buildConfig.Reproducible = true
+		buildConfig.Epoch = time.Unix(epoch, 0).UTC()
+	}
 
 	if i := runconfig.IsolationLevel(r.FormValue("isolation")); i != "" {
 		if !runconfig.IsolationLevel.IsValid(i) {
@@ -355,6 +371,54 @@ func (s *router) postBuild(ctx context.Context, w http.ResponseWriter, r *http.R
 		buildConfig.BuildArgs = buildArgs
 	}
 
+	var cacheFrom = []string{}
+	cacheFromJSON := r.FormValue("cachefrom")
+	if cacheFromJSON != "" {
+		if err := json.NewDecoder(strings.NewReader(cacheFromJSON)).Decode(&cacheFrom); err != nil {
+			return errf(err)
+		}
+		buildConfig.CacheFrom = cacheFrom
+	}
+
+	buildConfig.NetworkMode = r.FormValue("networkmode")
+	extraHostsJSON := r.FormValue("extrahosts")
+	if extraHostsJSON != "" {
+		var extraHosts = []string{}
+		if err := json.NewDecoder(strings.NewReader(extraHostsJSON)).Decode(&extraHosts); err != nil {
+			return errf(err)
+		}
+		for _, extraHost := range extraHosts {
+			if _, err := opts.ValidateExtraHost(extraHost); err != nil {
+				return errf(err)
+			}
+		}
+		buildConfig.ExtraHosts = extraHosts
+	}
+	// the RUN containers are checked like the ones of docker run, with the
+	// default network mode when none is given
+	if err := runconfig.ValidateNetMode(&runconfig.Config{}, runconfig.SetDefaultNetModeIfBlank(&runconfig.HostConfig{
+		NetworkMode: runconfig.NetworkMode(buildConfig.NetworkMode),
+		ExtraHosts:  buildConfig.ExtraHosts,
+	})); err != nil {
+		return errf(err)
+	}
+
+	// the build secrets are sent in a header rather than in the query, so
+	// they don't end up in the logs
+	if secretsEncoded := r.Header.Get("X-Build-Secrets"); secretsEncoded != "" {
+		var secrets = map[string][]byte{}
+		secretsJSON := base64.NewDecoder(base64.URLEncoding, strings.NewReader(secretsEncoded))
+		if err := json.NewDecoder(secretsJSON).Decode(&secrets); err != nil {
+			return errf(err)
+		}
+		for id := range secrets {
+			if _, err := opts.ValidateSecretID(id); err != nil {
+				return errf(err)
+			}
+		}
+		buildConfig.Secrets = secrets
+	}
+
 	remoteURL := r.FormValue("remote")
 
 	// Currently, only used if context is from a remote url.
@@ -403,6 +467,15 @@ func (s *router) postBuild(ctx context.Context, w http.ResponseWriter, r *http.R
 	}
 	b.Stdout = &streamformatter.StdoutFormatter{Writer: output, StreamFormatter: sf}
 	b.Stderr = &streamformatter.StderrFormatter{Writer: output, StreamFormatter: sf}
+	switch progress := r.FormValue("progress"); progress {
+	case "", "plain":
+	case "json":
+		b.Events = func(ev *jsonmessage.JSONBuildEvent) {
+			output.Write(sf.FormatBuildEvent(ev))
+		}
+	default:
+		return errf(fmt.Errorf("invalid progress mode %q, must be plain or json", progress))
+	}
 
 	if closeNotifier, ok := w.(http.CloseNotifier); ok {
 		finished := make(chan struct{})
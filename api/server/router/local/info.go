@@ -10,6 +10,7 @@ import (
 	"github.com/docker/docker/api"
 	"github.com/docker/docker/api/server/httputils"
 	"github.com/docker/docker/api/types"
+	eventtypes "github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/dockerversion"
 	"github.com/docker/docker/pkg/ioutils"
 	"github.com/docker/docker/pkg/jsonmessage"
@@ -94,14 +95,28 @@ func (s *router) getEvents(ctx context.Context, w http.ResponseWriter, r *http.R
 	current, l, cancel := s.daemon.SubscribeToEvents()
 	defer cancel()
 
+	// Clients older than 1.22 only know about container and image events,
+	// in the shape of a JSONMessage.
+	legacy := httputils.VersionFromContext(ctx).LessThan("1.22")
+
 	eventFilter := s.daemon.GetEventFilter(ef)
-	handleEvent := func(ev *jsonmessage.JSONMessage) error {
-		if eventFilter.Include(ev) {
-			if err := enc.Encode(ev); err != nil {
-				return err
-			}
+	handleEvent := func(ev eventtypes.Message) error {
+		if !eventFilter.Include(ev) {
+			return nil
+		}
+		if !legacy {
+			return enc.Encode(ev)
+		}
+		if ev.Type != eventtypes.ContainerEventType && ev.Type != eventtypes.ImageEventType {
+			return nil
 		}
-		return nil
+		return enc.Encode(&jsonmessage.JSONMessage{
+			Status:   ev.Status,
+			ID:       ev.ID,
+			From:     ev.From,
+			Time:     ev.Time,
+			TimeNano: ev.TimeNano,
+		})
 	}
 
 	if since == -1 {
@@ -124,7 +139,7 @@ func (s *router) getEvents(ctx context.Context, w http.ResponseWriter, r *http.R
 	for {
 		select {
 		case ev := <-l:
-			jev, ok := ev.(*jsonmessage.JSONMessage)
+			jev, ok := ev.(eventtypes.Message)
 			if !ok {
 				continue
 			}
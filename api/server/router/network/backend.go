@@ -19,5 +19,6 @@ type Backend interface {
 	ConnectContainerToNetwork(containerName, networkName string) error
 	DisconnectContainerFromNetwork(containerName string,
 		network libnetwork.Network) error
+	DeleteNetwork(networkID string) error
 	NetworkControllerEnabled() bool
 }
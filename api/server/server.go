@@ -14,6 +14,7 @@ import (
 	"github.com/docker/docker/api/server/router/network"
 	"github.com/docker/docker/api/server/router/volume"
 	"github.com/docker/docker/daemon"
+	"github.com/docker/docker/pkg/authorization"
 	"github.com/docker/docker/pkg/sockets"
 	"github.com/docker/docker/utils"
 	"github.com/gorilla/mux"
@@ -33,6 +34,10 @@ type Config struct {
 	SocketGroup string
 	TLSConfig   *tls.Config
 	Addrs       []Addr
+
+	// AuthorizationPluginNames are the authorization plugins which approve
+	// each request, in order of evaluation.
+	AuthorizationPluginNames []string
 }
 
 // Server contains instance details for the server
@@ -41,6 +46,8 @@ type Server struct {
 	start   chan struct{}
 	servers []*HTTPServer
 	routers []router.Router
+
+	authZPlugins []authorization.Plugin
 }
 
 // Addr contains string representation of address and its protocol (tcp, unix...).
@@ -56,6 +63,9 @@ func New(cfg *Config) (*Server, error) {
 		cfg:   cfg,
 		start: make(chan struct{}),
 	}
+	if len(cfg.AuthorizationPluginNames) > 0 {
+		s.authZPlugins = authorization.NewPlugins(cfg.AuthorizationPluginNames)
+	}
 	for _, addr := range cfg.Addrs {
 		srv, err := s.newServer(addr.Proto, addr.Addr)
 		if err != nil {
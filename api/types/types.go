@@ -21,6 +21,13 @@ type ContainerCreateResponse struct {
 	Warnings []string `json:"Warnings"`
 }
 
+// ContainerUpdateResponse contains response of Remote API:
+// POST "/containers/{name:.*}/update"
+type ContainerUpdateResponse struct {
+	// Warnings are any warnings encountered during the update of the container.
+	Warnings []string `json:"Warnings"`
+}
+
 // ContainerExecCreateResponse contains response of Remote API:
 // POST "/containers/{name:.*}/exec"
 type ContainerExecCreateResponse struct {
@@ -200,6 +207,8 @@ type Info struct {
 	Debug              bool
 	NFd                int
 	OomKillDisable     bool
+	PidsLimit          bool
+	Seccomp            bool
 	NGoroutines        int
 	SystemTime         string
 	ExecutionDriver    string
@@ -223,6 +232,7 @@ type Info struct {
 	ServerVersion      string
 	ClusterStore       string
 	ClusterAdvertise   string
+	LiveRestoreEnabled bool
 }
 
 // ExecStartCheck is a temp struct used by execStart
@@ -248,6 +258,31 @@ type ContainerState struct {
 	Error      string
 	StartedAt  string
 	FinishedAt string
+	Health     *Health `json:",omitempty"`
+}
+
+// Health states of a container
+const (
+	NoHealthcheck = "none"      // Indicates there is no health check
+	Starting      = "starting"  // Starting indicates that the container is not yet ready
+	Healthy       = "healthy"   // Healthy indicates that the container is running correctly
+	Unhealthy     = "unhealthy" // Unhealthy indicates that the container has a problem
+)
+
+// Health stores information about the health of a container
+// it's part of ContainerState and will return by "inspect" command
+type Health struct {
+	Status        string               // Status is one of Starting, Healthy or Unhealthy
+	FailingStreak int                  // FailingStreak is the number of consecutive failures
+	Log           []*HealthcheckResult // Log contains the last few results (oldest first)
+}
+
+// HealthcheckResult stores information about a single run of a health check
+type HealthcheckResult struct {
+	Start    time.Time // Start is the time this check started
+	End      time.Time // End is the time this check ended
+	ExitCode int       // ExitCode meanings: 0=healthy, 1=unhealthy, 2=reserved (considered unhealthy), else=error running probe
+	Output   string    // Output from last check
 }
 
 // ContainerJSONBase contains response of Remote API:
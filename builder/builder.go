@@ -147,6 +147,17 @@ type Docker interface {
 	Unmount(c *daemon.Container) error
 	// Start starts a new container
 	Start(c *daemon.Container) error
+	// Squash creates an image with the changes of the image `imageID` since
+	// its ancestor `parentID` in a single layer, and returns its ID. The
+	// images are created like reproducible commits if `reproducible` is set.
+	Squash(imageID, parentID string, reproducible bool) (string, error)
+}
+
+// ImageCacheBuilder makes image caches using other images as cache sources.
+type ImageCacheBuilder interface {
+	// MakeImageCache returns an ImageCache which also matches the history
+	// of the images referenced by `cacheFrom`.
+	MakeImageCache(cacheFrom []string) ImageCache
 }
 
 // ImageCache abstracts an image cache store.
@@ -8,11 +8,15 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/builder"
+	"github.com/docker/docker/builder/dockerfile/check"
+	"github.com/docker/docker/builder/dockerfile/command"
 	"github.com/docker/docker/builder/dockerfile/parser"
 	"github.com/docker/docker/daemon"
+	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/docker/docker/pkg/stringid"
 	"github.com/docker/docker/pkg/ulimit"
 	"github.com/docker/docker/runconfig"
@@ -25,6 +29,7 @@ var validCommitCommands = map[string]bool{
 	"expose":     true,
 	"label":      true,
 	"onbuild":    true,
+	"shell":      true,
 	"user":       true,
 	"volume":     true,
 	"workdir":    true,
@@ -54,6 +59,19 @@ type Config struct {
 	Pull        bool
 	BuildArgs   map[string]string // build-time args received in build context for expansion/substitution and commands in 'run'.
 	Isolation   runconfig.IsolationLevel
+	Target      string            // name of the build stage to stop the build at, the last stage if empty
+	Squash      bool              // squash the layers of the build into one layer on top of the base image
+	CacheFrom   []string          // images whose history is used as a build cache, in addition to the local images
+	NetworkMode string            // network mode of the RUN containers, as with docker run --net
+	ExtraHosts  []string          // extra /etc/hosts entries of the RUN containers, as with docker run --add-host
+	Secrets     map[string][]byte // build secrets by ID, only mounted in the RUN containers asking for them
+
+	// Reproducible makes the images created by the build depend only on
+	// their content: they are created at Epoch, their layers are normalized
+	// with the files modified at Epoch, and their IDs are derived from their
+	// content.
+	Reproducible bool
+	Epoch        time.Time
 
 	// resource constraints
 	// TODO: factor out to be reused with Run ?
@@ -76,13 +94,15 @@ type Builder struct {
 
 	Stdout io.Writer
 	Stderr io.Writer
+	Events func(*jsonmessage.JSONBuildEvent) // receives the structured progress of the build, if set
 
-	docker  builder.Docker
-	context builder.Context
+	docker     builder.Docker
+	imageCache builder.ImageCache
+	context    builder.Context
 
 	dockerfile       *parser.Node
 	runConfig        *runconfig.Config // runconfig for cmd, run, entrypoint etc.
-	flags            *BFlags
+	flags            *check.BFlags
 	tmpContainers    map[string]struct{}
 	image            string // imageID
 	noBaseImage      bool
@@ -94,6 +114,18 @@ type Builder struct {
 	cancelOnce       sync.Once
 	allowedBuildArgs map[string]bool // list of build-time args that are allowed for expansion/substitution and passing to commands in 'run'.
 
+	// build stages, each started by a FROM instruction
+	stageName   string            // name of the current stage, if any
+	stageBase   string            // ID of the base image of the current stage, empty for scratch
+	stageImages []string          // image IDs of the completed stages, by index
+	stageNames  map[string]string // image IDs of the completed stages, by name
+
+	secretsRoot string // tmpfs holding the build secrets of the RUN containers
+
+	// finish event of the current step, only recorded if Events is set
+	step      *jsonmessage.JSONBuildEvent
+	stepStart time.Time
+
 	// TODO: remove once docker.Commit can receive a tag
 	id           string
 	activeImages []string
@@ -120,6 +152,12 @@ func NewBuilder(config *Config, docker builder.Docker, context builder.Context,
 		cancelled:        make(chan struct{}),
 		id:               stringid.GenerateNonCryptoID(),
 		allowedBuildArgs: make(map[string]bool),
+		stageNames:       make(map[string]string),
+	}
+	if icb, ok := docker.(builder.ImageCacheBuilder); ok && len(config.CacheFrom) > 0 {
+		b.imageCache = icb.MakeImageCache(config.CacheFrom)
+	} else if c, ok := docker.(builder.ImageCache); ok {
+		b.imageCache = c
 	}
 	if dockerfile != nil {
 		b.dockerfile, err = parser.Parse(dockerfile)
@@ -149,6 +187,7 @@ func (b *Builder) Build() (string, error) {
 	defer func() {
 		b.docker.Release(b.id, b.activeImages)
 	}()
+	defer b.releaseSecrets()
 
 	// If Dockerfile was not parsed yet, extract it from the Context
 	if b.dockerfile == nil {
@@ -157,8 +196,18 @@ func (b *Builder) Build() (string, error) {
 		}
 	}
 
+	if err := b.checkStages(); err != nil {
+		return "", err
+	}
+
 	var shortImgID string
 	for i, n := range b.dockerfile.Children {
+		// the FROM of the stage following the target ends the build
+		if n.Value == command.From && b.Target != "" && b.stageName == b.Target {
+			b.declareArgs(b.dockerfile.Children[i:])
+			break
+		}
+
 		select {
 		case <-b.cancelled:
 			logrus.Debug("Builder: build cancelled!")
@@ -167,12 +216,18 @@ func (b *Builder) Build() (string, error) {
 		default:
 			// Not cancelled yet, keep going...
 		}
+		b.startStep(i, n)
 		if err := b.dispatch(i, n); err != nil {
+			if n.File != "" {
+				err = fragmentError(n, err)
+			}
+			b.finishStep(err)
 			if b.ForceRemove {
 				b.clearTmp()
 			}
 			return "", err
 		}
+		b.finishStep(nil)
 		shortImgID = stringid.TruncateID(b.image)
 		fmt.Fprintf(b.Stdout, " ---> %s\n", shortImgID)
 		if b.Remove {
@@ -196,6 +251,13 @@ func (b *Builder) Build() (string, error) {
 		return "", fmt.Errorf("No image was generated. Is your Dockerfile empty?")
 	}
 
+	if b.Squash && b.image != b.stageBase {
+		if err := b.squash(); err != nil {
+			return "", err
+		}
+		shortImgID = stringid.TruncateID(b.image)
+	}
+
 	fmt.Fprintf(b.Stdout, "Successfully built %s\n", shortImgID)
 	return b.image, nil
 }
@@ -1,4 +1,4 @@
-package dockerfile
+package check
 
 import (
 	"fmt"
@@ -82,6 +82,16 @@ func (bf *BFlags) addFlag(name string, flagType FlagType) *Flag {
 	return newFlag
 }
 
+// Lookup returns the flag with the given name, nil if there is none.
+func (bf *BFlags) Lookup(name string) *Flag {
+	return bf.flags[name]
+}
+
+// Name returns the name of the flag
+func (fl *Flag) Name() string {
+	return fl.name
+}
+
 // IsUsed checks if the flag is used
 func (fl *Flag) IsUsed() bool {
 	if _, ok := fl.bf.used[fl.name]; ok {
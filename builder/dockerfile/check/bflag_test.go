@@ -1,7 +1,9 @@
-package dockerfile
+package check
 
 import (
 	"testing"
+
+	"github.com/docker/docker/builder/dockerfile/command"
 )
 
 func TestBuilderFlags(t *testing.T) {
@@ -185,3 +187,21 @@ func TestBuilderFlags(t *testing.T) {
 		t.Fatalf("Teset %s, bool1 should be true", bf.Args)
 	}
 }
+
+func TestInstructionFlags(t *testing.T) {
+	bf := NewFlags(command.Copy, []string{"--from=base"})
+	if err := bf.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if from := bf.Lookup("from"); from == nil || !from.IsUsed() || from.Value != "base" {
+		t.Fatalf("Expected the from flag to be base, got %+v", from)
+	}
+
+	bf = NewFlags(command.Run, []string{"--from=base"})
+	if err := bf.Parse(); err == nil || err.Error() != "Unknown flag: from" {
+		t.Fatalf("Expected RUN not to accept the from flag, got %v", err)
+	}
+	if bf.Lookup("from") != nil {
+		t.Fatal("Expected RUN to have no from flag")
+	}
+}
@@ -0,0 +1,503 @@
+// Package check validates Dockerfiles without running a build.
+//
+// It only depends on the Dockerfile parser, so the checks can be run without
+// a daemon, for example by the client or in a pre-commit hook. The checks
+// mirror the ones of the instruction dispatchers of the builder, which share
+// the descriptions of the instructions and the parsing of their flags with
+// them, but only report the first error they hit once the build reaches it.
+package check
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/docker/docker/builder/dockerfile/command"
+	"github.com/docker/docker/builder/dockerfile/parser"
+	derr "github.com/docker/docker/errors"
+)
+
+// Error is a problem found in a Dockerfile.
+type Error struct {
+	File    string // included fragment of the instruction, empty for the Dockerfile
+	Line    int    // line of the instruction in its file, 0 for the whole file
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Line == 0 {
+		return e.Message
+	}
+	if e.File != "" {
+		return fmt.Sprintf("%s line %d: %s", e.File, e.Line, e.Message)
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// jsonForm lists the instructions which have an optional JSON form.
+var jsonForm = map[string]bool{
+	command.Add:         true,
+	command.Cmd:         true,
+	command.Copy:        true,
+	command.Entrypoint:  true,
+	command.Healthcheck: true,
+	command.Run:         true,
+	command.Volume:      true,
+}
+
+// replaceEnv lists the instructions whose arguments are subject to the
+// substitution of the variables.
+var replaceEnv = map[string]bool{
+	command.Add:        true,
+	command.Arg:        true,
+	command.Copy:       true,
+	command.Env:        true,
+	command.Expose:     true,
+	command.Label:      true,
+	command.StopSignal: true,
+	command.User:       true,
+	command.Volume:     true,
+	command.Workdir:    true,
+}
+
+// builtinArgs are the build-time variables which can be used without being
+// declared by an ARG instruction.
+var builtinArgs = []string{
+	"HTTP_PROXY", "http_proxy",
+	"HTTPS_PROXY", "https_proxy",
+	"FTP_PROXY", "ftp_proxy",
+	"NO_PROXY", "no_proxy",
+}
+
+// validStageName matches the names of build stages.
+var validStageName = regexp.MustCompile(`^[a-z][a-z0-9_.-]*$`)
+
+// stageEnv is the environment of a build stage, as far as the Dockerfile
+// tells: the environment of an image used as a base isn't known.
+type stageEnv struct {
+	vars  map[string]bool
+	known bool
+}
+
+type checker struct {
+	errs []*Error
+	file string
+	line int
+
+	inStage    bool
+	stageName  string
+	stages     int                 // number of stages started so far
+	stageNames map[string]bool     // names of the stages started so far
+	allStages  map[string]bool     // names of all the stages of the Dockerfile
+	stageEnvs  map[string]stageEnv // environment of the named stages
+	env        stageEnv            // environment of the current stage
+
+	args    map[string]bool // build-time variables declared so far
+	allArgs map[string]bool // build-time variables declared in the Dockerfile
+}
+
+// Dockerfile checks the Dockerfile read from r, without running any of its
+// instructions, and returns all the problems found in the order of the file,
+// followed by the ones of the fragments it includes. target is the build stage
+// to build, if any, and include opens the fragments of the INCLUDE
+// instructions.
+//
+// The environment of the images the Dockerfile builds on isn't known, so the
+// variables of a stage based on an image are only reported as undefined when
+// they are declared by an ARG instruction after their use.
+func Dockerfile(r io.Reader, target string, include parser.IncludeFunc) []*Error {
+	ast, parseErrs := parser.ParseAll(r, include)
+	c := &checker{
+		stageNames: make(map[string]bool),
+		allStages:  make(map[string]bool),
+		stageEnvs:  make(map[string]stageEnv),
+		args:       make(map[string]bool),
+		allArgs:    make(map[string]bool),
+	}
+	for _, e := range parseErrs {
+		c.errs = append(c.errs, &Error{File: e.File, Line: e.Line, Message: e.Err.Error()})
+	}
+	for _, name := range builtinArgs {
+		c.args[name] = true
+	}
+	for _, n := range ast.Children {
+		switch n.Value {
+		case command.From:
+			if _, name, err := ParseFrom(nodeArgs(n)); err == nil && name != "" {
+				c.allStages[name] = true
+			}
+		case command.Arg:
+			if n.Next != nil {
+				c.allArgs[strings.SplitN(n.Next.Value, "=", 2)[0]] = true
+			}
+		}
+	}
+
+	files := map[string]int{"": 0}
+	for _, n := range ast.Children {
+		if _, ok := files[n.File]; !ok {
+			files[n.File] = len(files)
+		}
+		c.file, c.line = n.File, n.StartLine
+		c.instruction(n)
+	}
+
+	c.file, c.line = "", 0
+	if len(ast.Children) == 0 && len(parseErrs) == 0 {
+		c.errorf("The Dockerfile has no instructions")
+	}
+	if target != "" && !c.allStages[strings.ToLower(target)] {
+		c.error(derr.ErrorCodeStageNotFound.WithArgs(target))
+	}
+
+	for _, e := range c.errs {
+		if _, ok := files[e.File]; !ok {
+			files[e.File] = len(files)
+		}
+	}
+	sort.Stable(byPosition{c.errs, files})
+	return c.errs
+}
+
+func (c *checker) errorf(format string, a ...interface{}) {
+	c.errs = append(c.errs, &Error{File: c.file, Line: c.line, Message: fmt.Sprintf(format, a...)})
+}
+
+// error records err, without the code of the builder errors.
+func (c *checker) error(err error) {
+	message := err.Error()
+	switch e := err.(type) {
+	case errcode.ErrorCode:
+		message = e.Message()
+	case errcode.Error:
+		message = e.Message
+	}
+	c.errs = append(c.errs, &Error{File: c.file, Line: c.line, Message: message})
+}
+
+// instruction checks the instruction n.
+func (c *checker) instruction(n *parser.Node) {
+	if _, ok := Instructions[n.Value]; !ok {
+		c.errorf("Unknown instruction: %s", strings.ToUpper(n.Value))
+		return
+	}
+	if n.Value != command.From && !c.inStage {
+		c.error(derr.ErrorCodeMissingFrom)
+	}
+
+	args := nodeArgs(n)
+	upper := strings.ToUpper(n.Value)
+	switch n.Value {
+	case command.From:
+		c.checkFlags(NewFlags(n.Value, n.Flags))
+		c.from(args)
+		return
+	case command.Onbuild:
+		c.checkFlags(NewFlags(n.Value, n.Flags))
+		c.onbuild(n)
+		return
+	case command.Healthcheck:
+		c.healthcheck(n, args)
+		return
+	case command.Shell:
+		if len(args) > 0 && !n.Attributes["json"] {
+			c.error(derr.ErrorCodeNotJSON.WithArgs(upper))
+		}
+	}
+
+	flags := c.checkFlags(NewFlags(n.Value, n.Flags))
+	if err := CheckArgs(n.Value, args); err != nil {
+		c.error(err)
+	}
+
+	if jsonForm[n.Value] && !n.Attributes["json"] {
+		c.checkJSON(strings.Join(args, " "))
+	}
+	if n.Value == command.Volume {
+		for _, v := range args {
+			if strings.TrimSpace(v) == "" {
+				c.error(derr.ErrorCodeVolumeEmpty)
+			}
+		}
+	}
+	if from := flags.Lookup("from"); from != nil && from.IsUsed() {
+		c.copyFrom(from.Value)
+	}
+
+	if replaceEnv[n.Value] {
+		for _, arg := range args {
+			c.checkReferences(arg)
+		}
+	}
+	switch n.Value {
+	case command.Env:
+		for i := 0; i < len(args); i += 2 {
+			c.env.vars[args[i]] = true
+		}
+	case command.Arg:
+		if len(args) == 1 {
+			c.args[strings.SplitN(args[0], "=", 2)[0]] = true
+		}
+	}
+}
+
+// from starts a new build stage.
+func (c *checker) from(args []string) {
+	if c.inStage && c.stageName != "" {
+		c.stageEnvs[c.stageName] = c.env
+	}
+	c.inStage = true
+	c.stages++
+	c.stageName = ""
+	c.env = stageEnv{vars: make(map[string]bool)}
+
+	image, name, err := ParseFrom(args)
+	if err != nil {
+		c.error(err)
+		return
+	}
+	if name != "" {
+		if c.stageNames[name] {
+			c.error(derr.ErrorCodeDuplicateStage.WithArgs(name))
+		}
+		c.stageNames[name] = true
+		c.stageName = name
+	}
+
+	if base, ok := c.stageEnvs[strings.ToLower(image)]; ok {
+		for v := range base.vars {
+			c.env.vars[v] = true
+		}
+		c.env.known = base.known
+	} else {
+		c.env.known = image == "scratch"
+	}
+}
+
+// onbuild checks the trigger of the ONBUILD instruction n.
+func (c *checker) onbuild(n *parser.Node) {
+	if n.Next == nil || len(n.Next.Children) == 0 {
+		c.error(derr.ErrorCodeAtLeastOneArg.WithArgs("ONBUILD"))
+		return
+	}
+	trigger := n.Next.Children[0]
+	switch trigger.Value {
+	case command.Onbuild:
+		c.error(derr.ErrorCodeChainOnBuild)
+	case command.From, command.Maintainer, command.Include:
+		c.error(derr.ErrorCodeBadOnBuildCmd.WithArgs(strings.ToUpper(trigger.Value)))
+	default:
+		if _, ok := Instructions[trigger.Value]; !ok {
+			c.errorf("Unknown instruction: %s", strings.ToUpper(trigger.Value))
+			return
+		}
+		// the trigger runs in the builds using the image, whose variables
+		// aren't known
+		c.instructionIn(trigger, stageEnv{vars: make(map[string]bool)})
+	}
+}
+
+// instructionIn checks the instruction n with the environment env, leaving
+// the state of the current stage untouched.
+func (c *checker) instructionIn(n *parser.Node, env stageEnv) {
+	saved, savedArgs := c.env, c.args
+	c.env, c.args = env, make(map[string]bool)
+	for v := range savedArgs {
+		c.args[v] = true
+	}
+	c.instruction(n)
+	c.env, c.args = saved, savedArgs
+}
+
+// healthcheck checks the HEALTHCHECK instruction n.
+func (c *checker) healthcheck(n *parser.Node, args []string) {
+	if len(args) == 0 {
+		c.error(derr.ErrorCodeAtLeastOneArg.WithArgs("HEALTHCHECK"))
+		return
+	}
+	switch typ := strings.ToUpper(args[0]); typ {
+	case "NONE":
+		// the flags only apply to a health check command
+		flags := NewBFlags()
+		flags.Args = n.Flags
+		c.checkFlags(flags)
+		if len(args) != 1 {
+			c.errorf("HEALTHCHECK NONE takes no arguments")
+		}
+	case "CMD":
+		flags := c.checkFlags(NewFlags(n.Value, n.Flags))
+		if len(args) == 1 {
+			c.errorf("Missing command after HEALTHCHECK CMD")
+		} else if !n.Attributes["json"] {
+			c.checkJSON(strings.Join(args[1:], " "))
+		}
+		for _, name := range []string{"interval", "timeout"} {
+			if v := flags.Lookup(name).Value; v != "" {
+				if d, err := time.ParseDuration(v); err != nil {
+					c.error(err)
+				} else if d <= 0 {
+					c.errorf("Interval %#v must be positive", name)
+				}
+			}
+		}
+		if v := flags.Lookup("retries").Value; v != "" {
+			if retries, err := strconv.ParseInt(v, 10, 32); err != nil {
+				c.error(err)
+			} else if retries < 1 {
+				c.errorf("--retries must be at least 1 (not %d)", retries)
+			}
+		}
+	default:
+		c.checkFlags(NewFlags(n.Value, n.Flags))
+		c.errorf("Unknown type %#v in HEALTHCHECK (try CMD)", typ)
+	}
+}
+
+// checkFlags parses the flags bf of an instruction, and returns them.
+func (c *checker) checkFlags(bf *BFlags) *BFlags {
+	if err := bf.Parse(); err != nil {
+		c.error(err)
+	}
+	return bf
+}
+
+// checkJSON reports the arguments of the shell form of an instruction which
+// look like a JSON array: they are most likely a broken exec form.
+func (c *checker) checkJSON(rest string) {
+	rest = strings.TrimSpace(rest)
+	if !strings.HasPrefix(rest, "[") || !strings.HasSuffix(rest, "]") {
+		return
+	}
+	// a shell test such as [ -f file ] is fine
+	if strings.HasPrefix(rest, `["`) || strings.HasPrefix(rest, `['`) || strings.Contains(rest, ",") {
+		c.errorf(`Error parsing "%s" as a JSON array`, rest)
+	}
+}
+
+// copyFrom checks the value of the --from flag of COPY, which is the name or
+// the index of an earlier stage, or an image.
+func (c *checker) copyFrom(from string) {
+	name := strings.ToLower(from)
+	switch {
+	case c.stageNames[name] && name == c.stageName:
+		c.errorf("COPY --from=%s refers to its own build stage", from)
+	case !c.stageNames[name] && c.allStages[name]:
+		c.errorf("COPY --from=%s refers to a build stage which is defined later", from)
+	case !c.stageNames[name]:
+		if index, err := strconv.Atoi(from); err == nil && (index < 0 || index >= c.stages-1) {
+			c.errorf("invalid from flag value %s: index out of bounds", from)
+		}
+	}
+}
+
+// checkReferences reports the undefined variables word refers to.
+func (c *checker) checkReferences(word string) {
+	for _, name := range references(word) {
+		if c.args[name] || c.env.vars[name] {
+			continue
+		}
+		if c.env.known || c.allArgs[name] {
+			c.errorf("Undefined variable: %s", name)
+		}
+	}
+}
+
+// references returns the names of the variables word refers to, following
+// the quoting rules of the builder. The variables with a default or an
+// alternate value, as in ${name:-value} or ${name:+value}, are left out.
+func references(word string) []string {
+	var (
+		names    []string
+		inSingle bool
+		inDouble bool
+	)
+	for i := 0; i < len(word); i++ {
+		switch ch := word[i]; {
+		case ch == '\\' && !inSingle:
+			i++
+		case ch == '\'' && !inDouble:
+			inSingle = !inSingle
+		case ch == '"' && !inSingle:
+			inDouble = !inDouble
+		case ch == '$' && !inSingle:
+			name, modified, n := readVariable(word[i+1:])
+			i += n
+			if name != "" && !modified {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// readVariable reads the variable at the start of s, which follows a $. It
+// returns its name, whether it has a modifier, and the length read.
+func readVariable(s string) (string, bool, int) {
+	if strings.HasPrefix(s, "{") {
+		end := strings.Index(s, "}")
+		if end < 0 {
+			return "", false, len(s)
+		}
+		inner := s[1:end]
+		name := inner[:nameLength(inner)]
+		rest := inner[len(name):]
+		return name, strings.HasPrefix(rest, ":-") || strings.HasPrefix(rest, ":+"), end + 1
+	}
+	n := nameLength(s)
+	return s[:n], false, n
+}
+
+// nameLength returns the length of the variable name at the start of s.
+func nameLength(s string) int {
+	for i, ch := range s {
+		if !(ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9') {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// ParseFrom returns the image and the optional stage name of the arguments
+// of a FROM instruction.
+func ParseFrom(args []string) (string, string, error) {
+	switch {
+	case len(args) == 1:
+		return args[0], "", nil
+	case len(args) == 3 && strings.EqualFold(args[1], "AS"):
+		stageName := strings.ToLower(args[2])
+		if !validStageName.MatchString(stageName) {
+			return "", "", fmt.Errorf("invalid name for build stage: %q, name can't start with a number or contain symbols", args[2])
+		}
+		return args[0], stageName, nil
+	}
+	return "", "", derr.ErrorCodeBadFrom
+}
+
+// nodeArgs returns the arguments of the instruction n.
+func nodeArgs(n *parser.Node) []string {
+	var args []string
+	for next := n.Next; next != nil; next = next.Next {
+		args = append(args, next.Value)
+	}
+	return args
+}
+
+// byPosition sorts errors by file, in the order of the files, and by line.
+type byPosition struct {
+	errs  []*Error
+	files map[string]int
+}
+
+func (l byPosition) Len() int { return len(l.errs) }
+func (l byPosition) Less(i, j int) bool {
+	if fi, fj := l.files[l.errs[i].File], l.files[l.errs[j].File]; fi != fj {
+		return fi < fj
+	}
+	return l.errs[i].Line < l.errs[j].Line
+}
+func (l byPosition) Swap(i, j int) { l.errs[i], l.errs[j] = l.errs[j], l.errs[i] }
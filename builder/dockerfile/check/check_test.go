@@ -0,0 +1,156 @@
+package check
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestParseFrom(t *testing.T) {
+	valid := []struct {
+		args      []string
+		image     string
+		stageName string
+	}{
+		{[]string{"busybox"}, "busybox", ""},
+		{[]string{"busybox", "AS", "build"}, "busybox", "build"},
+		{[]string{"busybox", "as", "Build-1.0_x"}, "busybox", "build-1.0_x"},
+	}
+	for _, v := range valid {
+		image, stageName, err := ParseFrom(v.args)
+		if err != nil {
+			t.Fatalf("Expected %q to be valid, got %v", v.args, err)
+		}
+		if image != v.image || stageName != v.stageName {
+			t.Fatalf("Expected %q to give %q and %q, got %q and %q", v.args, v.image, v.stageName, image, stageName)
+		}
+	}
+
+	invalid := [][]string{
+		{},
+		{"busybox", "build"},
+		{"busybox", "AS"},
+		{"busybox", "FROM", "build"},
+		{"busybox", "AS", "build", "extra"},
+		{"busybox", "AS", "1build"},
+		{"busybox", "AS", "build:latest"},
+	}
+	for _, args := range invalid {
+		if _, _, err := ParseFrom(args); err == nil {
+			t.Fatalf("Expected %q to be invalid", args)
+		}
+	}
+}
+
+func TestDockerfile(t *testing.T) {
+	valid := []string{
+		"FROM busybox\nRUN echo hello\n",
+		"FROM busybox\nARG version=1.0\nENV VERSION=$version DIR=/opt\nWORKDIR $DIR/${VERSION}\n",
+		"FROM busybox\nRUN [ -f /etc/passwd ]\nCMD [\"sh\"]\n",
+		"FROM busybox\nENV PATH=/opt/bin:$PATH\nLABEL proxy=$http_proxy default=${unset:-none}\n",
+		"FROM busybox AS build\nRUN --secret=token true\nFROM scratch\nCOPY --from=build /bin/sh /sh\nCOPY --from=0 /bin/sh /sh\n",
+		"FROM busybox\nHEALTHCHECK --interval=5s --retries=3 CMD true\nSHELL [\"/bin/bash\", \"-c\"]\n",
+		"FROM busybox\nONBUILD COPY . /src\n",
+	}
+	for _, dockerfile := range valid {
+		if errs := Dockerfile(strings.NewReader(dockerfile), "", nil); len(errs) != 0 {
+			t.Fatalf("Expected %q to be valid, got %v", dockerfile, errs)
+		}
+	}
+
+	invalid := []struct {
+		dockerfile string
+		errs       []string
+	}{
+		{"", []string{"The Dockerfile has no instructions"}},
+		{"RUN true\nFROM busybox", []string{"line 1: Please provide a source image with `from` prior to run"}},
+		{"FROM busybox\nFOO bar\nRUN true\nBAR", []string{"line 2: Unknown instruction: FOO", "line 4: Unknown instruction: BAR"}},
+		{"FROM busybox\nRUN --mount=cache true\nCOPY --from a b\nRUN --secret=a --secret=b true", []string{
+			"line 2: Unknown flag: mount",
+			"line 3: Missing a value on flag: from",
+			"line 4: Duplicate flag specified: secret",
+		}},
+		{"FROM busybox\nWORKDIR\nCOPY a\nEXPOSE", []string{
+			"line 2: WORKDIR requires exactly one argument",
+			"line 3: COPY requires at least two arguments",
+			"line 4: EXPOSE requires at least one argument",
+		}},
+		{"FROM busybox\nCMD ['echo', 'hi']\nSHELL /bin/bash -c\nENV onlyname", []string{
+			`line 2: Error parsing "['echo', 'hi']" as a JSON array`,
+			"line 3: SHELL requires the arguments to be in JSON form",
+			"line 4: ENV must have two arguments",
+		}},
+		{"FROM scratch\nENV A=$undefined\nFROM busybox\nWORKDIR $version\nARG version", []string{
+			"line 2: Undefined variable: undefined",
+			"line 4: Undefined variable: version",
+		}},
+		{"FROM busybox AS a\nFROM busybox AS A\nCOPY --from=b x y\nCOPY --from=5 x y\nFROM busybox AS b", []string{
+			"line 2: duplicate name a for build stages",
+			"line 3: COPY --from=b refers to a build stage which is defined later",
+			"line 4: invalid from flag value 5: index out of bounds",
+		}},
+		{"FROM busybox\nHEALTHCHECK --interval=0s --retries=0 CMD true\nHEALTHCHECK --timeout=1s NONE\nHEALTHCHECK GET /", []string{
+			`line 2: Interval "interval" must be positive`,
+			"line 2: --retries must be at least 1 (not 0)",
+			"line 3: Unknown flag: timeout",
+			`line 4: Unknown type "GET" in HEALTHCHECK (try CMD)`,
+		}},
+		{"FROM busybox\nONBUILD ONBUILD RUN true\nONBUILD FROM busybox\nONBUILD RUN --mount=x true", []string{
+			"line 2: Chaining ONBUILD via `ONBUILD ONBUILD` isn't allowed",
+			"line 3: FROM isn't allowed as an ONBUILD trigger",
+			"line 4: Unknown flag: mount",
+		}},
+	}
+	for _, v := range invalid {
+		errs := Dockerfile(strings.NewReader(v.dockerfile), "", nil)
+		if got := fmt.Sprint(errs); got != fmt.Sprint(v.errs) {
+			t.Fatalf("Expected %q to give %q, got %q", v.dockerfile, v.errs, got)
+		}
+	}
+
+	errs := Dockerfile(strings.NewReader("FROM busybox AS build\n"), "test", nil)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "failed to reach build target test") {
+		t.Fatalf("Expected a missing target error, got %v", errs)
+	}
+}
+
+func TestReferences(t *testing.T) {
+	tests := map[string]string{
+		`$a`:                  "[a]",
+		`${a}/$b_c-$1`:        "[a b_c 1]",
+		`\$a '$b' "$c" $`:     "[c]",
+		`${a:-x} ${b:+y} ${c`: "[]",
+	}
+	for word, expected := range tests {
+		if got := fmt.Sprint(references(word)); got != expected {
+			t.Fatalf("Expected %q to refer to %s, got %s", word, expected, got)
+		}
+	}
+}
+
+func TestDockerfileIncludes(t *testing.T) {
+	fragments := map[string]string{
+		"setup": "ARG version\nRUN --mount=x true\nFOO bar\n",
+	}
+	include := func(path string) (io.ReadCloser, error) {
+		fragment, ok := fragments[path]
+		if !ok {
+			return nil, fmt.Errorf("no such fragment: %s", path)
+		}
+		return ioutil.NopCloser(strings.NewReader(fragment)), nil
+	}
+
+	dockerfile := "FROM busybox\nINCLUDE setup\nWORKDIR $version\nINCLUDE missing\nONBUILD INCLUDE setup\n"
+	expected := []string{
+		"line 4: no such fragment: missing",
+		"line 5: INCLUDE isn't allowed as an ONBUILD trigger",
+		"setup line 2: Unknown flag: mount",
+		"setup line 3: Unknown instruction: FOO",
+	}
+	errs := Dockerfile(strings.NewReader(dockerfile), "", include)
+	if got := fmt.Sprint(errs); got != fmt.Sprint(expected) {
+		t.Fatalf("Expected %q, got %q", expected, got)
+	}
+}
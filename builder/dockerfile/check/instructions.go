@@ -0,0 +1,66 @@
+package check
+
+import (
+	"strings"
+
+	"github.com/docker/docker/builder/dockerfile/command"
+	derr "github.com/docker/docker/errors"
+)
+
+// Instruction describes the arguments and the flags accepted by a Dockerfile
+// instruction. The dispatchers of the builder use the same descriptions as
+// the checks, so that both accept the same instructions.
+type Instruction struct {
+	MinArgs int      // minimum number of arguments
+	MaxArgs int      // maximum number of arguments, unlimited if negative
+	Flags   []string // names of the flags, which all take a value
+}
+
+// Instructions are the Dockerfile instructions, by name.
+var Instructions = map[string]Instruction{
+	command.Add:         {MinArgs: 2, MaxArgs: -1},
+	command.Arg:         {MinArgs: 1, MaxArgs: 1},
+	command.Cmd:         {MaxArgs: -1},
+	command.Copy:        {MinArgs: 2, MaxArgs: -1, Flags: []string{"from"}},
+	command.Entrypoint:  {MaxArgs: -1},
+	command.Env:         {MinArgs: 1, MaxArgs: -1},
+	command.Expose:      {MinArgs: 1, MaxArgs: -1},
+	command.From:        {MinArgs: 1, MaxArgs: -1},
+	command.Healthcheck: {MinArgs: 1, MaxArgs: -1, Flags: []string{"interval", "timeout", "retries"}},
+	command.Label:       {MinArgs: 1, MaxArgs: -1},
+	command.Maintainer:  {MinArgs: 1, MaxArgs: 1},
+	command.Onbuild:     {MinArgs: 1, MaxArgs: -1},
+	command.Run:         {MaxArgs: -1, Flags: []string{"secret"}},
+	command.Shell:       {MinArgs: 1, MaxArgs: -1},
+	command.StopSignal:  {MinArgs: 1, MaxArgs: 1},
+	command.User:        {MinArgs: 1, MaxArgs: 1},
+	command.Volume:      {MinArgs: 1, MaxArgs: -1},
+	command.Workdir:     {MinArgs: 1, MaxArgs: 1},
+}
+
+// CheckArgs returns the error of the instruction name given a wrong number
+// of arguments args, nil if it accepts them.
+func CheckArgs(name string, args []string) error {
+	spec := Instructions[name]
+	upper := strings.ToUpper(name)
+	switch {
+	case spec.MaxArgs == 1 && len(args) != 1:
+		return derr.ErrorCodeExactlyOneArg.WithArgs(upper)
+	case len(args) < spec.MinArgs && spec.MinArgs == 1:
+		return derr.ErrorCodeAtLeastOneArg.WithArgs(upper)
+	case len(args) < spec.MinArgs:
+		return derr.ErrorCodeAtLeastTwoArgs.WithArgs(upper)
+	}
+	return nil
+}
+
+// NewFlags returns the flags of the instruction name, to be parsed from
+// args.
+func NewFlags(name string, args []string) *BFlags {
+	bf := NewBFlags()
+	bf.Args = args
+	for _, flag := range Instructions[name].Flags {
+		bf.AddString(flag, "")
+	}
+	return bf
+}
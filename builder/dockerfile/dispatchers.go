@@ -15,11 +15,14 @@ import (
 	"regexp"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/builder/dockerfile/check"
+	"github.com/docker/docker/builder/dockerfile/command"
 	derr "github.com/docker/docker/errors"
-	"github.com/docker/docker/image"
 	flag "github.com/docker/docker/pkg/mflag"
 	"github.com/docker/docker/pkg/nat"
 	"github.com/docker/docker/pkg/signal"
@@ -45,8 +48,8 @@ func nullDispatch(b *Builder, args []string, attributes map[string]bool, origina
 // in the dockerfile available from the next statement on via ${foo}.
 //
 func env(b *Builder, args []string, attributes map[string]bool, original string) error {
-	if len(args) == 0 {
-		return derr.ErrorCodeAtLeastOneArg.WithArgs("ENV")
+	if err := check.CheckArgs(command.Env, args); err != nil {
+		return err
 	}
 
 	if len(args)%2 != 0 {
@@ -104,8 +107,8 @@ func env(b *Builder, args []string, attributes map[string]bool, original string)
 //
 // Sets the maintainer metadata.
 func maintainer(b *Builder, args []string, attributes map[string]bool, original string) error {
-	if len(args) != 1 {
-		return derr.ErrorCodeExactlyOneArg.WithArgs("MAINTAINER")
+	if err := check.CheckArgs(command.Maintainer, args); err != nil {
+		return err
 	}
 
 	if err := b.flags.Parse(); err != nil {
@@ -121,8 +124,8 @@ func maintainer(b *Builder, args []string, attributes map[string]bool, original
 // Sets the Label variable foo to bar,
 //
 func label(b *Builder, args []string, attributes map[string]bool, original string) error {
-	if len(args) == 0 {
-		return derr.ErrorCodeAtLeastOneArg.WithArgs("LABEL")
+	if err := check.CheckArgs(command.Label, args); err != nil {
+		return err
 	}
 	if len(args)%2 != 0 {
 		// should never get here, but just in case
@@ -157,15 +160,15 @@ func label(b *Builder, args []string, attributes map[string]bool, original strin
 // exist here. If you do not wish to have this automatic handling, use COPY.
 //
 func add(b *Builder, args []string, attributes map[string]bool, original string) error {
-	if len(args) < 2 {
-		return derr.ErrorCodeAtLeastTwoArgs.WithArgs("ADD")
+	if err := check.CheckArgs(command.Add, args); err != nil {
+		return err
 	}
 
 	if err := b.flags.Parse(); err != nil {
 		return err
 	}
 
-	return b.runContextCommand(args, true, true, "ADD")
+	return b.runContextCommand(args, true, true, "ADD", nil)
 }
 
 // COPY foo /path
@@ -173,31 +176,49 @@ func add(b *Builder, args []string, attributes map[string]bool, original string)
 // Same as 'ADD' but without the tar and remote url handling.
 //
 func dispatchCopy(b *Builder, args []string, attributes map[string]bool, original string) error {
-	if len(args) < 2 {
-		return derr.ErrorCodeAtLeastTwoArgs.WithArgs("COPY")
+	if err := check.CheckArgs(command.Copy, args); err != nil {
+		return err
 	}
 
+	flFrom := b.flags.Lookup("from")
+
 	if err := b.flags.Parse(); err != nil {
 		return err
 	}
 
-	return b.runContextCommand(args, false, false, "COPY")
+	if flFrom.Value == "" {
+		return b.runContextCommand(args, false, false, "COPY", nil)
+	}
+
+	imageID, err := b.copySourceImage(flFrom.Value)
+	if err != nil {
+		return err
+	}
+	source, err := b.mountCopySource(imageID)
+	if err != nil {
+		return err
+	}
+	defer b.releaseCopySource(source)
+
+	return b.runContextCommand(args, false, false, "COPY", source)
 }
 
-// FROM imagename
+// FROM imagename [AS name]
 //
-// This sets the image the dockerfile will build on top of.
+// This sets the image the dockerfile will build on top of, and starts a new
+// build stage. The stage can be named so later stages can refer to it.
 //
 func from(b *Builder, args []string, attributes map[string]bool, original string) error {
-	if len(args) != 1 {
-		return derr.ErrorCodeExactlyOneArg.WithArgs("FROM")
+	name, stageName, err := check.ParseFrom(args)
+	if err != nil {
+		return err
 	}
 
 	if err := b.flags.Parse(); err != nil {
 		return err
 	}
 
-	name := args[0]
+	b.startStage(stageName)
 
 	// Windows cannot support a container with no base image.
 	if name == NoBaseImageSpecifier {
@@ -209,21 +230,21 @@ func from(b *Builder, args []string, attributes map[string]bool, original string
 		return nil
 	}
 
-	var (
-		image *image.Image
-		err   error
-	)
-	// TODO: don't use `name`, instead resolve it to a digest
-	if !b.Pull {
-		image, err = b.docker.LookupImage(name)
-		// TODO: shouldn't we error out if error is different from "not found" ?
-	}
-	if image == nil {
-		image, err = b.docker.Pull(name)
+	// an earlier stage of the Dockerfile
+	if imageID, ok := b.stageNames[strings.ToLower(name)]; ok {
+		image, err := b.docker.LookupImage(imageID)
 		if err != nil {
 			return err
 		}
+		b.stageBase = image.ID
+		return b.processImageFrom(image)
 	}
+
+	image, err := b.pullOrLookupImage(name)
+	if err != nil {
+		return err
+	}
+	b.stageBase = image.ID
 	return b.processImageFrom(image)
 }
 
@@ -237,8 +258,8 @@ func from(b *Builder, args []string, attributes map[string]bool, original string
 // cases.
 //
 func onbuild(b *Builder, args []string, attributes map[string]bool, original string) error {
-	if len(args) == 0 {
-		return derr.ErrorCodeAtLeastOneArg.WithArgs("ONBUILD")
+	if err := check.CheckArgs(command.Onbuild, args); err != nil {
+		return err
 	}
 
 	if err := b.flags.Parse(); err != nil {
@@ -249,7 +270,7 @@ func onbuild(b *Builder, args []string, attributes map[string]bool, original str
 	switch triggerInstruction {
 	case "ONBUILD":
 		return derr.ErrorCodeChainOnBuild
-	case "MAINTAINER", "FROM":
+	case "MAINTAINER", "FROM", "INCLUDE":
 		return derr.ErrorCodeBadOnBuildCmd.WithArgs(triggerInstruction)
 	}
 
@@ -264,8 +285,8 @@ func onbuild(b *Builder, args []string, attributes map[string]bool, original str
 // Set the working directory for future RUN/CMD/etc statements.
 //
 func workdir(b *Builder, args []string, attributes map[string]bool, original string) error {
-	if len(args) != 1 {
-		return derr.ErrorCodeExactlyOneArg.WithArgs("WORKDIR")
+	if err := check.CheckArgs(command.Workdir, args); err != nil {
+		return err
 	}
 
 	if err := b.flags.Parse(); err != nil {
@@ -289,8 +310,8 @@ func workdir(b *Builder, args []string, attributes map[string]bool, original str
 // RUN some command yo
 //
 // run a command and commit the image. Args are automatically prepended with
-// 'sh -c' under linux or 'cmd /S /C' under Windows, in the event there is
-// only one argument. The difference in processing:
+// the shell set by SHELL, or 'sh -c' under linux or 'cmd /S /C' under Windows,
+// in the event there is only one argument. The difference in processing:
 //
 // RUN echo hi          # sh -c echo hi       (Linux)
 // RUN echo hi          # cmd /S /C echo hi   (Windows)
@@ -301,18 +322,21 @@ func run(b *Builder, args []string, attributes map[string]bool, original string)
 		return derr.ErrorCodeMissingFrom
 	}
 
+	flSecret := b.flags.Lookup("secret")
 	if err := b.flags.Parse(); err != nil {
 		return err
 	}
+	// the build secrets are neither part of the command nor of the config,
+	// so they aren't committed and don't change the cache lookup
+	secrets, err := b.requestedSecrets(flSecret.Value)
+	if err != nil {
+		return err
+	}
 
 	args = handleJSONArgs(args, attributes)
 
 	if !attributes["json"] {
-		if runtime.GOOS != "windows" {
-			args = append([]string{"/bin/sh", "-c"}, args...)
-		} else {
-			args = append([]string{"cmd", "/S", "/C"}, args...)
-		}
+		args = append(getShell(b.runConfig), args...)
 	}
 
 	runCmd := flag.NewFlagSet("run", flag.ContinueOnError)
@@ -394,7 +418,17 @@ func run(b *Builder, args []string, attributes map[string]bool, original string)
 
 	logrus.Debugf("[BUILDER] Command to be executed: %v", b.runConfig.Cmd)
 
-	c, err := b.create()
+	var binds []string
+	if len(secrets) > 0 {
+		bind, release, err := b.secretsBind(secrets)
+		if err != nil {
+			return err
+		}
+		defer release()
+		binds = append(binds, bind)
+	}
+
+	c, err := b.create(binds...)
 	if err != nil {
 		return err
 	}
@@ -404,10 +438,18 @@ func run(b *Builder, args []string, attributes map[string]bool, original string)
 	b.docker.Mount(c)
 	defer b.docker.Unmount(c)
 
+	var mountpoint []string
+	if len(secrets) > 0 {
+		if mountpoint, err = missingMountpoint(c, secretsMountPath); err != nil {
+			return err
+		}
+	}
+
 	err = b.run(c)
 	if err != nil {
 		return err
 	}
+	removeMountpoint(mountpoint)
 
 	// revert to original config environment and set the command string to
 	// have the build-time env vars in it (if any) so that future cache look-ups
@@ -434,11 +476,7 @@ func cmd(b *Builder, args []string, attributes map[string]bool, original string)
 	cmdSlice := handleJSONArgs(args, attributes)
 
 	if !attributes["json"] {
-		if runtime.GOOS != "windows" {
-			cmdSlice = append([]string{"/bin/sh", "-c"}, cmdSlice...)
-		} else {
-			cmdSlice = append([]string{"cmd", "/S", "/C"}, cmdSlice...)
-		}
+		cmdSlice = append(getShell(b.runConfig), cmdSlice...)
 	}
 
 	b.runConfig.Cmd = stringutils.NewStrSlice(cmdSlice...)
@@ -456,8 +494,8 @@ func cmd(b *Builder, args []string, attributes map[string]bool, original string)
 
 // ENTRYPOINT /usr/sbin/nginx
 //
-// Set the entrypoint (which defaults to sh -c on linux, or cmd /S /C on Windows) to
-// /usr/sbin/nginx. Will accept the CMD as the arguments to /usr/sbin/nginx.
+// Set the entrypoint (which defaults to the shell set by SHELL, or sh -c on linux,
+// or cmd /S /C on Windows) to /usr/sbin/nginx. Will accept the CMD as the arguments to /usr/sbin/nginx.
 //
 // Handles command processing similar to CMD and RUN, only b.runConfig.Entrypoint
 // is initialized at NewBuilder time instead of through argument parsing.
@@ -478,11 +516,7 @@ func entrypoint(b *Builder, args []string, attributes map[string]bool, original
 		b.runConfig.Entrypoint = nil
 	default:
 		// ENTRYPOINT echo hi
-		if runtime.GOOS != "windows" {
-			b.runConfig.Entrypoint = stringutils.NewStrSlice("/bin/sh", "-c", parsed[0])
-		} else {
-			b.runConfig.Entrypoint = stringutils.NewStrSlice("cmd", "/S", "/C", parsed[0])
-		}
+		b.runConfig.Entrypoint = stringutils.NewStrSlice(append(getShell(b.runConfig), parsed[0])...)
 	}
 
 	// when setting the entrypoint if a CMD was not explicitly set then
@@ -506,8 +540,8 @@ func entrypoint(b *Builder, args []string, attributes map[string]bool, original
 func expose(b *Builder, args []string, attributes map[string]bool, original string) error {
 	portsTab := args
 
-	if len(args) == 0 {
-		return derr.ErrorCodeAtLeastOneArg.WithArgs("EXPOSE")
+	if err := check.CheckArgs(command.Expose, args); err != nil {
+		return err
 	}
 
 	if err := b.flags.Parse(); err != nil {
@@ -545,8 +579,8 @@ func expose(b *Builder, args []string, attributes map[string]bool, original stri
 // ENTRYPOINT/CMD at container run time.
 //
 func user(b *Builder, args []string, attributes map[string]bool, original string) error {
-	if len(args) != 1 {
-		return derr.ErrorCodeExactlyOneArg.WithArgs("USER")
+	if err := check.CheckArgs(command.User, args); err != nil {
+		return err
 	}
 
 	if err := b.flags.Parse(); err != nil {
@@ -562,8 +596,8 @@ func user(b *Builder, args []string, attributes map[string]bool, original string
 // Expose the volume /foo for use. Will also accept the JSON array form.
 //
 func volume(b *Builder, args []string, attributes map[string]bool, original string) error {
-	if len(args) == 0 {
-		return derr.ErrorCodeAtLeastOneArg.WithArgs("VOLUME")
+	if err := check.CheckArgs(command.Volume, args); err != nil {
+		return err
 	}
 
 	if err := b.flags.Parse(); err != nil {
@@ -590,8 +624,12 @@ func volume(b *Builder, args []string, attributes map[string]bool, original stri
 //
 // Set the signal that will be used to kill the container.
 func stopSignal(b *Builder, args []string, attributes map[string]bool, original string) error {
-	if len(args) != 1 {
-		return fmt.Errorf("STOPSIGNAL requires exactly one argument")
+	if err := check.CheckArgs(command.StopSignal, args); err != nil {
+		return err
+	}
+
+	if err := b.flags.Parse(); err != nil {
+		return err
 	}
 
 	sig := args[0]
@@ -604,6 +642,111 @@ func stopSignal(b *Builder, args []string, attributes map[string]bool, original
 	return b.commit("", b.runConfig.Cmd, fmt.Sprintf("STOPSIGNAL %v", args))
 }
 
+// HEALTHCHECK foo
+//
+// Set the default health check command to run in the container.
+// HEALTHCHECK NONE disables any health check inherited from the base image.
+// Argument handling of HEALTHCHECK CMD is the same as CMD.
+//
+func healthcheck(b *Builder, args []string, attributes map[string]bool, original string) error {
+	if err := check.CheckArgs(command.Healthcheck, args); err != nil {
+		return err
+	}
+	typ := strings.ToUpper(args[0])
+	args = args[1:]
+	if typ == "NONE" {
+		if len(args) != 0 {
+			return fmt.Errorf("HEALTHCHECK NONE takes no arguments")
+		}
+		// the flags only apply to a health check command
+		flags := check.NewBFlags()
+		flags.Args = b.flags.Args
+		if err := flags.Parse(); err != nil {
+			return err
+		}
+		b.runConfig.Healthcheck = &runconfig.HealthConfig{
+			Test: []string{typ},
+		}
+	} else {
+		if b.runConfig.Healthcheck != nil {
+			oldCmd := b.runConfig.Healthcheck.Test
+			if len(oldCmd) > 0 && oldCmd[0] != "NONE" {
+				fmt.Fprintf(b.Stdout, "Note: overriding previous HEALTHCHECK: %v\n", oldCmd)
+			}
+		}
+
+		healthcheck := runconfig.HealthConfig{}
+
+		flInterval := b.flags.Lookup("interval")
+		flTimeout := b.flags.Lookup("timeout")
+		flRetries := b.flags.Lookup("retries")
+
+		if err := b.flags.Parse(); err != nil {
+			return err
+		}
+
+		switch typ {
+		case "CMD":
+			cmdSlice := handleJSONArgs(args, attributes)
+			if len(cmdSlice) == 0 {
+				return fmt.Errorf("Missing command after HEALTHCHECK CMD")
+			}
+
+			if !attributes["json"] {
+				typ = "CMD-SHELL"
+			}
+
+			healthcheck.Test = append([]string{typ}, cmdSlice...)
+		default:
+			return fmt.Errorf("Unknown type %#v in HEALTHCHECK (try CMD)", typ)
+		}
+
+		interval, err := parseOptInterval(flInterval)
+		if err != nil {
+			return err
+		}
+		healthcheck.Interval = interval
+
+		timeout, err := parseOptInterval(flTimeout)
+		if err != nil {
+			return err
+		}
+		healthcheck.Timeout = timeout
+
+		if flRetries.Value != "" {
+			retries, err := strconv.ParseInt(flRetries.Value, 10, 32)
+			if err != nil {
+				return err
+			}
+			if retries < 1 {
+				return fmt.Errorf("--retries must be at least 1 (not %d)", retries)
+			}
+			healthcheck.Retries = int(retries)
+		}
+
+		b.runConfig.Healthcheck = &healthcheck
+	}
+
+	return b.commit("", b.runConfig.Cmd, fmt.Sprintf("HEALTHCHECK %q", b.runConfig.Healthcheck.Test))
+}
+
+// parseOptInterval parses a duration flag of HEALTHCHECK. An unset flag
+// returns zero, so that the default of the daemon is used.
+func parseOptInterval(f *check.Flag) (time.Duration, error) {
+	s := f.Value
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("Interval %#v must be positive", f.Name())
+	}
+	return d, nil
+}
+
 // ARG name[=value]
 //
 // Adds the variable foo to the trusted list of variables that can be passed
@@ -647,3 +790,37 @@ func arg(b *Builder, args []string, attributes map[string]bool, original string)
 
 	return b.commit("", b.runConfig.Cmd, fmt.Sprintf("ARG %s", arg))
 }
+
+// SHELL ["/bin/bash", "-c"]
+//
+// Set the shell the shell form of RUN, CMD and ENTRYPOINT is run with. It is
+// stored in the image and inherited by the child images.
+//
+func shell(b *Builder, args []string, attributes map[string]bool, original string) error {
+	if err := b.flags.Parse(); err != nil {
+		return err
+	}
+
+	shellSlice := handleJSONArgs(args, attributes)
+	switch {
+	case len(shellSlice) == 0:
+		// SHELL []
+		return derr.ErrorCodeAtLeastOneArg.WithArgs("SHELL")
+	case attributes["json"]:
+		// SHELL ["powershell", "-command"]
+		b.runConfig.Shell = stringutils.NewStrSlice(shellSlice...)
+	default:
+		// SHELL powershell -command - not JSON
+		return derr.ErrorCodeNotJSON.WithArgs("SHELL")
+	}
+	return b.commit("", b.runConfig.Cmd, fmt.Sprintf("SHELL %v", shellSlice))
+}
+
+// getShell returns the shell of the shell form of RUN, CMD and ENTRYPOINT,
+// set by SHELL in the image or the default one of the platform.
+func getShell(c *runconfig.Config) []string {
+	if c.Shell.Len() > 0 {
+		return append([]string{}, c.Shell.Slice()...)
+	}
+	return append([]string{}, defaultShell...)
+}
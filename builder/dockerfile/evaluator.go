@@ -24,6 +24,7 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/docker/docker/builder/dockerfile/check"
 	"github.com/docker/docker/builder/dockerfile/command"
 	"github.com/docker/docker/builder/dockerfile/parser"
 )
@@ -44,10 +45,14 @@ var replaceEnvAllowed = map[string]bool{
 
 // Certain commands are allowed to have their args split into more
 // words after env var replacements. Meaning:
-//   ENV foo="123 456"
-//   EXPOSE $foo
+//
+//	ENV foo="123 456"
+//	EXPOSE $foo
+//
 // should result in the same thing as:
-//   EXPOSE 123 456
+//
+//	EXPOSE 123 456
+//
 // and not treat "123 456" as a single word.
 // Note that: EXPOSE "$foo" and EXPOSE $foo are not the same thing.
 // Quotes will cause it to still be treated as single word.
@@ -59,22 +64,24 @@ var evaluateTable map[string]func(*Builder, []string, map[string]bool, string) e
 
 func init() {
 	evaluateTable = map[string]func(*Builder, []string, map[string]bool, string) error{
-		command.Env:        env,
-		command.Label:      label,
-		command.Maintainer: maintainer,
-		command.Add:        add,
-		command.Copy:       dispatchCopy, // copy() is a go builtin
-		command.From:       from,
-		command.Onbuild:    onbuild,
-		command.Workdir:    workdir,
-		command.Run:        run,
-		command.Cmd:        cmd,
-		command.Entrypoint: entrypoint,
-		command.Expose:     expose,
-		command.Volume:     volume,
-		command.User:       user,
-		command.StopSignal: stopSignal,
-		command.Arg:        arg,
+		command.Env:         env,
+		command.Label:       label,
+		command.Maintainer:  maintainer,
+		command.Add:         add,
+		command.Copy:        dispatchCopy, // copy() is a go builtin
+		command.From:        from,
+		command.Onbuild:     onbuild,
+		command.Workdir:     workdir,
+		command.Run:         run,
+		command.Cmd:         cmd,
+		command.Entrypoint:  entrypoint,
+		command.Expose:      expose,
+		command.Volume:      volume,
+		command.User:        user,
+		command.StopSignal:  stopSignal,
+		command.Shell:       shell,
+		command.Arg:         arg,
+		command.Healthcheck: healthcheck,
 	}
 }
 
@@ -193,8 +200,7 @@ func (b *Builder) dispatch(stepN int, ast *parser.Node) error {
 	// XXX yes, we skip any cmds that are not valid; the parser should have
 	// picked these out already.
 	if f, ok := evaluateTable[cmd]; ok {
-		b.flags = NewBFlags()
-		b.flags.Args = flags
+		b.flags = check.NewFlags(cmd, flags)
 		return f(b, strList, attrs, original)
 	}
 
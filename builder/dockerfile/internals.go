@@ -15,14 +15,18 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/api"
 	"github.com/docker/docker/builder"
+	"github.com/docker/docker/builder/dockerfile/check"
+	"github.com/docker/docker/builder/dockerfile/command"
 	"github.com/docker/docker/builder/dockerfile/parser"
 	"github.com/docker/docker/daemon"
+	derr "github.com/docker/docker/errors"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/httputils"
@@ -38,6 +42,13 @@ import (
 	"github.com/docker/docker/runconfig"
 )
 
+// nopCommand returns the command recorded in the images of the build for
+// the instructions which run nothing, with the shell of config so that the
+// build cache tells apart the images built with different shells.
+func nopCommand(config *runconfig.Config, comment string) *stringutils.StrSlice {
+	return stringutils.NewStrSlice(append(getShell(config), nopPrefix+comment)...)
+}
+
 func (b *Builder) commit(id string, autoCmd *stringutils.StrSlice, comment string) error {
 	if b.disableCommit {
 		return nil
@@ -48,11 +59,7 @@ func (b *Builder) commit(id string, autoCmd *stringutils.StrSlice, comment strin
 	b.runConfig.Image = b.image
 	if id == "" {
 		cmd := b.runConfig.Cmd
-		if runtime.GOOS != "windows" {
-			b.runConfig.Cmd = stringutils.NewStrSlice("/bin/sh", "-c", "#(nop) "+comment)
-		} else {
-			b.runConfig.Cmd = stringutils.NewStrSlice("cmd", "/S /C", "REM (nop) "+comment)
-		}
+		b.runConfig.Cmd = nopCommand(b.runConfig, comment)
 		defer func(cmd *stringutils.StrSlice) { b.runConfig.Cmd = cmd }(cmd)
 
 		if hit, err := b.probeCache(); err != nil {
@@ -77,9 +84,11 @@ func (b *Builder) commit(id string, autoCmd *stringutils.StrSlice, comment strin
 	autoConfig.Cmd = autoCmd
 
 	commitCfg := &daemon.ContainerCommitConfig{
-		Author: b.maintainer,
-		Pause:  true,
-		Config: &autoConfig,
+		Author:       b.maintainer,
+		Pause:        true,
+		Config:       &autoConfig,
+		Reproducible: b.Reproducible,
+		Created:      b.Epoch,
 	}
 
 	// Commit the container
@@ -98,8 +107,22 @@ type copyInfo struct {
 	decompress bool
 }
 
-func (b *Builder) runContextCommand(args []string, allowRemote bool, allowLocalDecompression bool, cmdName string) error {
-	if b.context == nil {
+// copySource is the root filesystem of an image, mounted through a temporary
+// container, which COPY --from copies files from instead of the build context.
+type copySource struct {
+	imageID   string
+	container *daemon.Container
+	context   builder.Context
+}
+
+// runContextCommand copies files from the build context, or from source if it
+// is not nil, into a new layer.
+func (b *Builder) runContextCommand(args []string, allowRemote bool, allowLocalDecompression bool, cmdName string, source *copySource) error {
+	context := b.context
+	if source != nil {
+		context = source.context
+	}
+	if context == nil {
 		return fmt.Errorf("No context given. Impossible to use %s", cmdName)
 	}
 
@@ -135,7 +158,7 @@ func (b *Builder) runContextCommand(args []string, allowRemote bool, allowLocalD
 			continue
 		}
 		// not a URL
-		subInfos, err := b.calcCopyInfo(cmdName, orig, allowLocalDecompression, true)
+		subInfos, err := b.calcCopyInfo(context, cmdName, orig, allowLocalDecompression, true)
 		if err != nil {
 			return err
 		}
@@ -177,12 +200,13 @@ func (b *Builder) runContextCommand(args []string, allowRemote bool, allowLocalD
 		origPaths = strings.Join(origs, " ")
 	}
 
-	cmd := b.runConfig.Cmd
-	if runtime.GOOS != "windows" {
-		b.runConfig.Cmd = stringutils.NewStrSlice("/bin/sh", "-c", fmt.Sprintf("#(nop) %s %s in %s", cmdName, srcHash, dest))
-	} else {
-		b.runConfig.Cmd = stringutils.NewStrSlice("cmd", "/S", "/C", fmt.Sprintf("REM (nop) %s %s in %s", cmdName, srcHash, dest))
+	// files copied from an image are hashed by path, the image tells them apart
+	if source != nil {
+		srcHash = "from:" + source.imageID + ":" + srcHash
 	}
+
+	cmd := b.runConfig.Cmd
+	b.runConfig.Cmd = nopCommand(b.runConfig, fmt.Sprintf("%s %s in %s", cmdName, srcHash, dest))
 	defer func(cmd *stringutils.StrSlice) { b.runConfig.Cmd = cmd }(cmd)
 
 	if hit, err := b.probeCache(); err != nil {
@@ -319,7 +343,7 @@ func (b *Builder) download(srcURL string) (fi builder.FileInfo, err error) {
 	return &builder.HashedFileInfo{FileInfo: builder.PathFileInfo{FileInfo: tmpFileSt, FilePath: tmpFileName}, FileHash: hash}, nil
 }
 
-func (b *Builder) calcCopyInfo(cmdName, origPath string, allowLocalDecompression, allowWildcards bool) ([]copyInfo, error) {
+func (b *Builder) calcCopyInfo(context builder.Context, cmdName, origPath string, allowLocalDecompression, allowWildcards bool) ([]copyInfo, error) {
 
 	// Work in daemon-specific OS filepath semantics
 	origPath = filepath.FromSlash(origPath)
@@ -332,7 +356,7 @@ func (b *Builder) calcCopyInfo(cmdName, origPath string, allowLocalDecompression
 	// Deal with wildcards
 	if allowWildcards && containsWildcards(origPath) {
 		var copyInfos []copyInfo
-		if err := b.context.Walk("", func(path string, info builder.FileInfo, err error) error {
+		if err := context.Walk("", func(path string, info builder.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
@@ -346,7 +370,7 @@ func (b *Builder) calcCopyInfo(cmdName, origPath string, allowLocalDecompression
 
 			// Note we set allowWildcards to false in case the name has
 			// a * in it
-			subInfos, err := b.calcCopyInfo(cmdName, path, allowLocalDecompression, false)
+			subInfos, err := b.calcCopyInfo(context, cmdName, path, allowLocalDecompression, false)
 			if err != nil {
 				return err
 			}
@@ -360,7 +384,7 @@ func (b *Builder) calcCopyInfo(cmdName, origPath string, allowLocalDecompression
 
 	// Must be a dir or a file
 
-	statPath, fi, err := b.context.Stat(origPath)
+	statPath, fi, err := context.Stat(origPath)
 	if err != nil {
 		return nil, err
 	}
@@ -379,7 +403,7 @@ func (b *Builder) calcCopyInfo(cmdName, origPath string, allowLocalDecompression
 	}
 	// Must be a dir
 	var subfiles []string
-	err = b.context.Walk(statPath, func(path string, info builder.FileInfo, err error) error {
+	err = context.Walk(statPath, func(path string, info builder.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -460,6 +484,161 @@ func (b *Builder) processImageFrom(img *image.Image) error {
 	return nil
 }
 
+// checkStages validates the names of the build stages of the Dockerfile and
+// the build target before anything is built.
+func (b *Builder) checkStages() error {
+	b.Target = strings.ToLower(b.Target)
+	names := make(map[string]bool)
+	for _, n := range b.dockerfile.Children {
+		if n.Value != command.From {
+			continue
+		}
+		var args []string
+		for next := n.Next; next != nil; next = next.Next {
+			args = append(args, next.Value)
+		}
+		_, stageName, err := check.ParseFrom(args)
+		if err != nil {
+			return err
+		}
+		if stageName == "" {
+			continue
+		}
+		if names[stageName] {
+			return derr.ErrorCodeDuplicateStage.WithArgs(stageName)
+		}
+		names[stageName] = true
+	}
+	if b.Target != "" && !names[b.Target] {
+		return derr.ErrorCodeStageNotFound.WithArgs(b.Target)
+	}
+	return nil
+}
+
+// declareArgs marks the ARG instructions of nodes as declared. It is used
+// for the stages following the build target, which are not built, so that
+// their build args are not reported as unconsumed.
+func (b *Builder) declareArgs(nodes []*parser.Node) {
+	for _, n := range nodes {
+		if n.Value == command.Arg && n.Next != nil {
+			b.allowedBuildArgs[strings.SplitN(n.Next.Value, "=", 2)[0]] = true
+		}
+	}
+}
+
+// startStage records the image of the current build stage, if any, and resets
+// the state of the builder for the stage named name.
+func (b *Builder) startStage(name string) {
+	if b.image != "" || b.noBaseImage {
+		b.stageImages = append(b.stageImages, b.image)
+		if b.stageName != "" {
+			b.stageNames[b.stageName] = b.image
+		}
+	}
+
+	b.stageName = name
+	b.stageBase = ""
+	b.image = ""
+	b.noBaseImage = false
+	b.maintainer = ""
+	b.cmdSet = false
+	b.cacheBusted = false
+	b.runConfig = new(runconfig.Config)
+}
+
+// pullOrLookupImage returns the image name, pulling it if it isn't found
+// locally or if the build always pulls.
+func (b *Builder) pullOrLookupImage(name string) (*image.Image, error) {
+	var (
+		img *image.Image
+		err error
+	)
+	// TODO: don't use `name`, instead resolve it to a digest
+	if !b.Pull {
+		img, err = b.docker.LookupImage(name)
+		// TODO: shouldn't we error out if error is different from "not found" ?
+	}
+	if img == nil {
+		img, err = b.docker.Pull(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return img, nil
+}
+
+// copySourceImage resolves the value of COPY --from, the name or the index of
+// an earlier build stage, or an image, to an image ID.
+func (b *Builder) copySourceImage(from string) (string, error) {
+	imageID, ok := b.stageNames[strings.ToLower(from)]
+	if !ok {
+		if index, err := strconv.Atoi(from); err == nil {
+			if index < 0 || index >= len(b.stageImages) {
+				return "", fmt.Errorf("invalid from flag value %s: index out of bounds", from)
+			}
+			imageID, ok = b.stageImages[index], true
+		}
+	}
+	if ok {
+		if imageID == "" {
+			return "", fmt.Errorf("build stage %s has no image to copy from", from)
+		}
+		return imageID, nil
+	}
+
+	img, err := b.pullOrLookupImage(from)
+	if err != nil {
+		return "", err
+	}
+	return img.ID, nil
+}
+
+// mountCopySource creates a temporary container from the image and returns
+// its mounted root filesystem as a copySource. It must be released with
+// releaseCopySource.
+func (b *Builder) mountCopySource(imageID string) (*copySource, error) {
+	config := &runconfig.Config{
+		Image: imageID,
+		Cmd:   nopCommand(b.runConfig, "COPY --from"),
+	}
+	c, _, err := b.docker.Create(config, nil)
+	if err != nil {
+		return nil, err
+	}
+	source := &copySource{imageID: imageID, container: c}
+	root, err := c.GetResourcePath(string(os.PathSeparator))
+	if err != nil {
+		b.releaseCopySource(source)
+		return nil, err
+	}
+	source.context = builder.MakeDirectoryContext(root)
+	return source, nil
+}
+
+// releaseCopySource unmounts and removes the container of source.
+func (b *Builder) releaseCopySource(source *copySource) {
+	b.docker.Unmount(source.container)
+	b.removeContainer(source.container.ID)
+}
+
+// squash replaces the image of the build by an image holding the changes of
+// the last build stage in a single layer on top of its base image.
+func (b *Builder) squash() error {
+	if runtime.GOOS == "windows" {
+		return fmt.Errorf("Windows does not support squashing images")
+	}
+	fmt.Fprintf(b.Stdout, "Squashing layers of %s\n", stringid.TruncateID(b.image))
+	id, err := b.docker.Squash(b.image, b.stageBase, b.Reproducible)
+	if err != nil {
+		return err
+	}
+	b.docker.Retain(b.id, id)
+	b.activeImages = append(b.activeImages, id)
+	b.image = id
+	fmt.Fprintf(b.Stdout, " ---> %s\n", stringid.TruncateID(id))
+	return nil
+}
+
 // probeCache checks if `b.docker` implements builder.ImageCache and image-caching
 // is enabled (`b.UseCache`).
 // If so attempts to look up the current `b.image` and `b.runConfig` pair with `b.docker`.
@@ -467,19 +646,24 @@ func (b *Builder) processImageFrom(img *image.Image) error {
 // If no image is found, it returns `(false, nil)`.
 // If there is any error, it returns `(false, err)`.
 func (b *Builder) probeCache() (bool, error) {
-	c, ok := b.docker.(builder.ImageCache)
-	if !ok || !b.UseCache || b.cacheBusted {
+	if b.imageCache == nil || !b.UseCache {
 		return false, nil
 	}
-	cache, err := c.GetCachedImage(b.image, b.runConfig)
+	if b.cacheBusted {
+		b.setStepCache(cacheMiss)
+		return false, nil
+	}
+	cache, err := b.imageCache.GetCachedImage(b.image, b.runConfig)
 	if err != nil {
 		return false, err
 	}
 	if len(cache) == 0 {
 		logrus.Debugf("[BUILDER] Cache miss: %s", b.runConfig.Cmd)
 		b.cacheBusted = true
+		b.setStepCache(cacheMiss)
 		return false, nil
 	}
+	b.setStepCache(cacheHit)
 
 	fmt.Fprintf(b.Stdout, " ---> Using cache\n")
 	logrus.Debugf("[BUILDER] Use cached version: %s", b.runConfig.Cmd)
@@ -492,7 +676,9 @@ func (b *Builder) probeCache() (bool, error) {
 	return true, nil
 }
 
-func (b *Builder) create() (*daemon.Container, error) {
+// create creates a container of the current image and runConfig, with the
+// volumes binds.
+func (b *Builder) create(binds ...string) (*daemon.Container, error) {
 	if b.image == "" && !b.noBaseImage {
 		return nil, fmt.Errorf("Please provide a source image with `from` prior to run")
 	}
@@ -510,6 +696,9 @@ func (b *Builder) create() (*daemon.Container, error) {
 		MemorySwap:   b.MemorySwap,
 		Ulimits:      b.Ulimits,
 		Isolation:    b.Isolation,
+		NetworkMode:  runconfig.NetworkMode(b.NetworkMode),
+		ExtraHosts:   b.ExtraHosts,
+		Binds:        binds,
 	}
 
 	config := *b.runConfig
@@ -525,6 +714,7 @@ func (b *Builder) create() (*daemon.Container, error) {
 	}
 
 	b.tmpContainers[c.ID] = struct{}{}
+	b.setStepContainer(c.ID)
 	fmt.Fprintf(b.Stdout, " ---> Running in %s\n", stringid.TruncateID(c.ID))
 
 	if config.Cmd.Len() > 0 {
@@ -633,7 +823,7 @@ func (b *Builder) readDockerfile() error {
 			return fmt.Errorf("The Dockerfile (%s) cannot be empty", b.DockerfileName)
 		}
 	}
-	b.dockerfile, err = parser.Parse(f)
+	b.dockerfile, err = parser.ParseWithIncludes(f, b.openFragment)
 	f.Close()
 	if err != nil {
 		return err
@@ -653,6 +843,29 @@ func (b *Builder) readDockerfile() error {
 	return nil
 }
 
+// openFragment opens the Dockerfile fragment path of an INCLUDE instruction
+// in the build context.
+func (b *Builder) openFragment(path string) (io.ReadCloser, error) {
+	f, err := b.context.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("Cannot locate included Dockerfile fragment: %s", path)
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// fragmentError prefixes err with the location of the instruction n of an
+// included Dockerfile fragment, keeping the exit code of a failed command.
+func fragmentError(n *parser.Node, err error) error {
+	location := fmt.Sprintf("%s line %d", n.File, n.StartLine)
+	if jerr, ok := err.(*jsonmessage.JSONError); ok {
+		return &jsonmessage.JSONError{Message: location + ": " + jerr.Message, Code: jerr.Code}
+	}
+	return fmt.Errorf("%s: %v", location, err)
+}
+
 // determine if build arg is part of built-in args or user
 // defined args in Dockerfile at any point in time.
 func (b *Builder) isBuildArgAllowed(arg string) bool {
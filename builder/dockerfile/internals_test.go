@@ -0,0 +1,57 @@
+package dockerfile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/builder/dockerfile/parser"
+	"github.com/docker/docker/pkg/stringutils"
+	"github.com/docker/docker/runconfig"
+)
+
+func TestCheckStages(t *testing.T) {
+	dockerfile := `FROM busybox AS base
+RUN true
+FROM base AS Final
+FROM busybox
+`
+	for _, target := range []string{"", "base", "FINAL"} {
+		b := newTestBuilder(t, dockerfile, target)
+		if err := b.checkStages(); err != nil {
+			t.Fatalf("Expected target %q to be valid, got %v", target, err)
+		}
+	}
+
+	b := newTestBuilder(t, dockerfile, "other")
+	if err := b.checkStages(); err == nil || !strings.Contains(err.Error(), "failed to reach build target other") {
+		t.Fatalf("Expected a missing target error, got %v", err)
+	}
+
+	b = newTestBuilder(t, "FROM busybox AS base\nFROM busybox AS BASE\n", "")
+	if err := b.checkStages(); err == nil || !strings.Contains(err.Error(), "duplicate name base") {
+		t.Fatalf("Expected a duplicate stage error, got %v", err)
+	}
+}
+
+func TestNopCommand(t *testing.T) {
+	config := &runconfig.Config{}
+	expected := append(append([]string{}, defaultShell...), nopPrefix+"ENV FOO=bar")
+	if cmd := nopCommand(config, "ENV FOO=bar"); !reflect.DeepEqual(cmd.Slice(), expected) {
+		t.Fatalf("Expected %v, got %v", expected, cmd.Slice())
+	}
+
+	config.Shell = stringutils.NewStrSlice("/bin/bash", "-c")
+	expected = []string{"/bin/bash", "-c", nopPrefix + "ENV FOO=bar"}
+	if cmd := nopCommand(config, "ENV FOO=bar"); !reflect.DeepEqual(cmd.Slice(), expected) {
+		t.Fatalf("Expected %v, got %v", expected, cmd.Slice())
+	}
+}
+
+func newTestBuilder(t *testing.T, dockerfile, target string) *Builder {
+	node, err := parser.Parse(strings.NewReader(dockerfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Builder{Config: &Config{Target: target}, dockerfile: node}
+}
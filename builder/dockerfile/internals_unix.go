@@ -7,6 +7,14 @@ import (
 	"path/filepath"
 )
 
+// defaultShell is the shell of the shell form of RUN, CMD and ENTRYPOINT when
+// no SHELL instruction was given.
+var defaultShell = []string{"/bin/sh", "-c"}
+
+// nopPrefix marks the commands of the instructions which run nothing as
+// comments of the shell.
+const nopPrefix = "#(nop) "
+
 func fixPermissions(source, destination string, uid, gid int, destExisted bool) error {
 	// If the destination didn't already exist, or the destination isn't a
 	// directory, then we should Lchown the destination. Otherwise, we shouldn't
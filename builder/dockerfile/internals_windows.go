@@ -2,6 +2,14 @@
 
 package dockerfile
 
+// defaultShell is the shell of the shell form of RUN, CMD and ENTRYPOINT when
+// no SHELL instruction was given.
+var defaultShell = []string{"cmd", "/S", "/C"}
+
+// nopPrefix marks the commands of the instructions which run nothing as
+// comments of the shell.
+const nopPrefix = "REM (nop) "
+
 func fixPermissions(source, destination string, uid, gid int, destExisted bool) error {
 	// chown is not supported on Windows
 	return nil
@@ -329,3 +329,37 @@ func parseMaybeJSONToList(rest string) (*Node, map[string]bool, error) {
 
 	return parseStringsWhitespaceDelimited(rest)
 }
+
+// parseHealthConfig parses the arguments of HEALTHCHECK, which are a type
+// (NONE or CMD) optionally followed by a command with the same syntax as
+// CMD.
+//
+// HEALTHCHECK CMD curl -f http://localhost/ -> (healthcheck "CMD" "curl -f http://localhost/")
+//
+func parseHealthConfig(rest string) (*Node, map[string]bool, error) {
+	// Find the end of the first argument
+	var sep int
+	for ; sep < len(rest); sep++ {
+		if unicode.IsSpace(rune(rest[sep])) {
+			break
+		}
+	}
+	next := sep
+	for ; next < len(rest); next++ {
+		if !unicode.IsSpace(rune(rest[next])) {
+			break
+		}
+	}
+
+	if sep == 0 {
+		return nil, nil, nil
+	}
+
+	typ := rest[:sep]
+	cmd, attrs, err := parseMaybeJSON(rest[next:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &Node{Value: typ, Next: cmd}, attrs, nil
+}
@@ -3,7 +3,10 @@ package parser
 
 import (
 	"bufio"
+	"fmt"
 	"io"
+	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"unicode"
@@ -22,7 +25,6 @@ import (
 // This data structure is frankly pretty lousy for handling complex languages,
 // but lucky for us the Dockerfile isn't very complicated. This structure
 // works a little more effectively than a "proper" parse tree for our needs.
-//
 type Node struct {
 	Value      string          // actual content
 	Next       *Node           // the next item in the current sexp
@@ -32,6 +34,7 @@ type Node struct {
 	Flags      []string        // only top Node should have this set
 	StartLine  int             // the line in the original dockerfile where the node begins
 	EndLine    int             // the line in the original dockerfile where the node ends
+	File       string          // the included fragment the node comes from, empty for the dockerfile
 }
 
 var (
@@ -49,22 +52,25 @@ func init() {
 	// functions. Errors are propagated up by Parse() and the resulting AST can
 	// be incorporated directly into the existing AST as a next.
 	dispatch = map[string]func(string) (*Node, map[string]bool, error){
-		command.User:       parseString,
-		command.Onbuild:    parseSubCommand,
-		command.Workdir:    parseString,
-		command.Env:        parseEnv,
-		command.Label:      parseLabel,
-		command.Maintainer: parseString,
-		command.From:       parseString,
-		command.Add:        parseMaybeJSONToList,
-		command.Copy:       parseMaybeJSONToList,
-		command.Run:        parseMaybeJSON,
-		command.Cmd:        parseMaybeJSON,
-		command.Entrypoint: parseMaybeJSON,
-		command.Expose:     parseStringsWhitespaceDelimited,
-		command.Volume:     parseMaybeJSONToList,
-		command.StopSignal: parseString,
-		command.Arg:        parseNameOrNameVal,
+		command.User:        parseString,
+		command.Onbuild:     parseSubCommand,
+		command.Workdir:     parseString,
+		command.Env:         parseEnv,
+		command.Label:       parseLabel,
+		command.Maintainer:  parseString,
+		command.From:        parseStringsWhitespaceDelimited,
+		command.Shell:       parseMaybeJSON,
+		command.Add:         parseMaybeJSONToList,
+		command.Copy:        parseMaybeJSONToList,
+		command.Run:         parseMaybeJSON,
+		command.Cmd:         parseMaybeJSON,
+		command.Entrypoint:  parseMaybeJSON,
+		command.Expose:      parseStringsWhitespaceDelimited,
+		command.Volume:      parseMaybeJSONToList,
+		command.StopSignal:  parseString,
+		command.Arg:         parseNameOrNameVal,
+		command.Healthcheck: parseHealthConfig,
+		command.Include:     parseStringsWhitespaceDelimited,
 	}
 }
 
@@ -100,24 +106,85 @@ func parseLine(line string) (string, *Node, error) {
 	return "", node, nil
 }
 
+// LineError is an error in the instruction starting at Line of the Dockerfile,
+// or of the Dockerfile fragment File included by it.
+type LineError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s line %d: %v", e.File, e.Line, e.Err)
+}
+
+// IncludeFunc opens the Dockerfile fragment at path, relative to the root of
+// the build context, of an INCLUDE instruction.
+type IncludeFunc func(path string) (io.ReadCloser, error)
+
 // Parse is the main parse routine.
 // It handles an io.ReadWriteCloser and returns the root of the AST.
 func Parse(rwc io.Reader) (*Node, error) {
-	currentLine := 0
+	return ParseWithIncludes(rwc, nil)
+}
+
+// ParseWithIncludes parses the Dockerfile read from r like Parse, expanding
+// the INCLUDE instructions in place with the instructions of the fragments
+// opened by include. The errors in a fragment report its path and line.
+func ParseWithIncludes(r io.Reader, include IncludeFunc) (*Node, error) {
+	p := &parser{include: include, stopOnError: true}
+	root := p.parse(r)
+	if len(p.errs) > 0 {
+		if p.errs[0].File == "" {
+			return nil, p.errs[0].Err
+		}
+		return nil, p.errs[0]
+	}
+	return root, nil
+}
+
+// ParseAll parses the whole Dockerfile read from r, skipping the invalid
+// instructions, and expanding the INCLUDE instructions with the fragments
+// opened by include, which may be nil. It returns the AST of the valid
+// instructions along with the errors of the invalid ones, in the order of the
+// file.
+func ParseAll(r io.Reader, include IncludeFunc) (*Node, []*LineError) {
+	p := &parser{include: include}
+	return p.parse(r), p.errs
+}
+
+type parser struct {
+	include     IncludeFunc
+	stopOnError bool
+	errs        []*LineError
+	files       []string // the fragments being included
+}
+
+// parse parses the Dockerfile read from r, stopping at the first invalid
+// instruction if stopOnError is set.
+func (p *parser) parse(r io.Reader) *Node {
 	root := &Node{}
 	root.StartLine = -1
+	p.parseFile(r, "", root)
+	return root
+}
+
+// parseFile appends the instructions of the Dockerfile or fragment file read
+// from rwc to the children of root.
+func (p *parser) parseFile(rwc io.Reader, file string, root *Node) {
+	currentLine := 0
 	scanner := bufio.NewScanner(rwc)
 
 	for scanner.Scan() {
 		scannedLine := strings.TrimLeftFunc(scanner.Text(), unicode.IsSpace)
 		currentLine++
-		line, child, err := parseLine(scannedLine)
-		if err != nil {
-			return nil, err
-		}
 		startLine := currentLine
+		line, child, err := parseLine(scannedLine)
 
-		if line != "" && child == nil {
+		if err == nil && line != "" && child == nil {
 			for scanner.Scan() {
 				newline := scanner.Text()
 				currentLine++
@@ -127,35 +194,86 @@ func Parse(rwc io.Reader) (*Node, error) {
 				}
 
 				line, child, err = parseLine(line + newline)
-				if err != nil {
-					return nil, err
-				}
-
-				if child != nil {
+				if err != nil || child != nil {
 					break
 				}
 			}
-			if child == nil && line != "" {
+			if err == nil && child == nil && line != "" {
 				line, child, err = parseLine(line)
-				if err != nil {
-					return nil, err
+			}
+		}
+
+		if err == nil && child != nil && child.Value == command.Include {
+			if file == "" {
+				p.updateRootLines(root, currentLine)
+			}
+			err = p.includeFragment(child, root)
+			if err == nil {
+				if p.stopOnError && len(p.errs) > 0 {
+					return
 				}
+				continue
 			}
 		}
 
+		if err != nil {
+			p.errs = append(p.errs, &LineError{File: file, Line: startLine, Err: err})
+			if p.stopOnError {
+				return
+			}
+			continue
+		}
+
 		if child != nil {
 			// Update the line information for the current child.
+			child.File = file
 			child.StartLine = startLine
 			child.EndLine = currentLine
-			// Update the line information for the root. The starting line of the root is always the
-			// starting line of the first child and the ending line is the ending line of the last child.
-			if root.StartLine < 0 {
-				root.StartLine = currentLine
+			if file == "" {
+				p.updateRootLines(root, currentLine)
 			}
-			root.EndLine = currentLine
 			root.Children = append(root.Children, child)
 		}
 	}
+}
 
-	return root, nil
+// updateRootLines updates the line information for the root. The starting
+// line of the root is always the starting line of the first child and the
+// ending line is the ending line of the last child.
+func (p *parser) updateRootLines(root *Node, line int) {
+	if root.StartLine < 0 {
+		root.StartLine = line
+	}
+	root.EndLine = line
+}
+
+// includeFragment appends the instructions of the fragment of the INCLUDE
+// instruction n to the children of root.
+func (p *parser) includeFragment(n *Node, root *Node) error {
+	if n.Next == nil || n.Next.Next != nil {
+		return fmt.Errorf("INCLUDE requires exactly one argument")
+	}
+	if len(n.Flags) > 0 {
+		return fmt.Errorf("INCLUDE doesn't take flags")
+	}
+	if p.include == nil {
+		return fmt.Errorf("INCLUDE can only be used in the Dockerfile of a build")
+	}
+
+	name := path.Clean("/" + filepath.ToSlash(n.Next.Value))[1:]
+	for _, f := range p.files {
+		if f == name {
+			return fmt.Errorf("INCLUDE of %s includes itself", name)
+		}
+	}
+	f, err := p.include(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	p.files = append(p.files, name)
+	defer func() { p.files = p.files[:len(p.files)-1] }()
+	p.parseFile(f, name, root)
+	return nil
 }
@@ -3,10 +3,13 @@ package parser
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -152,3 +155,92 @@ func TestLineInformation(t *testing.T) {
 		}
 	}
 }
+
+func TestParseAll(t *testing.T) {
+	dockerfile := `FROM busybox
+ENV onlyname
+RUN echo \
+  hello
+LABEL a=b
+CMD ["echo", 1]
+ENV foo bar
+LABEL a=b \
+  c
+`
+	ast, errs := ParseAll(strings.NewReader(dockerfile), nil)
+	if len(ast.Children) != 4 {
+		t.Fatalf("Expected the 4 valid instructions, got %d", len(ast.Children))
+	}
+	var lines []int
+	for _, err := range errs {
+		lines = append(lines, err.Line)
+	}
+	if !reflect.DeepEqual(lines, []int{2, 6, 8}) {
+		t.Fatalf("Expected errors at lines 2, 6 and 8, got %v", errs)
+	}
+
+	if _, err := Parse(strings.NewReader(dockerfile)); err == nil || err.Error() != errs[0].Err.Error() {
+		t.Fatalf("Expected Parse to stop at the first error %v, got %v", errs[0], err)
+	}
+}
+
+func TestParseWithIncludes(t *testing.T) {
+	fragments := map[string]string{
+		"common/setup": "ENV a b\nINCLUDE common/user\n",
+		"common/user":  "# the user\nUSER \\\n  nobody\n",
+		"bad":          "ENV a b\nENV onlyname\n",
+		"loop":         "INCLUDE ./loop\n",
+	}
+	include := func(path string) (io.ReadCloser, error) {
+		fragment, ok := fragments[path]
+		if !ok {
+			return nil, fmt.Errorf("no such fragment: %s", path)
+		}
+		return ioutil.NopCloser(strings.NewReader(fragment)), nil
+	}
+
+	ast, err := ParseWithIncludes(strings.NewReader("FROM busybox\nINCLUDE /common/setup\nRUN true\n"), include)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		value string
+		file  string
+		start int
+		end   int
+	}{
+		{"from", "", 1, 1},
+		{"env", "common/setup", 1, 1},
+		{"user", "common/user", 2, 3},
+		{"run", "", 3, 3},
+	}
+	if len(ast.Children) != len(expected) {
+		t.Fatalf("Expected %d instructions, got %d", len(expected), len(ast.Children))
+	}
+	for i, child := range ast.Children {
+		e := expected[i]
+		if child.Value != e.value || child.File != e.file || child.StartLine != e.start || child.EndLine != e.end {
+			t.Fatalf("Expected %s from %q at lines %d-%d, got %s from %q at lines %d-%d",
+				e.value, e.file, e.start, e.end, child.Value, child.File, child.StartLine, child.EndLine)
+		}
+	}
+	if ast.StartLine != 1 || ast.EndLine != 3 {
+		t.Fatalf("Expected the root to span lines 1-3, got %d-%d", ast.StartLine, ast.EndLine)
+	}
+
+	for dockerfile, expectedErr := range map[string]string{
+		"FROM busybox\nINCLUDE bad\n":     "bad line 2: ENV must have two arguments",
+		"FROM busybox\nINCLUDE loop\n":    "loop line 1: INCLUDE of loop includes itself",
+		"FROM busybox\nINCLUDE missing\n": "no such fragment: missing",
+		"FROM busybox\nINCLUDE a b\n":     "INCLUDE requires exactly one argument",
+	} {
+		_, err := ParseWithIncludes(strings.NewReader(dockerfile), include)
+		if err == nil || err.Error() != expectedErr {
+			t.Fatalf("Expected error %q parsing %q, got %v", expectedErr, dockerfile, err)
+		}
+	}
+
+	if _, err := Parse(strings.NewReader("FROM busybox\nINCLUDE common/setup\n")); err == nil {
+		t.Fatal("Expected INCLUDE to fail without a way to open the fragments")
+	}
+}
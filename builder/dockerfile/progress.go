@@ -0,0 +1,66 @@
+package dockerfile
+
+import (
+	"time"
+
+	"github.com/docker/docker/builder/dockerfile/parser"
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// Values of the Cache field of the build events.
+const (
+	cacheHit  = "hit"
+	cacheMiss = "miss"
+)
+
+// startStep sends the start event of the step stepN of the Dockerfile, and
+// starts recording its finish event, if the build events are requested.
+func (b *Builder) startStep(stepN int, ast *parser.Node) {
+	if b.Events == nil {
+		return
+	}
+	b.step = &jsonmessage.JSONBuildEvent{
+		Step:        stepN + 1,
+		Instruction: ast.Original,
+		Line:        ast.StartLine,
+		File:        ast.File,
+	}
+	start := *b.step
+	start.Type = jsonmessage.BuildStepStart
+	b.Events(&start)
+	b.stepStart = time.Now()
+}
+
+// finishStep sends the finish event of the current step, or its error event
+// if err isn't nil.
+func (b *Builder) finishStep(err error) {
+	if b.step == nil {
+		return
+	}
+	ev := b.step
+	b.step = nil
+	ev.Duration = int64(time.Since(b.stepStart))
+	if err != nil {
+		ev.Type = jsonmessage.BuildStepError
+		ev.Error = &jsonmessage.JSONError{Message: err.Error()}
+	} else {
+		ev.Type = jsonmessage.BuildStepFinish
+		ev.ImageID = b.image
+	}
+	b.Events(ev)
+}
+
+// setStepCache records the result of the build cache lookup of the current
+// step.
+func (b *Builder) setStepCache(result string) {
+	if b.step != nil {
+		b.step.Cache = result
+	}
+}
+
+// setStepContainer records the intermediate container of the current step.
+func (b *Builder) setStepContainer(id string) {
+	if b.step != nil {
+		b.step.ContainerID = id
+	}
+}
@@ -0,0 +1,59 @@
+package dockerfile
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/builder/dockerfile/parser"
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+func TestStepEvents(t *testing.T) {
+	var events []*jsonmessage.JSONBuildEvent
+	b := newTestBuilder(t, "FROM busybox\n\nRUN make\n", "")
+	b.Events = func(ev *jsonmessage.JSONBuildEvent) {
+		events = append(events, ev)
+	}
+
+	b.startStep(1, b.dockerfile.Children[1])
+	b.setStepCache(cacheMiss)
+	b.setStepContainer("container")
+	b.image = "image"
+	b.finishStep(nil)
+
+	b.startStep(2, b.dockerfile.Children[1])
+	b.finishStep(errors.New("failed"))
+
+	if len(events) != 4 {
+		t.Fatalf("Expected 4 events, got %d", len(events))
+	}
+	start, finish := events[0], events[1]
+	if start.Type != jsonmessage.BuildStepStart || start.Step != 2 || start.Instruction != "RUN make" || start.Line != 3 || start.File != "" || start.Cache != "" {
+		t.Fatalf("Unexpected start event %+v", start)
+	}
+	if finish.Type != jsonmessage.BuildStepFinish || finish.Cache != cacheMiss || finish.ContainerID != "container" || finish.ImageID != "image" {
+		t.Fatalf("Unexpected finish event %+v", finish)
+	}
+	if failed := events[3]; failed.Type != jsonmessage.BuildStepError || failed.Error == nil || failed.Error.Message != "failed" || failed.ImageID != "" {
+		t.Fatalf("Unexpected error event %+v", failed)
+	}
+
+	// the steps of an included fragment name it
+	b.startStep(3, &parser.Node{Original: "RUN make test", StartLine: 1, File: "build/test.inc"})
+	b.finishStep(nil)
+	if len(events) != 6 {
+		t.Fatalf("Expected 6 events, got %d", len(events))
+	}
+	if included := events[5]; included.Line != 1 || included.File != "build/test.inc" {
+		t.Fatalf("Unexpected event of an included step %+v", included)
+	}
+
+	// without Events, nothing is recorded
+	b.Events = nil
+	b.startStep(0, b.dockerfile.Children[0])
+	b.setStepCache(cacheHit)
+	b.finishStep(nil)
+	if b.step != nil || len(events) != 6 {
+		t.Fatal("Expected no events without an Events hook")
+	}
+}
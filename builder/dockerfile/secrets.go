@@ -0,0 +1,125 @@
+package dockerfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/daemon"
+	derr "github.com/docker/docker/errors"
+)
+
+// secretsMountPath is where the build secrets asked for by a RUN instruction
+// are mounted in its container.
+const secretsMountPath = "/run/secrets"
+
+// requestedSecrets returns the IDs in the comma separated list value of the
+// build secrets asked for by a RUN instruction. All of them must have been
+// passed to the build.
+func (b *Builder) requestedSecrets(value string) ([]string, error) {
+	var ids []string
+	for _, id := range strings.Split(value, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if _, ok := b.Secrets[id]; !ok {
+			return nil, derr.ErrorCodeSecretNotFound.WithArgs(id)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// secretsBind writes the build secrets ids to a new directory of the secrets
+// root of the build, which is created on first use. It returns the bind
+// mounting the directory read-only at secretsMountPath, and a function
+// removing the directory once the container has run.
+func (b *Builder) secretsBind(ids []string) (string, func(), error) {
+	if b.secretsRoot == "" {
+		root, err := ioutil.TempDir("", "docker-build-secrets")
+		if err != nil {
+			return "", nil, err
+		}
+		if err := mountSecretsRoot(root); err != nil {
+			os.RemoveAll(root)
+			return "", nil, err
+		}
+		b.secretsRoot = root
+	}
+
+	dir, err := ioutil.TempDir(b.secretsRoot, "run")
+	if err != nil {
+		return "", nil, err
+	}
+	release := func() {
+		if err := os.RemoveAll(dir); err != nil {
+			logrus.Errorf("Error removing the build secrets in %s: %v", dir, err)
+		}
+	}
+	// the root of the secrets is only accessible by the daemon, the files
+	// must be readable by any user of the container
+	if err := os.Chmod(dir, 0755); err != nil {
+		release()
+		return "", nil, err
+	}
+	for _, id := range ids {
+		if err := ioutil.WriteFile(filepath.Join(dir, id), b.Secrets[id], 0444); err != nil {
+			release()
+			return "", nil, err
+		}
+	}
+	return dir + ":" + secretsMountPath + ":ro", release, nil
+}
+
+// releaseSecrets unmounts and removes the secrets root of the build, if any.
+func (b *Builder) releaseSecrets() {
+	if b.secretsRoot == "" {
+		return
+	}
+	if err := unmountSecretsRoot(b.secretsRoot); err != nil {
+		logrus.Errorf("Error unmounting the build secrets in %s: %v", b.secretsRoot, err)
+		return
+	}
+	if err := os.RemoveAll(b.secretsRoot); err != nil {
+		logrus.Errorf("Error removing the build secrets in %s: %v", b.secretsRoot, err)
+	}
+	b.secretsRoot = ""
+}
+
+// missingMountpoint returns the paths on the host of the directories of p,
+// from the outermost, which don't exist in the root filesystem of the mounted
+// container c and will be created to mount a volume at p.
+func missingMountpoint(c *daemon.Container, p string) ([]string, error) {
+	var missing []string
+	for dir, rest := "/", strings.Split(strings.TrimPrefix(p, "/"), "/"); len(rest) > 0; rest = rest[1:] {
+		dir = path.Join(dir, rest[0])
+		hostPath, err := c.GetResourcePath(dir)
+		if err != nil {
+			return nil, err
+		}
+		if len(missing) == 0 {
+			if _, err := os.Lstat(hostPath); err == nil {
+				continue
+			} else if !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+		missing = append(missing, hostPath)
+	}
+	return missing, nil
+}
+
+// removeMountpoint removes the directories created on the host to mount a
+// volume, from the innermost, so they aren't committed. Directories which
+// are no longer empty are left alone.
+func removeMountpoint(missing []string) {
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := os.Remove(missing[i]); err != nil {
+			return
+		}
+	}
+}
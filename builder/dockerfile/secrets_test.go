@@ -0,0 +1,30 @@
+package dockerfile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRequestedSecrets(t *testing.T) {
+	b := &Builder{Config: &Config{Secrets: map[string][]byte{
+		"token": []byte("s3cr3t"),
+		"npmrc": []byte("//registry"),
+	}}}
+
+	ids, err := b.requestedSecrets("npmrc, token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []string{"npmrc", "token"}) {
+		t.Fatalf("Expected the npmrc and token secrets, got %q", ids)
+	}
+
+	if ids, err := b.requestedSecrets(""); err != nil || len(ids) != 0 {
+		t.Fatalf("Expected no secrets, got %q and %v", ids, err)
+	}
+
+	if _, err := b.requestedSecrets("token,other"); err == nil || !strings.Contains(err.Error(), "secret other was not passed") {
+		t.Fatalf("Expected a missing secret error, got %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+// +build !windows
+
+package dockerfile
+
+import "github.com/docker/docker/pkg/mount"
+
+// mountSecretsRoot mounts a tmpfs at root, so the build secrets are never
+// written to disk.
+func mountSecretsRoot(root string) error {
+	return mount.Mount("tmpfs", root, "tmpfs", "mode=0700")
+}
+
+func unmountSecretsRoot(root string) error {
+	return mount.Unmount(root)
+}
@@ -0,0 +1,13 @@
+// +build windows
+
+package dockerfile
+
+import derr "github.com/docker/docker/errors"
+
+func mountSecretsRoot(root string) error {
+	return derr.ErrorCodeNotOnWindows.WithArgs("RUN --secret")
+}
+
+func unmountSecretsRoot(root string) error {
+	return nil
+}
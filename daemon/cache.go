@@ -0,0 +1,113 @@
+package daemon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/runconfig"
+)
+
+// ImageCache is a build cache which, in addition to the local children of
+// the parent image, matches the steps in the history of a set of cache source
+// images. The sources are usually pulled from a registry right before the
+// build, so their ancestors may be copies of the base image of the build with
+// other IDs: parents are matched by the checksums of their layers as well.
+type ImageCache struct {
+	daemon  *Daemon
+	sources [][]*image.Image  // the histories of the sources
+	keys    map[string]string // the content keys of the images, by ID
+}
+
+// NewImageCache returns an ImageCache using the images given by refs as
+// cache sources. The references which don't resolve to an image are skipped.
+func (daemon *Daemon) NewImageCache(refs []string) *ImageCache {
+	cache := &ImageCache{
+		daemon: daemon,
+		keys:   make(map[string]string),
+	}
+	for _, ref := range refs {
+		img, err := daemon.repositories.LookupImage(ref)
+		if err != nil {
+			logrus.Warnf("Could not look up %s for cache resolution, skipping: %v", ref, err)
+			continue
+		}
+		history := []*image.Image{img}
+		for img.Parent != "" {
+			if img, err = daemon.Graph().Get(img.Parent); err != nil {
+				break
+			}
+			history = append(history, img)
+		}
+		if err != nil {
+			logrus.Warnf("Could not read the history of %s for cache resolution, skipping: %v", ref, err)
+			continue
+		}
+		cache.sources = append(cache.sources, history)
+	}
+	return cache
+}
+
+// GetCachedImage returns the most recent image, among the local children of
+// the image parentID and the history of the cache sources, which was created
+// by config on top of parentID or of an image with the same content.
+func (cache *ImageCache) GetCachedImage(parentID string, config *runconfig.Config) (*image.Image, error) {
+	if img, err := cache.daemon.ImageGetCached(parentID, config); err != nil || img != nil {
+		return img, err
+	}
+
+	parentKey := cache.contentKey(parentID)
+	var match *image.Image
+	for _, history := range cache.sources {
+		for _, img := range history {
+			if img.Parent != parentID && (parentKey == "" || cache.contentKey(img.Parent) != parentKey) {
+				continue
+			}
+			if runconfig.Compare(&img.ContainerConfig, config) {
+				if match == nil || match.Created.Before(img.Created) {
+					match = img
+				}
+			}
+		}
+	}
+	return match, nil
+}
+
+// contentKey returns a checksum of the layers of the image id and of all its
+// ancestors, or an empty string if the checksum of one of them is unknown.
+func (cache *ImageCache) contentKey(id string) string {
+	if id == "" {
+		return ""
+	}
+	if key, ok := cache.keys[id]; ok {
+		return key
+	}
+
+	var (
+		digests []string
+		key     string
+	)
+	for next := id; next != ""; {
+		dgst, err := cache.daemon.Graph().LayerDigest(next)
+		if err != nil {
+			digests = nil
+			break
+		}
+		digests = append(digests, dgst.String())
+		img, err := cache.daemon.Graph().Get(next)
+		if err != nil {
+			digests = nil
+			break
+		}
+		next = img.Parent
+	}
+	if digests != nil {
+		hasher := sha256.New()
+		hasher.Write([]byte(strings.Join(digests, ",")))
+		key = hex.EncodeToString(hasher.Sum(nil))
+	}
+	cache.keys[id] = key
+	return key
+}
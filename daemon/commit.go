@@ -3,6 +3,7 @@ package daemon
 import (
 	"fmt"
 	"runtime"
+	"time"
 
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/archive"
@@ -21,6 +22,10 @@ type ContainerCommitConfig struct {
 	// merge container config into commit config before commit
 	MergeConfigs bool
 	Config       *runconfig.Config
+	// create the image at Created, with a normalized layer and an ID
+	// derived from its content, so the same changes give the same image
+	Reproducible bool
+	Created      time.Time
 }
 
 // Commit creates a new filesystem image from the current state of a container.
@@ -58,7 +63,12 @@ func (daemon *Daemon) Commit(name string, c *ContainerCommitConfig) (*image.Imag
 	}()
 
 	// Create a new image from the container's base layers + a new layer from container changes
-	img, err := daemon.graph.Create(rwTar, container.ID, container.ImageID, c.Comment, c.Author, container.Config, c.Config)
+	var img *image.Image
+	if c.Reproducible {
+		img, err = daemon.graph.CreateReproducible(rwTar, container.ImageID, c.Comment, c.Author, container.Config, c.Config, c.Created)
+	} else {
+		img, err = daemon.graph.Create(rwTar, container.ID, container.ImageID, c.Comment, c.Author, container.Config, c.Config)
+	}
 	if err != nil {
 		return nil, err
 	}
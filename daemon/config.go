@@ -1,8 +1,18 @@
 package daemon
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/graph"
 	"github.com/docker/docker/opts"
 	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/registry"
 	"github.com/docker/docker/runconfig"
 )
 
@@ -12,41 +22,60 @@ const (
 )
 
 // CommonConfig defines the configuration of a docker daemon which are
-// common across platforms.
+// common across platforms. The json tags are the names of the options in
+// the configuration file, which are the same as the names of the flags.
 type CommonConfig struct {
-	AutoRestart    bool
-	Bridge         bridgeConfig // Bridge holds bridge network specific configuration.
-	Context        map[string][]string
-	DisableBridge  bool
-	DNS            []string
-	DNSOptions     []string
-	DNSSearch      []string
-	ExecOptions    []string
-	ExecRoot       string
-	GraphDriver    string
-	GraphOptions   []string
-	Labels         []string
-	LogConfig      runconfig.LogConfig
-	Mtu            int
-	Pidfile        string
-	RemappedRoot   string
-	Root           string
-	TrustKeyPath   string
-	DefaultNetwork string
+	AuthorizationPlugins []string            `json:"authorization-plugin,omitempty"` // AuthorizationPlugins holds list of authorization plugins
+	AutoRestart          bool                `json:"-"`
+	Bridge               bridgeConfig        `json:"-"` // Bridge holds bridge network specific configuration.
+	Context              map[string][]string `json:"-"`
+	Debug                bool                `json:"debug,omitempty"`
+	DisableBridge        bool                `json:"-"`
+	DNS                  []string            `json:"dns,omitempty"`
+	DNSOptions           []string            `json:"dns-opt,omitempty"`
+	DNSSearch            []string            `json:"dns-search,omitempty"`
+	ExecOptions          []string            `json:"exec-opt,omitempty"`
+	ExecRoot             string              `json:"exec-root,omitempty"`
+	GraphDriver          string              `json:"storage-driver,omitempty"`
+	GraphOptions         []string            `json:"storage-opt,omitempty"`
+	Labels               []string            `json:"label,omitempty"`
+	LiveRestoreEnabled   bool                `json:"live-restore,omitempty"`
+	LogConfig            runconfig.LogConfig `json:"-"`
+	Mtu                  int                 `json:"mtu,omitempty"`
+	Pidfile              string              `json:"pidfile,omitempty"`
+	RemappedRoot         string              `json:"-"`
+	Root                 string              `json:"graph,omitempty"`
+	TrustKeyPath         string              `json:"-"`
+	DefaultNetwork       string              `json:"-"`
 
 	// ClusterStore is the storage backend used for the cluster information. It is used by both
 	// multihost networking (to store networks and endpoints information) and by the node discovery
 	// mechanism.
-	ClusterStore string
+	ClusterStore string `json:"cluster-store,omitempty"`
 
 	// ClusterOpts is used to pass options to the discovery package for tuning libkv settings, such
 	// as TLS configuration settings.
-	ClusterOpts map[string]string
+	ClusterOpts map[string]string `json:"cluster-store-opt,omitempty"`
 
 	// ClusterAdvertise is the network endpoint that the Engine advertises for the purpose of node
 	// discovery. This should be a 'host:port' combination on which that daemon instance is
 	// reachable by other hosts.
-	ClusterAdvertise string
+	ClusterAdvertise string `json:"cluster-advertise,omitempty"`
+
+	// MaxConcurrentDownloads and MaxConcurrentUploads are the number of
+	// layers the pulls and the pushes of the daemon transfer at the same
+	// time.
+	MaxConcurrentDownloads int `json:"max-concurrent-downloads,omitempty"`
+	MaxConcurrentUploads   int `json:"max-concurrent-uploads,omitempty"`
+
+	// Options holds the registry mirrors and the insecure registries.
+	registry.Options
+
+	// reloadLock protects the options which can be reloaded while the
+	// daemon is running.
+	reloadLock sync.Mutex
+	// valuesSet holds the options set in the configuration file.
+	valuesSet map[string]interface{}
 }
 
 // InstallCommonFlags adds command-line options to the top-level flag parser for
@@ -72,4 +101,204 @@ func (config *Config) InstallCommonFlags(cmd *flag.FlagSet, usageFn func(string)
 	cmd.StringVar(&config.ClusterAdvertise, []string{"-cluster-advertise"}, "", usageFn("Address or interface name to advertise"))
 	cmd.StringVar(&config.ClusterStore, []string{"-cluster-store"}, "", usageFn("Set the cluster store"))
 	cmd.Var(opts.NewMapOpts(config.ClusterOpts, nil), []string{"-cluster-store-opt"}, usageFn("Set cluster store options"))
+	cmd.Var(opts.NewListOptsRef(&config.AuthorizationPlugins, nil), []string{"-authorization-plugin"}, usageFn("List authorization plugins in order from first evaluator to last"))
+	cmd.IntVar(&config.MaxConcurrentDownloads, []string{"-max-concurrent-downloads"}, graph.DefaultMaxConcurrentDownloads, usageFn("Set the maximum number of layers downloaded at the same time"))
+	cmd.IntVar(&config.MaxConcurrentUploads, []string{"-max-concurrent-uploads"}, graph.DefaultMaxConcurrentUploads, usageFn("Set the maximum number of layers uploaded at the same time"))
+}
+
+// logConfig holds the log options of the configuration file, which are
+// stored in a runconfig.LogConfig.
+type logConfig struct {
+	Type   string            `json:"log-driver,omitempty"`
+	Config map[string]string `json:"log-opt,omitempty"`
+}
+
+// IsValueSet returns true if the option was set in the configuration file.
+func (config *Config) IsValueSet(name string) bool {
+	if config.valuesSet == nil {
+		return false
+	}
+	_, ok := config.valuesSet[name]
+	return ok
+}
+
+// MergeDaemonConfigurations loads the configuration file on top of the
+// configuration built from the flags. It is an error for the file to set an
+// option which was also set with a flag of one of the flag sets, which are
+// the daemon flags and the common flags such as -D.
+func MergeDaemonConfigurations(flagsConfig *Config, configFile string, flags ...*flag.FlagSet) error {
+	return loadConfigurationFile(flagsConfig, configFile, flags)
+}
+
+// ReloadConfiguration reads the configuration file again and calls reload
+// with a configuration which only holds the options set in the file.
+func ReloadConfiguration(configFile string, reload func(*Config), flags ...*flag.FlagSet) error {
+	newConfig := &Config{}
+	if err := loadConfigurationFile(newConfig, configFile, flags); err != nil {
+		return err
+	}
+	reload(newConfig)
+	return nil
+}
+
+// loadConfigurationFile decodes the configuration file into config, after
+// checking that every option in the file is known and that none of them
+// was also set with a flag.
+func loadConfigurationFile(config *Config, configFile string, flags []*flag.FlagSet) error {
+	b, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(b, &values); err != nil {
+		return fmt.Errorf("unable to parse the configuration file %s: %v", configFile, err)
+	}
+	if err := findConfigurationConflicts(values, flags); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(b, config); err != nil {
+		return fmt.Errorf("unable to load the configuration file %s: %v", configFile, err)
+	}
+	// The bridge and log options are not stored at the top level of
+	// Config, they are decoded separately.
+	if err := json.Unmarshal(b, &config.Bridge); err != nil {
+		return fmt.Errorf("unable to load the configuration file %s: %v", configFile, err)
+	}
+	var lc logConfig
+	if err := json.Unmarshal(b, &lc); err != nil {
+		return fmt.Errorf("unable to load the configuration file %s: %v", configFile, err)
+	}
+	if _, ok := values["log-driver"]; ok {
+		config.LogConfig.Type = lc.Type
+	}
+	if _, ok := values["log-opt"]; ok {
+		config.LogConfig.Config = lc.Config
+	}
+
+	config.valuesSet = values
+	return validateConfiguration(config)
+}
+
+// findConfigurationConflicts returns an error if the configuration file
+// sets options which do not exist, or which were also set with a flag of
+// one of the flag sets.
+func findConfigurationConflicts(values map[string]interface{}, flags []*flag.FlagSet) error {
+	known := make(map[string]bool)
+	configKeys(reflect.TypeOf(Config{}), known)
+	configKeys(reflect.TypeOf(bridgeConfig{}), known)
+	configKeys(reflect.TypeOf(logConfig{}), known)
+
+	var unknown []string
+	for name := range values {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("the following directives don't match any configuration option: %s", strings.Join(unknown, ", "))
+	}
+
+	var conflicts []string
+	// the flag sets may share flags, which are only reported once
+	seen := make(map[string]bool)
+	for _, fs := range flags {
+		if fs == nil {
+			continue
+		}
+		fs.Visit(func(f *flag.Flag) {
+			for _, name := range f.Names {
+				name = strings.TrimLeft(name, "#-")
+				if value, ok := values[name]; ok {
+					if !seen[name] {
+						seen[name] = true
+						conflicts = append(conflicts, fmt.Sprintf("%s: (from flag: %v, from file: %v)", name, f.Value.String(), value))
+					}
+					break
+				}
+			}
+		})
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("the following directives are specified both as a flag and in the configuration file: %s", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// configKeys adds the names of the options held by a configuration
+// structure to keys, following embedded structures.
+func configKeys(t reflect.Type, keys map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if field.Anonymous && tag == "" {
+			configKeys(field.Type, keys)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name := strings.Split(tag, ",")[0]; name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+}
+
+// validateConfiguration validates the options set in the configuration
+// file, as the flags validate the options set on the command line.
+func validateConfiguration(config *Config) error {
+	if config.IsValueSet("dns") {
+		for _, dns := range config.DNS {
+			if _, err := opts.ValidateIPAddress(dns); err != nil {
+				return err
+			}
+		}
+	}
+	if config.IsValueSet("dns-search") {
+		for _, search := range config.DNSSearch {
+			if _, err := opts.ValidateDNSSearch(search); err != nil {
+				return err
+			}
+		}
+	}
+	if config.IsValueSet("label") {
+		for _, label := range config.Labels {
+			if _, err := opts.ValidateLabel(label); err != nil {
+				return err
+			}
+		}
+	}
+	if config.IsValueSet("registry-mirror") {
+		for i, mirror := range config.Mirrors {
+			m, err := registry.ValidateMirror(mirror)
+			if err != nil {
+				return err
+			}
+			config.Mirrors[i] = m
+		}
+	}
+	if config.IsValueSet("insecure-registry") {
+		for i, r := range config.InsecureRegistries {
+			name, err := registry.ValidateIndexName(r)
+			if err != nil {
+				return err
+			}
+			config.InsecureRegistries[i] = name
+		}
+	}
+	return validateMaxConcurrentTransfers(config)
+}
+
+// validateMaxConcurrentTransfers returns an error if the number of layers
+// transferred at the same time is negative. Zero selects the default.
+func validateMaxConcurrentTransfers(config *Config) error {
+	if config.MaxConcurrentDownloads < 0 {
+		return fmt.Errorf("invalid max-concurrent-downloads %d: must not be negative", config.MaxConcurrentDownloads)
+	}
+	if config.MaxConcurrentUploads < 0 {
+		return fmt.Errorf("invalid max-concurrent-uploads %d: must not be negative", config.MaxConcurrentUploads)
+	}
+	return nil
 }
@@ -0,0 +1,196 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	flag "github.com/docker/docker/pkg/mflag"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "docker-config-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func newTestFlags(config *Config, args ...string) (*flag.FlagSet, error) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	config.LogConfig.Config = make(map[string]string)
+	config.ClusterOpts = make(map[string]string)
+	config.InstallFlags(flags, func(s string) string { return s })
+	config.Options.InstallFlags(flags, func(s string) string { return s })
+	return flags, flags.Parse(args)
+}
+
+func TestMergeDaemonConfigurations(t *testing.T) {
+	configFile := writeConfigFile(t, `{"label": ["foo=bar"], "registry-mirror": ["https://mirror.example.com"], "log-driver": "syslog", "icc": false}`)
+	defer os.Remove(configFile)
+
+	config := &Config{}
+	flags, err := newTestFlags(config, "--graph", "/var/lib/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := MergeDaemonConfigurations(config, configFile, flags); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Root != "/var/lib/test" {
+		t.Fatalf("expected root from the flags, got %q", config.Root)
+	}
+	if len(config.Labels) != 1 || config.Labels[0] != "foo=bar" {
+		t.Fatalf("expected labels from the file, got %v", config.Labels)
+	}
+	if len(config.Mirrors) != 1 || config.Mirrors[0] != "https://mirror.example.com/" {
+		t.Fatalf("expected validated mirrors from the file, got %v", config.Mirrors)
+	}
+	if config.LogConfig.Type != "syslog" {
+		t.Fatalf("expected log driver from the file, got %q", config.LogConfig.Type)
+	}
+	if !config.IsValueSet("label") || config.IsValueSet("graph") {
+		t.Fatal("expected only the options of the file to be set")
+	}
+}
+
+func TestMergeDaemonConfigurationsConflicts(t *testing.T) {
+	configFile := writeConfigFile(t, `{"label": ["foo=bar"]}`)
+	defer os.Remove(configFile)
+
+	config := &Config{}
+	flags, err := newTestFlags(config, "--label", "foo=baz")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = MergeDaemonConfigurations(config, configFile, flags)
+	if err == nil || !strings.Contains(err.Error(), "specified both as a flag and in the configuration file: label") {
+		t.Fatalf("expected a conflict error, got %v", err)
+	}
+}
+
+func TestMergeDaemonConfigurationsCommonFlagsConflicts(t *testing.T) {
+	configFile := writeConfigFile(t, `{"debug": true}`)
+	defer os.Remove(configFile)
+
+	config := &Config{}
+	flags, err := newTestFlags(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var debug bool
+	commonFlags := flag.NewFlagSet("common", flag.ContinueOnError)
+	commonFlags.BoolVar(&debug, []string{"D", "-debug"}, false, "Enable debug mode")
+	if err := commonFlags.Parse([]string{"-D"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err = MergeDaemonConfigurations(config, configFile, flags, commonFlags)
+	if err == nil || !strings.Contains(err.Error(), "specified both as a flag and in the configuration file: debug") {
+		t.Fatalf("expected a conflict error, got %v", err)
+	}
+
+	// the common flags merged in the daemon flags are only reported once
+	flag.Merge(flags, commonFlags)
+	if err := flags.Parse([]string{"-D"}); err != nil {
+		t.Fatal(err)
+	}
+	err = MergeDaemonConfigurations(config, configFile, flags, commonFlags)
+	if err == nil || strings.Count(err.Error(), "debug:") != 1 {
+		t.Fatalf("expected a single conflict error, got %v", err)
+	}
+}
+
+func TestMergeDaemonConfigurationsUnknownOption(t *testing.T) {
+	configFile := writeConfigFile(t, `{"labels": ["foo=bar"]}`)
+	defer os.Remove(configFile)
+
+	config := &Config{}
+	flags, err := newTestFlags(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = MergeDaemonConfigurations(config, configFile, flags)
+	if err == nil || !strings.Contains(err.Error(), "don't match any configuration option: labels") {
+		t.Fatalf("expected an unknown option error, got %v", err)
+	}
+}
+
+func TestMergeDaemonConfigurationsInvalidValue(t *testing.T) {
+	configFile := writeConfigFile(t, `{"registry-mirror": ["ftp://mirror.example.com"]}`)
+	defer os.Remove(configFile)
+
+	config := &Config{}
+	flags, err := newTestFlags(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := MergeDaemonConfigurations(config, configFile, flags); err == nil {
+		t.Fatal("expected an error for an invalid registry mirror")
+	}
+}
+
+func TestMergeDaemonConfigurationsMaxConcurrentTransfers(t *testing.T) {
+	configFile := writeConfigFile(t, `{"max-concurrent-uploads": 10}`)
+	defer os.Remove(configFile)
+
+	config := &Config{}
+	flags, err := newTestFlags(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := MergeDaemonConfigurations(config, configFile, flags); err != nil {
+		t.Fatal(err)
+	}
+	if config.MaxConcurrentDownloads != 3 || config.MaxConcurrentUploads != 10 {
+		t.Fatalf("expected 3 concurrent downloads and 10 concurrent uploads, got %d and %d", config.MaxConcurrentDownloads, config.MaxConcurrentUploads)
+	}
+
+	invalidFile := writeConfigFile(t, `{"max-concurrent-downloads": -1}`)
+	defer os.Remove(invalidFile)
+
+	config = &Config{}
+	if flags, err = newTestFlags(config); err != nil {
+		t.Fatal(err)
+	}
+	err = MergeDaemonConfigurations(config, invalidFile, flags)
+	if err == nil || !strings.Contains(err.Error(), "max-concurrent-downloads") {
+		t.Fatalf("expected an error for a negative number of downloads, got %v", err)
+	}
+}
+
+func TestReloadConfiguration(t *testing.T) {
+	configFile := writeConfigFile(t, `{"label": ["foo=bar"], "debug": true}`)
+	defer os.Remove(configFile)
+
+	config := &Config{}
+	flags, err := newTestFlags(config, "--graph", "/var/lib/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reloaded *Config
+	if err := ReloadConfiguration(configFile, func(c *Config) { reloaded = c }, flags); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded == nil {
+		t.Fatal("expected the reload function to be called")
+	}
+	if !reloaded.IsValueSet("label") || !reloaded.IsValueSet("debug") || reloaded.IsValueSet("cluster-store") {
+		t.Fatal("expected only the options of the file to be set")
+	}
+	if !reloaded.Debug || len(reloaded.Labels) != 1 || reloaded.Labels[0] != "foo=bar" {
+		t.Fatalf("unexpected reloaded configuration: debug=%t labels=%v", reloaded.Debug, reloaded.Labels)
+	}
+}
@@ -53,6 +53,7 @@ type Container struct {
 	// Fields below here are platform specific.
 	activeLinks     map[string]*links.Link
 	AppArmorProfile string
+	SeccompProfile  string
 	HostnamePath    string
 	HostsPath       string
 	ShmPath         string
@@ -301,6 +302,7 @@ func (daemon *Daemon) populateCommand(c *Container, env []string) error {
 		BlkioWeightDevice: weightDevices,
 		OomKillDisable:    c.hostConfig.OomKillDisable,
 		MemorySwappiness:  -1,
+		PidsLimit:         c.hostConfig.PidsLimit,
 	}
 
 	if c.hostConfig.MemorySwappiness != nil {
@@ -349,9 +351,12 @@ func (daemon *Daemon) populateCommand(c *Container, env []string) error {
 		GIDMapping:         gidMap,
 		GroupAdd:           c.hostConfig.GroupAdd,
 		Ipc:                ipc,
+		LiveRestore:        daemon.configStore.LiveRestoreEnabled && !c.Config.Tty,
 		Pid:                pid,
 		ReadonlyRootfs:     c.hostConfig.ReadonlyRootfs,
 		RemappedRoot:       remappedRoot,
+		SeccompProfile:     c.SeccompProfile,
+		Sysctls:            c.hostConfig.Sysctls,
 		UIDMapping:         uidMap,
 		UTS:                uts,
 	}
@@ -359,6 +364,21 @@ func (daemon *Daemon) populateCommand(c *Container, env []string) error {
 	return nil
 }
 
+// updateCommandResources sets the updatable resource limits of hostConfig
+// on the resources of an execdriver command.
+func updateCommandResources(resources *execdriver.Resources, hostConfig *runconfig.HostConfig) {
+	resources.Memory = hostConfig.Memory
+	resources.MemoryReservation = hostConfig.MemoryReservation
+	resources.CPUShares = hostConfig.CPUShares
+	resources.BlkioWeight = hostConfig.BlkioWeight
+	resources.MemorySwap = hostConfig.MemorySwap
+	resources.KernelMemory = hostConfig.KernelMemory
+	resources.CpusetCpus = hostConfig.CpusetCpus
+	resources.CpusetMems = hostConfig.CpusetMems
+	resources.CPUPeriod = hostConfig.CPUPeriod
+	resources.CPUQuota = hostConfig.CPUQuota
+}
+
 func mergeDevices(defaultDevices, userDevices []*configs.Device) []*configs.Device {
 	if len(userDevices) == 0 {
 		return defaultDevices
@@ -1103,6 +1123,17 @@ func (daemon *Daemon) setNetworkNamespaceKey(containerID string, pid int) error
 	return sandbox.SetKey(path)
 }
 
+// reattachNetworkNamespace links the network sandbox of a container which
+// was kept running with live restore to its network namespace, as the
+// pre-start hook does when the container is started.
+func (daemon *Daemon) reattachNetworkNamespace(container *Container) error {
+	n := container.command.Network
+	if n == nil || n.ContainerID != "" || n.NamespacePath != "" {
+		return nil
+	}
+	return daemon.setNetworkNamespaceKey(container.ID, container.Pid)
+}
+
 func (daemon *Daemon) getIpcContainer(container *Container) (*Container, error) {
 	containerID := container.hostConfig.IpcMode.Container()
 	c, err := daemon.Get(containerID)
@@ -1426,6 +1457,12 @@ func (container *Container) unmountIpcMounts(unmount func(pth string) error) {
 	}
 }
 
+// isLiveRestored returns whether the container's process is kept running
+// when the daemon stops, to be reattached to by the next daemon.
+func (container *Container) isLiveRestored() bool {
+	return container.command != nil && container.command.LiveRestore
+}
+
 func (container *Container) ipcMounts() []execdriver.Mount {
 	var mounts []execdriver.Mount
 
@@ -1451,6 +1488,20 @@ func (container *Container) ipcMounts() []execdriver.Mount {
 	return mounts
 }
 
+// tmpfsMounts returns the tmpfs mounts requested for the container, sorted
+// by destination.
+func (container *Container) tmpfsMounts() []execdriver.Mount {
+	var mounts []execdriver.Mount
+	for dest, data := range container.hostConfig.Tmpfs {
+		mounts = append(mounts, execdriver.Mount{
+			Source:      "tmpfs",
+			Destination: dest,
+			Data:        data,
+		})
+	}
+	return sortMounts(mounts)
+}
+
 func detachMounted(path string) error {
 	return syscall.Unmount(path, syscall.MNT_DETACH)
 }
@@ -7,6 +7,7 @@ import (
 
 	"github.com/docker/docker/daemon/execdriver"
 	derr "github.com/docker/docker/errors"
+	"github.com/docker/docker/runconfig"
 	"github.com/docker/docker/volume"
 	"github.com/docker/libnetwork"
 )
@@ -103,12 +104,16 @@ func (daemon *Daemon) populateCommand(c *Container, env []string) error {
 		return derr.ErrorCodeGetGraph.WithArgs(c.ImageID, err)
 	}
 	for i := img; i != nil && err == nil; i, err = daemon.graph.GetParent(i) {
-		lp, err := daemon.driver.Get(i.ID, "")
+		layerID, err := daemon.graph.LayerStorageID(i.ID)
+		if err != nil {
+			return derr.ErrorCodeGetLayer.WithArgs(daemon.driver.String(), i.ID, err)
+		}
+		lp, err := daemon.driver.Get(layerID, "")
 		if err != nil {
 			return derr.ErrorCodeGetLayer.WithArgs(daemon.driver.String(), i.ID, err)
 		}
 		layerPaths = append(layerPaths, lp)
-		err = daemon.driver.Put(i.ID)
+		err = daemon.driver.Put(layerID)
 		if err != nil {
 			return derr.ErrorCodePutLayer.WithArgs(daemon.driver.String(), i.ID, err)
 		}
@@ -142,12 +147,23 @@ func (daemon *Daemon) populateCommand(c *Container, env []string) error {
 	return nil
 }
 
+// updateCommandResources sets the updatable resource limits of hostConfig
+// on the resources of an execdriver command.
+func updateCommandResources(resources *execdriver.Resources, hostConfig *runconfig.HostConfig) {
+	resources.CPUShares = hostConfig.CPUShares
+}
+
 // getSize returns real size & virtual size
 func (daemon *Daemon) getSize(container *Container) (int64, int64) {
 	// TODO Windows
 	return 0, 0
 }
 
+// reattachNetworkNamespace is a no-op on Windows.
+func (daemon *Daemon) reattachNetworkNamespace(container *Container) error {
+	return nil
+}
+
 // setNetworkNamespaceKey is a no-op on Windows.
 func (daemon *Daemon) setNetworkNamespaceKey(containerID string, pid int) error {
 	return nil
@@ -183,10 +199,20 @@ func detachMounted(path string) error {
 	return nil
 }
 
+// isLiveRestored returns whether the container's process is kept running
+// when the daemon stops. Live restore is not supported on Windows.
+func (container *Container) isLiveRestored() bool {
+	return false
+}
+
 func (container *Container) ipcMounts() []execdriver.Mount {
 	return nil
 }
 
+func (container *Container) tmpfsMounts() []execdriver.Mount {
+	return nil
+}
+
 func getDefaultRouteMtu() (int, error) {
 	return -1, errSystemNotSupported
 }
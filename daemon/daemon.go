@@ -20,6 +20,7 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/api"
 	"github.com/docker/docker/api/types"
+	eventtypes "github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/cliconfig"
 	"github.com/docker/docker/daemon/events"
 	"github.com/docker/docker/daemon/execdriver"
@@ -38,7 +39,6 @@ import (
 	"github.com/docker/docker/pkg/graphdb"
 	"github.com/docker/docker/pkg/idtools"
 	"github.com/docker/docker/pkg/ioutils"
-	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/docker/docker/pkg/mount"
 	"github.com/docker/docker/pkg/namesgenerator"
 	"github.com/docker/docker/pkg/nat"
@@ -122,7 +122,7 @@ type Daemon struct {
 	EventsService    *events.Events
 	netController    libnetwork.NetworkController
 	volumes          *store.VolumeStore
-	discoveryWatcher discovery.Watcher
+	discoveryWatcher *discoveryReloader
 	root             string
 	shutdown         bool
 	uidMaps          []idtools.IDMap
@@ -220,26 +220,10 @@ func (daemon *Daemon) Register(container *Container) error {
 	// we'll waste time if we update it for every container
 	daemon.idIndex.Add(container.ID)
 
-	if container.IsRunning() {
-		logrus.Debugf("killing old running container %s", container.ID)
-		// Set exit code to 128 + SIGKILL (9) to properly represent unsuccessful exit
-		container.setStoppedLocking(&execdriver.ExitStatus{ExitCode: 137})
-		// use the current driver and ensure that the container is dead x.x
-		cmd := &execdriver.Command{
-			CommonCommand: execdriver.CommonCommand{
-				ID: container.ID,
-			},
-		}
-		daemon.execDriver.Terminate(cmd)
-
-		container.unmountIpcMounts(mount.Unmount)
-
-		if err := daemon.Unmount(container); err != nil {
-			logrus.Debugf("unmount error %s", err)
-		}
-		if err := container.toDiskLocking(); err != nil {
-			logrus.Errorf("Error saving stopped state to disk: %v", err)
-		}
+	// with live restore, running containers are reattached to once they
+	// are all registered
+	if container.IsRunning() && !daemon.configStore.LiveRestoreEnabled {
+		daemon.killStaleContainer(container)
 	}
 
 	if err := daemon.verifyVolumesInfo(container); err != nil {
@@ -253,6 +237,30 @@ func (daemon *Daemon) Register(container *Container) error {
 	return nil
 }
 
+// killStaleContainer kills a container left running by a previous daemon
+// and records it as stopped.
+func (daemon *Daemon) killStaleContainer(container *Container) {
+	logrus.Debugf("killing old running container %s", container.ID)
+	// Set exit code to 128 + SIGKILL (9) to properly represent unsuccessful exit
+	container.setStoppedLocking(&execdriver.ExitStatus{ExitCode: 137})
+	// use the current driver and ensure that the container is dead x.x
+	cmd := &execdriver.Command{
+		CommonCommand: execdriver.CommonCommand{
+			ID: container.ID,
+		},
+	}
+	daemon.execDriver.Terminate(cmd)
+
+	container.unmountIpcMounts(mount.Unmount)
+
+	if err := daemon.Unmount(container); err != nil {
+		logrus.Debugf("unmount error %s", err)
+	}
+	if err := container.toDiskLocking(); err != nil {
+		logrus.Errorf("Error saving stopped state to disk: %v", err)
+	}
+}
+
 func (daemon *Daemon) ensureName(container *Container) error {
 	if container.Name == "" {
 		name, err := daemon.generateNewName(container.ID)
@@ -303,6 +311,15 @@ func (daemon *Daemon) restore() error {
 		if (container.Driver == "" && currentDriver == "aufs") || container.Driver == currentDriver {
 			logrus.Debugf("Loaded container %v", container.ID)
 
+			// The images of the containers created before the migration of
+			// the graph are now known by their content IDs.
+			if img, err := daemon.graph.Get(container.ImageID); err == nil && img.ID != container.ImageID {
+				container.ImageID = img.ID
+				if err := container.toDisk(); err != nil {
+					logrus.Errorf("Failed to update the image of container %v: %v", container.ID, err)
+				}
+			}
+
 			containers[container.ID] = &cr{container: container}
 		} else {
 			logrus.Debugf("Cannot load container %s because it was created with another graph driver.", container.ID)
@@ -344,16 +361,36 @@ func (daemon *Daemon) restore() error {
 				return
 			}
 
+			// containers left running are reattached to once all the
+			// containers they may depend on are registered
+			if container.IsRunning() {
+				return
+			}
+
 			// check the restart policy on the containers and restart any container with
 			// the restart policy of "always"
-			if daemon.configStore.AutoRestart && container.shouldRestart() {
-				logrus.Debugf("Starting container %s", container.ID)
+			daemon.autoRestartContainer(container)
+		}(c.container, c.registered)
+	}
+	group.Wait()
 
-				if err := daemon.containerStart(container); err != nil {
-					logrus.Errorf("Failed to start container %s: %s", container.ID, err)
-				}
+	// Only containers reattached to with live restore can still be running
+	for _, c := range containers {
+		if !c.container.IsRunning() || !daemon.Exists(c.container.ID) {
+			continue
+		}
+		group.Add(1)
+
+		go func(container *Container) {
+			defer group.Done()
+
+			logrus.Debugf("Restoring running container %s", container.ID)
+			if err := daemon.containerRestore(container); err != nil {
+				logrus.Errorf("Failed to restore running container %s: %s", container.ID, err)
+				daemon.killStaleContainer(container)
+				daemon.autoRestartContainer(container)
 			}
-		}(c.container, c.registered)
+		}(c.container)
 	}
 	group.Wait()
 
@@ -367,6 +404,18 @@ func (daemon *Daemon) restore() error {
 	return nil
 }
 
+// autoRestartContainer starts a container loaded from disk if its restart
+// policy requires it.
+func (daemon *Daemon) autoRestartContainer(container *Container) {
+	if daemon.configStore.AutoRestart && container.shouldRestart() {
+		logrus.Debugf("Starting container %s", container.ID)
+
+		if err := daemon.containerStart(container); err != nil {
+			logrus.Errorf("Failed to start container %s: %s", container.ID, err)
+		}
+	}
+}
+
 func (daemon *Daemon) mergeAndVerifyConfig(config *runconfig.Config, img *image.Image) error {
 	if img != nil && img.Config != nil {
 		if err := runconfig.Merge(config, img.Config); err != nil {
@@ -535,29 +584,14 @@ func (daemon *Daemon) GetEventFilter(filter filters.Args) *events.Filter {
 			filter["container"][i] = c.ID
 		}
 	}
-	return events.NewFilter(filter, daemon.GetLabels)
+	return events.NewFilter(filter)
 }
 
 // SubscribeToEvents returns the currently record of events, a channel to stream new events from, and a function to cancel the stream of events.
-func (daemon *Daemon) SubscribeToEvents() ([]*jsonmessage.JSONMessage, chan interface{}, func()) {
+func (daemon *Daemon) SubscribeToEvents() ([]eventtypes.Message, chan interface{}, func()) {
 	return daemon.EventsService.Subscribe()
 }
 
-// GetLabels for a container or image id
-func (daemon *Daemon) GetLabels(id string) map[string]string {
-	// TODO: TestCase
-	container := daemon.containers.Get(id)
-	if container != nil {
-		return container.Config.Labels
-	}
-
-	img, err := daemon.repositories.LookupImage(id)
-	if err == nil {
-		return img.ContainerConfig.Labels
-	}
-	return nil
-}
-
 // children returns all child containers of the container with the
 // given name. The containers are returned as a map from the container
 // name to a pointer to Container.
@@ -612,6 +646,9 @@ func NewDaemon(config *Config, registryService *registry.Service) (daemon *Daemo
 	if err := checkConfigOptions(config); err != nil {
 		return nil, err
 	}
+	if err := validateMaxConcurrentTransfers(config); err != nil {
+		return nil, err
+	}
 
 	// Do we have a disabled network?
 	config.DisableBridge = isBridgeNetworkDisabled(config)
@@ -737,10 +774,12 @@ func NewDaemon(config *Config, registryService *registry.Service) (daemon *Daemo
 	eventsService := events.New()
 	logrus.Debug("Creating repository list")
 	tagCfg := &graph.TagStoreConfig{
-		Graph:    g,
-		Key:      trustKey,
-		Registry: registryService,
-		Events:   eventsService,
+		Graph:                  g,
+		Key:                    trustKey,
+		Registry:               registryService,
+		Events:                 eventsService,
+		MaxConcurrentDownloads: config.MaxConcurrentDownloads,
+		MaxConcurrentUploads:   config.MaxConcurrentUploads,
 	}
 	repositories, err := graph.NewTagStore(filepath.Join(config.Root, "repositories-"+d.driver.String()), tagCfg)
 	if err != nil {
@@ -812,6 +851,7 @@ func NewDaemon(config *Config, registryService *registry.Service) (daemon *Daemo
 	d.RegistryService = registryService
 	d.EventsService = eventsService
 	d.volumes = volStore
+	d.volumes.SetEventLogger(d.LogVolumeEvent)
 	d.root = config.Root
 	d.uidMaps = uidMaps
 	d.gidMaps = gidMaps
@@ -871,6 +911,9 @@ func (daemon *Daemon) shutdownContainer(c *Container) error {
 // Shutdown stops the daemon.
 func (daemon *Daemon) Shutdown() error {
 	daemon.shutdown = true
+	// containers kept running with live restore still use their root
+	// filesystem, so it must stay mounted
+	keepMounts := false
 	if daemon.containers != nil {
 		group := sync.WaitGroup{}
 		logrus.Debug("starting clean shutdown of all containers...")
@@ -878,6 +921,11 @@ func (daemon *Daemon) Shutdown() error {
 			if !container.IsRunning() {
 				continue
 			}
+			if container.isLiveRestored() {
+				logrus.Debugf("leaving %s running for live restore", container.ID)
+				keepMounts = true
+				continue
+			}
 			logrus.Debugf("stopping %s", container.ID)
 			group.Add(1)
 			go func(c *Container) {
@@ -903,12 +951,16 @@ func (daemon *Daemon) Shutdown() error {
 		}
 	}
 
-	if daemon.driver != nil {
+	if daemon.driver != nil && !keepMounts {
 		if err := daemon.driver.Cleanup(); err != nil {
 			logrus.Errorf("Error during graph storage driver.Cleanup(): %v", err)
 		}
 	}
 
+	if keepMounts {
+		return nil
+	}
+
 	if err := daemon.cleanupMounts(); err != nil {
 		return err
 	}
@@ -954,6 +1006,15 @@ func (daemon *Daemon) Run(c *Container, pipes *execdriver.Pipes, startCallback e
 	return daemon.execDriver.Run(c.command, pipes, hooks)
 }
 
+// Restore uses the execution driver to reattach to a container left
+// running by a previous daemon
+func (daemon *Daemon) Restore(c *Container, pipes *execdriver.Pipes, startCallback execdriver.DriverCallback) (execdriver.ExitStatus, error) {
+	hooks := execdriver.Hooks{
+		Start: startCallback,
+	}
+	return daemon.execDriver.Restore(c.command, pipes, hooks)
+}
+
 func (daemon *Daemon) kill(c *Container, sig int) error {
 	return daemon.execDriver.Kill(c.command, sig)
 }
@@ -990,8 +1051,12 @@ func (daemon *Daemon) createRootfs(container *Container) error {
 	if err := idtools.MkdirAs(container.root, 0700, rootUID, rootGID); err != nil {
 		return err
 	}
+	layerID, err := daemon.graph.LayerStorageID(container.ImageID)
+	if err != nil {
+		return err
+	}
 	initID := fmt.Sprintf("%s-init", container.ID)
-	if err := daemon.driver.Create(initID, container.ImageID); err != nil {
+	if err := daemon.driver.Create(initID, layerID); err != nil {
 		return err
 	}
 	initPath, err := daemon.driver.Get(initID, "")
@@ -1030,7 +1095,7 @@ func (daemon *Daemon) TagImage(repoName, tag, imageName string, force bool) erro
 	if err := daemon.repositories.Tag(repoName, tag, imageName, force); err != nil {
 		return err
 	}
-	daemon.EventsService.Log("tag", utils.ImageReference(repoName, tag), "")
+	daemon.LogImageEvent(utils.ImageReference(repoName, tag), "tag")
 	return nil
 }
 
@@ -1245,6 +1310,10 @@ func (daemon *Daemon) verifyContainerSettings(hostConfig *runconfig.HostConfig,
 				return nil, err
 			}
 		}
+
+		if err := verifyHealthcheck(config.Healthcheck); err != nil {
+			return nil, err
+		}
 	}
 
 	if hostConfig == nil {
@@ -1268,6 +1337,38 @@ func (daemon *Daemon) verifyContainerSettings(hostConfig *runconfig.HostConfig,
 	return verifyPlatformContainerSettings(daemon, hostConfig, config)
 }
 
+// verifyHealthcheck checks the health check settings of a container, which
+// the client validates too, but not every client.
+func verifyHealthcheck(healthcheck *runconfig.HealthConfig) error {
+	if healthcheck == nil {
+		return nil
+	}
+	if len(healthcheck.Test) > 0 {
+		switch healthcheck.Test[0] {
+		case "NONE":
+			if len(healthcheck.Test) > 1 {
+				return fmt.Errorf("Health check type NONE takes no arguments")
+			}
+		case "CMD", "CMD-SHELL":
+			if len(healthcheck.Test) < 2 {
+				return fmt.Errorf("Missing command for health check type %s", healthcheck.Test[0])
+			}
+		default:
+			return fmt.Errorf("Unknown health check type %q (try CMD, CMD-SHELL or NONE)", healthcheck.Test[0])
+		}
+	}
+	if healthcheck.Interval < 0 {
+		return fmt.Errorf("Health check interval cannot be negative")
+	}
+	if healthcheck.Timeout < 0 {
+		return fmt.Errorf("Health check timeout cannot be negative")
+	}
+	if healthcheck.Retries < 0 {
+		return fmt.Errorf("Health check retries cannot be negative")
+	}
+	return nil
+}
+
 func configureVolumes(config *Config, rootUID, rootGID int) (*store.VolumeStore, error) {
 	volumesDriver, err := local.New(config.Root, rootUID, rootGID)
 	if err != nil {
@@ -14,8 +14,10 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/daemon/graphdriver"
 	derr "github.com/docker/docker/errors"
+	"github.com/docker/docker/opts"
 	pblkiodev "github.com/docker/docker/pkg/blkiodev"
 	"github.com/docker/docker/pkg/idtools"
+	"github.com/docker/docker/pkg/mount"
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/pkg/parsers/kernel"
 	"github.com/docker/docker/pkg/sysinfo"
@@ -62,15 +64,22 @@ func parseSecurityOpt(container *Container, config *runconfig.HostConfig) error
 	)
 
 	for _, opt := range config.SecurityOpt {
-		con := strings.SplitN(opt, ":", 2)
-		if len(con) == 1 {
+		// Options are either in the key=value or the legacy key:value form.
+		i := strings.IndexAny(opt, "=:")
+		if i == -1 {
 			return fmt.Errorf("Invalid --security-opt: %q", opt)
 		}
+		con := []string{opt[:i], opt[i+1:]}
 		switch con[0] {
 		case "label":
 			labelOpts = append(labelOpts, con[1])
 		case "apparmor":
 			container.AppArmorProfile = con[1]
+		case "seccomp":
+			if !supportsSeccomp && con[1] != "unconfined" {
+				return fmt.Errorf("Seccomp is not supported by this daemon, cannot run a custom seccomp profile")
+			}
+			container.SeccompProfile = con[1]
 		default:
 			return fmt.Errorf("Invalid --security-opt: %q", opt)
 		}
@@ -131,16 +140,11 @@ func (daemon *Daemon) adaptContainerSettings(hostConfig *runconfig.HostConfig, a
 	}
 }
 
-// verifyPlatformContainerSettings performs platform-specific validation of the
-// hostconfig and config structures.
-func verifyPlatformContainerSettings(daemon *Daemon, hostConfig *runconfig.HostConfig, config *runconfig.Config) ([]string, error) {
+// verifyContainerResources validates the resource limits of the hostconfig
+// against what the kernel supports. Limits the kernel cannot enforce are
+// discarded with a warning.
+func verifyContainerResources(hostConfig *runconfig.HostConfig, sysInfo *sysinfo.SysInfo) ([]string, error) {
 	warnings := []string{}
-	sysInfo := sysinfo.New(true)
-
-	warnings, err := daemon.verifyExperimentalContainerSettings(hostConfig, config)
-	if err != nil {
-		return warnings, err
-	}
 
 	// memory subsystem checks and adjustments
 	if hostConfig.Memory != 0 && hostConfig.Memory < 4194304 {
@@ -243,6 +247,42 @@ func verifyPlatformContainerSettings(daemon *Daemon, hostConfig *runconfig.HostC
 		hostConfig.OomKillDisable = false
 		return warnings, fmt.Errorf("Your kernel does not support oom kill disable.")
 	}
+	if hostConfig.PidsLimit != 0 && !sysInfo.PidsLimit {
+		warnings = append(warnings, "Your kernel does not support pids limit capabilities, pids limit discarded.")
+		logrus.Warnf("Your kernel does not support pids limit capabilities, pids limit discarded.")
+		hostConfig.PidsLimit = 0
+	}
+	return warnings, nil
+}
+
+// verifyPlatformContainerSettings performs platform-specific validation of the
+// hostconfig and config structures.
+func verifyPlatformContainerSettings(daemon *Daemon, hostConfig *runconfig.HostConfig, config *runconfig.Config) ([]string, error) {
+	sysInfo := sysinfo.New(true)
+
+	warnings, err := daemon.verifyExperimentalContainerSettings(hostConfig, config)
+	if err != nil {
+		return warnings, err
+	}
+
+	w, err := verifyContainerResources(hostConfig, sysInfo)
+	warnings = append(warnings, w...)
+	if err != nil {
+		return warnings, err
+	}
+
+	for dest, options := range hostConfig.Tmpfs {
+		if !filepath.IsAbs(dest) {
+			return warnings, fmt.Errorf("Invalid tmpfs destination '%s': it needs to be an absolute path", dest)
+		}
+		if _, _, err := mount.ParseTmpfsOptions(options); err != nil {
+			return warnings, err
+		}
+	}
+
+	if err := verifySysctls(hostConfig); err != nil {
+		return warnings, err
+	}
 
 	if sysInfo.IPv4ForwardingDisabled {
 		warnings = append(warnings, "IPv4 forwarding is disabled. Networking will not work.")
@@ -251,6 +291,33 @@ func verifyPlatformContainerSettings(daemon *Daemon, hostConfig *runconfig.HostC
 	return warnings, nil
 }
 
+// verifySysctls checks that the sysctls of hostConfig are namespaced, and
+// only set in the namespaces private to the container: they would change the
+// host or another container otherwise.
+func verifySysctls(hostConfig *runconfig.HostConfig) error {
+	for k, v := range hostConfig.Sysctls {
+		if _, err := opts.ValidateSysctl(k + "=" + v); err != nil {
+			return err
+		}
+		if strings.HasPrefix(k, "net.") {
+			switch {
+			case hostConfig.NetworkMode.IsHost():
+				return fmt.Errorf("Sysctl '%s' is not allowed when using the host's network namespace", k)
+			case hostConfig.NetworkMode.IsContainer():
+				return fmt.Errorf("Sysctl '%s' is not allowed when sharing the network namespace of container %s", k, hostConfig.NetworkMode.ConnectedContainer())
+			}
+			continue
+		}
+		switch {
+		case hostConfig.IpcMode.IsHost():
+			return fmt.Errorf("Sysctl '%s' is not allowed when using the host's IPC namespace", k)
+		case hostConfig.IpcMode.IsContainer():
+			return fmt.Errorf("Sysctl '%s' is not allowed when sharing the IPC namespace of container %s", k, hostConfig.IpcMode.Container())
+		}
+	}
+	return nil
+}
+
 // checkConfigOptions checks for mutually incompatible config options
 func checkConfigOptions(config *Config) error {
 	// Check for mutually incompatible config options
@@ -85,3 +85,30 @@ func TestAdjustCPUSharesNoAdjustment(t *testing.T) {
 		t.Error("Expected CPUShares to be unchanged")
 	}
 }
+
+func TestVerifySysctls(t *testing.T) {
+	valid := []*runconfig.HostConfig{
+		{Sysctls: map[string]string{"net.core.somaxconn": "1024", "kernel.shmmax": "1"}},
+		{NetworkMode: "none", Sysctls: map[string]string{"net.core.somaxconn": "1024"}},
+		{NetworkMode: "container:web", Sysctls: map[string]string{"kernel.shmmax": "1"}},
+		{IpcMode: "container:web", Sysctls: map[string]string{"net.core.somaxconn": "1024"}},
+	}
+	for _, hostConfig := range valid {
+		if err := verifySysctls(hostConfig); err != nil {
+			t.Fatalf("Expected %+v to be valid, got %v", hostConfig, err)
+		}
+	}
+
+	invalid := []*runconfig.HostConfig{
+		{Sysctls: map[string]string{"kernel.hostname": "foo"}},
+		{NetworkMode: "host", Sysctls: map[string]string{"net.core.somaxconn": "1024"}},
+		{NetworkMode: "container:web", Sysctls: map[string]string{"net.core.somaxconn": "1024"}},
+		{IpcMode: "host", Sysctls: map[string]string{"kernel.shmmax": "1"}},
+		{IpcMode: "container:web", Sysctls: map[string]string{"fs.mqueue.msg_max": "10"}},
+	}
+	for _, hostConfig := range invalid {
+		if err := verifySysctls(hostConfig); err == nil {
+			t.Fatalf("Expected %+v to be invalid", hostConfig)
+		}
+	}
+}
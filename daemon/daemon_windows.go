@@ -8,6 +8,7 @@ import (
 	// register the windows graph driver
 	_ "github.com/docker/docker/daemon/graphdriver/windows"
 	"github.com/docker/docker/pkg/parsers"
+	"github.com/docker/docker/pkg/sysinfo"
 	"github.com/docker/docker/pkg/system"
 	"github.com/docker/docker/runconfig"
 	"github.com/docker/libnetwork"
@@ -53,9 +54,21 @@ func (daemon *Daemon) adaptContainerSettings(hostConfig *runconfig.HostConfig, a
 	}
 }
 
+// verifyContainerResources validates the resource limits of the hostconfig
+// against what the platform supports.
+func verifyContainerResources(hostConfig *runconfig.HostConfig, sysInfo *sysinfo.SysInfo) ([]string, error) {
+	return nil, nil
+}
+
 // verifyPlatformContainerSettings performs platform-specific validation of the
 // hostconfig and config structures.
 func verifyPlatformContainerSettings(daemon *Daemon, hostConfig *runconfig.HostConfig, config *runconfig.Config) ([]string, error) {
+	if len(hostConfig.Tmpfs) > 0 {
+		return nil, fmt.Errorf("Windows does not support tmpfs mounts")
+	}
+	if len(hostConfig.Sysctls) > 0 {
+		return nil, fmt.Errorf("Windows does not support sysctls")
+	}
 	return nil, nil
 }
 
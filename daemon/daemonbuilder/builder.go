@@ -206,6 +206,27 @@ func (d Docker) GetCachedImage(imgID string, cfg *runconfig.Config) (string, err
 	return cache.ID, nil
 }
 
+// MakeImageCache returns an ImageCache which also matches the history of the
+// images referenced by cacheFrom.
+func (d Docker) MakeImageCache(cacheFrom []string) builder.ImageCache {
+	return imageCache{d.Daemon.NewImageCache(cacheFrom)}
+}
+
+// imageCache adapts a daemon.ImageCache to builder.ImageCache.
+type imageCache struct {
+	*daemon.ImageCache
+}
+
+// GetCachedImage returns the ID of a cached image whose parent equals `parent`
+// and runconfig equals `cfg`, or an empty ID on a cache miss.
+func (c imageCache) GetCachedImage(imgID string, cfg *runconfig.Config) (string, error) {
+	cache, err := c.ImageCache.GetCachedImage(imgID, cfg)
+	if cache == nil || err != nil {
+		return "", err
+	}
+	return cache.ID, nil
+}
+
 // Kill stops the container execution abruptly.
 func (d Docker) Kill(container *daemon.Container) error {
 	return d.Daemon.Kill(container)
@@ -221,6 +242,16 @@ func (d Docker) Unmount(c *daemon.Container) error {
 	return d.Daemon.Unmount(c)
 }
 
+// Squash creates an image with the changes of the image imageID since its
+// ancestor parentID in a single layer.
+func (d Docker) Squash(imageID, parentID string, reproducible bool) (string, error) {
+	img, err := d.Daemon.Graph().Squash(imageID, parentID, reproducible)
+	if err != nil {
+		return "", err
+	}
+	return img.ID, nil
+}
+
 // Start starts a container
 func (d Docker) Start(c *daemon.Container) error {
 	return d.Daemon.Start(c)
@@ -1,6 +1,7 @@
 package daemon
 
 import (
+	"sync"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -18,18 +19,69 @@ const (
 	defaultDiscoveryTTL = 60 * time.Second
 )
 
+// discoveryReloader registers the daemon in the discovery backend, and
+// allows replacing the backend and the advertised address when the daemon
+// configuration is reloaded. It implements discovery.Watcher by watching
+// the current backend.
+type discoveryReloader struct {
+	mu      sync.Mutex
+	backend discovery.Backend
+	stop    chan struct{}
+}
+
 // initDiscovery initialized the nodes discovery subsystem by connecting to the specified backend
 // and start a registration loop to advertise the current node under the specified address.
-func initDiscovery(backend, address string, clusterOpts map[string]string) (discovery.Backend, error) {
+func initDiscovery(backend, address string, clusterOpts map[string]string) (*discoveryReloader, error) {
+	reloader := &discoveryReloader{}
+	if err := reloader.Reload(backend, address, clusterOpts); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// Watch watches the current discovery backend for entry changes.
+func (d *discoveryReloader) Watch(stopCh <-chan struct{}) (<-chan discovery.Entries, <-chan error) {
+	d.mu.Lock()
+	backend := d.backend
+	d.mu.Unlock()
+	return backend.Watch(stopCh)
+}
+
+// Reload connects to the specified backend and restarts the registration
+// loop with the specified address, stopping the previous one.
+func (d *discoveryReloader) Reload(backend, address string, clusterOpts map[string]string) error {
 	discoveryBackend, err := discovery.New(backend, defaultDiscoveryHeartbeat, defaultDiscoveryTTL, clusterOpts)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	// We call Register() on the discovery backend in a loop for the whole lifetime of the daemon,
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.stopRegistration()
+	d.backend = discoveryBackend
+	d.stop = make(chan struct{})
+
+	// We call Register() on the discovery backend in a loop until the next reload,
 	// but we never actually Watch() for nodes appearing and disappearing for the moment.
-	go registrationLoop(discoveryBackend, address)
-	return discoveryBackend, nil
+	go registrationLoop(discoveryBackend, address, d.stop)
+	return nil
+}
+
+// Stop stops registering the daemon in the discovery backend.
+func (d *discoveryReloader) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stopRegistration()
+}
+
+// stopRegistration stops the registration loop, if it is running. The
+// caller must hold the lock.
+func (d *discoveryReloader) stopRegistration() {
+	if d.stop != nil {
+		close(d.stop)
+		d.stop = nil
+	}
 }
 
 func registerAddr(backend discovery.Backend, addr string) {
@@ -39,11 +91,19 @@ func registerAddr(backend discovery.Backend, addr string) {
 }
 
 // registrationLoop registers the current node against the discovery backend using the specified
-// address. The function never returns, as registration against the backend comes with a TTL and
-// requires regular heartbeats.
-func registrationLoop(discoveryBackend discovery.Backend, address string) {
+// address. The function only returns when stop is closed, as registration against the backend
+// comes with a TTL and requires regular heartbeats.
+func registrationLoop(discoveryBackend discovery.Backend, address string, stop <-chan struct{}) {
 	registerAddr(discoveryBackend, address)
-	for range time.Tick(defaultDiscoveryHeartbeat) {
-		registerAddr(discoveryBackend, address)
+
+	ticker := time.NewTicker(defaultDiscoveryHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			registerAddr(discoveryBackend, address)
+		case <-stop:
+			return
+		}
 	}
 }
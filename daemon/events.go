@@ -1,10 +1,90 @@
 package daemon
 
+import (
+	"os"
+	"strings"
+
+	eventtypes "github.com/docker/docker/api/types/events"
+	"github.com/docker/libnetwork"
+)
+
 // LogContainerEvent generates an event related to a container.
 func (daemon *Daemon) LogContainerEvent(container *Container, action string) {
-	daemon.EventsService.Log(
-		action,
-		container.ID,
-		container.Config.Image,
-	)
+	daemon.LogContainerEventWithAttributes(container, action, map[string]string{})
+}
+
+// LogContainerEventWithAttributes generates an event related to a container
+// with specific given attributes. The labels, the image and the name of the
+// container are always part of the attributes.
+func (daemon *Daemon) LogContainerEventWithAttributes(container *Container, action string, attributes map[string]string) {
+	copyAttributes(attributes, container.Config.Labels)
+	if container.Config.Image != "" {
+		attributes["image"] = container.Config.Image
+	}
+	attributes["name"] = strings.TrimLeft(container.Name, "/")
+
+	actor := eventtypes.Actor{
+		ID:         container.ID,
+		Attributes: attributes,
+	}
+	daemon.EventsService.Log(action, eventtypes.ContainerEventType, actor)
+}
+
+// LogImageEvent generates an event related to an image.
+func (daemon *Daemon) LogImageEvent(imageID, action string) {
+	daemon.repositories.LogImageEvent(imageID, action)
+}
+
+// LogVolumeEvent generates an event related to a volume.
+func (daemon *Daemon) LogVolumeEvent(volumeID, action string, attributes map[string]string) {
+	actor := eventtypes.Actor{
+		ID:         volumeID,
+		Attributes: attributes,
+	}
+	daemon.EventsService.Log(action, eventtypes.VolumeEventType, actor)
+}
+
+// LogNetworkEvent generates an event related to a network with only the
+// default attributes.
+func (daemon *Daemon) LogNetworkEvent(nw libnetwork.Network, action string) {
+	daemon.LogNetworkEventWithAttributes(nw, action, map[string]string{})
+}
+
+// LogNetworkEventWithAttributes generates an event related to a network with
+// specific given attributes. The name and the type of the network are always
+// part of the attributes.
+func (daemon *Daemon) LogNetworkEventWithAttributes(nw libnetwork.Network, action string, attributes map[string]string) {
+	attributes["name"] = nw.Name()
+	attributes["type"] = nw.Type()
+
+	actor := eventtypes.Actor{
+		ID:         nw.ID(),
+		Attributes: attributes,
+	}
+	daemon.EventsService.Log(action, eventtypes.NetworkEventType, actor)
+}
+
+// LogDaemonEventWithAttributes generates an event related to the daemon
+// itself with specific given attributes. The name of the daemon is always
+// part of the attributes.
+func (daemon *Daemon) LogDaemonEventWithAttributes(action string, attributes map[string]string) {
+	if hostname, err := os.Hostname(); err == nil {
+		attributes["name"] = hostname
+	}
+
+	actor := eventtypes.Actor{
+		ID:         daemon.ID,
+		Attributes: attributes,
+	}
+	daemon.EventsService.Log(action, eventtypes.DaemonEventType, actor)
+}
+
+// copyAttributes guarantees that labels are not mutated by event triggers.
+func copyAttributes(attributes, labels map[string]string) {
+	if labels == nil {
+		return
+	}
+	for k, v := range labels {
+		attributes[k] = v
+	}
 }
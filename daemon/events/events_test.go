@@ -5,7 +5,7 @@ import (
 	"testing"
 	"time"
 
-	"github.com/docker/docker/pkg/jsonmessage"
+	eventtypes "github.com/docker/docker/api/types/events"
 )
 
 func TestEventsLog(t *testing.T) {
@@ -18,10 +18,14 @@ func TestEventsLog(t *testing.T) {
 	if count != 2 {
 		t.Fatalf("Must be 2 subscribers, got %d", count)
 	}
-	e.Log("test", "cont", "image")
+	actor := eventtypes.Actor{
+		ID:         "cont",
+		Attributes: map[string]string{"image": "image"},
+	}
+	e.Log("test", eventtypes.ContainerEventType, actor)
 	select {
 	case msg := <-l1:
-		jmsg, ok := msg.(*jsonmessage.JSONMessage)
+		jmsg, ok := msg.(eventtypes.Message)
 		if !ok {
 			t.Fatalf("Unexpected type %T", msg)
 		}
@@ -42,7 +46,7 @@ func TestEventsLog(t *testing.T) {
 	}
 	select {
 	case msg := <-l2:
-		jmsg, ok := msg.(*jsonmessage.JSONMessage)
+		jmsg, ok := msg.(eventtypes.Message)
 		if !ok {
 			t.Fatalf("Unexpected type %T", msg)
 		}
@@ -70,7 +74,7 @@ func TestEventsLogTimeout(t *testing.T) {
 
 	c := make(chan struct{})
 	go func() {
-		e.Log("test", "cont", "image")
+		e.Log("test", eventtypes.ImageEventType, eventtypes.Actor{ID: "image"})
 		close(c)
 	}()
 
@@ -88,7 +92,12 @@ func TestLogEvents(t *testing.T) {
 		action := fmt.Sprintf("action_%d", i)
 		id := fmt.Sprintf("cont_%d", i)
 		from := fmt.Sprintf("image_%d", i)
-		e.Log(action, id, from)
+
+		actor := eventtypes.Actor{
+			ID:         id,
+			Attributes: map[string]string{"image": from},
+		}
+		e.Log(action, eventtypes.ContainerEventType, actor)
 	}
 	time.Sleep(50 * time.Millisecond)
 	current, l, _ := e.Subscribe()
@@ -97,16 +106,21 @@ func TestLogEvents(t *testing.T) {
 		action := fmt.Sprintf("action_%d", num)
 		id := fmt.Sprintf("cont_%d", num)
 		from := fmt.Sprintf("image_%d", num)
-		e.Log(action, id, from)
+
+		actor := eventtypes.Actor{
+			ID:         id,
+			Attributes: map[string]string{"image": from},
+		}
+		e.Log(action, eventtypes.ContainerEventType, actor)
 	}
 	if len(e.events) != eventsLimit {
 		t.Fatalf("Must be %d events, got %d", eventsLimit, len(e.events))
 	}
 
-	var msgs []*jsonmessage.JSONMessage
+	var msgs []eventtypes.Message
 	for len(msgs) < 10 {
 		m := <-l
-		jm, ok := (m).(*jsonmessage.JSONMessage)
+		jm, ok := (m).(eventtypes.Message)
 		if !ok {
 			t.Fatalf("Unexpected type %T", m)
 		}
@@ -133,3 +147,36 @@ func TestLogEvents(t *testing.T) {
 		t.Fatalf("Last action is %s, must be action_89", lastC.Status)
 	}
 }
+
+func TestLogLegacyFields(t *testing.T) {
+	e := New()
+
+	e.Log("create", eventtypes.ContainerEventType, eventtypes.Actor{
+		ID:         "cont",
+		Attributes: map[string]string{"image": "busybox"},
+	})
+	e.Log("tag", eventtypes.ImageEventType, eventtypes.Actor{ID: "busybox:latest"})
+	e.Log("create", eventtypes.VolumeEventType, eventtypes.Actor{ID: "vol"})
+
+	current, l, _ := e.Subscribe()
+	defer e.Evict(l)
+	if len(current) != 3 {
+		t.Fatalf("Must be 3 events, got %d", len(current))
+	}
+
+	container := current[0]
+	if container.Status != "create" || container.ID != "cont" || container.From != "busybox" {
+		t.Fatalf("Unexpected legacy fields for container event: %#v", container)
+	}
+	image := current[1]
+	if image.Status != "tag" || image.ID != "busybox:latest" || image.From != "" {
+		t.Fatalf("Unexpected legacy fields for image event: %#v", image)
+	}
+	volume := current[2]
+	if volume.Status != "" || volume.ID != "" || volume.From != "" {
+		t.Fatalf("Volume events must not have legacy fields: %#v", volume)
+	}
+	if volume.Type != eventtypes.VolumeEventType || volume.Action != "create" || volume.Actor.ID != "vol" {
+		t.Fatalf("Unexpected volume event: %#v", volume)
+	}
+}
@@ -1,51 +1,113 @@
 package events
 
 import (
-	"github.com/docker/docker/pkg/jsonmessage"
+	eventtypes "github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/pkg/parsers/filters"
 )
 
 // Filter can filter out docker events from a stream
 type Filter struct {
-	filter    filters.Args
-	getLabels func(id string) map[string]string
+	filter filters.Args
 }
 
 // NewFilter creates a new Filter
-func NewFilter(filter filters.Args, getLabels func(id string) map[string]string) *Filter {
-	return &Filter{filter: filter, getLabels: getLabels}
+func NewFilter(filter filters.Args) *Filter {
+	return &Filter{filter: filter}
 }
 
 // Include returns true when the event ev is included by the filters
-func (ef *Filter) Include(ev *jsonmessage.JSONMessage) bool {
-	return isFieldIncluded(ev.Status, ef.filter["event"]) &&
-		isFieldIncluded(ev.ID, ef.filter["container"]) &&
-		ef.isImageIncluded(ev.ID, ev.From) &&
-		ef.isLabelFieldIncluded(ev.ID)
+func (ef *Filter) Include(ev eventtypes.Message) bool {
+	return isFieldIncluded(ev.Action, ef.filter["event"]) &&
+		isFieldIncluded(ev.Type, ef.filter["type"]) &&
+		ef.isContainerIncluded(ev) &&
+		ef.isImageIncluded(ev) &&
+		ef.isVolumeIncluded(ev) &&
+		ef.isNetworkIncluded(ev) &&
+		ef.isDaemonIncluded(ev) &&
+		ef.isLabelFieldIncluded(ev.Actor.Attributes)
 }
 
-func (ef *Filter) isLabelFieldIncluded(id string) bool {
+func (ef *Filter) isLabelFieldIncluded(attributes map[string]string) bool {
 	if _, ok := ef.filter["label"]; !ok {
 		return true
 	}
-	return ef.filter.MatchKVList("label", ef.getLabels(id))
+	return ef.filter.MatchKVList("label", attributes)
 }
 
-// The image filter will be matched against both event.ID (for image events)
-// and event.From (for container events), so that any container that was created
+// The container filter is matched against the actor of container events
+// and against the container that network events refer to.
+func (ef *Filter) isContainerIncluded(ev eventtypes.Message) bool {
+	if len(ef.filter["container"]) == 0 {
+		return true
+	}
+	switch ev.Type {
+	case eventtypes.ContainerEventType:
+		return isFieldMatched(ev.Actor.ID, ef.filter["container"])
+	case eventtypes.NetworkEventType:
+		return isFieldMatched(ev.Actor.Attributes["container"], ef.filter["container"])
+	}
+	return false
+}
+
+func (ef *Filter) isVolumeIncluded(ev eventtypes.Message) bool {
+	if len(ef.filter["volume"]) == 0 {
+		return true
+	}
+	return ev.Type == eventtypes.VolumeEventType &&
+		isFieldMatched(ev.Actor.ID, ef.filter["volume"])
+}
+
+// The network filter is matched against both the ID and the name of the
+// network.
+func (ef *Filter) isNetworkIncluded(ev eventtypes.Message) bool {
+	if len(ef.filter["network"]) == 0 {
+		return true
+	}
+	return ev.Type == eventtypes.NetworkEventType &&
+		(isFieldMatched(ev.Actor.ID, ef.filter["network"]) ||
+			isFieldMatched(ev.Actor.Attributes["name"], ef.filter["network"]))
+}
+
+// The daemon filter is matched against both the ID and the name of the
+// daemon.
+func (ef *Filter) isDaemonIncluded(ev eventtypes.Message) bool {
+	if len(ef.filter["daemon"]) == 0 {
+		return true
+	}
+	return ev.Type == eventtypes.DaemonEventType &&
+		(isFieldMatched(ev.Actor.ID, ef.filter["daemon"]) ||
+			isFieldMatched(ev.Actor.Attributes["name"], ef.filter["daemon"]))
+}
+
+// The image filter will be matched against both the ID of image events
+// and the image of container events, so that any container that was created
 // from an image will be included in the image events. Also compare both
 // against the stripped repo name without any tags.
-func (ef *Filter) isImageIncluded(eventID string, eventFrom string) bool {
-	stripTag := func(image string) string {
-		repo, _ := parsers.ParseRepositoryTag(image)
-		return repo
+func (ef *Filter) isImageIncluded(ev eventtypes.Message) bool {
+	if len(ef.filter["image"]) == 0 {
+		return true
+	}
+
+	var image string
+	switch ev.Type {
+	case eventtypes.ImageEventType:
+		image = ev.Actor.ID
+	case eventtypes.ContainerEventType:
+		image = ev.Actor.Attributes["image"]
+	default:
+		return false
 	}
 
-	return isFieldIncluded(eventID, ef.filter["image"]) ||
-		isFieldIncluded(eventFrom, ef.filter["image"]) ||
-		isFieldIncluded(stripTag(eventID), ef.filter["image"]) ||
-		isFieldIncluded(stripTag(eventFrom), ef.filter["image"])
+	repo, _ := parsers.ParseRepositoryTag(image)
+	return isFieldMatched(image, ef.filter["image"]) ||
+		isFieldMatched(repo, ef.filter["image"])
+}
+
+// isFieldMatched returns true when field is one of the values of filter.
+// Unlike isFieldIncluded, an empty field never matches.
+func isFieldMatched(field string, filter []string) bool {
+	return field != "" && isFieldIncluded(field, filter)
 }
 
 func isFieldIncluded(field string, filter []string) bool {
@@ -0,0 +1,92 @@
+package events
+
+import (
+	"testing"
+
+	eventtypes "github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/pkg/parsers/filters"
+)
+
+func TestFilterInclude(t *testing.T) {
+	containerEvent := eventtypes.Message{
+		Type:   eventtypes.ContainerEventType,
+		Action: "start",
+		Actor: eventtypes.Actor{
+			ID:         "container_id",
+			Attributes: map[string]string{"image": "busybox:latest", "com.example.label": "yes"},
+		},
+	}
+	imageEvent := eventtypes.Message{
+		Type:   eventtypes.ImageEventType,
+		Action: "tag",
+		Actor:  eventtypes.Actor{ID: "ubuntu:15.10"},
+	}
+	volumeEvent := eventtypes.Message{
+		Type:   eventtypes.VolumeEventType,
+		Action: "create",
+		Actor: eventtypes.Actor{
+			ID:         "volume_id",
+			Attributes: map[string]string{"driver": "local"},
+		},
+	}
+	networkCreateEvent := eventtypes.Message{
+		Type:   eventtypes.NetworkEventType,
+		Action: "create",
+		Actor: eventtypes.Actor{
+			ID:         "network_id",
+			Attributes: map[string]string{"name": "mynet", "type": "bridge"},
+		},
+	}
+	networkConnectEvent := eventtypes.Message{
+		Type:   eventtypes.NetworkEventType,
+		Action: "connect",
+		Actor: eventtypes.Actor{
+			ID:         "network_id",
+			Attributes: map[string]string{"container": "container_id", "name": "mynet", "type": "bridge"},
+		},
+	}
+	daemonEvent := eventtypes.Message{
+		Type:   eventtypes.DaemonEventType,
+		Action: "reload",
+		Actor: eventtypes.Actor{
+			ID:         "daemon_id",
+			Attributes: map[string]string{"name": "daemon_name"},
+		},
+	}
+	all := []eventtypes.Message{containerEvent, imageEvent, volumeEvent, networkCreateEvent, networkConnectEvent, daemonEvent}
+
+	cases := []struct {
+		filter   filters.Args
+		included []eventtypes.Message
+	}{
+		{filters.Args{}, all},
+		{filters.Args{"type": {"volume"}}, []eventtypes.Message{volumeEvent}},
+		{filters.Args{"type": {"container", "image"}}, []eventtypes.Message{containerEvent, imageEvent}},
+		{filters.Args{"event": {"create"}}, []eventtypes.Message{volumeEvent, networkCreateEvent}},
+		{filters.Args{"container": {"container_id"}}, []eventtypes.Message{containerEvent, networkConnectEvent}},
+		{filters.Args{"image": {"busybox"}}, []eventtypes.Message{containerEvent}},
+		{filters.Args{"image": {"ubuntu:15.10"}}, []eventtypes.Message{imageEvent}},
+		{filters.Args{"volume": {"volume_id"}}, []eventtypes.Message{volumeEvent}},
+		{filters.Args{"network": {"network_id"}}, []eventtypes.Message{networkCreateEvent, networkConnectEvent}},
+		{filters.Args{"network": {"mynet"}}, []eventtypes.Message{networkCreateEvent, networkConnectEvent}},
+		{filters.Args{"network": {"network_id"}, "event": {"connect"}}, []eventtypes.Message{networkConnectEvent}},
+		{filters.Args{"daemon": {"daemon_id"}}, []eventtypes.Message{daemonEvent}},
+		{filters.Args{"daemon": {"daemon_name"}}, []eventtypes.Message{daemonEvent}},
+		{filters.Args{"label": {"com.example.label=yes"}}, []eventtypes.Message{containerEvent}},
+	}
+
+	for _, c := range cases {
+		ef := NewFilter(c.filter)
+		for _, ev := range all {
+			expected := false
+			for _, inc := range c.included {
+				if inc.Type == ev.Type && inc.Action == ev.Action {
+					expected = true
+				}
+			}
+			if ef.Include(ev) != expected {
+				t.Fatalf("Filter %v: expected inclusion of %s %s to be %v", c.filter, ev.Type, ev.Action, expected)
+			}
+		}
+	}
+}
@@ -34,6 +34,8 @@ type ExecConfig struct {
 	OpenStdout bool
 	Container  *Container
 	canRemove  bool
+	// pid is the process of the command on the host, once it is started
+	pid int
 
 	// waitStart will be closed immediately after the exec is really started.
 	waitStart chan struct{}
@@ -335,6 +337,9 @@ func (d *Daemon) containerExec(container *Container, ec *ExecConfig) error {
 				c.Close()
 			}
 		}
+		ec.Lock()
+		ec.pid = pid
+		ec.Unlock()
 		close(ec.waitStart)
 		return nil
 	}
@@ -3,6 +3,8 @@
 package daemon
 
 import (
+	"syscall"
+
 	"github.com/docker/docker/daemon/execdriver"
 	"github.com/docker/docker/runconfig"
 )
@@ -18,3 +20,13 @@ func setPlatformSpecificExecProcessConfig(config *runconfig.ExecConfig, containe
 	pc.User = user
 	pc.Privileged = config.Privileged
 }
+
+// kill kills the process of the exec command, if it is running.
+func (ec *ExecConfig) kill() error {
+	ec.Lock()
+	defer ec.Unlock()
+	if !ec.Running || ec.pid == 0 {
+		return nil
+	}
+	return syscall.Kill(ec.pid, syscall.SIGKILL)
+}
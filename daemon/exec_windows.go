@@ -9,3 +9,8 @@ import (
 // ProcessConfig structure. This is a no-op on Windows
 func setPlatformSpecificExecProcessConfig(config *runconfig.ExecConfig, container *Container, pc *execdriver.ProcessConfig) {
 }
+
+// kill is a no-op on Windows, where the exec commands are left to finish.
+func (ec *ExecConfig) kill() error {
+	return nil
+}
@@ -59,6 +59,11 @@ type Driver interface {
 	// the exit code. It's the last stage on Docker side for running a container.
 	Run(c *Command, pipes *Pipes, hooks Hooks) (ExitStatus, error)
 
+	// Restore reattaches to a container which was left running by a
+	// previous daemon, blocks until its process exits and returns the exit
+	// code, like Run does for a container it started itself.
+	Restore(c *Command, pipes *Pipes, hooks Hooks) (ExitStatus, error)
+
 	// Exec executes the process in an existing container, blocks until the
 	// process exits and returns the exit code.
 	Exec(c *Command, processConfig *ProcessConfig, pipes *Pipes, hooks Hooks) (int, error)
@@ -72,6 +77,10 @@ type Driver interface {
 	// Unpause unpauses a container.
 	Unpause(c *Command) error
 
+	// Update updates the resource limits of a running container to those
+	// of its Command.
+	Update(c *Command) error
+
 	// Name returns the name of the driver.
 	Name() string
 
@@ -110,6 +119,7 @@ type ResourceStats struct {
 	Read        time.Time `json:"read"`
 	MemoryLimit int64     `json:"memory_limit"`
 	SystemUsage uint64    `json:"system_usage"`
+	PidsCurrent uint64    `json:"pids_current"`
 }
 
 // CommonProcessConfig is the common platform agnostic part of the ProcessConfig
@@ -28,6 +28,7 @@ type Mount struct {
 	Writable    bool   `json:"writable"`
 	Private     bool   `json:"private"`
 	Slave       bool   `json:"slave"`
+	Data        string `json:"data"`
 }
 
 // Resources contains all resource configs for a driver.
@@ -47,6 +48,7 @@ type Resources struct {
 	Rlimits           []*ulimit.Rlimit         `json:"rlimits"`
 	OomKillDisable    bool                     `json:"oom_kill_disable"`
 	MemorySwappiness  int64                    `json:"memory_swappiness"`
+	PidsLimit         int64                    `json:"pids_limit"`
 }
 
 // ProcessConfig is the platform specific structure that describes a process
@@ -113,9 +115,12 @@ type Command struct {
 	GIDMapping         []idtools.IDMap   `json:"gidmapping"`
 	GroupAdd           []string          `json:"group_add"`
 	Ipc                *Ipc              `json:"ipc"`
+	LiveRestore        bool              `json:"live_restore"` // keep the container running while the daemon is down
 	Pid                *Pid              `json:"pid"`
 	ReadonlyRootfs     bool              `json:"readonly_rootfs"`
 	RemappedRoot       *User             `json:"remap_root"`
+	SeccompProfile     string            `json:"seccomp_profile"`
+	Sysctls            map[string]string `json:"sysctls"`
 	UIDMapping         []idtools.IDMap   `json:"uidmapping"`
 	UTS                *UTS              `json:"uts"`
 }
@@ -8,6 +8,7 @@ import (
 	"syscall"
 
 	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/pkg/mount"
 
 	"github.com/opencontainers/runc/libcontainer/apparmor"
 	"github.com/opencontainers/runc/libcontainer/configs"
@@ -74,11 +75,16 @@ func (d *Driver) createContainer(c *execdriver.Command, hooks execdriver.Hooks)
 		}
 	}
 	container.AdditionalGroups = c.GroupAdd
+	container.Sysctl = c.Sysctls
 
 	if c.AppArmorProfile != "" {
 		container.AppArmorProfile = c.AppArmorProfile
 	}
 
+	if err := d.setupSeccomp(container, c); err != nil {
+		return nil, err
+	}
+
 	if err := execdriver.SetupCgroups(container, c); err != nil {
 		return nil, err
 	}
@@ -267,9 +273,21 @@ func (d *Driver) setupRlimits(container *configs.Config, c *execdriver.Command)
 	}
 }
 
+const (
+	// defaultTmpfsFlags are the mount flags of the tmpfs mounts which
+	// don't specify any option.
+	defaultTmpfsFlags = syscall.MS_NOEXEC | syscall.MS_NOSUID | syscall.MS_NODEV
+	// defaultTmpfsData is the mount data of the tmpfs mounts which don't
+	// specify any option.
+	defaultTmpfsData = "size=65536k"
+)
+
 func (d *Driver) setupMounts(container *configs.Config, c *execdriver.Command) error {
 	userMounts := make(map[string]struct{})
 	for _, m := range c.Mounts {
+		if _, exists := userMounts[m.Destination]; exists && m.Source == "tmpfs" {
+			return fmt.Errorf("Duplicate mount point '%s'", m.Destination)
+		}
 		userMounts[m.Destination] = struct{}{}
 	}
 
@@ -287,6 +305,26 @@ func (d *Driver) setupMounts(container *configs.Config, c *execdriver.Command) e
 	container.Mounts = defaultMounts
 
 	for _, m := range c.Mounts {
+		if m.Source == "tmpfs" {
+			flags := defaultTmpfsFlags
+			data := defaultTmpfsData
+			if m.Data != "" {
+				var err error
+				flags, data, err = mount.ParseTmpfsOptions(m.Data)
+				if err != nil {
+					return err
+				}
+			}
+			container.Mounts = append(container.Mounts, &configs.Mount{
+				Source:      m.Source,
+				Destination: m.Destination,
+				Data:        data,
+				Device:      "tmpfs",
+				Flags:       flags,
+			})
+			continue
+		}
+
 		flags := syscall.MS_BIND | syscall.MS_REC
 		if !m.Writable {
 			flags |= syscall.MS_RDONLY
@@ -39,9 +39,12 @@ const (
 type Driver struct {
 	root             string
 	initPath         string
+	options          []string
 	activeContainers map[string]libcontainer.Container
 	machineMemory    int64
 	factory          libcontainer.Factory
+	// pidsLimits are the pids limits of the containers being started
+	pidsLimits map[string]int64
 	sync.Mutex
 }
 
@@ -70,19 +73,38 @@ func NewDriver(root, initPath string, options []string) (*Driver, error) {
 		}
 	}
 
+	d := &Driver{
+		root:             root,
+		initPath:         initPath,
+		options:          options,
+		activeContainers: make(map[string]libcontainer.Container),
+		machineMemory:    meminfo.MemTotal,
+		pidsLimits:       make(map[string]int64),
+	}
+	if err := d.setupFactory(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// setupFactory creates the libcontainer factory of the driver, with the
+// cgroup manager chosen by the options of the driver.
+func (d *Driver) setupFactory() error {
 	// choose cgroup manager
 	// this makes sure there are no breaking changes to people
 	// who upgrade from versions without native.cgroupdriver opt
 	cgm := libcontainer.Cgroupfs
-	if systemd.UseSystemd() {
+	useSystemd := systemd.UseSystemd()
+	if useSystemd {
 		cgm = libcontainer.SystemdCgroups
 	}
 
 	// parse the options
-	for _, option := range options {
+	for _, option := range d.options {
 		key, val, err := parsers.ParseKeyValueOpt(option)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		key = strings.ToLower(key)
 		switch key {
@@ -92,36 +114,32 @@ func NewDriver(root, initPath string, options []string) (*Driver, error) {
 			case "systemd":
 				if systemd.UseSystemd() {
 					cgm = libcontainer.SystemdCgroups
+					useSystemd = true
 				} else {
 					// warn them that they chose the wrong driver
 					logrus.Warn("You cannot use systemd as native.cgroupdriver, using cgroupfs instead")
 				}
 			case "cgroupfs":
 				cgm = libcontainer.Cgroupfs
+				useSystemd = false
 			default:
-				return nil, fmt.Errorf("Unknown native.cgroupdriver given %q. try cgroupfs or systemd", val)
+				return fmt.Errorf("Unknown native.cgroupdriver given %q. try cgroupfs or systemd", val)
 			}
 		default:
-			return nil, fmt.Errorf("Unknown option %s\n", key)
+			return fmt.Errorf("Unknown option %s\n", key)
 		}
 	}
 
 	f, err := libcontainer.New(
-		root,
-		cgm,
+		d.root,
+		d.pidsCgroups(cgm, useSystemd),
 		libcontainer.InitPath(reexec.Self(), DriverName),
 	)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	return &Driver{
-		root:             root,
-		initPath:         initPath,
-		activeContainers: make(map[string]libcontainer.Container),
-		machineMemory:    meminfo.MemTotal,
-		factory:          f,
-	}, nil
+	d.factory = f
+	return nil
 }
 
 type execOutput struct {
@@ -146,12 +164,18 @@ func (d *Driver) Run(c *execdriver.Command, pipes *execdriver.Pipes, hooks execd
 		User: c.ProcessConfig.User,
 	}
 
+	if c.LiveRestore {
+		return d.runShim(c, container, p, pipes, hooks)
+	}
+
 	if err := setupPipes(container, &c.ProcessConfig, p, pipes); err != nil {
 		return execdriver.ExitStatus{ExitCode: -1}, err
 	}
 
+	d.setPidsLimit(c.ID, c.Resources.PidsLimit)
 	cont, err := d.factory.Create(c.ID, container)
 	if err != nil {
+		d.setPidsLimit(c.ID, 0)
 		return execdriver.ExitStatus{ExitCode: -1}, err
 	}
 	d.Lock()
@@ -302,6 +326,42 @@ func (d *Driver) Unpause(c *execdriver.Command) error {
 	return active.Resume()
 }
 
+// Update implements the exec driver Driver interface,
+// it applies the resources of the command to the cgroups of the running container.
+func (d *Driver) Update(c *execdriver.Command) error {
+	d.Lock()
+	active := d.activeContainers[c.ID]
+	d.Unlock()
+	if active == nil {
+		return execdriver.ErrNotRunning
+	}
+
+	config := active.Config()
+	// work on a copy of the cgroup config so that a failed update doesn't
+	// leave the container with a configuration that was never applied.
+	cgroup := *config.Cgroups
+	config.Cgroups = &cgroup
+	currentSwap := cgroup.MemorySwap
+	if err := execdriver.SetupCgroups(&config, c); err != nil {
+		return err
+	}
+
+	// The kernel refuses a memory limit above the memory+swap limit and
+	// libcontainer writes the memory limit first, so when both are raised
+	// the memory+swap limit has to be applied on its own beforehand.
+	if currentSwap > 0 && cgroup.MemorySwap > 0 && cgroup.Memory > currentSwap {
+		swapOnly := config
+		swapCgroup := cgroup
+		swapCgroup.Memory = 0
+		swapOnly.Cgroups = &swapCgroup
+		if err := active.Set(swapOnly); err != nil {
+			return err
+		}
+	}
+
+	return active.Set(config)
+}
+
 // Terminate implements the exec driver Driver interface.
 func (d *Driver) Terminate(c *execdriver.Command) error {
 	defer d.cleanContainer(c.ID)
@@ -354,6 +414,7 @@ func (d *Driver) GetPidsForContainer(id string) ([]int, error) {
 func (d *Driver) cleanContainer(id string) error {
 	d.Lock()
 	delete(d.activeContainers, id)
+	delete(d.pidsLimits, id)
 	d.Unlock()
 	return os.RemoveAll(filepath.Join(d.root, id))
 }
@@ -380,6 +441,14 @@ func (d *Driver) Stats(id string) (*execdriver.ResourceStats, error) {
 	if err != nil {
 		return nil, err
 	}
+	state, err := c.State()
+	if err != nil {
+		return nil, err
+	}
+	pids, err := pidsCurrent(state.CgroupPaths)
+	if err != nil {
+		return nil, err
+	}
 	memoryLimit := c.Config().Cgroups.Memory
 	// if the container does not have any memory limit specified set the
 	// limit to the machines memory
@@ -390,6 +459,7 @@ func (d *Driver) Stats(id string) (*execdriver.ResourceStats, error) {
 		Stats:       stats,
 		Read:        now,
 		MemoryLimit: memoryLimit,
+		PidsCurrent: pids,
 	}, nil
 }
 
@@ -0,0 +1,193 @@
+// +build linux,cgo
+
+package native
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/opencontainers/runc/libcontainer"
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+// The vendored libcontainer doesn't know the pids cgroup controller, so the
+// driver puts the containers in a pids cgroup and applies their limit on
+// top of the cgroup manager of libcontainer.
+
+// pidsCgroups returns a libcontainer factory option which sets the cgroup
+// manager with cgm, and wraps it so that the containers also join a pids
+// cgroup limited to the limit the driver recorded for them. useSystemd is
+// whether cgm is the systemd cgroup manager, which names the cgroups of the
+// containers after their scope unit.
+func (d *Driver) pidsCgroups(cgm func(*libcontainer.LinuxFactory) error, useSystemd bool) func(*libcontainer.LinuxFactory) error {
+	return func(l *libcontainer.LinuxFactory) error {
+		if err := cgm(l); err != nil {
+			return err
+		}
+		newManager := l.NewCgroupsManager
+		l.NewCgroupsManager = func(config *configs.Cgroup, paths map[string]string) cgroups.Manager {
+			return &pidsManager{
+				Manager: newManager(config, paths),
+				cgroup:  config,
+				systemd: useSystemd,
+				limit:   d.pidsLimit(config.Name),
+				path:    paths["pids"],
+			}
+		}
+		return nil
+	}
+}
+
+func (d *Driver) setPidsLimit(id string, limit int64) {
+	d.Lock()
+	defer d.Unlock()
+	if limit == 0 {
+		delete(d.pidsLimits, id)
+		return
+	}
+	d.pidsLimits[id] = limit
+}
+
+func (d *Driver) pidsLimit(id string) int64 {
+	d.Lock()
+	defer d.Unlock()
+	return d.pidsLimits[id]
+}
+
+// pidsManager is a cgroup manager which also manages the pids cgroup of a
+// container.
+type pidsManager struct {
+	cgroups.Manager
+	cgroup  *configs.Cgroup
+	systemd bool
+	limit   int64
+	// path is the pids cgroup of the container, empty if it didn't join
+	// one
+	path string
+}
+
+// Apply puts the process pid in the cgroups of the container. It isn't an
+// error if the pids cgroup isn't mounted, unless there is a limit to apply.
+func (m *pidsManager) Apply(pid int) error {
+	if err := m.Manager.Apply(pid); err != nil {
+		return err
+	}
+	path, err := pidsCgroupPath(m.cgroup, m.systemd)
+	if err != nil {
+		if cgroups.IsNotFound(err) && m.limit == 0 {
+			return nil
+		}
+		return err
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return err
+	}
+	if m.limit != 0 {
+		limit := "max"
+		if m.limit > 0 {
+			limit = strconv.FormatInt(m.limit, 10)
+		}
+		if err := ioutil.WriteFile(filepath.Join(path, "pids.max"), []byte(limit), 0700); err != nil {
+			return err
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(path, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0700); err != nil {
+		return err
+	}
+	m.path = path
+	return nil
+}
+
+// GetPaths returns the cgroups of the container, so that the pids cgroup is
+// saved in the state of the container and found again on restore.
+func (m *pidsManager) GetPaths() map[string]string {
+	paths := make(map[string]string)
+	for subsystem, path := range m.Manager.GetPaths() {
+		paths[subsystem] = path
+	}
+	if m.path != "" {
+		paths["pids"] = m.path
+	}
+	return paths
+}
+
+// Destroy removes the cgroups of the container.
+func (m *pidsManager) Destroy() error {
+	if err := m.Manager.Destroy(); err != nil {
+		return err
+	}
+	if m.path == "" {
+		return nil
+	}
+	return cgroups.RemovePaths(map[string]string{"pids": m.path})
+}
+
+// pidsCgroupPath returns the pids cgroup of a container, found the same way
+// as its other cgroups by the cgroup manager of libcontainer, the systemd
+// one if useSystemd.
+func pidsCgroupPath(c *configs.Cgroup, useSystemd bool) (string, error) {
+	if useSystemd {
+		mnt, err := cgroups.FindCgroupMountpoint("pids")
+		if err != nil {
+			return "", err
+		}
+		initPath, err := cgroups.GetInitCgroupDir("pids")
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(mnt, initPath, systemdScopeCgroup(c)), nil
+	}
+
+	mnt, root, err := cgroups.FindCgroupMountpointAndRoot("pids")
+	if err != nil {
+		return "", err
+	}
+
+	cgroup := c.Name
+	if c.Parent != "" {
+		cgroup = filepath.Join(c.Parent, cgroup)
+	}
+	if filepath.IsAbs(cgroup) {
+		return filepath.Join(mnt, cgroup), nil
+	}
+
+	initPath, err := cgroups.GetThisCgroupDir("pids")
+	if err != nil {
+		return "", err
+	}
+	relDir, err := filepath.Rel(root, initPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(mnt, relDir, cgroup), nil
+}
+
+// systemdScopeCgroup returns the cgroup of the scope unit the systemd cgroup
+// manager of libcontainer puts a container in, relative to the cgroup of
+// systemd.
+func systemdScopeCgroup(c *configs.Cgroup) string {
+	slice := "system.slice"
+	if c.Slice != "" {
+		slice = c.Slice
+	}
+	return filepath.Join(slice, fmt.Sprintf("%s-%s.scope", c.Parent, c.Name))
+}
+
+// pidsCurrent returns the number of processes in the pids cgroup of the
+// container whose cgroups are paths, zero if it isn't in one.
+func pidsCurrent(paths map[string]string) (uint64, error) {
+	path, ok := paths["pids"]
+	if !ok {
+		return 0, nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(path, "pids.current"))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
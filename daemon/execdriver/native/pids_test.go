@@ -0,0 +1,21 @@
+// +build linux,cgo
+
+package native
+
+import (
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+func TestSystemdScopeCgroup(t *testing.T) {
+	c := &configs.Cgroup{Name: "abcdef", Parent: "docker"}
+	if cgroup := systemdScopeCgroup(c); cgroup != "system.slice/docker-abcdef.scope" {
+		t.Fatalf("Expected the scope of the container in the system slice, got %s", cgroup)
+	}
+
+	c.Slice = "machine.slice"
+	if cgroup := systemdScopeCgroup(c); cgroup != "machine.slice/docker-abcdef.scope" {
+		t.Fatalf("Expected the scope of the container in its slice, got %s", cgroup)
+	}
+}
@@ -0,0 +1,220 @@
+// +build linux,cgo
+
+package native
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/opencontainers/runc/libcontainer"
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+const (
+	stdinFifo  = "stdin"
+	stdoutFifo = "stdout"
+	stderrFifo = "stderr"
+
+	// restorePollPeriod is how often a restored container is checked for
+	// the exit status of its process
+	restorePollPeriod = 100 * time.Millisecond
+)
+
+// errUnknownExitStatus is returned when the shim of a restored container
+// exited without recording the exit status of its process.
+var errUnknownExitStatus = errors.New("the shim of the container exited without recording the exit status of its process")
+
+// Restore implements the exec driver Driver interface,
+// it reattaches to a container which was started with live restore
+// enabled and kept running while the daemon was down.
+func (d *Driver) Restore(c *execdriver.Command, pipes *execdriver.Pipes, hooks execdriver.Hooks) (execdriver.ExitStatus, error) {
+	root := filepath.Join(d.root, c.ID)
+	shim, err := readShimState(root)
+	if err != nil {
+		return execdriver.ExitStatus{ExitCode: -1}, fmt.Errorf("container %s was not started with live restore enabled", c.ID)
+	}
+
+	cont, err := d.factory.Load(c.ID)
+	if err != nil {
+		return execdriver.ExitStatus{ExitCode: -1}, err
+	}
+	status, err := cont.Status()
+	if err != nil {
+		return execdriver.ExitStatus{ExitCode: -1}, err
+	}
+	if status == libcontainer.Destroyed {
+		return execdriver.ExitStatus{ExitCode: -1}, execdriver.ErrNotRunning
+	}
+	state, err := cont.State()
+	if err != nil {
+		return execdriver.ExitStatus{ExitCode: -1}, err
+	}
+
+	d.Lock()
+	d.activeContainers[c.ID] = cont
+	d.Unlock()
+	defer func() {
+		cont.Destroy()
+		d.cleanContainer(c.ID)
+	}()
+
+	// stdin is closed along with the previous daemon, only the output of
+	// the container is reattached
+	if err := attachFifos(root, &c.ProcessConfig, &execdriver.Pipes{Stdout: pipes.Stdout, Stderr: pipes.Stderr}); err != nil {
+		return execdriver.ExitStatus{ExitCode: -1}, err
+	}
+
+	oom := notifyOnOOM(cont)
+	if hooks.Start != nil {
+		hooks.Start(&c.ProcessConfig, state.InitProcessPid, oom)
+	}
+
+	exitCode, err := waitExitStatus(root, shim, state.InitProcessPid, state.InitProcessStartTime)
+	if err != nil {
+		return execdriver.ExitStatus{ExitCode: -1}, err
+	}
+	// destroying the container also kills the processes left behind in the
+	// cgroup when the container shares the pid namespace of the host
+	cont.Destroy()
+	_, oomKill := <-oom
+	return execdriver.ExitStatus{ExitCode: exitCode, OOMKilled: oomKill}, nil
+}
+
+// createFifos connects the stdio of the container process to named pipes
+// in the state directory root of the container instead of anonymous pipes.
+// Unlike anonymous pipes they survive the daemon, so the process keeps
+// running when the daemon stops and a new daemon can reopen them to collect
+// its output. The returned files are the ends handed to the process, they
+// have to be closed once the process has been started.
+func createFifos(root string, container *configs.Config, p *libcontainer.Process, stdin bool) (childFiles []*os.File, err error) {
+	rootuid, err := container.HostUID()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			for _, f := range childFiles {
+				f.Close()
+			}
+		}
+	}()
+
+	names := []string{stdoutFifo, stderrFifo}
+	if stdin {
+		names = append(names, stdinFifo)
+	}
+	for _, name := range names {
+		path := filepath.Join(root, name)
+		if err := syscall.Mkfifo(path, 0600); err != nil {
+			return nil, fmt.Errorf("Failed to create %s fifo: %v", name, err)
+		}
+		if err := os.Chown(path, rootuid, rootuid); err != nil {
+			return nil, fmt.Errorf("Failed to chown %s fifo: %v", name, err)
+		}
+	}
+
+	// the process opens its outputs for reading as well, so that writing
+	// to them blocks instead of raising SIGPIPE while no daemon reads them
+	stdout, err := os.OpenFile(filepath.Join(root, stdoutFifo), os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	childFiles = append(childFiles, stdout)
+	p.Stdout = stdout
+
+	stderr, err := os.OpenFile(filepath.Join(root, stderrFifo), os.O_RDWR, 0)
+	if err != nil {
+		return childFiles, err
+	}
+	childFiles = append(childFiles, stderr)
+	p.Stderr = stderr
+
+	if stdin {
+		stdin, err := os.OpenFile(filepath.Join(root, stdinFifo), os.O_RDONLY|syscall.O_NONBLOCK, 0)
+		if err != nil {
+			return childFiles, err
+		}
+		childFiles = append(childFiles, stdin)
+		p.Stdin = stdin
+	}
+	return childFiles, nil
+}
+
+// attachFifos connects the pipes of the daemon to the fifos of the
+// container whose state directory is root.
+func attachFifos(root string, processConfig *execdriver.ProcessConfig, pipes *execdriver.Pipes) (err error) {
+	term := &execdriver.StdConsole{}
+	processConfig.Terminal = term
+	defer func() {
+		if err != nil {
+			term.Close()
+		}
+	}()
+
+	if err := attachFifo(filepath.Join(root, stdoutFifo), pipes.Stdout, term); err != nil {
+		return err
+	}
+	if err := attachFifo(filepath.Join(root, stderrFifo), pipes.Stderr, term); err != nil {
+		return err
+	}
+	if pipes.Stdin != nil {
+		w, err := os.OpenFile(filepath.Join(root, stdinFifo), os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		go func() {
+			io.Copy(w, pipes.Stdin)
+			w.Close()
+		}()
+	}
+	return nil
+}
+
+// attachFifo copies what the container process writes to the fifo at path
+// to w until the process closes it.
+func attachFifo(path string, w io.Writer, term *execdriver.StdConsole) error {
+	// opening for reading in non-blocking mode doesn't wait for a writer
+	r, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	term.Closers = append(term.Closers, r)
+	if w != nil {
+		go io.Copy(w, r)
+	}
+	return nil
+}
+
+// waitExitStatus waits for the exit of the process of the container whose
+// state directory is root, and returns the exit status its shim recorded.
+// If the shim is gone without recording it, the exit status is unknown:
+// errUnknownExitStatus is returned once the process is gone too.
+func waitExitStatus(root string, shim *shimState, pid int, startTime string) (int, error) {
+	for {
+		exitCode, err := readExitStatus(root)
+		if err == nil {
+			return exitCode, nil
+		}
+		if !os.IsNotExist(err) {
+			return -1, err
+		}
+		if !shim.running() {
+			// the shim may have recorded it just before exiting
+			if exitCode, err := readExitStatus(root); err == nil {
+				return exitCode, nil
+			}
+			for processRunning(pid, startTime) {
+				time.Sleep(restorePollPeriod)
+			}
+			return -1, errUnknownExitStatus
+		}
+		time.Sleep(restorePollPeriod)
+	}
+}
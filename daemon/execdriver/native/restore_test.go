@@ -0,0 +1,162 @@
+// +build linux,cgo
+
+package native
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/system"
+)
+
+func TestWaitExitStatus(t *testing.T) {
+	root, err := ioutil.TempDir("", "native-exit-status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	startTime, err := system.GetProcessStartTime(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	shim := &shimState{Pid: os.Getpid(), StartTime: startTime}
+
+	// the exit status is read once the running shim records it
+	go func() {
+		time.Sleep(2 * restorePollPeriod)
+		writeExitStatus(root, 3)
+	}()
+	exitCode, err := waitExitStatus(root, shim, os.Getpid(), startTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 3 {
+		t.Fatalf("Expected exit code 3, got %d", exitCode)
+	}
+}
+
+func TestWaitExitStatusWithoutShim(t *testing.T) {
+	root, err := ioutil.TempDir("", "native-exit-status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	// neither the shim nor the process are running anymore, and no exit
+	// status was recorded: it must not be made up
+	shim := &shimState{Pid: cmd.Process.Pid, StartTime: "0"}
+	if _, err := waitExitStatus(root, shim, cmd.Process.Pid, "0"); err != errUnknownExitStatus {
+		t.Fatalf("Expected %v, got %v", errUnknownExitStatus, err)
+	}
+}
+
+func TestStartShim(t *testing.T) {
+	var hookState configs.HookState
+	config := &shimConfig{
+		ID: "test",
+		Config: &configs.Config{
+			Hooks: &configs.Hooks{
+				Prestart: []configs.Hook{
+					configs.NewFunctionHook(func(s configs.HookState) error {
+						hookState = s
+						return nil
+					}),
+				},
+			},
+		},
+		Prestart: true,
+	}
+
+	// the shim side of the exchange
+	daemonR, shimW := io.Pipe()
+	shimR, daemonW := io.Pipe()
+	go func() {
+		dec := json.NewDecoder(shimR)
+		enc := json.NewEncoder(shimW)
+		var received shimConfig
+		if err := dec.Decode(&received); err != nil || received.ID != "test" || !received.Prestart {
+			enc.Encode(shimMessage{Type: shimError, Error: fmt.Sprintf("unexpected config %+v: %v", received, err)})
+			return
+		}
+		enc.Encode(shimMessage{Type: shimPrestart, HookState: configs.HookState{ID: "test", Pid: 42}})
+		var reply shimMessage
+		if err := dec.Decode(&reply); err != nil || reply.Error != "" {
+			enc.Encode(shimMessage{Type: shimError, Error: fmt.Sprintf("unexpected reply %+v: %v", reply, err)})
+			return
+		}
+		enc.Encode(shimMessage{Type: shimStarted, Pid: 42})
+	}()
+
+	pid, err := startShim(config, daemonW, daemonR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid != 42 {
+		t.Fatalf("Expected pid 42, got %d", pid)
+	}
+	if hookState.Pid != 42 || hookState.ID != "test" {
+		t.Fatalf("Expected the prestart hook to run for the process, got %+v", hookState)
+	}
+}
+
+func TestAttachFifoWithoutReader(t *testing.T) {
+	root, err := ioutil.TempDir("", "native-fifos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	// no reader is attached: the child end of the fifo must still be
+	// writable without raising SIGPIPE
+	if err := syscall.Mkfifo(filepath.Join(root, stdoutFifo), 0600); err != nil {
+		t.Fatal(err)
+	}
+	w, err := os.OpenFile(filepath.Join(root, stdoutFifo), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	out := &closingBuffer{done: make(chan struct{})}
+	term := &execdriver.StdConsole{}
+	if err := attachFifo(filepath.Join(root, stdoutFifo), out, term); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	<-out.done
+	term.Close()
+	if got := string(out.data); got != "hello\n" {
+		t.Fatalf("Expected output %q, got %q", "hello\n", got)
+	}
+}
+
+// closingBuffer signals done once it received a line.
+type closingBuffer struct {
+	data []byte
+	done chan struct{}
+}
+
+func (b *closingBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	if len(b.data) > 0 && b.data[len(b.data)-1] == '\n' {
+		close(b.done)
+	}
+	return len(p), nil
+}
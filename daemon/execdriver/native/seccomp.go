@@ -0,0 +1,127 @@
+// +build linux,cgo
+
+package native
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/seccomp"
+)
+
+// seccompProfile is the JSON representation of a seccomp profile, as given
+// with --security-opt seccomp=profile.json. Actions, operators and
+// architectures use the names of the libseccomp header, e.g. SCMP_ACT_ALLOW.
+type seccompProfile struct {
+	DefaultAction string            `json:"defaultAction"`
+	Architectures []string          `json:"architectures"`
+	Syscalls      []*seccompSyscall `json:"syscalls"`
+}
+
+type seccompSyscall struct {
+	Name   string        `json:"name"`
+	Action string        `json:"action"`
+	Args   []*seccompArg `json:"args"`
+}
+
+type seccompArg struct {
+	Index    uint   `json:"index"`
+	Value    uint64 `json:"value"`
+	ValueTwo uint64 `json:"valueTwo"`
+	Op       string `json:"op"`
+}
+
+// seccompArches maps the architecture names of the libseccomp header to
+// the ones used by libcontainer.
+var seccompArches = map[string]string{
+	"SCMP_ARCH_X86":         "x86",
+	"SCMP_ARCH_X86_64":      "amd64",
+	"SCMP_ARCH_X32":         "x32",
+	"SCMP_ARCH_ARM":         "arm",
+	"SCMP_ARCH_AARCH64":     "arm64",
+	"SCMP_ARCH_MIPS":        "mips",
+	"SCMP_ARCH_MIPS64":      "mips64",
+	"SCMP_ARCH_MIPS64N32":   "mips64n32",
+	"SCMP_ARCH_MIPSEL":      "mipsel",
+	"SCMP_ARCH_MIPSEL64":    "mipsel64",
+	"SCMP_ARCH_MIPSEL64N32": "mipsel64n32",
+}
+
+// setupSeccomp sets the seccomp filter of the container. Containers get the
+// default profile, unless they are privileged or were given another one.
+// The "unconfined" profile disables the filtering.
+func (d *Driver) setupSeccomp(container *configs.Config, c *execdriver.Command) (err error) {
+	switch c.SeccompProfile {
+	case "":
+		if !c.ProcessConfig.Privileged {
+			container.Seccomp = getDefaultSeccompProfile()
+		}
+	case "unconfined":
+		container.Seccomp = nil
+	default:
+		container.Seccomp, err = loadSeccompProfile(c.SeccompProfile)
+	}
+	return err
+}
+
+// loadSeccompProfile converts a JSON seccomp profile to the libcontainer
+// configuration.
+func loadSeccompProfile(body string) (*configs.Seccomp, error) {
+	var profile seccompProfile
+	if err := json.Unmarshal([]byte(body), &profile); err != nil {
+		return nil, fmt.Errorf("Decoding seccomp profile failed: %v", err)
+	}
+
+	defaultAction, err := seccomp.ConvertStringToAction(profile.DefaultAction)
+	if err != nil {
+		return nil, err
+	}
+	config := &configs.Seccomp{
+		DefaultAction: defaultAction,
+		Architectures: []string{},
+		Syscalls:      []*configs.Syscall{},
+	}
+
+	for _, arch := range profile.Architectures {
+		a, ok := seccompArches[arch]
+		if !ok {
+			return nil, fmt.Errorf("string %s is not a valid architecture for seccomp", arch)
+		}
+		config.Architectures = append(config.Architectures, a)
+	}
+
+	for _, call := range profile.Syscalls {
+		if call == nil || call.Name == "" {
+			return nil, fmt.Errorf("seccomp profile contains a syscall without a name")
+		}
+		action, err := seccomp.ConvertStringToAction(call.Action)
+		if err != nil {
+			return nil, err
+		}
+		newCall := &configs.Syscall{
+			Name:   call.Name,
+			Action: action,
+			Args:   []*configs.Arg{},
+		}
+		for _, arg := range call.Args {
+			if arg == nil {
+				continue
+			}
+			op, err := seccomp.ConvertStringToOperator(arg.Op)
+			if err != nil {
+				return nil, err
+			}
+			newCall.Args = append(newCall.Args, &configs.Arg{
+				Index:    arg.Index,
+				Value:    arg.Value,
+				ValueTwo: arg.ValueTwo,
+				Op:       op,
+			})
+		}
+		config.Syscalls = append(config.Syscalls, newCall)
+	}
+
+	return config, nil
+}
@@ -0,0 +1,51 @@
+// +build linux,cgo,seccomp
+
+package native
+
+import (
+	"testing"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+func TestSetupSeccompDefaultProfile(t *testing.T) {
+	d := &Driver{}
+	container := &configs.Config{}
+	if err := d.setupSeccomp(container, &execdriver.Command{}); err != nil {
+		t.Fatal(err)
+	}
+	if container.Seccomp == nil {
+		t.Fatal("Expected the default seccomp profile to be set")
+	}
+	if container.Seccomp != getDefaultSeccompProfile() {
+		t.Fatalf("Expected the default seccomp profile, got %+v", container.Seccomp)
+	}
+	if container.Seccomp.DefaultAction != configs.Errno {
+		t.Fatalf("Expected the default action to be Errno, got %v", container.Seccomp.DefaultAction)
+	}
+}
+
+func TestSetupSeccompPrivileged(t *testing.T) {
+	d := &Driver{}
+	container := &configs.Config{}
+	c := &execdriver.Command{}
+	c.ProcessConfig.Privileged = true
+	if err := d.setupSeccomp(container, c); err != nil {
+		t.Fatal(err)
+	}
+	if container.Seccomp != nil {
+		t.Fatal("Expected privileged containers not to be filtered")
+	}
+}
+
+func TestSetupSeccompUnconfined(t *testing.T) {
+	d := &Driver{}
+	container := &configs.Config{}
+	if err := d.setupSeccomp(container, &execdriver.Command{SeccompProfile: "unconfined"}); err != nil {
+		t.Fatal(err)
+	}
+	if container.Seccomp != nil {
+		t.Fatal("Expected the unconfined profile to disable the filtering")
+	}
+}
@@ -0,0 +1,58 @@
+// +build linux,cgo
+
+package native
+
+import (
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+func TestLoadSeccompProfile(t *testing.T) {
+	profile := `{
+		"defaultAction": "SCMP_ACT_ERRNO",
+		"architectures": ["SCMP_ARCH_X86_64", "SCMP_ARCH_X86"],
+		"syscalls": [
+			{"name": "write", "action": "SCMP_ACT_ALLOW", "args": []},
+			{"name": "personality", "action": "SCMP_ACT_ALLOW", "args": [
+				{"index": 0, "value": 8, "valueTwo": 0, "op": "SCMP_CMP_EQ"}
+			]}
+		]
+	}`
+	config, err := loadSeccompProfile(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.DefaultAction != configs.Errno {
+		t.Fatalf("Expected the default action to be Errno, got %v", config.DefaultAction)
+	}
+	if len(config.Architectures) != 2 || config.Architectures[0] != "amd64" || config.Architectures[1] != "x86" {
+		t.Fatalf("Expected architectures [amd64 x86], got %v", config.Architectures)
+	}
+	if len(config.Syscalls) != 2 {
+		t.Fatalf("Expected 2 syscalls, got %d", len(config.Syscalls))
+	}
+	if config.Syscalls[0].Name != "write" || config.Syscalls[0].Action != configs.Allow {
+		t.Fatalf("Unexpected syscall rule %+v", config.Syscalls[0])
+	}
+	args := config.Syscalls[1].Args
+	if len(args) != 1 || args[0].Value != 8 || args[0].Op != configs.EqualTo {
+		t.Fatalf("Unexpected syscall arguments %+v", args)
+	}
+}
+
+func TestLoadSeccompProfileInvalid(t *testing.T) {
+	invalid := []string{
+		`{"defaultAction": "SCMP_ACT_TRACE"}`,
+		`{"defaultAction": "SCMP_ACT_ERRNO", "architectures": ["SCMP_ARCH_FOO"]}`,
+		`{"defaultAction": "SCMP_ACT_ERRNO", "syscalls": [{"name": "write", "action": "SCMP_ACT_FOO"}]}`,
+		`{"defaultAction": "SCMP_ACT_ERRNO", "syscalls": [{"action": "SCMP_ACT_ALLOW"}]}`,
+		`{"defaultAction": "SCMP_ACT_ERRNO", "syscalls": [{"name": "write", "action": "SCMP_ACT_ALLOW", "args": [{"op": "SCMP_CMP_FOO"}]}]}`,
+		`not json`,
+	}
+	for _, profile := range invalid {
+		if _, err := loadSeccompProfile(profile); err == nil {
+			t.Fatalf("Expected an error for profile %s", profile)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+// +build linux,cgo,!seccomp
+
+package native
+
+import "github.com/opencontainers/runc/libcontainer/configs"
+
+// getDefaultSeccompProfile returns nil, as the daemon was built without
+// seccomp support.
+func getDefaultSeccompProfile() *configs.Seccomp {
+	return nil
+}
@@ -0,0 +1,380 @@
+// +build linux,cgo
+
+package native
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/pkg/reexec"
+	"github.com/opencontainers/runc/libcontainer"
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/system"
+	"github.com/opencontainers/runc/libcontainer/utils"
+)
+
+// The containers started with live restore enabled are started by a shim,
+// a process of their own which is their parent instead of the daemon. The
+// shim outlives the daemon, reaps the process of the container when it
+// exits and records its exit status in the state directory of the
+// container, where the daemon reads it, whether it is the daemon which
+// started the container or one restoring it.
+
+const (
+	shimCommand = "docker-native-shim"
+
+	// files of the state directory of a container started by a shim
+	shimStateFile  = "shim.json"
+	exitStatusFile = "exit-status"
+
+	// types of the messages between the daemon and a shim
+	shimPrestart = "prestart"
+	shimStarted  = "started"
+	shimError    = "error"
+)
+
+func init() {
+	reexec.Register(shimCommand, shim)
+}
+
+// shimConfig is the container a shim starts.
+type shimConfig struct {
+	ID        string
+	Root      string
+	Options   []string
+	Config    *configs.Config
+	Args      []string
+	Env       []string
+	Cwd       string
+	User      string
+	PidsLimit int64
+	// Stdin is whether the process of the container has a stdin fifo.
+	Stdin bool
+	// Prestart is whether the daemon has prestart hooks to run, which can
+	// only run in the daemon.
+	Prestart bool
+}
+
+// shimMessage is a message between the daemon and a shim. The shim asks
+// the daemon to run the prestart hooks, which replies with their error if
+// any, then tells it that the container was started or failed to.
+type shimMessage struct {
+	Type      string
+	HookState configs.HookState
+	Pid       int    `json:",omitempty"`
+	Error     string `json:",omitempty"`
+}
+
+// shimState identifies the process of a shim, to know whether it is still
+// running.
+type shimState struct {
+	Pid       int
+	StartTime string
+}
+
+// runShim starts the container c with a shim and waits for the exit of its
+// process.
+func (d *Driver) runShim(c *execdriver.Command, container *configs.Config, p *libcontainer.Process, pipes *execdriver.Pipes, hooks execdriver.Hooks) (execdriver.ExitStatus, error) {
+	config := &shimConfig{
+		ID:        c.ID,
+		Root:      d.root,
+		Options:   d.options,
+		Config:    container,
+		Args:      p.Args,
+		Env:       p.Env,
+		Cwd:       p.Cwd,
+		User:      p.User,
+		PidsLimit: c.Resources.PidsLimit,
+		Stdin:     pipes.Stdin != nil,
+		Prestart:  container.Hooks != nil && len(container.Hooks.Prestart) > 0,
+	}
+
+	cmd := reexec.Command(shimCommand)
+	// the shim has to outlive the daemon
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return execdriver.ExitStatus{ExitCode: -1}, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return execdriver.ExitStatus{ExitCode: -1}, err
+	}
+	if err := cmd.Start(); err != nil {
+		return execdriver.ExitStatus{ExitCode: -1}, err
+	}
+	defer d.cleanContainer(c.ID)
+
+	pid, err := startShim(config, stdin, stdout)
+	stdin.Close()
+	if err != nil {
+		cmd.Wait()
+		return execdriver.ExitStatus{ExitCode: -1}, err
+	}
+
+	cont, err := d.factory.Load(c.ID)
+	if err != nil {
+		syscall.Kill(pid, syscall.SIGKILL)
+		cmd.Wait()
+		return execdriver.ExitStatus{ExitCode: -1}, err
+	}
+	d.Lock()
+	d.activeContainers[c.ID] = cont
+	d.Unlock()
+	defer cont.Destroy()
+
+	if err := attachFifos(filepath.Join(d.root, c.ID), &c.ProcessConfig, pipes); err != nil {
+		syscall.Kill(pid, syscall.SIGKILL)
+		cmd.Wait()
+		return execdriver.ExitStatus{ExitCode: -1}, err
+	}
+
+	oom := notifyOnOOM(cont)
+	if hooks.Start != nil {
+		hooks.Start(&c.ProcessConfig, pid, oom)
+	}
+
+	cmd.Wait()
+	exitCode, err := readExitStatus(filepath.Join(d.root, c.ID))
+	if err != nil {
+		return execdriver.ExitStatus{ExitCode: -1}, err
+	}
+	cont.Destroy()
+	_, oomKill := <-oom
+	return execdriver.ExitStatus{ExitCode: exitCode, OOMKilled: oomKill}, nil
+}
+
+// startShim sends config to a shim, runs the prestart hooks of the
+// container for it, and returns the pid of the process of the container
+// once it started.
+func startShim(config *shimConfig, w io.Writer, r io.Reader) (int, error) {
+	enc := json.NewEncoder(w)
+	dec := json.NewDecoder(r)
+	if err := enc.Encode(config); err != nil {
+		return -1, err
+	}
+	for {
+		var m shimMessage
+		if err := dec.Decode(&m); err != nil {
+			if err == io.EOF {
+				return -1, errors.New("the shim exited before starting the container")
+			}
+			return -1, err
+		}
+		switch m.Type {
+		case shimPrestart:
+			reply := shimMessage{Type: shimPrestart}
+			var prestart []configs.Hook
+			if config.Config.Hooks != nil {
+				prestart = config.Config.Hooks.Prestart
+			}
+			for _, hook := range prestart {
+				if err := hook.Run(m.HookState); err != nil {
+					reply.Error = err.Error()
+					break
+				}
+			}
+			if err := enc.Encode(reply); err != nil {
+				return -1, err
+			}
+		case shimStarted:
+			return m.Pid, nil
+		case shimError:
+			return -1, errors.New(m.Error)
+		default:
+			return -1, fmt.Errorf("unexpected message from the shim: %q", m.Type)
+		}
+	}
+}
+
+// shim is the main function of the shim of a container. It reads the
+// container to start on its stdin, and exits once the process of the
+// container exited and its exit status is recorded.
+func shim() {
+	if err := runContainer(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// runContainer starts the container the daemon sends on r, reporting to the
+// daemon on w, then waits for its process and records its exit status.
+func runContainer(r io.ReadCloser, w io.WriteCloser) error {
+	var config shimConfig
+	dec := json.NewDecoder(r)
+	enc := json.NewEncoder(w)
+	if err := dec.Decode(&config); err != nil {
+		return err
+	}
+
+	p, cont, err := startContainer(&config, dec, enc)
+	if err != nil {
+		enc.Encode(shimMessage{Type: shimError, Error: err.Error()})
+		return err
+	}
+	pid, err := p.Pid()
+	if err == nil {
+		err = enc.Encode(shimMessage{Type: shimStarted, Pid: pid})
+	}
+	if err != nil {
+		enc.Encode(shimMessage{Type: shimError, Error: err.Error()})
+		p.Signal(os.Kill)
+		p.Wait()
+		cont.Destroy()
+		return err
+	}
+	// the daemon doesn't talk to the shim anymore, and may go away
+	r.Close()
+	w.Close()
+
+	waitF := p.Wait
+	if nss := cont.Config().Namespaces; !nss.Contains(configs.NEWPID) {
+		waitF = waitInPIDHost(p, cont)
+	}
+	ps, err := waitF()
+	if err != nil {
+		execErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return err
+		}
+		ps = execErr.ProcessState
+	}
+	exitCode := utils.ExitStatus(ps.Sys().(syscall.WaitStatus))
+	return writeExitStatus(filepath.Join(config.Root, config.ID), exitCode)
+}
+
+// startContainer creates and starts the container of config, relaying its
+// prestart hooks to the daemon.
+func startContainer(config *shimConfig, dec *json.Decoder, enc *json.Encoder) (*libcontainer.Process, libcontainer.Container, error) {
+	d := &Driver{
+		root:             config.Root,
+		options:          config.Options,
+		activeContainers: make(map[string]libcontainer.Container),
+		pidsLimits:       make(map[string]int64),
+	}
+	if err := d.setupFactory(); err != nil {
+		return nil, nil, err
+	}
+
+	if config.Prestart {
+		config.Config.Hooks = &configs.Hooks{
+			Prestart: []configs.Hook{
+				configs.NewFunctionHook(func(s configs.HookState) error {
+					if err := enc.Encode(shimMessage{Type: shimPrestart, HookState: s}); err != nil {
+						return err
+					}
+					var reply shimMessage
+					if err := dec.Decode(&reply); err != nil {
+						return err
+					}
+					if reply.Error != "" {
+						return errors.New(reply.Error)
+					}
+					return nil
+				}),
+			},
+		}
+	}
+
+	d.setPidsLimit(config.ID, config.PidsLimit)
+	cont, err := d.factory.Create(config.ID, config.Config)
+	if err != nil {
+		return nil, nil, err
+	}
+	root := filepath.Join(config.Root, config.ID)
+	if err := writeShimState(root); err != nil {
+		cont.Destroy()
+		return nil, nil, err
+	}
+
+	p := &libcontainer.Process{
+		Args: config.Args,
+		Env:  config.Env,
+		Cwd:  config.Cwd,
+		User: config.User,
+	}
+	// the fifos live in the state directory of the container, which only
+	// exists once the container has been created
+	childFiles, err := createFifos(root, config.Config, p, config.Stdin)
+	if err != nil {
+		cont.Destroy()
+		return nil, nil, err
+	}
+	err = cont.Start(p)
+	for _, f := range childFiles {
+		f.Close()
+	}
+	if err != nil {
+		cont.Destroy()
+		return nil, nil, err
+	}
+	return p, cont, nil
+}
+
+// writeShimState records the process of the shim in the state directory
+// root of its container.
+func writeShimState(root string) error {
+	startTime, err := system.GetProcessStartTime(os.Getpid())
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(shimState{Pid: os.Getpid(), StartTime: startTime})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(root, shimStateFile), data, 0600)
+}
+
+func readShimState(root string) (*shimState, error) {
+	data, err := ioutil.ReadFile(filepath.Join(root, shimStateFile))
+	if err != nil {
+		return nil, err
+	}
+	var s shimState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// running returns whether the process of the shim is still running.
+func (s *shimState) running() bool {
+	return processRunning(s.Pid, s.StartTime)
+}
+
+// processRunning returns whether the process with the given pid and start
+// time is still running, and not a process which reused its pid.
+func processRunning(pid int, startTime string) bool {
+	current, err := system.GetProcessStartTime(pid)
+	return err == nil && current == startTime
+}
+
+// writeExitStatus records the exit status of the process of the container
+// in its state directory root. The file is renamed into place, so that its
+// readers never see it partially written.
+func writeExitStatus(root string, exitCode int) error {
+	path := filepath.Join(root, exitStatusFile)
+	if err := ioutil.WriteFile(path+".tmp", []byte(strconv.Itoa(exitCode)), 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func readExitStatus(root string) (int, error) {
+	data, err := ioutil.ReadFile(filepath.Join(root, exitStatusFile))
+	if err != nil {
+		return -1, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
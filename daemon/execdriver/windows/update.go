@@ -0,0 +1,14 @@
+// +build windows
+
+package windows
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/daemon/execdriver"
+)
+
+// Update implements the exec driver Driver interface.
+func (d *Driver) Update(c *execdriver.Command) error {
+	return fmt.Errorf("Windows: Updating resources of a running container is not implemented")
+}
@@ -23,9 +23,10 @@ type Tagger interface {
 	Tag(repoName, tag, imageName string, force bool) error
 }
 
-// Recorder is an interface that exposes the Graph.Register and Graph.Exists
-// functions without needing to import graph.
+// Recorder is an interface that exposes the Graph.Register, Graph.Exists and
+// Graph.LayerStorageID functions without needing to import graph.
 type Recorder interface {
 	Exists(id string) bool
-	Register(img image.Descriptor, layerData io.Reader) error
+	Register(img image.Descriptor, layerData io.Reader) (*image.Image, error)
+	LayerStorageID(id string) (string, error)
 }
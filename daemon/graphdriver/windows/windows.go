@@ -446,7 +446,8 @@ func (d *Driver) RestoreCustomImages(tagger graphdriver.Tagger, recorder graphdr
 				Size:          imageData.Size,
 			}
 
-			if err := recorder.Register(customImageDescriptor{img}, nil); err != nil {
+			img, err := recorder.Register(customImageDescriptor{img}, nil)
+			if err != nil {
 				return nil, err
 			}
 
@@ -455,8 +456,12 @@ func (d *Driver) RestoreCustomImages(tagger graphdriver.Tagger, recorder graphdr
 				return nil, err
 			}
 
-			// Create the alternate ID file.
-			if err := d.setID(img.ID, folderName); err != nil {
+			// Create the alternate ID file for the layer of the image.
+			layerID, err := recorder.LayerStorageID(img.ID)
+			if err != nil {
+				return nil, err
+			}
+			if err := d.setID(layerID, folderName); err != nil {
 				return nil, err
 			}
 
@@ -0,0 +1,375 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/docker/pkg/stringutils"
+	"github.com/docker/docker/runconfig"
+)
+
+const (
+	// Longest health check probe output message to store. Longer messages
+	// will be truncated.
+	maxOutputLen = 4096
+
+	// Default interval between probe runs (from the end of the first to the
+	// start of the second). Also the time before the first probe.
+	defaultProbeInterval = 30 * time.Second
+
+	// The maximum length of time a single probe run should take. If the
+	// probe takes longer than this, the check is considered to have failed.
+	defaultProbeTimeout = 30 * time.Second
+
+	// Default number of consecutive failures of the health check for the
+	// container to be considered unhealthy.
+	defaultProbeRetries = 3
+
+	// Maximum number of probe results to keep in the health log.
+	maxLogEntries = 5
+)
+
+// Exit status code returned by a probe command when the container is
+// healthy. Any other exit code is considered a failure.
+const exitStatusHealthy = 0
+
+// Health holds the current health check state of a container, in addition
+// to the channel used to stop its monitor.
+type Health struct {
+	types.Health
+	stop chan struct{} // Closed to stop the monitor
+}
+
+// String returns a human-readable description of the health check state.
+func (s *Health) String() string {
+	if s.Status == types.Starting {
+		return "health: starting"
+	}
+	return s.Status
+}
+
+// openMonitorChannel creates and returns a new monitor channel. If there
+// already is one, it returns nil.
+func (s *Health) openMonitorChannel() chan struct{} {
+	if s.stop != nil {
+		logrus.Debugf("openMonitorChannel: monitor already open")
+		return nil
+	}
+	s.stop = make(chan struct{})
+	return s.stop
+}
+
+// closeMonitorChannel closes any existing monitor channel.
+func (s *Health) closeMonitorChannel() {
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+// probe is the interface implemented by the different kinds of health
+// checks. A probe running for longer than timeout is stopped and fails.
+type probe interface {
+	run(d *Daemon, container *Container, timeout time.Duration) (*types.HealthcheckResult, error)
+}
+
+// cmdProbe runs a command inside the container, through the same path as
+// "docker exec".
+type cmdProbe struct {
+	// Run the command with the system's default shell instead of execing
+	// it directly.
+	shell bool
+}
+
+// run executes the health check command in the container and returns its
+// exit code and output. The command is killed if it exceeds the timeout.
+func (p *cmdProbe) run(d *Daemon, container *Container, timeout time.Duration) (*types.HealthcheckResult, error) {
+	cmdSlice := container.Config.Healthcheck.Test[1:]
+	if p.shell {
+		if runtime.GOOS != "windows" {
+			cmdSlice = append([]string{"/bin/sh", "-c"}, cmdSlice...)
+		} else {
+			cmdSlice = append([]string{"cmd", "/S", "/C"}, cmdSlice...)
+		}
+	}
+	entrypoint, args := d.getEntrypointAndArgs(stringutils.NewStrSlice(), stringutils.NewStrSlice(cmdSlice...))
+
+	processConfig := &execdriver.ProcessConfig{
+		CommonProcessConfig: execdriver.CommonProcessConfig{
+			Entrypoint: entrypoint,
+			Arguments:  args,
+		},
+	}
+	setPlatformSpecificExecProcessConfig(&runconfig.ExecConfig{}, container, processConfig)
+
+	execConfig := &ExecConfig{
+		ID:            stringid.GenerateNonCryptoID(),
+		OpenStdout:    true,
+		OpenStderr:    true,
+		streamConfig:  streamConfig{},
+		ProcessConfig: processConfig,
+		Container:     container,
+		waitStart:     make(chan struct{}),
+	}
+	d.registerExecCommand(execConfig)
+	defer d.unregisterExecCommand(execConfig)
+
+	output := &limitedBuffer{}
+	execErr := make(chan error, 1)
+	go func() {
+		execErr <- d.ContainerExecStart(execConfig.ID, nil, output, output)
+	}()
+	select {
+	case err := <-execErr:
+		if err != nil {
+			return nil, err
+		}
+	case <-time.After(timeout):
+		// The command is killed so that hanging probes don't pile up in
+		// the container.
+		if err := execConfig.kill(); err != nil {
+			logrus.Warnf("Failed to kill the health check of container %s: %v", container.ID, err)
+		}
+		return &types.HealthcheckResult{
+			ExitCode: -1,
+			Output:   fmt.Sprintf("Health check exceeded timeout (%v)", timeout),
+			End:      time.Now(),
+		}, nil
+	}
+	return &types.HealthcheckResult{
+		End:      time.Now(),
+		ExitCode: execConfig.ExitCode,
+		Output:   output.String(),
+	}, nil
+}
+
+// handleProbeResult records the result of a probe in the health state of
+// the container, and updates the status if needed.
+func handleProbeResult(d *Daemon, c *Container, result *types.HealthcheckResult, stop chan struct{}) {
+	c.Lock()
+	defer c.Unlock()
+
+	// The container may have been stopped, or restarted with a new monitor,
+	// while the probe was running.
+	select {
+	case <-stop:
+		return
+	default:
+	}
+
+	retries := c.Config.Healthcheck.Retries
+	if retries <= 0 {
+		retries = defaultProbeRetries
+	}
+
+	h := c.State.Health
+	oldStatus := h.Status
+
+	if len(h.Log) >= maxLogEntries {
+		h.Log = append(h.Log[len(h.Log)+1-maxLogEntries:], result)
+	} else {
+		h.Log = append(h.Log, result)
+	}
+
+	if result.ExitCode == exitStatusHealthy {
+		h.FailingStreak = 0
+		h.Status = types.Healthy
+	} else {
+		// Failure (including invalid exit code)
+		h.FailingStreak++
+		if h.FailingStreak >= retries {
+			h.Status = types.Unhealthy
+		}
+		// Else we're starting or healthy. Stay in that state.
+	}
+
+	if err := c.toDisk(); err != nil {
+		logrus.Errorf("Error saving container to disk: %v", err)
+	}
+
+	if oldStatus != h.Status {
+		d.LogContainerEvent(c, "health_status: "+h.Status)
+	}
+}
+
+// monitor runs the probe of the container every interval, until the stop
+// channel is closed.
+func monitor(d *Daemon, c *Container, stop chan struct{}, probe probe) {
+	probeTimeout := timeoutWithDefault(c.Config.Healthcheck.Timeout, defaultProbeTimeout)
+	probeInterval := timeoutWithDefault(c.Config.Healthcheck.Interval, defaultProbeInterval)
+	for {
+		select {
+		case <-stop:
+			logrus.Debugf("Stop health check monitoring for container %s (received while idle)", c.ID)
+			return
+		case <-time.After(probeInterval):
+			logrus.Debugf("Running health check for container %s ...", c.ID)
+			startTime := time.Now()
+			results := make(chan *types.HealthcheckResult, 1)
+			go func() {
+				result, err := probe.run(d, c, probeTimeout)
+				if err != nil {
+					logrus.Warnf("Health check for container %s error: %v", c.ID, err)
+					result = &types.HealthcheckResult{
+						ExitCode: -1,
+						Output:   err.Error(),
+						End:      time.Now(),
+					}
+				}
+				result.Start = startTime
+				results <- result
+			}()
+			select {
+			case <-stop:
+				logrus.Debugf("Stop health check monitoring for container %s (received while probing)", c.ID)
+				return
+			case result := <-results:
+				logrus.Debugf("Health check for container %s done (exitCode=%d)", c.ID, result.ExitCode)
+				handleProbeResult(d, c, result, stop)
+			}
+		}
+	}
+}
+
+// getProbe returns the probe for the health check of the container, or nil
+// if the container has no health check.
+func getProbe(c *Container) probe {
+	config := c.Config.Healthcheck
+	if config == nil || len(config.Test) == 0 {
+		return nil
+	}
+	switch config.Test[0] {
+	case "CMD":
+		return &cmdProbe{shell: false}
+	case "CMD-SHELL":
+		return &cmdProbe{shell: true}
+	case "NONE":
+		return nil
+	default:
+		logrus.Warnf("Unknown health check type '%s' (expected 'CMD') in container %s", config.Test[0], c.ID)
+		return nil
+	}
+}
+
+// updateHealthMonitor ensures that the health monitor of the container is
+// running if the container is running and stopped otherwise.
+// The caller must hold the container lock.
+func (daemon *Daemon) updateHealthMonitor(c *Container) {
+	h := c.State.Health
+	if h == nil {
+		return // No healthcheck configured
+	}
+
+	probe := getProbe(c)
+	wantRunning := c.Running && !c.Paused && !c.Restarting && probe != nil
+	if wantRunning {
+		if stop := h.openMonitorChannel(); stop != nil {
+			go monitor(daemon, c, stop, probe)
+		}
+	} else {
+		h.closeMonitorChannel()
+	}
+}
+
+// initHealthMonitor resets the health state of a container that has just
+// been started and starts its monitor if it has a health check.
+// The caller must hold the container lock.
+func (daemon *Daemon) initHealthMonitor(c *Container) {
+	// If no healthcheck is setup then don't init the monitor
+	if getProbe(c) == nil {
+		return
+	}
+
+	// This is needed in case we're auto-restarting
+	daemon.stopHealthchecks(c)
+
+	c.State.Health = &Health{
+		Health: types.Health{
+			Status: types.Starting,
+		},
+	}
+
+	daemon.updateHealthMonitor(c)
+}
+
+// stopHealthchecks stops the health monitor of the container, if any.
+// The caller must hold the container lock.
+func (daemon *Daemon) stopHealthchecks(c *Container) {
+	if h := c.State.Health; h != nil {
+		h.closeMonitorChannel()
+	}
+}
+
+// timeoutWithDefault returns the configured duration, or the default if
+// it is not set or not valid.
+func timeoutWithDefault(configuredValue time.Duration, defaultValue time.Duration) time.Duration {
+	if configuredValue <= 0 {
+		return defaultValue
+	}
+	return configuredValue
+}
+
+// limitedBuffer is a buffer that silently drops anything written beyond
+// maxOutputLen, and records that the output was truncated.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	mu        sync.Mutex
+	truncated bool // indicates that data has been lost
+}
+
+// Write appends data to the buffer, up to maxOutputLen bytes.
+func (b *limitedBuffer) Write(data []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bufLen := b.buf.Len()
+	dataLen := len(data)
+	keep := maxOutputLen - bufLen
+	if keep > dataLen {
+		keep = dataLen
+	}
+	if keep > 0 {
+		b.buf.Write(data[:keep])
+	}
+	if keep < dataLen {
+		b.truncated = true
+	}
+	return dataLen, nil
+}
+
+// String returns the contents of the buffer, with "..." appended if it
+// overflowed.
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	out := b.buf.String()
+	if b.truncated {
+		out = out + "..."
+	}
+	return out
+}
+
+// healthString returns the health status of the container as used by the
+// health filter of "docker ps".
+func (s *State) healthString() string {
+	if s.Health == nil {
+		return types.NoHealthcheck
+	}
+	return s.Health.Status
+}
+
+// isValidHealthString checks the value of the health filter.
+func isValidHealthString(s string) bool {
+	return s == types.Starting ||
+		s == types.Healthy ||
+		s == types.Unhealthy ||
+		s == types.NoHealthcheck
+}
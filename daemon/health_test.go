@@ -0,0 +1,162 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	eventtypes "github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/daemon/events"
+	"github.com/docker/docker/runconfig"
+)
+
+func resetHealth(c *Container) {
+	c.State = NewState()
+	c.State.Health = &Health{}
+	c.State.Health.Status = types.Starting
+}
+
+func TestHealthStates(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "docker-daemon-health-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	e := events.New()
+	_, l, _ := e.Subscribe()
+	defer e.Evict(l)
+
+	expect := func(expected string) {
+		select {
+		case event := <-l:
+			ev := event.(eventtypes.Message)
+			if ev.Status != expected {
+				t.Errorf("Expecting event %#v, but got %#v\n", expected, ev.Status)
+			}
+		case <-time.After(1 * time.Second):
+			t.Errorf("Expecting event %#v, but got nothing\n", expected)
+		}
+	}
+
+	c := &Container{
+		CommonContainer: CommonContainer{
+			ID:   "container_id",
+			root: tmp,
+			Config: &runconfig.Config{
+				Image: "image_name",
+				Healthcheck: &runconfig.HealthConfig{
+					Retries: 1,
+				},
+			},
+		},
+	}
+	daemon := &Daemon{
+		EventsService: e,
+	}
+
+	stop := make(chan struct{})
+	handleResult := func(startTime time.Time, exitCode int) {
+		handleProbeResult(daemon, c, &types.HealthcheckResult{
+			Start:    startTime,
+			End:      startTime,
+			ExitCode: exitCode,
+		}, stop)
+	}
+
+	// starting -> failed -> success -> failed
+
+	resetHealth(c)
+	handleResult(c.State.StartedAt.Add(1*time.Second), 1)
+	expect("health_status: unhealthy")
+
+	resetHealth(c)
+	handleResult(c.State.StartedAt.Add(1*time.Second), 0)
+	expect("health_status: healthy")
+	handleResult(c.State.StartedAt.Add(2*time.Second), 1)
+	expect("health_status: unhealthy")
+
+	// Test retries
+
+	resetHealth(c)
+	c.Config.Healthcheck.Retries = 3
+
+	handleResult(c.State.StartedAt.Add(20*time.Second), 1)
+	handleResult(c.State.StartedAt.Add(40*time.Second), 1)
+	if c.State.Health.Status != types.Starting {
+		t.Errorf("Expecting starting, but got %#v\n", c.State.Health.Status)
+	}
+	if c.State.Health.FailingStreak != 2 {
+		t.Errorf("Expecting FailingStreak=2, but got %d\n", c.State.Health.FailingStreak)
+	}
+	handleResult(c.State.StartedAt.Add(60*time.Second), 1)
+	expect("health_status: unhealthy")
+
+	handleResult(c.State.StartedAt.Add(80*time.Second), 0)
+	expect("health_status: healthy")
+	if c.State.Health.FailingStreak != 0 {
+		t.Errorf("Expecting FailingStreak=0, but got %d\n", c.State.Health.FailingStreak)
+	}
+
+	// The log is bounded
+	for i := 0; i < 2*maxLogEntries; i++ {
+		handleResult(c.State.StartedAt.Add(time.Duration(i)*time.Second), 0)
+	}
+	if len(c.State.Health.Log) != maxLogEntries {
+		t.Errorf("Expecting %d log entries, but got %d\n", maxLogEntries, len(c.State.Health.Log))
+	}
+
+	// Results arriving after the monitor was stopped are ignored
+	close(stop)
+	handleResult(c.State.StartedAt.Add(100*time.Second), 1)
+	if c.State.Health.FailingStreak != 0 {
+		t.Errorf("Expecting the result to be ignored, but got FailingStreak=%d\n", c.State.Health.FailingStreak)
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	b := &limitedBuffer{}
+	b.Write([]byte("hello "))
+	b.Write([]byte(strings.Repeat("x", maxOutputLen)))
+
+	out := b.String()
+	if !strings.HasPrefix(out, "hello x") {
+		t.Fatalf("Expecting the beginning of the output to be kept, got %q", out[:10])
+	}
+	if !strings.HasSuffix(out, "...") || len(out) != maxOutputLen+3 {
+		t.Fatalf("Expecting the output to be truncated, got %d bytes", len(out))
+	}
+}
+
+func TestVerifyHealthcheck(t *testing.T) {
+	valid := []*runconfig.HealthConfig{
+		nil,
+		{},
+		{Test: []string{"NONE"}},
+		{Test: []string{"CMD", "true"}, Interval: time.Second, Timeout: time.Second, Retries: 1},
+		{Test: []string{"CMD-SHELL", "exit 0"}},
+	}
+	for _, healthcheck := range valid {
+		if err := verifyHealthcheck(healthcheck); err != nil {
+			t.Errorf("Expecting %+v to be valid, but got %v\n", healthcheck, err)
+		}
+	}
+
+	invalid := []*runconfig.HealthConfig{
+		{Test: []string{"FOO", "true"}},
+		{Test: []string{"NONE", "true"}},
+		{Test: []string{"CMD"}},
+		{Test: []string{"CMD-SHELL"}},
+		{Interval: -time.Second},
+		{Timeout: -time.Second},
+		{Retries: -1},
+	}
+	for _, healthcheck := range invalid {
+		if err := verifyHealthcheck(healthcheck); err == nil {
+			t.Errorf("Expecting %+v to be invalid\n", healthcheck)
+		}
+	}
+}
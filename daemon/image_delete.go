@@ -81,7 +81,7 @@ func (daemon *Daemon) ImageDelete(imageRef string, force, prune bool) ([]types.I
 
 		untaggedRecord := types.ImageDelete{Untagged: parsedRef}
 
-		daemon.EventsService.Log("untag", img.ID, "")
+		daemon.LogImageEvent(img.ID, "untag")
 		records = append(records, untaggedRecord)
 
 		// If has remaining references then untag finishes the remove
@@ -104,7 +104,7 @@ func (daemon *Daemon) ImageDelete(imageRef string, force, prune bool) ([]types.I
 
 			untaggedRecord := types.ImageDelete{Untagged: parsedRef}
 
-			daemon.EventsService.Log("untag", img.ID, "")
+			daemon.LogImageEvent(img.ID, "untag")
 			records = append(records, untaggedRecord)
 		}
 	}
@@ -171,7 +171,7 @@ func (daemon *Daemon) removeAllReferencesToImageID(imgID string, records *[]type
 
 		untaggedRecord := types.ImageDelete{Untagged: parsedRef}
 
-		daemon.EventsService.Log("untag", imgID, "")
+		daemon.LogImageEvent(imgID, "untag")
 		*records = append(*records, untaggedRecord)
 	}
 
@@ -232,7 +232,7 @@ func (daemon *Daemon) imageDeleteHelper(img *image.Image, records *[]types.Image
 		return err
 	}
 
-	daemon.EventsService.Log("delete", img.ID, "")
+	daemon.LogImageEvent(img.ID, "delete")
 	*records = append(*records, types.ImageDelete{Deleted: img.ID})
 
 	if !prune || img.Parent == "" {
@@ -56,6 +56,12 @@ func (daemon *Daemon) SystemInfo() (*types.Info, error) {
 
 	sysInfo := sysinfo.New(true)
 
+	// Labels and the cluster options can be changed by a configuration reload
+	config := daemon.config()
+	config.reloadLock.Lock()
+	labels, clusterStore, clusterAdvertise := config.Labels, config.ClusterStore, config.ClusterAdvertise
+	config.reloadLock.Unlock()
+
 	v := &types.Info{
 		ID:                 daemon.ID,
 		Containers:         len(daemon.List()),
@@ -75,17 +81,18 @@ func (daemon *Daemon) SystemInfo() (*types.Info, error) {
 		KernelVersion:      kernelVersion,
 		OperatingSystem:    operatingSystem,
 		IndexServerAddress: registry.IndexServer,
-		RegistryConfig:     daemon.RegistryService.Config,
+		RegistryConfig:     daemon.RegistryService.ServiceConfig(),
 		InitSha1:           dockerversion.InitSHA1,
 		InitPath:           initPath,
 		NCPU:               runtime.NumCPU(),
 		MemTotal:           meminfo.MemTotal,
 		DockerRootDir:      daemon.config().Root,
-		Labels:             daemon.config().Labels,
+		Labels:             labels,
 		ExperimentalBuild:  utils.ExperimentalBuild(),
 		ServerVersion:      dockerversion.Version,
-		ClusterStore:       daemon.config().ClusterStore,
-		ClusterAdvertise:   daemon.config().ClusterAdvertise,
+		ClusterStore:       clusterStore,
+		ClusterAdvertise:   clusterAdvertise,
+		LiveRestoreEnabled: daemon.config().LiveRestoreEnabled,
 		HTTPProxy:          os.Getenv("http_proxy"),
 		HTTPSProxy:         os.Getenv("https_proxy"),
 		NoProxy:            os.Getenv("no_proxy"),
@@ -99,6 +106,8 @@ func (daemon *Daemon) SystemInfo() (*types.Info, error) {
 		v.MemoryLimit = sysInfo.MemoryLimit
 		v.SwapLimit = sysInfo.SwapLimit
 		v.OomKillDisable = sysInfo.OomKillDisable
+		v.PidsLimit = sysInfo.PidsLimit
+		v.Seccomp = sysInfo.Seccomp && supportsSeccomp
 		v.CPUCfsPeriod = sysInfo.CPUCfsPeriod
 		v.CPUCfsQuota = sysInfo.CPUCfsQuota
 		v.CPUShares = sysInfo.CPUShares
@@ -116,6 +116,10 @@ func (daemon *Daemon) getInspectData(container *Container, size bool) (*types.Co
 		StartedAt:  container.State.StartedAt.Format(time.RFC3339Nano),
 		FinishedAt: container.State.FinishedAt.Format(time.RFC3339Nano),
 	}
+	if container.State.Health != nil {
+		health := container.State.Health.Health
+		containerState.Health = &health
+	}
 
 	contJSONBase := &types.ContainerJSONBase{
 		ID:           container.ID,
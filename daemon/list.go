@@ -14,6 +14,7 @@ import (
 	"github.com/docker/docker/pkg/graphdb"
 	"github.com/docker/docker/pkg/nat"
 	"github.com/docker/docker/pkg/parsers/filters"
+	"github.com/docker/docker/pkg/stringutils"
 )
 
 // iterationAction represents possible outcomes happening during the container iteration.
@@ -156,6 +157,14 @@ func (daemon *Daemon) foldFilter(config *ContainersConfig) (*listContext, error)
 		}
 	}
 
+	if i, ok := psFilters["health"]; ok {
+		for _, value := range i {
+			if !isValidHealthString(value) {
+				return nil, errors.New("Unrecognised filter value for health")
+			}
+		}
+	}
+
 	imagesFilter := map[string]bool{}
 	var ancestorFilter bool
 	if ancestors, ok := psFilters["ancestor"]; ok {
@@ -279,6 +288,11 @@ func includeContainerInList(container *Container, ctx *listContext) iterationAct
 		return excludeContainer
 	}
 
+	// Do not include container if its health doesn't match the filter
+	if healths, ok := ctx.filters["health"]; ok && !stringutils.InSlice(healths, container.State.healthString()) {
+		return excludeContainer
+	}
+
 	if ctx.ancestorFilter {
 		if len(ctx.images) == 0 {
 			return excludeContainer
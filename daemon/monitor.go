@@ -31,8 +31,14 @@ type containerSupervisor interface {
 	StartLogging(*Container) error
 	// Run starts a container
 	Run(c *Container, pipes *execdriver.Pipes, startCallback execdriver.DriverCallback) (execdriver.ExitStatus, error)
+	// Restore reattaches to a container left running by a previous daemon
+	Restore(c *Container, pipes *execdriver.Pipes, startCallback execdriver.DriverCallback) (execdriver.ExitStatus, error)
 	// IsShuttingDown tells whether the supervisor is shutting down or not
 	IsShuttingDown() bool
+	// initHealthMonitor starts the health check of a container that has just been started
+	initHealthMonitor(*Container)
+	// updateHealthMonitor starts or stops the health check of a container according to its state
+	updateHealthMonitor(*Container)
 }
 
 // containerMonitor monitors the execution of a container's main process.
@@ -74,6 +80,11 @@ type containerMonitor struct {
 
 	// lastStartTime is the time which the monitor last exec'd the container's process
 	lastStartTime time.Time
+
+	// reattach tells the monitor that the container's process is already
+	// running and was left behind by a previous daemon, so the first run
+	// reattaches to it instead of starting a new one
+	reattach bool
 }
 
 // newContainerMonitor returns an initialized containerMonitor for the provided container
@@ -104,6 +115,14 @@ func (m *containerMonitor) ExitOnNext() {
 	m.mux.Unlock()
 }
 
+// setRestartPolicy replaces the restart policy applied the next time the
+// container exits
+func (m *containerMonitor) setRestartPolicy(policy runconfig.RestartPolicy) {
+	m.mux.Lock()
+	m.restartPolicy = policy
+	m.mux.Unlock()
+}
+
 // Close closes the container's resources such as networking allocations and
 // unmounts the contatiner's root filesystem
 func (m *containerMonitor) Close() error {
@@ -138,6 +157,7 @@ func (m *containerMonitor) Start() error {
 			m.container.Lock()
 			defer m.container.Unlock()
 			m.container.setStopped(&exitStatus)
+			m.supervisor.updateHealthMonitor(m.container)
 		}
 		m.Close()
 	}()
@@ -146,11 +166,15 @@ func (m *containerMonitor) Start() error {
 		m.container.HasBeenManuallyStopped = false
 	}
 
-	// reset the restart count
-	m.container.RestartCount = -1
+	// reset the restart count, a reattached container keeps counting
+	if !m.reattach {
+		m.container.RestartCount = -1
+	}
 
 	for {
-		m.container.RestartCount++
+		if !m.reattach {
+			m.container.RestartCount++
+		}
 
 		if err := m.supervisor.StartLogging(m.container); err != nil {
 			m.resetContainer(false)
@@ -160,11 +184,26 @@ func (m *containerMonitor) Start() error {
 
 		pipes := execdriver.NewPipes(m.container.stdin, m.container.stdout, m.container.stderr, m.container.Config.OpenStdin)
 
-		m.logEvent("start")
+		run := m.supervisor.Run
+		if m.reattach {
+			run = m.supervisor.Restore
+		} else {
+			m.logEvent("start")
+		}
 
 		m.lastStartTime = time.Now()
 
-		if exitStatus, err = m.supervisor.Run(m.container, pipes, m.callback); err != nil {
+		exitStatus, err = run(m.container, pipes, m.callback)
+		if m.reattach {
+			m.reattach = false
+			// the process is gone or can't be reattached to, let the caller
+			// deal with the container as it would without live restore
+			if err != nil {
+				m.resetContainer(false)
+				return err
+			}
+		}
+		if err != nil {
 			// if we receive an internal error from the initial start of a container then lets
 			// return it instead of entering the restart loop
 			// set to 127 for container cmd not found/does not exist)
@@ -203,6 +242,7 @@ func (m *containerMonitor) Start() error {
 
 		if m.shouldRestart(exitStatus.ExitCode) {
 			m.container.setRestarting(&exitStatus)
+			m.supervisor.updateHealthMonitor(m.container)
 			m.logEvent("die")
 			m.resetContainer(true)
 
@@ -303,7 +343,11 @@ func (m *containerMonitor) callback(processConfig *execdriver.ProcessConfig, pid
 		}
 	}
 
-	m.container.setRunning(pid)
+	// a reattached container is already in the running state
+	if !m.reattach {
+		m.container.setRunning(pid)
+	}
+	m.supervisor.initHealthMonitor(m.container)
 
 	// signal that the process has started
 	// close channel only if not closed
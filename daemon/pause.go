@@ -38,6 +38,7 @@ func (daemon *Daemon) containerPause(container *Container) error {
 		return err
 	}
 	container.Paused = true
+	daemon.updateHealthMonitor(container)
 	daemon.LogContainerEvent(container, "pause")
 	return nil
 }
@@ -0,0 +1,141 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/pkg/discovery"
+)
+
+// Reload applies the options of config which can be changed while the
+// daemon is running, and which were set in the configuration file: the
+// daemon labels, the debug mode, the registry mirrors and insecure
+// registries, and the cluster discovery options. The changes are logged
+// and reported with a daemon reload event.
+func (daemon *Daemon) Reload(config *Config) error {
+	daemon.configStore.reloadLock.Lock()
+	defer daemon.configStore.reloadLock.Unlock()
+
+	attributes := make(map[string]string)
+
+	if config.IsValueSet("label") {
+		daemon.configStore.Labels = config.Labels
+		attributes["label"] = fmt.Sprintf("%v", config.Labels)
+	}
+
+	if config.IsValueSet("debug") {
+		daemon.configStore.Debug = config.Debug
+		setDebugMode(config.Debug)
+		attributes["debug"] = fmt.Sprintf("%t", config.Debug)
+	}
+
+	if config.IsValueSet("registry-mirror") || config.IsValueSet("insecure-registry") {
+		if config.IsValueSet("registry-mirror") {
+			daemon.configStore.Mirrors = config.Mirrors
+			attributes["registry-mirror"] = fmt.Sprintf("%v", config.Mirrors)
+		}
+		if config.IsValueSet("insecure-registry") {
+			daemon.configStore.InsecureRegistries = config.InsecureRegistries
+			attributes["insecure-registry"] = fmt.Sprintf("%v", config.InsecureRegistries)
+		}
+		daemon.RegistryService.Reload(&daemon.configStore.Options)
+	}
+
+	changed, err := daemon.reloadClusterDiscovery(config)
+	if err != nil {
+		return err
+	}
+	if changed {
+		attributes["cluster-store"] = daemon.configStore.ClusterStore
+		attributes["cluster-advertise"] = daemon.configStore.ClusterAdvertise
+		attributes["cluster-store-opt"] = fmt.Sprintf("%v", daemon.configStore.ClusterOpts)
+	}
+
+	if len(attributes) == 0 {
+		logrus.Info("Reloaded configuration: no changes")
+	} else {
+		var changes []string
+		for name, value := range attributes {
+			changes = append(changes, fmt.Sprintf("%s=%s", name, value))
+		}
+		logrus.Infof("Reloaded configuration: %s", strings.Join(changes, ", "))
+	}
+
+	daemon.LogDaemonEventWithAttributes("reload", attributes)
+	return nil
+}
+
+// setDebugMode enables or disables the debug logs of the daemon.
+func setDebugMode(debug bool) {
+	if debug {
+		os.Setenv("DEBUG", "1")
+		logrus.SetLevel(logrus.DebugLevel)
+		return
+	}
+	os.Unsetenv("DEBUG")
+	logrus.SetLevel(logrus.InfoLevel)
+}
+
+// reloadClusterDiscovery reconfigures the registration of the daemon in
+// the cluster discovery backend, if the cluster options changed. It
+// returns whether they changed.
+func (daemon *Daemon) reloadClusterDiscovery(config *Config) (bool, error) {
+	if !config.IsValueSet("cluster-store") && !config.IsValueSet("cluster-advertise") && !config.IsValueSet("cluster-store-opt") {
+		return false, nil
+	}
+
+	newClusterStore := daemon.configStore.ClusterStore
+	if config.IsValueSet("cluster-store") {
+		newClusterStore = config.ClusterStore
+	}
+	newAdvertise := daemon.configStore.ClusterAdvertise
+	if config.IsValueSet("cluster-advertise") {
+		newAdvertise = config.ClusterAdvertise
+	}
+	newClusterOpts := daemon.configStore.ClusterOpts
+	if config.IsValueSet("cluster-store-opt") {
+		newClusterOpts = config.ClusterOpts
+	}
+
+	enabled := newClusterStore != "" && newAdvertise != ""
+	if !enabled && newAdvertise != "" {
+		return false, fmt.Errorf("invalid cluster configuration. --cluster-advertise must be accompanied by --cluster-store configuration")
+	}
+	if enabled {
+		advertise, err := discovery.ParseAdvertise(newClusterStore, newAdvertise)
+		if err != nil {
+			return false, fmt.Errorf("discovery advertise parsing failed (%v)", err)
+		}
+		newAdvertise = advertise
+	}
+
+	if newClusterStore == daemon.configStore.ClusterStore &&
+		newAdvertise == daemon.configStore.ClusterAdvertise &&
+		reflect.DeepEqual(newClusterOpts, daemon.configStore.ClusterOpts) {
+		return false, nil
+	}
+
+	var err error
+	switch {
+	case !enabled:
+		// Discovery is disabled by the new configuration
+		if daemon.discoveryWatcher != nil {
+			daemon.discoveryWatcher.Stop()
+		}
+	case daemon.discoveryWatcher == nil:
+		daemon.discoveryWatcher, err = initDiscovery(newClusterStore, newAdvertise, newClusterOpts)
+	default:
+		err = daemon.discoveryWatcher.Reload(newClusterStore, newAdvertise, newClusterOpts)
+	}
+	if err != nil {
+		return false, fmt.Errorf("discovery initialization failed (%v)", err)
+	}
+
+	daemon.configStore.ClusterStore = newClusterStore
+	daemon.configStore.ClusterAdvertise = newAdvertise
+	daemon.configStore.ClusterOpts = newClusterOpts
+	return true, nil
+}
@@ -0,0 +1,6 @@
+// +build linux,seccomp
+
+package daemon
+
+// supportsSeccomp is true as the daemon was built with seccomp support.
+const supportsSeccomp = true
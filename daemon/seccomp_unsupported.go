@@ -0,0 +1,6 @@
+// +build !linux !seccomp
+
+package daemon
+
+// supportsSeccomp is false as the daemon was built without seccomp support.
+const supportsSeccomp = false
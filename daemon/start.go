@@ -122,17 +122,66 @@ func (daemon *Daemon) containerStart(container *Container) (err error) {
 		return err
 	}
 	mounts = append(mounts, container.ipcMounts()...)
+	mounts = append(mounts, container.tmpfsMounts()...)
 
 	container.command.Mounts = mounts
-	if err := daemon.waitForStart(container); err != nil {
+	if err := daemon.waitForStart(container, false); err != nil {
 		return err
 	}
 	container.HasBeenStartedBefore = true
 	return nil
 }
 
-func (daemon *Daemon) waitForStart(container *Container) error {
+// containerRestore reattaches the daemon to a container whose process was
+// kept running by a previous daemon with live restore enabled. It sets up
+// again everything containerStart sets up for the container, except for
+// the process itself which is left untouched.
+func (daemon *Daemon) containerRestore(container *Container) error {
+	container.Lock()
+	defer container.Unlock()
+
+	if err := daemon.prepareRestore(container); err != nil {
+		daemon.Cleanup(container)
+		return err
+	}
+	return daemon.waitForStart(container, true)
+}
+
+func (daemon *Daemon) prepareRestore(container *Container) error {
+	if err := daemon.conditionalMountOnStart(container); err != nil {
+		return err
+	}
+
+	container.hostConfig = runconfig.SetDefaultNetModeIfBlank(container.hostConfig)
+
+	// the network sandboxes of the previous daemon are gone, so the
+	// container gets new ones which are plugged into its network namespace
+	if err := daemon.initializeNetworking(container); err != nil {
+		return err
+	}
+	linkedEnv, err := daemon.setupLinkedContainers(container)
+	if err != nil {
+		return err
+	}
+	env := container.createDaemonEnvironment(linkedEnv)
+	if err := daemon.populateCommand(container, env); err != nil {
+		return err
+	}
+	if err := daemon.reattachNetworkNamespace(container); err != nil {
+		return err
+	}
+
+	mounts, err := daemon.setupMounts(container)
+	if err != nil {
+		return err
+	}
+	container.command.Mounts = mounts
+	return nil
+}
+
+func (daemon *Daemon) waitForStart(container *Container, reattach bool) error {
 	container.monitor = daemon.newContainerMonitor(container, container.hostConfig.RestartPolicy)
+	container.monitor.reattach = reattach
 
 	// block until we either receive an error from the initial start of the container's
 	// process or until the process is running in the container
@@ -38,6 +38,7 @@ func (daemon *Daemon) containerUnpause(container *Container) error {
 	}
 
 	container.Paused = false
+	daemon.updateHealthMonitor(container)
 	daemon.LogContainerEvent(container, "unpause")
 	return nil
 }
@@ -0,0 +1,139 @@
+package daemon
+
+import (
+	"fmt"
+
+	derr "github.com/docker/docker/errors"
+	"github.com/docker/docker/pkg/sysinfo"
+	"github.com/docker/docker/runconfig"
+	"github.com/docker/docker/utils"
+)
+
+// ContainerUpdate updates the resource limits and the restart policy of a
+// container. The new limits are applied to a running container right away
+// and are stored in its hostconfig so they are kept across restarts.
+func (daemon *Daemon) ContainerUpdate(name string, updateConfig *runconfig.UpdateConfig) ([]string, error) {
+	container, err := daemon.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if updateConfig == nil {
+		return nil, nil
+	}
+
+	warnings, err := daemon.update(container, updateConfig)
+	if err != nil {
+		return warnings, derr.ErrorCodeCantUpdate.WithArgs(container.ID, utils.GetErrorMessage(err))
+	}
+
+	daemon.LogContainerEvent(container, "update")
+	return warnings, nil
+}
+
+func (daemon *Daemon) update(container *Container, updateConfig *runconfig.UpdateConfig) ([]string, error) {
+	container.Lock()
+	defer container.Unlock()
+
+	if container.removalInProgress || container.Dead {
+		return nil, fmt.Errorf("Container is marked for removal and cannot be updated")
+	}
+
+	if err := validateRestartPolicy(updateConfig.RestartPolicy); err != nil {
+		return nil, err
+	}
+
+	// Work on a copy so that nothing changes unless the whole update succeeds.
+	hostConfig := *container.hostConfig
+	mergeUpdateConfig(&hostConfig, updateConfig)
+
+	if container.Running && hostConfig.KernelMemory != container.hostConfig.KernelMemory {
+		return nil, fmt.Errorf("Kernel memory limit of a running container cannot be updated, stop the container first")
+	}
+
+	warnings, err := verifyContainerResources(&hostConfig, sysinfo.New(true))
+	if err != nil {
+		return warnings, err
+	}
+
+	// The command is kept between restarts by the monitor, so it has to carry
+	// the new limits even when the container is not running at the moment.
+	if container.command != nil {
+		resources := *container.command.Resources
+		updateCommandResources(&resources, &hostConfig)
+
+		if container.Running && !container.Restarting {
+			current := container.command.Resources
+			container.command.Resources = &resources
+			if err := daemon.execDriver.Update(container.command); err != nil {
+				container.command.Resources = current
+				return warnings, err
+			}
+		} else {
+			container.command.Resources = &resources
+		}
+	}
+
+	container.hostConfig = &hostConfig
+	if container.monitor != nil {
+		container.monitor.setRestartPolicy(hostConfig.RestartPolicy)
+	}
+
+	return warnings, container.toDisk()
+}
+
+// mergeUpdateConfig copies the fields set in updateConfig to hostConfig.
+func mergeUpdateConfig(hostConfig *runconfig.HostConfig, updateConfig *runconfig.UpdateConfig) {
+	if updateConfig.BlkioWeight != 0 {
+		hostConfig.BlkioWeight = updateConfig.BlkioWeight
+	}
+	if updateConfig.CPUShares != 0 {
+		hostConfig.CPUShares = updateConfig.CPUShares
+	}
+	if updateConfig.CPUPeriod != 0 {
+		hostConfig.CPUPeriod = updateConfig.CPUPeriod
+	}
+	if updateConfig.CPUQuota != 0 {
+		hostConfig.CPUQuota = updateConfig.CPUQuota
+	}
+	if updateConfig.CpusetCpus != "" {
+		hostConfig.CpusetCpus = updateConfig.CpusetCpus
+	}
+	if updateConfig.CpusetMems != "" {
+		hostConfig.CpusetMems = updateConfig.CpusetMems
+	}
+	if updateConfig.KernelMemory != 0 {
+		hostConfig.KernelMemory = updateConfig.KernelMemory
+	}
+	if updateConfig.Memory != 0 {
+		hostConfig.Memory = updateConfig.Memory
+	}
+	if updateConfig.MemoryReservation != 0 {
+		hostConfig.MemoryReservation = updateConfig.MemoryReservation
+	}
+	if updateConfig.MemorySwap != 0 {
+		hostConfig.MemorySwap = updateConfig.MemorySwap
+	}
+	if updateConfig.RestartPolicy.Name != "" {
+		hostConfig.RestartPolicy = updateConfig.RestartPolicy
+	}
+}
+
+// validateRestartPolicy checks a restart policy received through the API,
+// as the client side parsing of runconfig.ParseRestartPolicy may have been
+// skipped. An empty policy is valid.
+func validateRestartPolicy(policy runconfig.RestartPolicy) error {
+	switch policy.Name {
+	case "", "no", "always", "unless-stopped":
+		if policy.MaximumRetryCount != 0 {
+			return fmt.Errorf("maximum restart count not valid with restart policy of %q", policy.Name)
+		}
+	case "on-failure":
+		if policy.MaximumRetryCount < 0 {
+			return fmt.Errorf("maximum restart count must be a positive integer")
+		}
+	default:
+		return fmt.Errorf("invalid restart policy %s", policy.Name)
+	}
+	return nil
+}
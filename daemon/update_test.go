@@ -0,0 +1,71 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/docker/docker/runconfig"
+)
+
+func TestMergeUpdateConfig(t *testing.T) {
+	hostConfig := &runconfig.HostConfig{
+		CPUShares:     512,
+		Memory:        300 * 1024 * 1024,
+		MemorySwap:    600 * 1024 * 1024,
+		RestartPolicy: runconfig.RestartPolicy{Name: "always"},
+	}
+
+	mergeUpdateConfig(hostConfig, &runconfig.UpdateConfig{
+		Memory:     400 * 1024 * 1024,
+		CpusetCpus: "0,1",
+	})
+
+	if hostConfig.Memory != 400*1024*1024 {
+		t.Fatalf("Expected memory to be updated, got %d", hostConfig.Memory)
+	}
+	if hostConfig.CpusetCpus != "0,1" {
+		t.Fatalf("Expected cpuset cpus to be updated, got %q", hostConfig.CpusetCpus)
+	}
+	if hostConfig.CPUShares != 512 {
+		t.Fatalf("Expected cpu shares to be left untouched, got %d", hostConfig.CPUShares)
+	}
+	if hostConfig.MemorySwap != 600*1024*1024 {
+		t.Fatalf("Expected memory swap to be left untouched, got %d", hostConfig.MemorySwap)
+	}
+	if !hostConfig.RestartPolicy.IsAlways() {
+		t.Fatalf("Expected restart policy to be left untouched, got %v", hostConfig.RestartPolicy)
+	}
+
+	mergeUpdateConfig(hostConfig, &runconfig.UpdateConfig{
+		RestartPolicy: runconfig.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3},
+	})
+	if !hostConfig.RestartPolicy.IsOnFailure() || hostConfig.RestartPolicy.MaximumRetryCount != 3 {
+		t.Fatalf("Expected restart policy to be updated, got %v", hostConfig.RestartPolicy)
+	}
+}
+
+func TestValidateRestartPolicy(t *testing.T) {
+	valid := []runconfig.RestartPolicy{
+		{},
+		{Name: "no"},
+		{Name: "always"},
+		{Name: "unless-stopped"},
+		{Name: "on-failure"},
+		{Name: "on-failure", MaximumRetryCount: 5},
+	}
+	for _, policy := range valid {
+		if err := validateRestartPolicy(policy); err != nil {
+			t.Fatalf("Expected %v to be valid, got %v", policy, err)
+		}
+	}
+
+	invalid := []runconfig.RestartPolicy{
+		{Name: "sometimes"},
+		{Name: "always", MaximumRetryCount: 1},
+		{Name: "on-failure", MaximumRetryCount: -1},
+	}
+	for _, policy := range invalid {
+		if err := validateRestartPolicy(policy); err == nil {
+			t.Fatalf("Expected %v to be invalid", policy)
+		}
+	}
+}
@@ -30,7 +30,13 @@ import (
 	"github.com/docker/docker/utils"
 )
 
-const daemonUsage = "       docker daemon [ --help | ... ]\n"
+const (
+	daemonUsage = "       docker daemon [ --help | ... ]\n"
+
+	// defaultDaemonConfigFile is the name of the daemon configuration file
+	// in the daemon configuration directory
+	defaultDaemonConfigFile = "daemon.json"
+)
 
 var (
 	flDaemon              = flag.Bool([]string{"#d", "#-daemon"}, false, "Enable daemon mode (deprecated; use docker daemon)")
@@ -74,15 +80,17 @@ func NewDaemonCli() *DaemonCli {
 	daemonConfig.ClusterOpts = make(map[string]string)
 	daemonConfig.InstallFlags(daemonFlags, presentInHelp)
 	daemonConfig.InstallFlags(flag.CommandLine, absentFromHelp)
-	registryOptions := new(registry.Options)
-	registryOptions.InstallFlags(daemonFlags, presentInHelp)
-	registryOptions.InstallFlags(flag.CommandLine, absentFromHelp)
+	daemonConfig.Options.InstallFlags(daemonFlags, presentInHelp)
+	daemonConfig.Options.InstallFlags(flag.CommandLine, absentFromHelp)
 	daemonFlags.Require(flag.Exact, 0)
 
-	return &DaemonCli{
-		Config:          daemonConfig,
-		registryOptions: registryOptions,
+	cli := &DaemonCli{
+		Config: daemonConfig,
 	}
+	configFile := filepath.Join(getDaemonConfDir(), defaultDaemonConfigFile)
+	daemonFlags.StringVar(&cli.configFile, []string{"-config-file"}, configFile, "Daemon configuration file")
+	flag.CommandLine.StringVar(&cli.configFile, []string{"-config-file"}, configFile, "")
+	return cli
 }
 
 func migrateKey() (err error) {
@@ -129,7 +137,7 @@ func migrateKey() (err error) {
 // DaemonCli represents the daemon CLI.
 type DaemonCli struct {
 	*daemon.Config
-	registryOptions *registry.Options
+	configFile string
 }
 
 func getGlobalFlag() (globalFlag *flag.Flag) {
@@ -170,6 +178,16 @@ func (cli *DaemonCli) CmdDaemon(args ...string) error {
 	daemonFlags.ParseFlags(args, true)
 	commonFlags.PostParse()
 
+	// The flags of the legacy `docker -d` form are parsed with the global flags
+	flags := daemonFlags
+	if *flDaemon {
+		flags = flag.CommandLine
+	}
+	cli.Config.Debug = commonFlags.Debug
+	if err := cli.loadConfigFile(flags); err != nil {
+		logrus.Fatal(err)
+	}
+
 	if commonFlags.TrustKey == "" {
 		commonFlags.TrustKey = filepath.Join(getDaemonConfDir(), defaultTrustKeyFile)
 	}
@@ -205,8 +223,9 @@ func (cli *DaemonCli) CmdDaemon(args ...string) error {
 	}
 
 	serverConfig := &apiserver.Config{
-		Logging: true,
-		Version: dockerversion.Version,
+		AuthorizationPluginNames: cli.Config.AuthorizationPlugins,
+		Logging:                  true,
+		Version:                  dockerversion.Version,
 	}
 	serverConfig = setPlatformServerConfig(serverConfig, cli.Config)
 
@@ -265,7 +284,7 @@ func (cli *DaemonCli) CmdDaemon(args ...string) error {
 	}
 	cli.TrustKeyPath = commonFlags.TrustKey
 
-	registryService := registry.NewService(cli.registryOptions)
+	registryService := registry.NewService(&cli.Config.Options)
 	d, err := daemon.NewDaemon(cli.Config, registryService)
 	if err != nil {
 		if pfile != nil {
@@ -287,6 +306,13 @@ func (cli *DaemonCli) CmdDaemon(args ...string) error {
 
 	api.InitRouters(d)
 
+	reload := func(config *daemon.Config) {
+		if err := d.Reload(config); err != nil {
+			logrus.Errorf("Error reconfiguring the daemon: %v", err)
+		}
+	}
+	setupConfigReloadTrap(cli.configFile, reload, flags, commonFlags.FlagSet)
+
 	signal.Trap(func() {
 		api.Close()
 		<-serveAPIWait
@@ -318,6 +344,22 @@ func (cli *DaemonCli) CmdDaemon(args ...string) error {
 	return nil
 }
 
+// loadConfigFile merges the daemon configuration file with the flags. The
+// default configuration file is optional.
+func (cli *DaemonCli) loadConfigFile(flags *flag.FlagSet) error {
+	if _, err := os.Stat(cli.configFile); os.IsNotExist(err) && !flags.IsSet("-config-file") {
+		return nil
+	}
+	if err := daemon.MergeDaemonConfigurations(cli.Config, cli.configFile, flags, commonFlags.FlagSet); err != nil {
+		return fmt.Errorf("unable to configure the Docker daemon with file %s: %v", cli.configFile, err)
+	}
+	if cli.Config.Debug && !commonFlags.Debug {
+		os.Setenv("DEBUG", "1")
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+	return nil
+}
+
 // shutdownDaemon just wraps daemon.Shutdown() to handle a timeout in case
 // d.Shutdown() is waiting too long to kill container or worst it's
 // blocked there
@@ -5,10 +5,13 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/signal"
 	"syscall"
 
+	"github.com/Sirupsen/logrus"
 	apiserver "github.com/docker/docker/api/server"
 	"github.com/docker/docker/daemon"
+	flag "github.com/docker/docker/pkg/mflag"
 	"github.com/docker/docker/pkg/system"
 
 	_ "github.com/docker/docker/daemon/execdriver/native"
@@ -48,3 +51,17 @@ func setDefaultUmask() error {
 func getDaemonConfDir() string {
 	return "/etc/docker"
 }
+
+// setupConfigReloadTrap reloads the daemon configuration file when the
+// daemon receives SIGHUP.
+func setupConfigReloadTrap(configFile string, reload func(*daemon.Config), flags ...*flag.FlagSet) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	go func() {
+		for range c {
+			if err := daemon.ReloadConfiguration(configFile, reload, flags...); err != nil {
+				logrus.Error(err)
+			}
+		}
+	}()
+}
@@ -3,10 +3,15 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"syscall"
 
+	"github.com/Sirupsen/logrus"
 	apiserver "github.com/docker/docker/api/server"
 	"github.com/docker/docker/daemon"
+	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/pkg/system"
 )
 
 func setPlatformServerConfig(serverConfig *apiserver.Config, daemonCfg *daemon.Config) *apiserver.Config {
@@ -31,3 +36,24 @@ func getDaemonConfDir() string {
 // notifySystem sends a message to the host when the server is ready to be used
 func notifySystem() {
 }
+
+// setupConfigReloadTrap reloads the daemon configuration file when the
+// daemon is signalled. Windows does not support signals like *nix systems,
+// so instead of trapping SIGHUP, we wait on a Win32 event to be signalled.
+func setupConfigReloadTrap(configFile string, reload func(*daemon.Config), flags ...*flag.FlagSet) {
+	go func() {
+		sa := syscall.SecurityAttributes{
+			Length: 0,
+		}
+		ev := "Global\\docker-daemon-config-" + fmt.Sprint(os.Getpid())
+		if h, _ := system.CreateEvent(&sa, false, false, ev); h != 0 {
+			logrus.Debugf("Config reload - waiting signal at %s", ev)
+			for {
+				syscall.WaitForSingleObject(h, syscall.INFINITE)
+				if err := daemon.ReloadConfiguration(configFile, reload, flags...); err != nil {
+					logrus.Error(err)
+				}
+			}
+		}
+	}()
+}
@@ -73,6 +73,51 @@ var (
 		HTTPStatusCode: http.StatusInternalServerError,
 	})
 
+	// ErrorCodeBadFrom is generated when the parser comes across a FROM
+	// command whose arguments are neither an image nor an image and a name.
+	ErrorCodeBadFrom = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:          "BADFROM",
+		Message:        "FROM requires either one argument, or three: FROM <image> AS <name>",
+		Description:    "The FROM command was passed invalid arguments",
+		HTTPStatusCode: http.StatusInternalServerError,
+	})
+
+	// ErrorCodeDuplicateStage is generated when two build stages of a
+	// Dockerfile have the same name.
+	ErrorCodeDuplicateStage = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:          "DUPLICATESTAGE",
+		Message:        "duplicate name %s for build stages",
+		Description:    "Two build stages of the Dockerfile have the same name",
+		HTTPStatusCode: http.StatusInternalServerError,
+	})
+
+	// ErrorCodeStageNotFound is generated when the build target is not
+	// one of the stages of the Dockerfile.
+	ErrorCodeStageNotFound = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:          "STAGENOTFOUND",
+		Message:        "failed to reach build target %s in Dockerfile",
+		Description:    "The build target is not a stage of the Dockerfile",
+		HTTPStatusCode: http.StatusInternalServerError,
+	})
+
+	// ErrorCodeNotJSON is generated when the arguments of a Dockerfile
+	// command which only has a JSON form are not a JSON array.
+	ErrorCodeNotJSON = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:          "NOTJSON",
+		Message:        "%s requires the arguments to be in JSON form",
+		Description:    "The command was passed arguments which are not a JSON array",
+		HTTPStatusCode: http.StatusInternalServerError,
+	})
+
+	// ErrorCodeSecretNotFound is generated when a RUN instruction asks for
+	// a build secret which was not passed to the build.
+	ErrorCodeSecretNotFound = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:          "SECRETNOTFOUND",
+		Message:        "secret %s was not passed to the build",
+		Description:    "The RUN instruction asked for a build secret which was not passed to the build",
+		HTTPStatusCode: http.StatusInternalServerError,
+	})
+
 	// ErrorCodeNotOnWindows is generated when the specified Dockerfile
 	// command is not supported on Windows.
 	ErrorCodeNotOnWindows = errcode.Register(errGroup, errcode.ErrorDescriptor{
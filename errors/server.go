@@ -33,4 +33,13 @@ var (
 		Description:    "Docker's networking stack is disabled for this platform",
 		HTTPStatusCode: http.StatusNotFound,
 	})
+
+	// ErrorCodeAuthorizationDenied is generated when an authorization plugin
+	// denies a request or its response.
+	ErrorCodeAuthorizationDenied = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:          "AUTHORIZATIONDENIED",
+		Message:        "authorization denied by plugin %s: %s",
+		Description:    "An authorization plugin denied the request",
+		HTTPStatusCode: http.StatusForbidden,
+	})
 )
@@ -0,0 +1,126 @@
+package graph
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/digest"
+)
+
+const (
+	// blobSourcesFileName is the name of the file of the blob source
+	// store, next to the file of the tag store.
+	blobSourcesFileName = "blobsources.json"
+	// maxBlobSources is the number of repositories remembered for each
+	// blob.
+	maxBlobSources = 5
+)
+
+// blobSource is a repository of a registry a blob was seen in.
+type blobSource struct {
+	// Registry is the URL of the registry endpoint.
+	Registry string `json:"registry"`
+	// Repository is the name of the repository on the registry.
+	Repository string `json:"repository"`
+}
+
+// blobSourceStore records the repositories each blob was last pulled from
+// or pushed to, so a push can mount a blob from another repository of the
+// same registry instead of uploading it.
+type blobSourceStore struct {
+	path string
+
+	mu      sync.Mutex
+	sources map[digest.Digest][]blobSource
+}
+
+// newBlobSourceStore returns the blob source store saved at path, which is
+// empty if it doesn't exist yet. The store is only a cache of where to mount
+// blobs from, so it is also empty if it can't be read.
+func newBlobSourceStore(path string) *blobSourceStore {
+	s := &blobSourceStore{
+		path:    path,
+		sources: make(map[digest.Digest][]blobSource),
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &s.sources)
+	}
+	if err != nil {
+		logrus.Warnf("Ignoring the blob sources of %s: %v", path, err)
+		s.sources = make(map[digest.Digest][]blobSource)
+	}
+	return s
+}
+
+// add records that the blobs dgsts were seen in the repository src, which
+// becomes their most recent source, and saves the store.
+func (s *blobSourceStore) add(src blobSource, dgsts ...digest.Digest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, dgst := range dgsts {
+		sources := []blobSource{src}
+		for _, old := range s.sources[dgst] {
+			if old != src && len(sources) < maxBlobSources {
+				sources = append(sources, old)
+			}
+		}
+		s.sources[dgst] = sources
+	}
+
+	data, err := json.Marshal(s.sources)
+	if err != nil {
+		return err
+	}
+	return s.write(data)
+}
+
+// write replaces the file of the store with data. The data is written to a
+// temporary file renamed into place, so that the file is never left partially
+// written.
+func (s *blobSourceStore) write(data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(s.path), "."+blobSourcesFileName)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0600)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// get returns the repositories of registry the blob dgst was seen in, from
+// the most recent one.
+func (s *blobSourceStore) get(dgst digest.Digest, registry string) []blobSource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sources []blobSource
+	for _, src := range s.sources[dgst] {
+		if src.Registry == registry {
+			sources = append(sources, src)
+		}
+	}
+	return sources
+}
@@ -0,0 +1,94 @@
+package graph
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/docker/distribution/digest"
+)
+
+func TestBlobSources(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "docker-blobsources-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	path := filepath.Join(tmp, blobSourcesFileName)
+
+	s := newBlobSourceStore(path)
+	dgst := digest.Digest("sha256:4b1f0a4c3a3da9ba1b1b6e4d1b1f0a4c3a3da9ba1b1b6e4d1b1f0a4c3a3da9ba")
+	if sources := s.get(dgst, "https://registry.example.com"); len(sources) != 0 {
+		t.Fatalf("Expected no sources, got %v", sources)
+	}
+
+	for i := 0; i < maxBlobSources+1; i++ {
+		if err := s.add(blobSource{Registry: "https://registry.example.com", Repository: fmt.Sprintf("foo/repo%d", i)}, dgst); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.add(blobSource{Registry: "https://registry.example.com", Repository: "foo/repo3"}, dgst); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.add(blobSource{Registry: "https://mirror.example.com", Repository: "foo/bar"}, dgst); err != nil {
+		t.Fatal(err)
+	}
+
+	// the sources are listed from the most recent one, once each
+	expected := []blobSource{
+		{Registry: "https://registry.example.com", Repository: "foo/repo3"},
+		{Registry: "https://registry.example.com", Repository: "foo/repo5"},
+		{Registry: "https://registry.example.com", Repository: "foo/repo4"},
+		{Registry: "https://registry.example.com", Repository: "foo/repo2"},
+	}
+	if sources := s.get(dgst, "https://registry.example.com"); !reflect.DeepEqual(sources, expected) {
+		t.Fatalf("Expected the sources %v, got %v", expected, sources)
+	}
+
+	// the sources are kept across restarts
+	s = newBlobSourceStore(path)
+	if sources := s.get(dgst, "https://registry.example.com"); !reflect.DeepEqual(sources, expected) {
+		t.Fatalf("Expected the sources %v after a restart, got %v", expected, sources)
+	}
+	if sources := s.get(dgst, "https://mirror.example.com"); len(sources) != 1 || sources[0].Repository != "foo/bar" {
+		t.Fatalf("Expected the source of the mirror, got %v", sources)
+	}
+}
+
+func TestBlobSourcesCorrupted(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "docker-blobsources-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	path := filepath.Join(tmp, blobSourcesFileName)
+	if err := ioutil.WriteFile(path, []byte(`{"sha256:4b1f`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// a corrupted store is started over
+	s := newBlobSourceStore(path)
+	dgst := digest.Digest("sha256:4b1f0a4c3a3da9ba1b1b6e4d1b1f0a4c3a3da9ba1b1b6e4d1b1f0a4c3a3da9ba")
+	if sources := s.get(dgst, "https://registry.example.com"); len(sources) != 0 {
+		t.Fatalf("Expected no sources, got %v", sources)
+	}
+	if err := s.add(blobSource{Registry: "https://registry.example.com", Repository: "foo/bar"}, dgst); err != nil {
+		t.Fatal(err)
+	}
+	s = newBlobSourceStore(path)
+	if sources := s.get(dgst, "https://registry.example.com"); len(sources) != 1 || sources[0].Repository != "foo/bar" {
+		t.Fatalf("Expected the source to be saved, got %v", sources)
+	}
+
+	// the store is written through a temporary file
+	files, err := ioutil.ReadDir(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != blobSourcesFileName {
+		t.Fatalf("Expected only the file of the store, got %v", files)
+	}
+}
@@ -0,0 +1,23 @@
+package graph
+
+import (
+	eventtypes "github.com/docker/docker/api/types/events"
+)
+
+// LogImageEvent generates an event related to an image. The ID of the event
+// is imageID, which can be an image ID or a reference to an image. The labels
+// of the image are part of the attributes when the image can be found.
+func (store *TagStore) LogImageEvent(imageID, action string) {
+	attributes := map[string]string{}
+	if img, err := store.LookupImage(imageID); err == nil && img.ContainerConfig.Labels != nil {
+		for k, v := range img.ContainerConfig.Labels {
+			attributes[k] = v
+		}
+	}
+
+	actor := eventtypes.Actor{
+		ID:         imageID,
+		Attributes: attributes,
+	}
+	store.eventsService.Log(action, eventtypes.ImageEventType, actor)
+}
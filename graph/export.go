@@ -139,7 +139,7 @@ func (s *TagStore) exportImage(name, tempdir string) error {
 			return err
 		}
 
-		imageInspectRaw, err := json.Marshal(img)
+		imageInspectRaw, err := s.graph.exportConfig(img)
 		if err != nil {
 			return err
 		}
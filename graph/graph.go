@@ -1,7 +1,7 @@
 package graph
 
 import (
-	"compress/gzip"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,7 +10,6 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -28,8 +27,6 @@ import (
 	"github.com/docker/docker/pkg/stringid"
 	"github.com/docker/docker/pkg/truncindex"
 	"github.com/docker/docker/runconfig"
-	"github.com/vbatts/tar-split/tar/asm"
-	"github.com/vbatts/tar-split/tar/storage"
 )
 
 // v1Descriptor is a non-content-addressable image descriptor
@@ -52,6 +49,29 @@ func (img v1Descriptor) MarshalConfig() ([]byte, error) {
 	return json.Marshal(img.img)
 }
 
+// v1ConfigDescriptor is an image descriptor for a raw v1 image
+// configuration, which is registered as is.
+type v1ConfigDescriptor struct {
+	id     string
+	parent string
+	config []byte
+}
+
+// ID returns the v1 ID of the image.
+func (img v1ConfigDescriptor) ID() string {
+	return img.id
+}
+
+// Parent returns the ID of the parent image.
+func (img v1ConfigDescriptor) Parent() string {
+	return img.parent
+}
+
+// MarshalConfig returns the raw configuration.
+func (img v1ConfigDescriptor) MarshalConfig() ([]byte, error) {
+	return img.config, nil
+}
+
 // The type is used to protect pulling or building related image
 // layers from deleteing when filtered by dangling=true
 // The key of layers is the images ID which is pulling or building
@@ -96,25 +116,52 @@ func (r *retainedLayers) Exists(layerID string) bool {
 }
 
 // A Graph is a store for versioned filesystem images and the relationship between them.
+//
+// The ID of an image is the digest of the configuration stored for it, which
+// references the digest of its layer and the ID of its parent. It isn't the
+// digest of the image configuration of a schema2 manifest, which lists all the
+// layers of the image and is rebuilt on push. The layers are kept in a layerStore, and shared by all the images
+// holding the same changes on top of the same layers.
 type Graph struct {
-	root             string
-	idIndex          *truncindex.TruncIndex
-	driver           graphdriver.Driver
-	imagesMutex      sync.Mutex
-	imageMutex       locker.Locker // protect images in driver.
-	retained         *retainedLayers
-	tarSplitDisabled bool
-	uidMaps          []idtools.IDMap
-	gidMaps          []idtools.IDMap
+	root        string
+	idIndex     *truncindex.TruncIndex
+	driver      graphdriver.Driver
+	layers      *layerStore
+	imagesMutex sync.Mutex
+	imageMutex  locker.Locker // protect images in driver.
+	retained    *retainedLayers
+	uidMaps     []idtools.IDMap
+	gidMaps     []idtools.IDMap
 
 	// access to parentRefs must be protected with imageMutex locking the image id
 	// on the key of the map (e.g. imageMutex.Lock(img.ID), parentRefs[img.ID]...)
 	parentRefs map[string]int
+
+	// indexMutex protects the maps below.
+	indexMutex sync.Mutex
+	// imageLayers maps the IDs of the images to their layer.
+	imageLayers map[string]*layer
+	// v1IDs maps the v1 IDs the images were registered with, by pulls from
+	// v1 registries, loads of saved images or the migration of the graph,
+	// to their IDs.
+	v1IDs map[string]string
+	// blobDiffIDs maps the digests of the compressed layers which have been
+	// pulled or pushed to the digests of the uncompressed layers.
+	blobDiffIDs map[digest.Digest]digest.Digest
 }
 
-// file names for ./graph/<ID>/
+// directory names for ./graph/
+const (
+	imageDBDirName = "imagedb"
+	layerDBDirName = "layerdb"
+)
+
+// file names for ./graph/imagedb/<ID>/ and, for the graphs to migrate,
+// ./graph/<ID>/
 const (
 	jsonFileName            = "json"
+	layerFileName           = "layer"
+	v1IDFileName            = "v1id"
 	layersizeFileName       = "layersize"
 	digestFileName          = "checksum"
 	tarDataFileName         = "tar-data.json.gz"
@@ -129,8 +176,13 @@ var (
 	errDigestNotSet = errors.New("digest is not set for layer")
 )
 
+// emptyTar is a tar archive holding no files, the layer of the images
+// registered without one.
+var emptyTar = make([]byte, 1024)
+
 // NewGraph instantiates a new graph at the given root path in the filesystem.
-// `root` will be created if it doesn't exist.
+// `root` will be created if it doesn't exist, and the images of a graph
+// created by a previous version are migrated.
 func NewGraph(root string, driver graphdriver.Driver, uidMaps, gidMaps []idtools.IDMap) (*Graph, error) {
 	abspath, err := filepath.Abs(root)
 	if err != nil {
@@ -142,28 +194,35 @@ func NewGraph(root string, driver graphdriver.Driver, uidMaps, gidMaps []idtools
 		return nil, err
 	}
 	// Create the root directory if it doesn't exists
-	if err := idtools.MkdirAllAs(root, 0700, rootUID, rootGID); err != nil && !os.IsExist(err) {
+	if err := idtools.MkdirAllAs(filepath.Join(root, imageDBDirName), 0700, rootUID, rootGID); err != nil && !os.IsExist(err) {
 		return nil, err
 	}
 
 	graph := &Graph{
-		root:       abspath,
-		idIndex:    truncindex.NewTruncIndex([]string{}),
-		driver:     driver,
-		retained:   &retainedLayers{layerHolders: make(map[string]map[string]struct{})},
-		uidMaps:    uidMaps,
-		gidMaps:    gidMaps,
-		parentRefs: make(map[string]int),
+		root:        abspath,
+		idIndex:     truncindex.NewTruncIndex([]string{}),
+		driver:      driver,
+		retained:    &retainedLayers{layerHolders: make(map[string]map[string]struct{})},
+		uidMaps:     uidMaps,
+		gidMaps:     gidMaps,
+		parentRefs:  make(map[string]int),
+		imageLayers: make(map[string]*layer),
+		v1IDs:       make(map[string]string),
+		blobDiffIDs: make(map[digest.Digest]digest.Digest),
 	}
 
 	// Windows does not currently support tarsplit functionality.
-	if runtime.GOOS == "windows" {
-		graph.tarSplitDisabled = true
+	graph.layers, err = newLayerStore(filepath.Join(abspath, layerDBDirName), driver, runtime.GOOS == "windows")
+	if err != nil {
+		return nil, err
 	}
 
 	if err := graph.restore(); err != nil {
 		return nil, err
 	}
+	if err := graph.migrate(); err != nil {
+		return nil, err
+	}
 	return graph, nil
 }
 
@@ -173,24 +232,18 @@ func (graph *Graph) IsHeld(layerID string) bool {
 }
 
 func (graph *Graph) restore() error {
-	dir, err := ioutil.ReadDir(graph.root)
+	dir, err := ioutil.ReadDir(graph.imagesRoot())
 	if err != nil {
 		return err
 	}
 	var ids = []string{}
 	for _, v := range dir {
 		id := v.Name()
-		if graph.driver.Exists(id) {
-			img, err := graph.loadImage(id)
-			if err != nil {
-				logrus.Warnf("ignoring image %s, it could not be restored: %v", id, err)
-				continue
-			}
-			graph.imageMutex.Lock(img.Parent)
-			graph.parentRefs[img.Parent]++
-			graph.imageMutex.Unlock(img.Parent)
-			ids = append(ids, id)
+		if err := graph.restoreImage(id); err != nil {
+			logrus.Warnf("ignoring image %s, it could not be restored: %v", id, err)
+			continue
 		}
+		ids = append(ids, id)
 	}
 
 	graph.idIndex = truncindex.NewTruncIndex(ids)
@@ -198,6 +251,42 @@ func (graph *Graph) restore() error {
 	return nil
 }
 
+// restoreImage takes a reference to the layer of the image id, and indexes
+// the image.
+func (graph *Graph) restoreImage(id string) error {
+	root := graph.imageRoot(id)
+	chainID, err := ioutil.ReadFile(filepath.Join(root, layerFileName))
+	if err != nil {
+		return err
+	}
+	l, err := graph.layers.acquire(digest.Digest(chainID))
+	if err != nil {
+		return err
+	}
+	img, err := graph.loadImage(id)
+	if err != nil {
+		graph.layers.release(l)
+		return err
+	}
+
+	graph.indexMutex.Lock()
+	graph.imageLayers[id] = l
+	if v1IDs, err := ioutil.ReadFile(filepath.Join(root, v1IDFileName)); err == nil {
+		for _, v1ID := range strings.Fields(string(v1IDs)) {
+			graph.v1IDs[v1ID] = id
+		}
+	}
+	if dgst, err := graph.getLayerDigest(id); err == nil {
+		graph.blobDiffIDs[dgst] = l.diffID
+	}
+	graph.indexMutex.Unlock()
+
+	graph.imageMutex.Lock(img.Parent)
+	graph.parentRefs[img.Parent]++
+	graph.imageMutex.Unlock(img.Parent)
+	return nil
+}
+
 // IsNotExist detects whether an image exists by parsing the incoming error
 // message.
 func (graph *Graph) IsNotExist(err error, id string) bool {
@@ -215,41 +304,28 @@ func (graph *Graph) Exists(id string) bool {
 	return true
 }
 
-// Get returns the image with the given id, or an error if the image doesn't exist.
+// Get returns the image with the given id, or an error if the image doesn't
+// exist. The image may also be named by one of the v1 IDs it was registered
+// with.
 func (graph *Graph) Get(name string) (*image.Image, error) {
 	id, err := graph.idIndex.Get(name)
 	if err != nil {
-		if err == truncindex.ErrNotExist {
-			return nil, fmt.Errorf("image %s does not exist", name)
-		}
-		return nil, err
-	}
-	img, err := graph.loadImage(id)
-	if err != nil {
-		return nil, err
-	}
-	if img.ID != id {
-		return nil, fmt.Errorf("Image stored at '%s' has wrong id '%s'", id, img.ID)
-	}
-
-	if img.Size < 0 {
-		size, err := graph.driver.DiffSize(img.ID, img.Parent)
-		if err != nil {
-			return nil, fmt.Errorf("unable to calculate size of image id %q: %s", img.ID, err)
-		}
-
-		img.Size = size
-		if err := graph.saveSize(graph.imageRoot(id), img.Size); err != nil {
+		if err != truncindex.ErrNotExist {
 			return nil, err
 		}
+		graph.indexMutex.Lock()
+		id = graph.v1IDs[name]
+		graph.indexMutex.Unlock()
+		if id == "" {
+			return nil, fmt.Errorf("image %s does not exist", name)
+		}
 	}
-	return img, nil
+	return graph.loadImage(id)
 }
 
 // Create creates a new image and registers it in the graph.
 func (graph *Graph) Create(layerData io.Reader, containerID, containerImage, comment, author string, containerConfig, config *runconfig.Config) (*image.Image, error) {
 	img := &image.Image{
-		ID:            stringid.GenerateRandomID(),
 		Comment:       comment,
 		Created:       time.Now().UTC(),
 		DockerVersion: dockerversion.Version,
@@ -265,106 +341,202 @@ func (graph *Graph) Create(layerData io.Reader, containerID, containerImage, com
 		img.ContainerConfig = *containerConfig
 	}
 
-	if err := graph.Register(v1Descriptor{img}, layerData); err != nil {
-		return nil, err
-	}
-	return img, nil
+	return graph.Register(v1Descriptor{img}, layerData)
 }
 
-// Register imports a pre-existing image into the graph.
-// Returns nil if the image is already registered.
-func (graph *Graph) Register(im image.Descriptor, layerData io.Reader) (err error) {
-	imgID := im.ID()
-
-	if err := image.ValidateID(imgID); err != nil {
-		return err
+// Register registers an image with the layer layerData in the graph, and
+// returns it. The ID of the image is the digest of its configuration, which
+// references the digest of the uncompressed layer and the ID of the parent;
+// the image is returned as is if it is already registered. The descriptor
+// may name the parent by one of its v1 IDs, and may give the v1 ID of the
+// image, by which the image can then be looked up too.
+func (graph *Graph) Register(im image.Descriptor, layerData io.Reader) (*image.Image, error) {
+	if v1ID := im.ID(); v1ID != "" {
+		if err := image.ValidateID(v1ID); err != nil {
+			return nil, err
+		}
 	}
 
-	// this is needed cause pull_v2 attemptIDReuse could deadlock
 	graph.imagesMutex.Lock()
 	defer graph.imagesMutex.Unlock()
 
-	// We need this entire operation to be atomic within the engine. Note that
-	// this doesn't mean Register is fully safe yet.
-	graph.imageMutex.Lock(imgID)
-	defer graph.imageMutex.Unlock(imgID)
-
 	return graph.register(im, layerData)
 }
 
-func (graph *Graph) register(im image.Descriptor, layerData io.Reader) (err error) {
-	imgID := im.ID()
+func (graph *Graph) register(im image.Descriptor, layerData io.Reader) (*image.Image, error) {
+	if v1ID := im.ID(); v1ID != "" {
+		if img, err := graph.Get(v1ID); err == nil {
+			return img, nil
+		}
+	}
 
-	// Skip register if image is already registered
-	if graph.Exists(imgID) {
-		return nil
+	var parentLayer digest.Digest
+	if name := im.Parent(); name != "" {
+		parent, err := graph.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		parentLayer = graph.imageLayer(parent.ID).chainID
+	}
+	if layerData == nil {
+		layerData = bytes.NewReader(emptyTar)
+	}
+	l, err := graph.layers.register(parentLayer, layerData)
+	if err != nil {
+		return nil, err
 	}
+	return graph.registerWithLayer(im, l)
+}
 
-	// The returned `error` must be named in this function's signature so that
-	// `err` is not shadowed in this deferred cleanup.
+// registerWithLayer registers an image whose layer is l, which must be based
+// on the layer of the parent of the image. The reference to l is taken over
+// by the image, or released if the image isn't registered.
+func (graph *Graph) registerWithLayer(im image.Descriptor, l *layer) (_ *image.Image, err error) {
 	defer func() {
-		// If any error occurs, remove the new dir from the driver.
-		// Don't check for errors since the dir might not have been created.
 		if err != nil {
-			graph.driver.Remove(imgID)
+			graph.layers.release(l)
 		}
 	}()
 
-	// Ensure that the image root does not exist on the filesystem
-	// when it is not registered in the graph.
-	// This is common when you switch from one graph driver to another
-	if err := os.RemoveAll(graph.imageRoot(imgID)); err != nil && !os.IsNotExist(err) {
-		return err
+	var parent *image.Image
+	if name := im.Parent(); name != "" {
+		if parent, err = graph.Get(name); err != nil {
+			return nil, err
+		}
+	}
+	if parentLayer := graph.imageLayer(parentID(parent)); parentLayer != l.parent {
+		return nil, fmt.Errorf("layer %s isn't based on the layer of the parent image", l.chainID)
 	}
 
-	// If the driver has this ID but the graph doesn't, remove it from the driver to start fresh.
-	// (the graph is the source of truth).
-	// Ignore errors, since we don't know if the driver correctly returns ErrNotExist.
-	// (FIXME: make that mandatory for drivers).
-	graph.driver.Remove(imgID)
-
-	tmp, err := graph.mktemp()
+	config, err := im.MarshalConfig()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer os.RemoveAll(tmp)
+	var parentDigest digest.Digest
+	if parent != nil {
+		parentDigest = digest.NewDigestFromHex(string(digest.Canonical), parent.ID)
+	}
+	if config, err = image.MakeImageConfig(config, l.diffID, parentDigest); err != nil {
+		return nil, err
+	}
+	dgst, err := image.StrongID(config)
+	if err != nil {
+		return nil, err
+	}
+	id := dgst.Hex()
 
-	parent := im.Parent()
+	// We need this entire operation to be atomic within the engine. Note that
+	// this doesn't mean Register is fully safe yet.
+	graph.imageMutex.Lock(id)
+	defer graph.imageMutex.Unlock(id)
 
-	// Create root filesystem in the driver
-	if err := createRootFilesystemInDriver(graph, imgID, parent); err != nil {
-		return err
+	v1ID := im.ID()
+	if v1ID == id {
+		// saved images are loaded with their IDs
+		v1ID = ""
+	}
+	if _, exists := graph.imageLayers[id]; exists {
+		// the image already holds a reference to its layer
+		graph.layers.release(l)
+		if v1ID != "" {
+			if err := graph.addV1ID(id, v1ID); err != nil {
+				return nil, err
+			}
+		}
+		return graph.Get(id)
 	}
 
-	// Apply the diff/layer
-	config, err := im.MarshalConfig()
+	tmp, err := graph.mktemp()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if err := graph.storeImage(imgID, parent, config, layerData, tmp); err != nil {
-		return err
+	defer os.RemoveAll(tmp)
+	if err := ioutil.WriteFile(jsonPath(tmp), config, 0600); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmp, layerFileName), []byte(l.chainID), 0600); err != nil {
+		return nil, err
+	}
+	if v1ID != "" {
+		if err := ioutil.WriteFile(filepath.Join(tmp, v1IDFileName), []byte(v1ID+"\n"), 0600); err != nil {
+			return nil, err
+		}
 	}
 	// Commit
-	if err := os.Rename(tmp, graph.imageRoot(imgID)); err != nil {
-		return err
+	if err := os.Rename(tmp, graph.imageRoot(id)); err != nil {
+		return nil, err
 	}
 
-	graph.idIndex.Add(imgID)
+	graph.indexMutex.Lock()
+	graph.imageLayers[id] = l
+	if v1ID != "" {
+		graph.v1IDs[v1ID] = id
+	}
+	graph.indexMutex.Unlock()
+	graph.idIndex.Add(id)
 
-	graph.imageMutex.Lock(parent)
-	graph.parentRefs[parent]++
-	graph.imageMutex.Unlock(parent)
+	parentRef := parentID(parent)
+	graph.imageMutex.Lock(parentRef)
+	graph.parentRefs[parentRef]++
+	graph.imageMutex.Unlock(parentRef)
 
-	return nil
+	return graph.loadImage(id)
 }
 
-func createRootFilesystemInDriver(graph *Graph, id, parent string) error {
-	if err := graph.driver.Create(id, parent); err != nil {
-		return fmt.Errorf("Driver %s failed to create image rootfs %s: %s", graph.driver, id, err)
+// addV1ID records that the image id was also registered with the v1 ID v1ID.
+func (graph *Graph) addV1ID(id, v1ID string) error {
+	graph.indexMutex.Lock()
+	defer graph.indexMutex.Unlock()
+	if graph.v1IDs[v1ID] == id {
+		return nil
+	}
+	f, err := os.OpenFile(filepath.Join(graph.imageRoot(id), v1IDFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(v1ID + "\n"); err != nil {
+		return err
 	}
+	graph.v1IDs[v1ID] = id
 	return nil
 }
 
+func parentID(parent *image.Image) string {
+	if parent == nil {
+		return ""
+	}
+	return parent.ID
+}
+
+// imageLayer returns the layer of the image id, or nil if there is no such
+// image.
+func (graph *Graph) imageLayer(id string) *layer {
+	graph.indexMutex.Lock()
+	defer graph.indexMutex.Unlock()
+	return graph.imageLayers[id]
+}
+
+// LayerStorageID returns the ID under which the graph driver stores the layer
+// of the image id, on top of which the filesystems of its containers are
+// created.
+func (graph *Graph) LayerStorageID(id string) (string, error) {
+	img, err := graph.Get(id)
+	if err != nil {
+		return "", err
+	}
+	return graph.imageLayer(img.ID).cacheID, nil
+}
+
+// diffIDForBlob returns the digest of the uncompressed layer which has been
+// pulled or pushed compressed with the digest blobSum.
+func (graph *Graph) diffIDForBlob(blobSum digest.Digest) (digest.Digest, bool) {
+	graph.indexMutex.Lock()
+	defer graph.indexMutex.Unlock()
+	diffID, ok := graph.blobDiffIDs[blobSum]
+	return diffID, ok
+}
+
 // TempLayerArchive creates a temporary archive of the given image's filesystem layer.
 //   The archive is stored on disk and will be automatically deleted as soon as has been read.
 //   If output is not nil, a human-readable progress bar will be written to it.
@@ -408,16 +580,13 @@ func (graph *Graph) mktemp() (string, error) {
 	return dir, nil
 }
 
-// Delete atomically removes an image from the graph.
+// Delete atomically removes an image from the graph, and releases its layer.
 func (graph *Graph) Delete(name string) error {
-	id, err := graph.idIndex.Get(name)
-	if err != nil {
-		return err
-	}
-	img, err := graph.Get(id)
+	img, err := graph.Get(name)
 	if err != nil {
 		return err
 	}
+	id := img.ID
 	graph.idIndex.Delete(id)
 	tmp, err := graph.mktemp()
 	if err != nil {
@@ -429,8 +598,20 @@ func (graph *Graph) Delete(name string) error {
 			tmp = graph.imageRoot(id)
 		}
 	}
-	// Remove rootfs data from the driver
-	graph.driver.Remove(id)
+
+	graph.indexMutex.Lock()
+	l := graph.imageLayers[id]
+	delete(graph.imageLayers, id)
+	for v1ID, i := range graph.v1IDs {
+		if i == id {
+			delete(graph.v1IDs, v1ID)
+		}
+	}
+	graph.indexMutex.Unlock()
+	// Remove rootfs data from the driver, unless other images share it
+	if l != nil {
+		graph.layers.release(l)
+	}
 
 	graph.imageMutex.Lock(img.Parent)
 	graph.parentRefs[img.Parent]--
@@ -520,20 +701,26 @@ func (graph *Graph) heads() map[string]*image.Image {
 
 // tarLayer returns a tar archive of the image's filesystem layer.
 func (graph *Graph) tarLayer(img *image.Image) (arch io.ReadCloser, err error) {
-	rdr, err := graph.assembleTarLayer(img)
-	if err != nil {
-		logrus.Debugf("[graph] tarLayer with traditional differ: %s", img.ID)
-		return graph.driver.Diff(img.ID, img.Parent)
+	l := graph.imageLayer(img.ID)
+	if l == nil {
+		return nil, fmt.Errorf("image %s does not exist", img.ID)
 	}
-	return rdr, nil
+	return graph.layers.tarStream(l)
+}
+
+func (graph *Graph) imagesRoot() string {
+	return filepath.Join(graph.root, imageDBDirName)
 }
 
 func (graph *Graph) imageRoot(id string) string {
-	return filepath.Join(graph.root, id)
+	return filepath.Join(graph.imagesRoot(), id)
 }
 
 // loadImage fetches the image with the given id from the graph.
 func (graph *Graph) loadImage(id string) (*image.Image, error) {
+	if err := image.ValidateID(id); err != nil {
+		return nil, err
+	}
 	root := graph.imageRoot(id)
 
 	// Open the JSON file to decode by streaming
@@ -551,53 +738,20 @@ func (graph *Graph) loadImage(id string) (*image.Image, error) {
 		return nil, err
 	}
 
-	if img.ID == "" {
-		img.ID = id
-	}
-
-	if img.Parent == "" && img.ParentID != "" && img.ParentID.Validate() == nil {
-		img.Parent = img.ParentID.Hex()
-	}
-
-	// compatibilityID for parent
-	parent, err := ioutil.ReadFile(filepath.Join(root, parentFileName))
-	if err == nil && len(parent) > 0 {
-		img.Parent = string(parent)
-	}
-
-	if err := image.ValidateID(img.ID); err != nil {
-		return nil, err
-	}
-
-	if buf, err := ioutil.ReadFile(filepath.Join(root, layersizeFileName)); err != nil {
-		if !os.IsNotExist(err) {
+	img.ID = id
+	if img.ParentID != "" {
+		if err := img.ParentID.Validate(); err != nil {
 			return nil, err
 		}
-		// If the layersize file does not exist then set the size to a negative number
-		// because a layer size of 0 (zero) is valid
-		img.Size = -1
-	} else {
-		// Using Atoi here instead would temporarily convert the size to a machine
-		// dependent integer type, which causes images larger than 2^31 bytes to
-		// display negative sizes on 32-bit machines:
-		size, err := strconv.ParseInt(string(buf), 10, 64)
-		if err != nil {
-			return nil, err
-		}
-		img.Size = int64(size)
+		img.Parent = img.ParentID.Hex()
+	}
+	if l := graph.imageLayer(id); l != nil {
+		img.Size = l.size
 	}
 
 	return img, nil
 }
 
-// saveSize stores the `size` in the provided graph `img` directory `root`.
-func (graph *Graph) saveSize(root string, size int64) error {
-	if err := ioutil.WriteFile(filepath.Join(root, layersizeFileName), []byte(strconv.FormatInt(size, 10)), 0600); err != nil {
-		return fmt.Errorf("Error storing image size in %s/%s: %s", root, layersizeFileName, err)
-	}
-	return nil
-}
-
 // setLayerDigestWithLock sets the digest for the image layer to the provided value.
 func (graph *Graph) setLayerDigestWithLock(id string, dgst digest.Digest) error {
 	graph.imageMutex.Lock(id)
@@ -610,6 +764,11 @@ func (graph *Graph) setLayerDigest(id string, dgst digest.Digest) error {
 	if err := ioutil.WriteFile(filepath.Join(root, digestFileName), []byte(dgst.String()), 0600); err != nil {
 		return fmt.Errorf("Error storing digest in %s/%s: %s", root, digestFileName, err)
 	}
+	if l := graph.imageLayer(id); l != nil {
+		graph.indexMutex.Lock()
+		graph.blobDiffIDs[dgst] = l.diffID
+		graph.indexMutex.Unlock()
+	}
 	return nil
 }
 
@@ -621,6 +780,12 @@ func (graph *Graph) getLayerDigestWithLock(id string) (digest.Digest, error) {
 	return graph.getLayerDigest(id)
 }
 
+// LayerDigest returns the checksum of the content of the layer of the image
+// id. It is only known for the layers which have been pulled or pushed.
+func (graph *Graph) LayerDigest(id string) (digest.Digest, error) {
+	return graph.getLayerDigestWithLock(id)
+}
+
 func (graph *Graph) getLayerDigest(id string) (digest.Digest, error) {
 	root := graph.imageRoot(id)
 	cs, err := ioutil.ReadFile(filepath.Join(root, digestFileName))
@@ -633,6 +798,34 @@ func (graph *Graph) getLayerDigest(id string) (digest.Digest, error) {
 	return digest.ParseDigest(string(cs))
 }
 
+// getDiffID returns the digest of the uncompressed layer of the image id.
+func (graph *Graph) getDiffID(id string) (digest.Digest, error) {
+	img, err := graph.Get(id)
+	if err != nil {
+		return "", err
+	}
+	return img.LayerID, nil
+}
+
+// exportConfig returns the configuration of the image img the way docker save
+// writes it: the configuration the image was registered with, along with its
+// ID and the ID of its parent, so that loading it gives the same image.
+func (graph *Graph) exportConfig(img *image.Image) ([]byte, error) {
+	config, err := ioutil.ReadFile(jsonPath(graph.imageRoot(img.ID)))
+	if err != nil {
+		return nil, err
+	}
+	var c map[string]*json.RawMessage
+	if err := json.Unmarshal(config, &c); err != nil {
+		return nil, err
+	}
+	c["id"] = rawJSON(img.ID)
+	if img.Parent != "" {
+		c["parent"] = rawJSON(img.Parent)
+	}
+	return json.Marshal(c)
+}
+
 // setV1CompatibilityConfig stores the v1Compatibility JSON data associated
 // with the image in the manifest to the disk
 func (graph *Graph) setV1CompatibilityConfig(id string, data []byte) error {
@@ -693,125 +886,3 @@ func (graph *Graph) generateV1CompatibilityChain(id string) ([]byte, error) {
 func jsonPath(root string) string {
 	return filepath.Join(root, jsonFileName)
 }
-
-// storeImage stores file system layer data for the given image to the
-// graph's storage driver. Image metadata is stored in a file
-// at the specified root directory.
-func (graph *Graph) storeImage(id, parent string, config []byte, layerData io.Reader, root string) (err error) {
-	var size int64
-	// Store the layer. If layerData is not nil, unpack it into the new layer
-	if layerData != nil {
-		if size, err = graph.disassembleAndApplyTarLayer(id, parent, layerData, root); err != nil {
-			return err
-		}
-	}
-
-	if err := graph.saveSize(root, size); err != nil {
-		return err
-	}
-
-	if err := ioutil.WriteFile(jsonPath(root), config, 0600); err != nil {
-		return err
-	}
-
-	// If image is pointing to a parent via CompatibilityID write the reference to disk
-	img, err := image.NewImgJSON(config)
-	if err != nil {
-		return err
-	}
-
-	if (img.ParentID.Validate() == nil && parent != img.ParentID.Hex()) || (allowBaseParentImage && img.ParentID == "" && parent != "") {
-		// save compatibilityID parent if it doesn't match parentID
-		// on windows always save a parent file pointing to the base layer
-		if err := ioutil.WriteFile(filepath.Join(root, parentFileName), []byte(parent), 0600); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func (graph *Graph) disassembleAndApplyTarLayer(id, parent string, layerData io.Reader, root string) (size int64, err error) {
-	var ar io.Reader
-
-	if graph.tarSplitDisabled {
-		ar = layerData
-	} else {
-		// this is saving the tar-split metadata
-		mf, err := os.OpenFile(filepath.Join(root, tarDataFileName), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(0600))
-		if err != nil {
-			return 0, err
-		}
-
-		mfz := gzip.NewWriter(mf)
-		metaPacker := storage.NewJSONPacker(mfz)
-		defer mf.Close()
-		defer mfz.Close()
-
-		inflatedLayerData, err := archive.DecompressStream(layerData)
-		if err != nil {
-			return 0, err
-		}
-
-		// we're passing nil here for the file putter, because the ApplyDiff will
-		// handle the extraction of the archive
-		rdr, err := asm.NewInputTarStream(inflatedLayerData, metaPacker, nil)
-		if err != nil {
-			return 0, err
-		}
-
-		ar = archive.Reader(rdr)
-	}
-
-	if size, err = graph.driver.ApplyDiff(id, parent, ar); err != nil {
-		return 0, err
-	}
-
-	return
-}
-
-func (graph *Graph) assembleTarLayer(img *image.Image) (io.ReadCloser, error) {
-	root := graph.imageRoot(img.ID)
-	mFileName := filepath.Join(root, tarDataFileName)
-	mf, err := os.Open(mFileName)
-	if err != nil {
-		if !os.IsNotExist(err) {
-			logrus.Errorf("failed to open %q: %s", mFileName, err)
-		}
-		return nil, err
-	}
-	pR, pW := io.Pipe()
-	// this will need to be in a goroutine, as we are returning the stream of a
-	// tar archive, but can not close the metadata reader early (when this
-	// function returns)...
-	go func() {
-		defer mf.Close()
-		// let's reassemble!
-		logrus.Debugf("[graph] TarLayer with reassembly: %s", img.ID)
-		mfz, err := gzip.NewReader(mf)
-		if err != nil {
-			pW.CloseWithError(fmt.Errorf("[graph] error with %s:  %s", mFileName, err))
-			return
-		}
-		defer mfz.Close()
-
-		// get our relative path to the container
-		fsLayer, err := graph.driver.Get(img.ID, "")
-		if err != nil {
-			pW.CloseWithError(err)
-			return
-		}
-		defer graph.driver.Put(img.ID)
-
-		metaUnpacker := storage.NewJSONUnpacker(mfz)
-		fileGetter := storage.NewPathFileGetter(fsLayer)
-		logrus.Debugf("[graph] %s is at %q", img.ID, fsLayer)
-		ots := asm.NewOutputTarStream(fileGetter, metaUnpacker)
-		defer ots.Close()
-		if _, err := io.Copy(pW, ots); err != nil {
-			pW.CloseWithError(err)
-			return
-		}
-		pW.Close()
-	}()
-	return pR, nil
-}
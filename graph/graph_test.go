@@ -42,7 +42,11 @@ func TestMount(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if _, err := driver.Get(image.ID, ""); err != nil {
+	layerID, err := graph.LayerStorageID(image.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := driver.Get(layerID, ""); err != nil {
 		t.Fatal(err)
 	}
 
@@ -82,7 +86,7 @@ func TestInterruptedRegister(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if err := graph.Register(v1Descriptor{image}, goodArchive); err != nil {
+	if _, err := graph.Register(v1Descriptor{image}, goodArchive); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -130,7 +134,7 @@ func TestRegister(t *testing.T) {
 		Comment: "testing",
 		Created: time.Now(),
 	}
-	err = graph.Register(v1Descriptor{image}, archive)
+	img, err := graph.Register(v1Descriptor{image}, archive)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -138,11 +142,12 @@ func TestRegister(t *testing.T) {
 	if l := len(images); l != 1 {
 		t.Fatalf("Wrong number of images. Should be %d, not %d", 1, l)
 	}
+	// the image can be looked up by its v1 ID too
 	if resultImg, err := graph.Get(image.ID); err != nil {
 		t.Fatal(err)
 	} else {
-		if resultImg.ID != image.ID {
-			t.Fatalf("Wrong image ID. Should be '%s', not '%s'", image.ID, resultImg.ID)
+		if resultImg.ID != img.ID {
+			t.Fatalf("Wrong image ID. Should be '%s', not '%s'", img.ID, resultImg.ID)
 		}
 		if resultImg.Comment != image.Comment {
 			t.Fatalf("Wrong image comment. Should be '%s', not '%s'", image.Comment, resultImg.Comment)
@@ -212,7 +217,7 @@ func TestDelete(t *testing.T) {
 		t.Fatal(err)
 	}
 	// Test delete twice (pull -> rm -> pull -> rm)
-	if err := graph.Register(v1Descriptor{img1}, archive); err != nil {
+	if _, err := graph.Register(v1Descriptor{img1}, archive); err != nil {
 		t.Fatal(err)
 	}
 	if err := graph.Delete(img1.ID); err != nil {
@@ -247,26 +252,96 @@ func TestByParent(t *testing.T) {
 		Parent:  parentImage.ID,
 	}
 
-	err := graph.Register(v1Descriptor{parentImage}, archive1)
+	parent, err := graph.Register(v1Descriptor{parentImage}, archive1)
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = graph.Register(v1Descriptor{childImage1}, archive2)
+	_, err = graph.Register(v1Descriptor{childImage1}, archive2)
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = graph.Register(v1Descriptor{childImage2}, archive3)
+	_, err = graph.Register(v1Descriptor{childImage2}, archive3)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	byParent := graph.ByParent()
-	numChildren := len(byParent[parentImage.ID])
+	numChildren := len(byParent[parent.ID])
 	if numChildren != 2 {
 		t.Fatalf("Expected 2 children, found %d", numChildren)
 	}
 }
 
+func TestRegisterSameImage(t *testing.T) {
+	graph, _ := tempGraph(t)
+	defer nukeGraph(graph)
+
+	created := time.Unix(1000000000, 0)
+	var ids []string
+	for _, v1ID := range []string{stringid.GenerateNonCryptoID(), stringid.GenerateNonCryptoID()} {
+		archive, err := fakeTar()
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := graph.Register(v1Descriptor{&image.Image{ID: v1ID, Comment: "same", Created: created}}, archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, img.ID, v1ID)
+	}
+	if ids[0] != ids[2] {
+		t.Fatalf("Expected the same image to have the same ID, got %s and %s", ids[0], ids[2])
+	}
+	assertNImages(graph, t, 1)
+	for _, v1ID := range []string{ids[1], ids[3]} {
+		if img, err := graph.Get(v1ID); err != nil || img.ID != ids[0] {
+			t.Fatalf("Expected v1 ID %s to name image %s, got %v, %v", v1ID, ids[0], img, err)
+		}
+	}
+}
+
+func TestRegisterSharesLayers(t *testing.T) {
+	graph, driver := tempGraph(t)
+	defer nukeGraph(graph)
+
+	base := createTestImage(graph, t)
+	var imgs []*image.Image
+	for _, comment := range []string{"first", "second"} {
+		img, err := graph.Register(v1Descriptor{&image.Image{Parent: base.ID, Comment: comment, Created: time.Now()}}, singleFileTar(t, "/foo", "foo"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		imgs = append(imgs, img)
+	}
+	if imgs[0].ID == imgs[1].ID {
+		t.Fatal("Expected images with different configurations to have different IDs")
+	}
+	first, err := graph.LayerStorageID(imgs[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := graph.LayerStorageID(imgs[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Fatalf("Expected the images to share their layer, got %s and %s", first, second)
+	}
+
+	if err := graph.Delete(imgs[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if !driver.Exists(first) {
+		t.Fatal("Expected the layer to be kept while an image uses it")
+	}
+	if err := graph.Delete(imgs[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	if driver.Exists(first) {
+		t.Fatal("Expected the layer to be removed with the last image using it")
+	}
+}
+
 func createTestImage(graph *Graph, t *testing.T) *image.Image {
 	archive, err := fakeTar()
 	if err != nil {
@@ -68,6 +68,6 @@ func (s *TagStore) Import(src string, repo string, tag string, msg string, inCon
 		}
 	}
 	outStream.Write(sf.FormatStatus("", img.ID))
-	s.eventsService.Log("import", img.ID, "")
+	s.LogImageEvent(img.ID, "import")
 	return nil
 }
@@ -0,0 +1,461 @@
+package graph
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/vbatts/tar-split/tar/asm"
+	"github.com/vbatts/tar-split/tar/storage"
+)
+
+// file names for ./graph/layerdb/<chain ID>/
+const (
+	diffFileName    = "diff"
+	cacheIDFileName = "cache-id"
+	sizeFileName    = "size"
+)
+
+// layer is a filesystem layer of the layer store. Its chain ID is the digest
+// of its diff ID, the digest of the uncompressed changes it holds, and of the
+// chain ID of its parent.
+type layer struct {
+	chainID digest.Digest
+	diffID  digest.Digest
+	parent  *layer
+	// cacheID is the ID of the layer in the graph driver.
+	cacheID string
+	size    int64
+	// refs counts the images using the layer and the layers based on it.
+	refs int
+}
+
+// layerStore stores the filesystem layers of the images in the graph driver.
+// The layers are keyed by chain ID, so the images with the same layers share
+// them whatever their history. A layer is removed from the driver when the
+// last image or layer referencing it releases it.
+type layerStore struct {
+	root             string
+	driver           graphdriver.Driver
+	tarSplitDisabled bool
+
+	mu     sync.Mutex
+	layers map[digest.Digest]*layer
+}
+
+// createChainID returns the chain ID of the layer holding the changes diffID
+// on top of the layer parent, which is empty for a base layer.
+func createChainID(parent, diffID digest.Digest) (digest.Digest, error) {
+	if parent == "" {
+		return diffID, nil
+	}
+	return digest.FromBytes([]byte(parent + " " + diffID))
+}
+
+func newLayerStore(root string, driver graphdriver.Driver, tarSplitDisabled bool) (*layerStore, error) {
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, err
+	}
+	ls := &layerStore{
+		root:             root,
+		driver:           driver,
+		tarSplitDisabled: tarSplitDisabled,
+		layers:           make(map[digest.Digest]*layer),
+	}
+	if err := ls.restore(); err != nil {
+		return nil, err
+	}
+	return ls, nil
+}
+
+func (ls *layerStore) layerRoot(chainID digest.Digest) string {
+	return filepath.Join(ls.root, chainID.Hex())
+}
+
+// restore loads the layers of the store. Their references are taken again by
+// the images using them.
+func (ls *layerStore) restore() error {
+	dir, err := ioutil.ReadDir(ls.root)
+	if err != nil {
+		return err
+	}
+	parents := make(map[*layer]digest.Digest)
+	for _, v := range dir {
+		chainID := digest.NewDigestFromHex(string(digest.Canonical), v.Name())
+		if err := chainID.Validate(); err != nil {
+			// leftover of an interrupted registration
+			os.RemoveAll(filepath.Join(ls.root, v.Name()))
+			continue
+		}
+		l, parent, err := ls.loadLayer(chainID)
+		if err != nil {
+			logrus.Warnf("ignoring layer %s, it could not be restored: %v", chainID, err)
+			continue
+		}
+		ls.layers[chainID] = l
+		parents[l] = parent
+	}
+	for l, parent := range parents {
+		if parent == "" {
+			continue
+		}
+		p, ok := ls.layers[parent]
+		if !ok {
+			logrus.Warnf("ignoring layer %s, its parent %s could not be restored", l.chainID, parent)
+			delete(ls.layers, l.chainID)
+			continue
+		}
+		l.parent = p
+		p.refs++
+	}
+	logrus.Debugf("Restored %d layers", len(ls.layers))
+	return nil
+}
+
+func (ls *layerStore) loadLayer(chainID digest.Digest) (*layer, digest.Digest, error) {
+	root := ls.layerRoot(chainID)
+	l := &layer{chainID: chainID}
+
+	diff, err := ioutil.ReadFile(filepath.Join(root, diffFileName))
+	if err != nil {
+		return nil, "", err
+	}
+	if l.diffID, err = digest.ParseDigest(string(diff)); err != nil {
+		return nil, "", err
+	}
+	cacheID, err := ioutil.ReadFile(filepath.Join(root, cacheIDFileName))
+	if err != nil {
+		return nil, "", err
+	}
+	l.cacheID = string(cacheID)
+	size, err := ioutil.ReadFile(filepath.Join(root, sizeFileName))
+	if err != nil {
+		return nil, "", err
+	}
+	if l.size, err = strconv.ParseInt(string(size), 10, 64); err != nil {
+		return nil, "", err
+	}
+
+	var parent digest.Digest
+	if buf, err := ioutil.ReadFile(filepath.Join(root, parentFileName)); err == nil {
+		if parent, err = digest.ParseDigest(string(buf)); err != nil {
+			return nil, "", err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, "", err
+	}
+	return l, parent, nil
+}
+
+// get returns the layer chainID, or nil if it doesn't exist.
+func (ls *layerStore) get(chainID digest.Digest) *layer {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	return ls.layers[chainID]
+}
+
+// acquire returns the layer chainID with a new reference to it.
+func (ls *layerStore) acquire(chainID digest.Digest) (*layer, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	l, ok := ls.layers[chainID]
+	if !ok {
+		return nil, fmt.Errorf("layer %s does not exist", chainID)
+	}
+	l.refs++
+	return l, nil
+}
+
+// release releases a reference to l, and removes it once it isn't referenced
+// anymore.
+func (ls *layerStore) release(l *layer) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	ls.releaseLocked(l)
+}
+
+func (ls *layerStore) releaseLocked(l *layer) {
+	for ; l != nil; l = l.parent {
+		l.refs--
+		if l.refs > 0 {
+			return
+		}
+		delete(ls.layers, l.chainID)
+		if err := os.RemoveAll(ls.layerRoot(l.chainID)); err != nil {
+			logrus.Errorf("Failed to remove the metadata of layer %s: %v", l.chainID, err)
+		}
+		if err := ls.driver.Remove(l.cacheID); err != nil {
+			logrus.Errorf("Failed to remove layer %s from the driver: %v", l.chainID, err)
+		}
+	}
+}
+
+// register stores the changes of layerData on top of the layer parent, which
+// is empty for a base layer, and returns the layer holding them with a new
+// reference to it. If the store already has a layer with the same chain ID,
+// the new one is discarded and the existing one returned.
+func (ls *layerStore) register(parent digest.Digest, layerData io.Reader) (_ *layer, err error) {
+	var (
+		p             *layer
+		parentCacheID string
+	)
+	if parent != "" {
+		if p, err = ls.acquire(parent); err != nil {
+			return nil, err
+		}
+		parentCacheID = p.cacheID
+	}
+	defer func() {
+		if err != nil && p != nil {
+			ls.release(p)
+		}
+	}()
+
+	tmp, err := ioutil.TempDir(ls.root, "tmp-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	cacheID := stringid.GenerateRandomID()
+	if err := ls.driver.Create(cacheID, parentCacheID); err != nil {
+		return nil, fmt.Errorf("Driver %s failed to create layer rootfs %s: %s", ls.driver, cacheID, err)
+	}
+	defer func() {
+		if err != nil {
+			ls.driver.Remove(cacheID)
+		}
+	}()
+
+	diffID, size, err := ls.applyTar(cacheID, parentCacheID, layerData, tmp)
+	if err != nil {
+		return nil, err
+	}
+	chainID, err := createChainID(parent, diffID)
+	if err != nil {
+		return nil, err
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if l, ok := ls.layers[chainID]; ok {
+		logrus.Debugf("Layer %s already exists, discarding %s", chainID, cacheID)
+		l.refs++
+		ls.driver.Remove(cacheID)
+		if p != nil {
+			ls.releaseLocked(p)
+		}
+		return l, nil
+	}
+
+	l := &layer{
+		chainID: chainID,
+		diffID:  diffID,
+		parent:  p,
+		cacheID: cacheID,
+		size:    size,
+		refs:    1,
+	}
+	if err := ls.commit(l, tmp); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// commit writes the metadata of l in tmp, moves it to the root of l and adds
+// l to the store. ls.mu must be held.
+func (ls *layerStore) commit(l *layer, tmp string) error {
+	files := map[string]string{
+		diffFileName:    l.diffID.String(),
+		cacheIDFileName: l.cacheID,
+		sizeFileName:    strconv.FormatInt(l.size, 10),
+	}
+	if l.parent != nil {
+		files[parentFileName] = l.parent.chainID.String()
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(tmp, name), []byte(content), 0600); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmp, ls.layerRoot(l.chainID)); err != nil {
+		return err
+	}
+	ls.layers[l.chainID] = l
+	return nil
+}
+
+// migrateLayer adds the existing layer cacheID of the driver, holding the
+// changes diffID on top of the layer parent, to the store. The tar-split
+// metadata tarData of the layer is copied if it isn't empty. Like register,
+// it returns the layer with a new reference to it, the existing one if the
+// store already has a layer with the same chain ID, in which case the driver
+// layer is kept as is since the layers of the driver based on it still need
+// it.
+func (ls *layerStore) migrateLayer(parent, diffID digest.Digest, cacheID string, size int64, tarData string) (_ *layer, err error) {
+	var p *layer
+	if parent != "" {
+		if p, err = ls.acquire(parent); err != nil {
+			return nil, err
+		}
+	}
+	defer func() {
+		if err != nil && p != nil {
+			ls.release(p)
+		}
+	}()
+
+	chainID, err := createChainID(parent, diffID)
+	if err != nil {
+		return nil, err
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if l, ok := ls.layers[chainID]; ok {
+		logrus.Infof("Layer %s already exists, keeping the duplicate %s in the driver", chainID, cacheID)
+		l.refs++
+		if p != nil {
+			ls.releaseLocked(p)
+		}
+		return l, nil
+	}
+
+	tmp, err := ioutil.TempDir(ls.root, "tmp-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	if tarData != "" {
+		if err := copyFile(tarData, filepath.Join(tmp, tarDataFileName)); err != nil {
+			return nil, err
+		}
+	}
+
+	l := &layer{
+		chainID: chainID,
+		diffID:  diffID,
+		parent:  p,
+		cacheID: cacheID,
+		size:    size,
+		refs:    1,
+	}
+	if err := ls.commit(l, tmp); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// applyTar applies the changes of layerData to the layer cacheID of the
+// driver, saving the tar-split metadata of the archive in root. It returns
+// the digest of the uncompressed archive and the size of the changes.
+func (ls *layerStore) applyTar(cacheID, parentCacheID string, layerData io.Reader, root string) (digest.Digest, int64, error) {
+	inflatedLayerData, err := archive.DecompressStream(layerData)
+	if err != nil {
+		return "", 0, err
+	}
+	defer inflatedLayerData.Close()
+
+	digester := digest.Canonical.New()
+	var ar io.Reader = io.TeeReader(inflatedLayerData, digester.Hash())
+
+	if !ls.tarSplitDisabled {
+		// this is saving the tar-split metadata
+		mf, err := os.OpenFile(filepath.Join(root, tarDataFileName), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(0600))
+		if err != nil {
+			return "", 0, err
+		}
+		mfz := gzip.NewWriter(mf)
+		metaPacker := storage.NewJSONPacker(mfz)
+		defer mf.Close()
+		defer mfz.Close()
+
+		// we're passing nil here for the file putter, because the ApplyDiff will
+		// handle the extraction of the archive
+		if ar, err = asm.NewInputTarStream(ar, metaPacker, nil); err != nil {
+			return "", 0, err
+		}
+	}
+
+	size, err := ls.driver.ApplyDiff(cacheID, parentCacheID, archive.Reader(ar))
+	if err != nil {
+		return "", 0, err
+	}
+	// The driver may stop reading at the end of the archive, before its
+	// padding, which the digest must include.
+	if _, err := io.Copy(ioutil.Discard, ar); err != nil {
+		return "", 0, err
+	}
+	return digester.Digest(), size, nil
+}
+
+// tarStream returns the tar archive of the changes of l.
+func (ls *layerStore) tarStream(l *layer) (io.ReadCloser, error) {
+	var parentCacheID string
+	if l.parent != nil {
+		parentCacheID = l.parent.cacheID
+	}
+	rdr, err := ls.assembleTarLayer(filepath.Join(ls.layerRoot(l.chainID), tarDataFileName), l.cacheID)
+	if err != nil {
+		logrus.Debugf("[graph] tarLayer with traditional differ: %s", l.chainID)
+		return ls.driver.Diff(l.cacheID, parentCacheID)
+	}
+	return rdr, nil
+}
+
+// assembleTarLayer reassembles the tar archive described by the tar-split
+// metadata mFileName from the files of the layer cacheID of the driver.
+func (ls *layerStore) assembleTarLayer(mFileName, cacheID string) (io.ReadCloser, error) {
+	mf, err := os.Open(mFileName)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logrus.Errorf("failed to open %q: %s", mFileName, err)
+		}
+		return nil, err
+	}
+	pR, pW := io.Pipe()
+	// this will need to be in a goroutine, as we are returning the stream of a
+	// tar archive, but can not close the metadata reader early (when this
+	// function returns)...
+	go func() {
+		defer mf.Close()
+		// let's reassemble!
+		logrus.Debugf("[graph] TarLayer with reassembly: %s", cacheID)
+		mfz, err := gzip.NewReader(mf)
+		if err != nil {
+			pW.CloseWithError(fmt.Errorf("[graph] error with %s:  %s", mFileName, err))
+			return
+		}
+		defer mfz.Close()
+
+		// get our relative path to the container
+		fsLayer, err := ls.driver.Get(cacheID, "")
+		if err != nil {
+			pW.CloseWithError(err)
+			return
+		}
+		defer ls.driver.Put(cacheID)
+
+		metaUnpacker := storage.NewJSONUnpacker(mfz)
+		fileGetter := storage.NewPathFileGetter(fsLayer)
+		logrus.Debugf("[graph] %s is at %q", cacheID, fsLayer)
+		ots := asm.NewOutputTarStream(fileGetter, metaUnpacker)
+		defer ots.Close()
+		if _, err := io.Copy(pW, ots); err != nil {
+			pW.CloseWithError(err)
+			return
+		}
+		pW.Close()
+	}()
+	return pR, nil
+}
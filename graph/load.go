@@ -122,7 +122,7 @@ func (s *TagStore) recursiveLoad(address, tmpImageDir string) error {
 				}
 			}
 		}
-		if err := s.graph.Register(v1Descriptor{img}, layer); err != nil {
+		if _, err := s.graph.Register(v1ConfigDescriptor{id: img.ID, parent: img.Parent, config: imageJSON}, layer); err != nil {
 			return err
 		}
 		logrus.Debugf("Completed processing %s", address)
@@ -0,0 +1,226 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/image"
+)
+
+// legacyImage is an image of a graph created by a previous version, stored
+// in ./graph/<ID>/ with the ID of its layer in the driver.
+type legacyImage struct {
+	id     string
+	parent string
+	root   string
+	config []byte
+}
+
+// migrate moves the images of a graph created by a previous version to the
+// image and layer stores. The layers of the driver are kept and adopted by
+// the layer store; the images get their content IDs, and keep their old IDs
+// as v1 IDs so they can still be looked up by them. The old metadata is
+// removed once all the images are migrated.
+func (graph *Graph) migrate() error {
+	legacy, err := graph.legacyImages()
+	if err != nil || len(legacy) == 0 {
+		return err
+	}
+	logrus.Infof("Migrating %d images to the content addressable image store", len(legacy))
+
+	migrated := make(map[string]string)
+	var migrateImage func(img *legacyImage) (string, error)
+	migrateImage = func(img *legacyImage) (string, error) {
+		if id, ok := migrated[img.id]; ok {
+			return id, nil
+		}
+		var parentID string
+		if img.parent != "" {
+			parent, ok := legacy[img.parent]
+			if !ok {
+				return "", fmt.Errorf("parent image %s does not exist", img.parent)
+			}
+			var err error
+			if parentID, err = migrateImage(parent); err != nil {
+				return "", err
+			}
+		}
+		newImg, err := graph.migrateImage(img, parentID)
+		if err != nil {
+			return "", err
+		}
+		migrated[img.id] = newImg.ID
+		return newImg.ID, nil
+	}
+
+	for _, img := range legacy {
+		if _, err := migrateImage(img); err != nil {
+			// The images based on this one won't be migrated either, but
+			// the others still can be.
+			logrus.Errorf("Failed to migrate image %s: %v", img.id, err)
+		}
+	}
+
+	for id, img := range legacy {
+		if _, ok := migrated[id]; !ok {
+			continue
+		}
+		if err := os.RemoveAll(img.root); err != nil {
+			logrus.Errorf("Failed to remove the old metadata of image %s: %v", id, err)
+		}
+	}
+	logrus.Infof("Migrated %d images", len(migrated))
+	return nil
+}
+
+// legacyImages returns the images of the graph stored by a previous version,
+// keyed by ID.
+func (graph *Graph) legacyImages() (map[string]*legacyImage, error) {
+	dir, err := ioutil.ReadDir(graph.root)
+	if err != nil {
+		return nil, err
+	}
+	images := make(map[string]*legacyImage)
+	for _, v := range dir {
+		id := v.Name()
+		if image.ValidateID(id) != nil || !v.IsDir() {
+			continue
+		}
+		img, err := graph.loadLegacyImage(id)
+		if err != nil {
+			logrus.Warnf("ignoring image %s, it could not be loaded: %v", id, err)
+			continue
+		}
+		images[id] = img
+	}
+	return images, nil
+}
+
+func (graph *Graph) loadLegacyImage(id string) (*legacyImage, error) {
+	root := filepath.Join(graph.root, id)
+	config, err := ioutil.ReadFile(jsonPath(root))
+	if err != nil {
+		return nil, err
+	}
+	img, err := image.NewImgJSON(config)
+	if err != nil {
+		return nil, err
+	}
+
+	parent := img.Parent
+	if parent == "" && img.ParentID != "" && img.ParentID.Validate() == nil {
+		parent = img.ParentID.Hex()
+	}
+	// compatibilityID for parent
+	if buf, err := ioutil.ReadFile(filepath.Join(root, parentFileName)); err == nil && len(buf) > 0 {
+		parent = string(buf)
+	}
+	return &legacyImage{id: id, parent: parent, root: root, config: config}, nil
+}
+
+// migrateImage adds the legacy image img to the stores, on top of the
+// migrated image parentID.
+func (graph *Graph) migrateImage(img *legacyImage, parentID string) (*image.Image, error) {
+	var parentLayer digest.Digest
+	if parentID != "" {
+		l := graph.imageLayer(parentID)
+		if l == nil {
+			return nil, fmt.Errorf("image %s does not exist", parentID)
+		}
+		parentLayer = l.chainID
+	}
+
+	tarData := filepath.Join(img.root, tarDataFileName)
+	if _, err := os.Stat(tarData); err != nil || graph.layers.tarSplitDisabled {
+		tarData = ""
+	}
+	// the driver layer of the image is based on the one of its legacy parent
+	diffID, err := graph.legacyDiffID(img.id, img.parent, tarData)
+	if err != nil {
+		return nil, err
+	}
+	size, err := graph.legacySize(img)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := graph.layers.migrateLayer(parentLayer, diffID, img.id, size, tarData)
+	if err != nil {
+		return nil, err
+	}
+	newImg, err := graph.registerWithLayer(v1ConfigDescriptor{id: img.id, parent: parentID, config: img.config}, l)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range []string{digestFileName, v1CompatibilityFileName} {
+		if err := copyFile(filepath.Join(img.root, name), filepath.Join(graph.imageRoot(newImg.ID), name)); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	if dgst, err := graph.getLayerDigest(newImg.ID); err == nil {
+		graph.indexMutex.Lock()
+		graph.blobDiffIDs[dgst] = l.diffID
+		graph.indexMutex.Unlock()
+	}
+	logrus.Debugf("Migrated image %s to %s", img.id, newImg.ID)
+	return newImg, nil
+}
+
+// legacyDiffID computes the digest of the uncompressed layer cacheID of the
+// driver, reassembled from its tar-split metadata tarData if set.
+func (graph *Graph) legacyDiffID(cacheID, parentCacheID, tarData string) (digest.Digest, error) {
+	var (
+		arch io.ReadCloser
+		err  error
+	)
+	if tarData != "" {
+		arch, err = graph.layers.assembleTarLayer(tarData, cacheID)
+	} else {
+		arch, err = graph.driver.Diff(cacheID, parentCacheID)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer arch.Close()
+	return digest.FromReader(arch)
+}
+
+// legacySize returns the size of the layer of the legacy image img, saved
+// along with it by recent versions.
+func (graph *Graph) legacySize(img *legacyImage) (int64, error) {
+	if buf, err := ioutil.ReadFile(filepath.Join(img.root, layersizeFileName)); err == nil {
+		return strconv.ParseInt(string(buf), 10, 64)
+	}
+	var legacy struct {
+		Size int64 `json:"Size"`
+	}
+	if err := json.Unmarshal(img.config, &legacy); err == nil && legacy.Size > 0 {
+		return legacy.Size, nil
+	}
+	return graph.driver.DiffSize(img.id, img.parent)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,114 @@
+package graph
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/stringid"
+)
+
+func TestMigrate(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "docker-graph-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	driver, err := graphdriver.New(tmp, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Cleanup()
+
+	// a graph stored the way previous versions did
+	baseLayer, err := fakeTar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := writeLegacyImage(t, tmp, driver, &image.Image{ID: stringid.GenerateNonCryptoID(), Comment: "base"}, baseLayer)
+	child := writeLegacyImage(t, tmp, driver, &image.Image{ID: stringid.GenerateNonCryptoID(), Parent: base.ID, Comment: "child"}, singleFileTar(t, "/foo", "foo"))
+
+	graph, err := NewGraph(tmp, driver, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertNImages(graph, t, 2)
+
+	img, err := graph.Get(child.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.ID == child.ID || img.Comment != "child" || img.LayerID == "" {
+		t.Fatalf("Expected the image to be migrated to its content ID, got %+v", img)
+	}
+	parent, err := graph.Get(base.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Parent != parent.ID {
+		t.Fatalf("Expected the parent of the image to be %s, got %s", parent.ID, img.Parent)
+	}
+	// the layers of the driver are kept
+	if layerID, err := graph.LayerStorageID(img.ID); err != nil || layerID != child.ID {
+		t.Fatalf("Expected the layer of the image to be %s, got %s (%v)", child.ID, layerID, err)
+	}
+	for _, id := range []string{base.ID, child.ID} {
+		if _, err := os.Stat(filepath.Join(tmp, id)); !os.IsNotExist(err) {
+			t.Fatalf("Expected the old metadata of image %s to be removed, got %v", id, err)
+		}
+	}
+
+	// the layer of the image is its diff ID
+	arch, err := graph.tarLayer(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arch.Close()
+	if dgst, err := digest.FromReader(arch); err != nil || dgst != img.LayerID {
+		t.Fatalf("Expected the layer to have the digest %s, got %s (%v)", img.LayerID, dgst, err)
+	}
+
+	// restarting doesn't migrate again
+	graph, err = NewGraph(tmp, driver, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertNImages(graph, t, 2)
+	if restored, err := graph.Get(child.ID); err != nil || restored.ID != img.ID {
+		t.Fatalf("Expected the v1 ID %s to name image %s after a restart, got %v, %v", child.ID, img.ID, restored, err)
+	}
+}
+
+// writeLegacyImage stores img with layerData in the graph at root and the
+// driver the way previous versions did.
+func writeLegacyImage(t *testing.T, root string, driver graphdriver.Driver, img *image.Image, layerData archive.Reader) *image.Image {
+	img.Created = time.Now().UTC()
+	if err := driver.Create(img.ID, img.Parent); err != nil {
+		t.Fatal(err)
+	}
+	size, err := driver.ApplyDiff(img.ID, img.Parent, layerData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img.Size = size
+
+	dir := filepath.Join(root, img.ID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	config, err := json.Marshal(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(jsonPath(dir), config, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
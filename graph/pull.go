@@ -125,7 +125,7 @@ func (s *TagStore) Pull(image string, tag string, imagePullConfig *ImagePullConf
 
 		}
 
-		s.eventsService.Log("pull", logName, "")
+		s.LogImageEvent(logName, "pull")
 		return nil
 	}
 
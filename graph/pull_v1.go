@@ -322,7 +322,7 @@ func (p *v1Puller) pullImage(out io.Writer, imgID, endpoint string) (layersDownl
 				layersDownloaded = true
 				defer layer.Close()
 
-				err = p.graph.Register(v1Descriptor{img},
+				_, err = p.graph.Register(v1ConfigDescriptor{id: img.ID, parent: img.Parent, config: imgJSON},
 					progressreader.New(progressreader.Config{
 						In:        layer,
 						Out:       broadcaster,
@@ -1,19 +1,23 @@
 package graph
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"runtime"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/digest"
 	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/docker/distribution/registry/client"
 	"github.com/docker/docker/image"
-	"github.com/docker/docker/pkg/broadcaster"
 	"github.com/docker/docker/pkg/progressreader"
 	"github.com/docker/docker/pkg/streamformatter"
 	"github.com/docker/docker/pkg/stringid"
@@ -29,12 +33,13 @@ type v2Puller struct {
 	sf        *streamformatter.StreamFormatter
 	repoInfo  *registry.RepositoryInfo
 	repo      distribution.Repository
+	manifests *manifestClient
 	sessionID string
 }
 
 func (p *v2Puller) Pull(tag string) (fallback bool, err error) {
 	// TODO(tiborvass): was ReceiveTimeout
-	p.repo, err = newV2Repository(p.repoInfo, p.endpoint, p.config.MetaHeaders, p.config.AuthConfig, "pull")
+	p.repo, p.manifests, err = newV2Repository(p.repoInfo, p.endpoint, p.config.MetaHeaders, p.config.AuthConfig, "pull")
 	if err != nil {
 		logrus.Warnf("Error getting v2 registry: %v", err)
 		return true, err
@@ -103,303 +108,297 @@ func (p *v2Puller) pullV2Repository(tag string) (err error) {
 	return nil
 }
 
-// downloadInfo is used to pass information from download to extractor
-type downloadInfo struct {
-	img         contentAddressableDescriptor
-	imgIndex    int
-	tmpFile     *os.File
-	digest      digest.Digest
-	layer       distribution.ReadSeekCloser
-	size        int64
-	err         chan error
-	poolKey     string
-	broadcaster *broadcaster.Buffered
+// layerDownload is a layer downloaded to a temporary file, which is shared
+// by the pulls which need it at the same time.
+type layerDownload struct {
+	path string
+	size int64
 }
 
-// contentAddressableDescriptor is used to pass image data from a manifest to the
-// graph.
-type contentAddressableDescriptor struct {
-	id              string
-	parent          string
-	strongID        digest.Digest
-	compatibilityID string
-	config          []byte
+// pullLayer is a layer of the manifest being pulled, along with the v1
+// configuration of its image.
+type pullLayer struct {
+	blobSum         digest.Digest
 	v1Compatibility []byte
+	// diffID is the digest of the uncompressed layer the image
+	// configuration of a schema2 manifest expects, empty for schema1
+	diffID digest.Digest
+	// id is the short ID of the layer in the progress output
+	id string
+	// layer is set, with a reference to it, when the layer is already in
+	// the layer store; download is set otherwise.
+	layer    *layer
+	download *transfer
 }
 
-func newContentAddressableImage(v1Compatibility []byte, blobSum digest.Digest, parent digest.Digest) (contentAddressableDescriptor, error) {
-	img := contentAddressableDescriptor{
-		v1Compatibility: v1Compatibility,
-	}
-
-	var err error
-	img.config, err = image.MakeImageConfig(v1Compatibility, blobSum, parent)
-	if err != nil {
-		return img, err
-	}
-	img.strongID, err = image.StrongID(img.config)
-	if err != nil {
-		return img, err
+// verifyDiffID checks that diffID is the digest of the uncompressed layer the
+// image configuration expects, if it expects one.
+func (pl *pullLayer) verifyDiffID(diffID digest.Digest) error {
+	if pl.diffID != "" && diffID != pl.diffID {
+		return fmt.Errorf("layer %s has the diff ID %s, while the image configuration expects %s", pl.blobSum, diffID, pl.diffID)
 	}
+	return nil
+}
 
-	unmarshalledConfig, err := image.NewImgJSON(v1Compatibility)
-	if err != nil {
-		return img, err
-	}
+type errVerification struct{}
 
-	img.compatibilityID = unmarshalledConfig.ID
-	img.id = img.strongID.Hex()
+func (errVerification) Error() string { return "verification failed" }
 
-	return img, nil
+// download returns the transfer downloading the layer blobSum, shown as id
+// in the progress output, to a temporary file.
+func (p *v2Puller) download(blobSum digest.Digest, id string) transferFunc {
+	return func(progress io.Writer) (interface{}, func(), error) {
+		tmpFile, err := ioutil.TempFile("", "GetImageBlob")
+		if err != nil {
+			return nil, nil, err
+		}
+		cleanup := func() {
+			if err := os.RemoveAll(tmpFile.Name()); err != nil {
+				logrus.Errorf("Failed to remove temp file: %s", tmpFile.Name())
+			}
+		}
+		size, err := p.fetchBlob(tmpFile, blobSum, id, progress)
+		if closeErr := tmpFile.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		logrus.Debugf("Downloaded %s to tempfile %s", blobSum, tmpFile.Name())
+		return &layerDownload{path: tmpFile.Name(), size: size}, cleanup, nil
+	}
 }
 
-// ID returns the actual ID to be used for the downloaded image. This may be
-// a computed ID.
-func (img contentAddressableDescriptor) ID() string {
-	return img.id
-}
+// maxDownloadAttempts is the number of times the download of a layer is
+// tried before the pull fails.
+const maxDownloadAttempts = 5
 
-// Parent returns the parent ID to be used for the image. This may be a
-// computed ID.
-func (img contentAddressableDescriptor) Parent() string {
-	return img.parent
+// downloadRetryDelay returns how long to wait before the given attempt to
+// download a layer, once the previous one failed.
+var downloadRetryDelay = func(attempt int) time.Duration {
+	return time.Duration(1<<uint(attempt-2)) * time.Second
 }
 
-// MarshalConfig renders the image structure into JSON.
-func (img contentAddressableDescriptor) MarshalConfig() ([]byte, error) {
-	return img.config, nil
-}
+// fetchBlob writes the layer blobSum to f, and returns its size. When the
+// download is interrupted, it is resumed where it stopped.
+func (p *v2Puller) fetchBlob(f *os.File, blobSum digest.Digest, id string, progress io.Writer) (int64, error) {
+	logrus.Debugf("pulling blob %q", blobSum)
 
-type errVerification struct{}
-
-func (errVerification) Error() string { return "verification failed" }
-
-func (p *v2Puller) download(di *downloadInfo) {
-	logrus.Debugf("pulling blob %q to %s", di.digest, di.img.id)
+	if blobSum == emptyGzipLayerDigest {
+		// The images without a layer of schema2 manifests aren't
+		// required to have the empty layer in the registry.
+		if _, err := f.Write(emptyGzipLayer); err != nil {
+			return 0, err
+		}
+		progress.Write(p.sf.FormatProgress(id, "Download complete", nil))
+		return int64(len(emptyGzipLayer)), nil
+	}
 
 	blobs := p.repo.Blobs(context.Background())
 
-	desc, err := blobs.Stat(context.Background(), di.digest)
+	desc, err := blobs.Stat(context.Background(), blobSum)
 	if err != nil {
 		logrus.Debugf("Error statting layer: %v", err)
-		di.err <- err
-		return
+		return 0, err
 	}
-	di.size = desc.Size
 
-	layerDownload, err := blobs.Open(context.Background(), di.digest)
+	verifier, err := digest.NewDigestVerifier(blobSum)
 	if err != nil {
-		logrus.Debugf("Error fetching layer: %v", err)
-		di.err <- err
-		return
+		return 0, err
 	}
-	defer layerDownload.Close()
 
-	verifier, err := digest.NewDigestVerifier(di.digest)
-	if err != nil {
-		di.err <- err
-		return
-	}
+	var offset int64
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			delay := downloadRetryDelay(attempt)
+			progress.Write(p.sf.FormatProgress(id, fmt.Sprintf("Retrying in %s (attempt %d of %d)", delay, attempt, maxDownloadAttempts), nil))
+			time.Sleep(delay)
+		}
 
-	reader := progressreader.New(progressreader.Config{
-		In:        ioutil.NopCloser(io.TeeReader(layerDownload, verifier)),
-		Out:       di.broadcaster,
-		Formatter: p.sf,
-		Size:      di.size,
-		NewLines:  false,
-		ID:        stringid.TruncateID(di.img.id),
-		Action:    "Downloading",
-	})
-	io.Copy(di.tmpFile, reader)
+		if verifier, offset, err = p.resumeBlob(f, verifier, blobSum, offset, desc.Size, id, progress); err == nil {
+			break
+		}
+		if attempt == maxDownloadAttempts || !retryableDownloadError(err) {
+			logrus.Debugf("Error fetching layer: %v", err)
+			return 0, err
+		}
+		logrus.Debugf("Download of %s interrupted at %d of %d bytes: %v", blobSum, offset, desc.Size, err)
+	}
 
-	di.broadcaster.Write(p.sf.FormatProgress(stringid.TruncateID(di.img.id), "Verifying Checksum", nil))
+	progress.Write(p.sf.FormatProgress(id, "Verifying Checksum", nil))
 
 	if !verifier.Verified() {
-		err = fmt.Errorf("filesystem layer verification failed for digest %s", di.digest)
+		err = fmt.Errorf("filesystem layer verification failed for digest %s", blobSum)
 		logrus.Error(err)
-		di.err <- err
-		return
+		return 0, err
 	}
 
-	di.broadcaster.Write(p.sf.FormatProgress(stringid.TruncateID(di.img.id), "Download complete", nil))
-
-	logrus.Debugf("Downloaded %s to tempfile %s", di.img.id, di.tmpFile.Name())
-	di.layer = layerDownload
-
-	di.err <- nil
+	progress.Write(p.sf.FormatProgress(id, "Download complete", nil))
+	return desc.Size, nil
 }
 
-func (p *v2Puller) pullV2Tag(out io.Writer, tag, taggedName string) (tagUpdated bool, err error) {
-	logrus.Debugf("Pulling tag from V2 registry: %q", tag)
-
-	manSvc, err := p.repo.Manifests(context.Background())
+// resumeBlob downloads the layer blobSum of the given size to f from offset,
+// where the previous attempt stopped, and feeds it to the verifier of the
+// download. If the registry doesn't support range requests, the layer is
+// downloaded again from the start with a new verifier. It returns the
+// verifier of the download, and the offset the download reached.
+func (p *v2Puller) resumeBlob(f *os.File, verifier digest.Verifier, blobSum digest.Digest, offset, size int64, id string, progress io.Writer) (digest.Verifier, int64, error) {
+	body, start, err := p.manifests.GetBlob(blobSum, offset)
 	if err != nil {
-		return false, err
+		return verifier, offset, err
 	}
+	defer body.Close()
 
-	unverifiedManifest, err := manSvc.GetByTag(tag)
-	if err != nil {
-		return false, err
+	if start != offset {
+		logrus.Debugf("Registry doesn't support range requests, downloading %s again", blobSum)
+		if err := f.Truncate(0); err != nil {
+			return verifier, offset, err
+		}
+		if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+			return verifier, offset, err
+		}
+		if verifier, err = digest.NewDigestVerifier(blobSum); err != nil {
+			return verifier, offset, err
+		}
 	}
-	if unverifiedManifest == nil {
-		return false, fmt.Errorf("image manifest does not exist for tag %q", tag)
+
+	reader := progressreader.New(progressreader.Config{
+		In:         ioutil.NopCloser(io.TeeReader(body, verifier)),
+		Out:        progress,
+		Formatter:  p.sf,
+		Size:       size,
+		Current:    start,
+		LastUpdate: start,
+		NewLines:   false,
+		ID:         id,
+		Action:     "Downloading",
+	})
+	n, err := io.Copy(f, reader)
+	offset = start + n
+	if err == nil && offset < size {
+		err = io.ErrUnexpectedEOF
 	}
-	var verifiedManifest *schema1.Manifest
-	verifiedManifest, err = verifyManifest(unverifiedManifest, tag)
-	if err != nil {
-		return false, err
+	return verifier, offset, err
+}
+
+// retryableDownloadError returns whether a download which failed with err
+// may succeed when it is tried again, which isn't the case when the
+// registry refused it.
+func retryableDownloadError(err error) bool {
+	switch err.(type) {
+	case errcode.Errors, errcode.Error, *client.UnexpectedHTTPResponseError:
+		return false
 	}
+	return true
+}
 
-	// remove duplicate layers and check parent chain validity
-	err = fixManifestLayers(verifiedManifest)
+func (p *v2Puller) pullV2Tag(out io.Writer, tag, taggedName string) (tagUpdated bool, err error) {
+	logrus.Debugf("Pulling tag from V2 registry: %q", tag)
+
+	verifiedManifest, diffIDs, manifestDigest, err := p.getManifest(tag)
 	if err != nil {
 		return false, err
 	}
 
-	imgs, err := p.getImageInfos(verifiedManifest)
+	// remove duplicate layers and check parent chain validity
+	err = fixManifestLayers(verifiedManifest)
 	if err != nil {
 		return false, err
 	}
 
 	out.Write(p.sf.FormatStatus(tag, "Pulling from %s", p.repo.Name()))
 
-	var downloads []*downloadInfo
-
-	var layerIDs []string
+	layers := make([]*pullLayer, len(verifiedManifest.FSLayers))
+	var imageIDs []string
 	defer func() {
-		p.graph.Release(p.sessionID, layerIDs...)
-
-		for _, d := range downloads {
-			p.poolRemoveWithError("pull", d.poolKey, err)
-			if d.tmpFile != nil {
-				d.tmpFile.Close()
-				if err := os.RemoveAll(d.tmpFile.Name()); err != nil {
-					logrus.Errorf("Failed to remove temp file: %s", d.tmpFile.Name())
-				}
-			}
-		}
-	}()
-
-	for i := len(verifiedManifest.FSLayers) - 1; i >= 0; i-- {
-		img := imgs[i]
-
-		p.graph.Retain(p.sessionID, img.id)
-		layerIDs = append(layerIDs, img.id)
+		p.graph.Release(p.sessionID, imageIDs...)
 
-		p.graph.imageMutex.Lock(img.id)
-
-		// Check if exists
-		if p.graph.Exists(img.id) {
-			if err := p.validateImageInGraph(img.id, imgs, i); err != nil {
-				p.graph.imageMutex.Unlock(img.id)
-				return false, fmt.Errorf("image validation failed: %v", err)
+		for _, pl := range layers {
+			if pl == nil {
+				continue
+			}
+			if pl.layer != nil {
+				p.graph.layers.release(pl.layer)
+			}
+			if pl.download != nil {
+				pl.download.release()
 			}
-			logrus.Debugf("Image already exists: %s", img.id)
-			p.graph.imageMutex.Unlock(img.id)
-			continue
-		}
-		p.graph.imageMutex.Unlock(img.id)
-
-		out.Write(p.sf.FormatProgress(stringid.TruncateID(img.id), "Pulling fs layer", nil))
-
-		d := &downloadInfo{
-			img:      img,
-			imgIndex: i,
-			poolKey:  "v2layer:" + img.id,
-			digest:   verifiedManifest.FSLayers[i].BlobSum,
-			// TODO: seems like this chan buffer solved hanging problem in go1.5,
-			// this can indicate some deeper problem that somehow we never take
-			// error from channel in loop below
-			err: make(chan error, 1),
-		}
-
-		tmpFile, err := ioutil.TempFile("", "GetImageBlob")
-		if err != nil {
-			return false, err
 		}
-		d.tmpFile = tmpFile
-
-		downloads = append(downloads, d)
+	}()
 
-		broadcaster, found := p.poolAdd("pull", d.poolKey)
-		broadcaster.Add(out)
-		d.broadcaster = broadcaster
-		if found {
-			d.err <- nil
-		} else {
-			go p.download(d)
-		}
+	parent, err := p.baseParent(verifiedManifest)
+	if err != nil {
+		return false, err
+	}
+	var parentLayer digest.Digest
+	if parent != "" {
+		parentLayer = p.graph.imageLayer(parent).chainID
 	}
 
-	for _, d := range downloads {
-		if err := <-d.err; err != nil {
-			return false, err
+	// The layers are only pulled from the first one which isn't already in
+	// the layer store on top of the same layers.
+	var (
+		chainID = parentLayer
+		known   = true
+	)
+	for i := len(verifiedManifest.FSLayers) - 1; i >= 0; i-- {
+		pl := &pullLayer{
+			blobSum:         verifiedManifest.FSLayers[i].BlobSum,
+			v1Compatibility: []byte(verifiedManifest.History[i].V1Compatibility),
+			diffID:          diffIDs[verifiedManifest.FSLayers[i].BlobSum],
 		}
+		layers[i] = pl
 
-		if d.layer == nil {
-			// Wait for a different pull to download and extract
-			// this layer.
-			err = d.broadcaster.Wait()
-			if err != nil {
-				return false, err
-			}
-			continue
-		}
-
-		d.tmpFile.Seek(0, 0)
-		err := func() error {
-			reader := progressreader.New(progressreader.Config{
-				In:        d.tmpFile,
-				Out:       d.broadcaster,
-				Formatter: p.sf,
-				Size:      d.size,
-				NewLines:  false,
-				ID:        stringid.TruncateID(d.img.id),
-				Action:    "Extracting",
-			})
-
-			p.graph.imagesMutex.Lock()
-			defer p.graph.imagesMutex.Unlock()
-
-			p.graph.imageMutex.Lock(d.img.id)
-			defer p.graph.imageMutex.Unlock(d.img.id)
-
-			// Must recheck the data on disk if any exists.
-			// This protects against races where something
-			// else is written to the graph under this ID
-			// after attemptIDReuse.
-			if p.graph.Exists(d.img.id) {
-				if err := p.validateImageInGraph(d.img.id, imgs, d.imgIndex); err != nil {
-					return fmt.Errorf("image validation failed: %v", err)
+		if known {
+			if diffID, ok := p.graph.diffIDForBlob(pl.blobSum); ok {
+				if err := pl.verifyDiffID(diffID); err != nil {
+					return false, err
+				}
+				if chainID, err = createChainID(chainID, diffID); err != nil {
+					return false, err
+				}
+				if pl.layer, err = p.graph.layers.acquire(chainID); err == nil {
+					logrus.Debugf("Layer already exists: %s", pl.blobSum)
+					continue
 				}
 			}
+			known = false
+		}
 
-			if err := p.graph.register(d.img, reader); err != nil {
-				return err
-			}
-
-			if err := p.graph.setLayerDigest(d.img.id, d.digest); err != nil {
-				return err
-			}
+		pl.id = stringid.TruncateID(pl.blobSum.Hex())
+		out.Write(p.sf.FormatProgress(pl.id, "Pulling fs layer", nil))
 
-			if err := p.graph.setV1CompatibilityConfig(d.img.id, d.img.v1Compatibility); err != nil {
-				return err
-			}
+		// The pulls which need the same layer at the same time share its
+		// download.
+		pl.download = p.downloadManager.transfer(pl.blobSum.String(), out, p.download(pl.blobSum, pl.id))
+	}
 
-			return nil
-		}()
+	// The images are registered from the base one, each on top of the
+	// previous one.
+	for i := len(layers) - 1; i >= 0; i-- {
+		pl := layers[i]
+		img, err := p.registerLayer(out, pl, parent)
 		if err != nil {
 			return false, err
 		}
-
-		d.broadcaster.Write(p.sf.FormatProgress(stringid.TruncateID(d.img.id), "Pull complete", nil))
-		d.broadcaster.Close()
-		tagUpdated = true
+		p.graph.Retain(p.sessionID, img.ID)
+		imageIDs = append(imageIDs, img.ID)
+		parent = img.ID
+		if pl.download != nil {
+			out.Write(p.sf.FormatProgress(pl.id, "Pull complete", nil))
+			tagUpdated = true
+		}
 	}
 
-	manifestDigest, _, err := digestFromManifest(unverifiedManifest, p.repoInfo.LocalName)
-	if err != nil {
-		return false, err
+	// The layers can now be mounted from this repository by the pushes to
+	// other repositories of the registry.
+	blobSums := make([]digest.Digest, len(layers))
+	for i, pl := range layers {
+		blobSums[i] = pl.blobSum
+	}
+	if err := p.blobSources.add(blobSource{Registry: p.endpoint.URL, Repository: p.repo.Name()}, blobSums...); err != nil {
+		logrus.Warnf("Failed to record the blobs pulled from %s: %v", p.repo.Name(), err)
 	}
 
 	// Check for new tag if no layers downloaded
@@ -417,7 +416,7 @@ func (p *v2Puller) pullV2Tag(out io.Writer, tag, taggedName string) (tagUpdated
 		}
 	}
 
-	firstID := layerIDs[len(layerIDs)-1]
+	firstID := imageIDs[len(imageIDs)-1]
 	if utils.DigestReference(tag) {
 		// TODO(stevvooe): Ideally, we should always set the digest so we can
 		// use the digest whether we pull by it or not. Unfortunately, the tag
@@ -440,6 +439,108 @@ func (p *v2Puller) pullV2Tag(out io.Writer, tag, taggedName string) (tagUpdated
 	return tagUpdated, nil
 }
 
+// getManifest returns the manifest of tag, which may also be a digest, as a
+// schema1 manifest along with its digest. When the registry has a manifest
+// list for tag, the manifest for the platform of the daemon is used, and the
+// digest is the one of the list. For a schema2 manifest, the digests of the
+// uncompressed layers its image configuration expects are returned by the
+// digests of their blobs.
+func (p *v2Puller) getManifest(tag string) (*schema1.Manifest, map[digest.Digest]digest.Digest, digest.Digest, error) {
+	mediaType, raw, err := p.manifests.Get(tag)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	var listDigest digest.Digest
+	if mediaType == manifestListMediaType {
+		if err := verifyRawManifest(raw, tag); err != nil {
+			return nil, nil, "", err
+		}
+		var list manifestList
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, nil, "", err
+		}
+		desc, err := selectManifest(&list, runtime.GOOS, runtime.GOARCH)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		if listDigest, err = digest.FromBytes(raw); err != nil {
+			return nil, nil, "", err
+		}
+
+		tag = desc.Digest.String()
+		if mediaType, raw, err = p.manifests.Get(tag); err != nil {
+			return nil, nil, "", err
+		}
+	}
+
+	var (
+		m              *schema1.Manifest
+		diffIDs        map[digest.Digest]digest.Digest
+		manifestDigest digest.Digest
+	)
+	switch mediaType {
+	case manifestListMediaType:
+		return nil, nil, "", fmt.Errorf("manifest list %s references another manifest list", listDigest)
+	case schema2MediaType:
+		if m, diffIDs, err = p.convertSchema2Manifest(raw, tag); err != nil {
+			return nil, nil, "", err
+		}
+		if manifestDigest, err = digest.FromBytes(raw); err != nil {
+			return nil, nil, "", err
+		}
+	default:
+		var signedManifest schema1.SignedManifest
+		if err := json.Unmarshal(raw, &signedManifest); err != nil {
+			return nil, nil, "", err
+		}
+		if m, err = verifyManifest(&signedManifest, tag); err != nil {
+			return nil, nil, "", err
+		}
+		if manifestDigest, _, err = digestFromManifest(&signedManifest, p.repoInfo.LocalName); err != nil {
+			return nil, nil, "", err
+		}
+	}
+
+	if listDigest != "" {
+		manifestDigest = listDigest
+	}
+	return m, diffIDs, manifestDigest, nil
+}
+
+// convertSchema2Manifest verifies the schema2 manifest raw of tag, fetches and
+// verifies its image configuration, and converts them to a schema1 manifest
+// along with the diff IDs of its layers.
+func (p *v2Puller) convertSchema2Manifest(raw []byte, tag string) (*schema1.Manifest, map[digest.Digest]digest.Digest, error) {
+	if err := verifyRawManifest(raw, tag); err != nil {
+		return nil, nil, err
+	}
+	var m schema2Manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, nil, err
+	}
+	if m.SchemaVersion != 2 {
+		return nil, nil, fmt.Errorf("unsupported schema version %d for tag %q", m.SchemaVersion, tag)
+	}
+
+	configJSON, err := p.repo.Blobs(context.Background()).Get(context.Background(), m.Config.Digest)
+	if err != nil {
+		return nil, nil, err
+	}
+	verifier, err := digest.NewDigestVerifier(m.Config.Digest)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := verifier.Write(configJSON); err != nil {
+		return nil, nil, err
+	}
+	if !verifier.Verified() {
+		return nil, nil, fmt.Errorf("image configuration verification failed for digest %s", m.Config.Digest)
+	}
+
+	return schema2ToSchema1(&m, configJSON)
+}
+
 func verifyManifest(signedManifest *schema1.SignedManifest, tag string) (m *schema1.Manifest, err error) {
 	// If pull by digest, then verify the manifest digest. NOTE: It is
 	// important to do this first, before any other content validation. If the
@@ -531,180 +632,93 @@ func fixManifestLayers(m *schema1.Manifest) error {
 	return nil
 }
 
-// getImageInfos returns an imageinfo struct for every image in the manifest.
-// These objects contain both calculated strongIDs and compatibilityIDs found
-// in v1Compatibility object.
-func (p *v2Puller) getImageInfos(m *schema1.Manifest) ([]contentAddressableDescriptor, error) {
-	imgs := make([]contentAddressableDescriptor, len(m.FSLayers))
+// baseParent returns the ID of the image the base image of the manifest is
+// based on, which is only set for the Windows base images, whose parent is
+// included with the installation.
+func (p *v2Puller) baseParent(m *schema1.Manifest) (string, error) {
+	if !allowBaseParentImage {
+		return "", nil
+	}
+	var base struct{ Parent string }
+	if err := json.Unmarshal([]byte(m.History[len(m.History)-1].V1Compatibility), &base); err != nil {
+		return "", err
+	}
+	if base.Parent == "" {
+		return "", nil
+	}
+	img, err := p.graph.Get(base.Parent)
+	if err != nil {
+		return "", err
+	}
+	return img.ID, nil
+}
 
-	var parent digest.Digest
-	for i := len(imgs) - 1; i >= 0; i-- {
-		var err error
-		imgs[i], err = newContentAddressableImage([]byte(m.History[i].V1Compatibility), m.FSLayers[i].BlobSum, parent)
+// registerLayer registers the image of the layer pl on top of the image
+// parent, once the layer is downloaded, writing the progress of its
+// extraction to out.
+func (p *v2Puller) registerLayer(out io.Writer, pl *pullLayer, parent string) (_ *image.Image, err error) {
+	var layerData io.Reader
+	if pl.download != nil {
+		result, err := pl.download.wait()
 		if err != nil {
 			return nil, err
 		}
-		parent = imgs[i].strongID
-	}
-
-	p.attemptIDReuse(imgs)
-
-	// reset the base layer parent for windows
-	if allowBaseParentImage {
-		var base struct{ Parent string }
-		if err := json.Unmarshal(imgs[len(imgs)-1].v1Compatibility, &base); err != nil {
+		download := result.(*layerDownload)
+		f, err := os.Open(download.path)
+		if err != nil {
 			return nil, err
 		}
-		if base.Parent != "" {
-			imgs[len(imgs)-1].parent = base.Parent
-		}
+		defer f.Close()
+		layerData = progressreader.New(progressreader.Config{
+			In:        f,
+			Out:       out,
+			Formatter: p.sf,
+			Size:      download.size,
+			NewLines:  false,
+			ID:        pl.id,
+			Action:    "Extracting",
+		})
 	}
 
-	return imgs, nil
-}
-
-// attemptIDReuse does a best attempt to match verified compatibilityIDs
-// already in the graph with the computed strongIDs so we can keep using them.
-// This process will never fail but may just return the strongIDs if none of
-// the compatibilityIDs exists or can be verified. If the strongIDs themselves
-// fail verification, we deterministically generate alternate IDs to use until
-// we find one that's available or already exists with the correct data.
-func (p *v2Puller) attemptIDReuse(imgs []contentAddressableDescriptor) {
-	// This function needs to be protected with a global lock, because it
-	// locks multiple IDs at once, and there's no good way to make sure
-	// the locking happens a deterministic order.
 	p.graph.imagesMutex.Lock()
 	defer p.graph.imagesMutex.Unlock()
 
-	idMap := make(map[string]struct{})
-	for _, img := range imgs {
-		idMap[img.id] = struct{}{}
-		idMap[img.compatibilityID] = struct{}{}
-
-		if p.graph.Exists(img.compatibilityID) {
-			if _, err := p.graph.generateV1CompatibilityChain(img.compatibilityID); err != nil {
-				logrus.Debugf("Migration v1Compatibility generation error: %v", err)
-				return
-			}
+	if pl.layer == nil && pl.diffID != "" {
+		// The layer is extracted before the image is registered, so that
+		// it can be verified first.
+		var parentLayer digest.Digest
+		if parent != "" {
+			parentLayer = p.graph.imageLayer(parent).chainID
 		}
-	}
-	for id := range idMap {
-		p.graph.imageMutex.Lock(id)
-		defer p.graph.imageMutex.Unlock(id)
-	}
-
-	// continueReuse controls whether the function will try to find
-	// existing layers on disk under the old v1 IDs, to avoid repulling
-	// them. The hashes are checked to ensure these layers are okay to
-	// use. continueReuse starts out as true, but is set to false if
-	// the code encounters something that doesn't match the expected hash.
-	continueReuse := true
-
-	for i := len(imgs) - 1; i >= 0; i-- {
-		if p.graph.Exists(imgs[i].id) {
-			// Found an image in the graph under the strongID. Validate the
-			// image before using it.
-			if err := p.validateImageInGraph(imgs[i].id, imgs, i); err != nil {
-				continueReuse = false
-				logrus.Debugf("not using existing strongID: %v", err)
-
-				// The strong ID existed in the graph but didn't
-				// validate successfully. We can't use the strong ID
-				// because it didn't validate successfully. Treat the
-				// graph like a hash table with probing... compute
-				// SHA256(id) until we find an ID that either doesn't
-				// already exist in the graph, or has existing content
-				// that validates successfully.
-				for {
-					if err := p.tryNextID(imgs, i, idMap); err != nil {
-						logrus.Debug(err.Error())
-					} else {
-						break
-					}
-				}
-			}
-			continue
-		}
-
-		if continueReuse {
-			compatibilityID := imgs[i].compatibilityID
-			if err := p.validateImageInGraph(compatibilityID, imgs, i); err != nil {
-				logrus.Debugf("stopping ID reuse: %v", err)
-				continueReuse = false
-			} else {
-				// The compatibility ID exists in the graph and was
-				// validated. Use it.
-				imgs[i].id = compatibilityID
-			}
+		if layerData == nil {
+			layerData = bytes.NewReader(emptyTar)
 		}
-	}
-
-	// fix up the parents of the images
-	for i := 0; i < len(imgs); i++ {
-		if i == len(imgs)-1 { // Base layer
-			imgs[i].parent = ""
-		} else {
-			imgs[i].parent = imgs[i+1].id
+		if pl.layer, err = p.graph.layers.register(parentLayer, layerData); err != nil {
+			return nil, err
 		}
 	}
-}
 
-// validateImageInGraph checks that an image in the graph has the expected
-// strongID. id is the entry in the graph to check, imgs is the slice of
-// images being processed (for access to the parent), and i is the index
-// into this slice which the graph entry should be checked against.
-func (p *v2Puller) validateImageInGraph(id string, imgs []contentAddressableDescriptor, i int) error {
-	img, err := p.graph.Get(id)
-	if err != nil {
-		return fmt.Errorf("missing: %v", err)
-	}
-	layerID, err := p.graph.getLayerDigest(id)
-	if err != nil {
-		return fmt.Errorf("digest: %v", err)
-	}
-	var parentID digest.Digest
-	if i != len(imgs)-1 {
-		if img.Parent != imgs[i+1].id { // comparing that graph points to validated ID
-			return fmt.Errorf("parent: %v %v", img.Parent, imgs[i+1].id)
+	desc := v1ConfigDescriptor{parent: parent, config: pl.v1Compatibility}
+	var img *image.Image
+	if l := pl.layer; l != nil {
+		if err := pl.verifyDiffID(l.diffID); err != nil {
+			return nil, err
 		}
-		parentID = imgs[i+1].strongID
-	} else if img.Parent != "" {
-		return fmt.Errorf("unexpected parent: %v", img.Parent)
+		// the image takes the reference over
+		pl.layer = nil
+		img, err = p.graph.registerWithLayer(desc, l)
+	} else {
+		img, err = p.graph.register(desc, layerData)
 	}
-
-	v1Config, err := p.graph.getV1CompatibilityConfig(img.ID)
 	if err != nil {
-		return fmt.Errorf("v1Compatibility: %v %v", img.ID, err)
+		return nil, err
 	}
 
-	json, err := image.MakeImageConfig(v1Config, layerID, parentID)
-	if err != nil {
-		return fmt.Errorf("make config: %v", err)
+	if err := p.graph.setLayerDigest(img.ID, pl.blobSum); err != nil {
+		return nil, err
 	}
-
-	if dgst, err := image.StrongID(json); err == nil && dgst == imgs[i].strongID {
-		logrus.Debugf("Validated %v as %v", dgst, id)
-	} else {
-		return fmt.Errorf("digest mismatch: %v %v, error: %v", dgst, imgs[i].strongID, err)
+	if err := p.graph.setV1CompatibilityConfig(img.ID, pl.v1Compatibility); err != nil {
+		return nil, err
 	}
-
-	// All clear
-	return nil
-}
-
-func (p *v2Puller) tryNextID(imgs []contentAddressableDescriptor, i int, idMap map[string]struct{}) error {
-	nextID, _ := digest.FromBytes([]byte(imgs[i].id))
-	imgs[i].id = nextID.Hex()
-
-	if _, exists := idMap[imgs[i].id]; !exists {
-		p.graph.imageMutex.Lock(imgs[i].id)
-		defer p.graph.imageMutex.Unlock(imgs[i].id)
-	}
-
-	if p.graph.Exists(imgs[i].id) {
-		if err := p.validateImageInGraph(imgs[i].id, imgs, i); err != nil {
-			return fmt.Errorf("not using existing strongID permutation %s: %v", imgs[i].id, err)
-		}
-	}
-	return nil
+	return img, nil
 }
@@ -1,14 +1,23 @@
 package graph
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/docker/distribution/digest"
 	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/registry/client"
+	"github.com/docker/docker/pkg/streamformatter"
+	"golang.org/x/net/context"
 )
 
 // TestFixManifestLayers checks that fixManifestLayers removes a duplicate
@@ -193,3 +202,115 @@ func TestValidateManifest(t *testing.T) {
 		t.Fatal("unexpected FSLayer in no-signature manifest")
 	}
 }
+
+// blobServer serves the blob data of the repository foo/bar. The first
+// download of the blob is interrupted halfway, and range requests are
+// honored if ranges is set.
+type blobServer struct {
+	data     []byte
+	ranges   bool
+	requests []string
+}
+
+func (bs *blobServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	dgst, err := digest.FromBytes(bs.data)
+	if err != nil || r.URL.Path != "/v2/foo/bar/blobs/"+dgst.String() {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Method == "HEAD" {
+		w.Header().Set("Content-Length", fmt.Sprint(len(bs.data)))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Docker-Content-Digest", dgst.String())
+		return
+	}
+	bs.requests = append(bs.requests, r.Header.Get("Range"))
+
+	var offset int
+	if rng := r.Header.Get("Range"); rng != "" && bs.ranges {
+		fmt.Sscanf(rng, "bytes=%d-", &offset)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, len(bs.data)-1, len(bs.data)))
+		w.Header().Set("Content-Length", fmt.Sprint(len(bs.data)-offset))
+		w.WriteHeader(http.StatusPartialContent)
+	} else {
+		w.Header().Set("Content-Length", fmt.Sprint(len(bs.data)))
+	}
+	if len(bs.requests) > 1 {
+		w.Write(bs.data[offset:])
+		return
+	}
+	w.Write(bs.data[:len(bs.data)/2])
+	w.(http.Flusher).Flush()
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err == nil {
+		conn.Close()
+	}
+}
+
+func testFetchBlob(t *testing.T, bs *blobServer) (int64, []byte, string, error) {
+	server := httptest.NewServer(bs)
+	defer server.Close()
+
+	defer func(delay func(int) time.Duration) { downloadRetryDelay = delay }(downloadRetryDelay)
+	downloadRetryDelay = func(int) time.Duration { return 0 }
+
+	repo, err := client.NewRepository(context.Background(), "foo/bar", server.URL, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests, err := newManifestClient("foo/bar", server.URL, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &v2Puller{repo: repo, manifests: manifests, sf: streamformatter.NewJSONStreamFormatter()}
+
+	f, err := ioutil.TempFile("", "docker-blob-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	dgst, err := digest.FromBytes(bs.data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var progress bytes.Buffer
+	size, fetchErr := p.fetchBlob(f, dgst, "layer", &progress)
+	data, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return size, data, progress.String(), fetchErr
+}
+
+func TestFetchBlobResume(t *testing.T) {
+	bs := &blobServer{data: bytes.Repeat([]byte("layer data "), 10000), ranges: true}
+	size, data, progress, err := testFetchBlob(t, bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(bs.data)) || !bytes.Equal(data, bs.data) {
+		t.Fatalf("Expected the whole blob to be downloaded, got %d bytes", len(data))
+	}
+	if len(bs.requests) != 2 || bs.requests[0] != "" || bs.requests[1] != fmt.Sprintf("bytes=%d-", len(bs.data)/2) {
+		t.Fatalf("Expected the download to be resumed where it stopped, got the ranges %q", bs.requests)
+	}
+	if !strings.Contains(progress, "Retrying in 0s (attempt 2 of 5)") {
+		t.Fatalf("Expected the retry in the progress output, got %s", progress)
+	}
+}
+
+func TestFetchBlobWithoutRanges(t *testing.T) {
+	bs := &blobServer{data: bytes.Repeat([]byte("layer data "), 10000)}
+	size, data, _, err := testFetchBlob(t, bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(bs.data)) || !bytes.Equal(data, bs.data) {
+		t.Fatalf("Expected the blob to be downloaded again, got %d bytes", len(data))
+	}
+	if len(bs.requests) != 2 {
+		t.Fatalf("Expected 2 downloads, got %d", len(bs.requests))
+	}
+}
@@ -51,7 +51,7 @@ func (s *TagStore) newPusher(endpoint registry.APIEndpoint, localRepo repository
 			repoInfo:     repoInfo,
 			config:       imagePushConfig,
 			sf:           sf,
-			layersPushed: make(map[digest.Digest]bool),
+			layersPushed: make(map[digest.Digest]int64),
 		}, nil
 	case registry.APIVersion1:
 		return &v1Pusher{
@@ -115,7 +115,7 @@ func (s *TagStore) Push(localName string, imagePushConfig *ImagePushConfig) erro
 
 		}
 
-		s.eventsService.Log("push", repoInfo.LocalName, "")
+		s.LogImageEvent(repoInfo.LocalName, "push")
 		return nil
 	}
 
@@ -3,6 +3,7 @@ package graph
 import (
 	"bufio"
 	"compress/gzip"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -32,15 +33,16 @@ type v2Pusher struct {
 	config    *ImagePushConfig
 	sf        *streamformatter.StreamFormatter
 	repo      distribution.Repository
+	manifests *manifestClient
 
-	// layersPushed is the set of layers known to exist on the remote side.
-	// This avoids redundant queries when pushing multiple tags that
-	// involve the same layers.
-	layersPushed map[digest.Digest]bool
+	// layersPushed maps the layers known to exist on the remote side to
+	// their size. This avoids redundant queries when pushing multiple tags
+	// that involve the same layers.
+	layersPushed map[digest.Digest]int64
 }
 
 func (p *v2Pusher) Push() (fallback bool, err error) {
-	p.repo, err = newV2Repository(p.repoInfo, p.endpoint, p.config.MetaHeaders, p.config.AuthConfig, "push", "pull")
+	p.repo, p.manifests, err = newV2Repository(p.repoInfo, p.endpoint, p.config.MetaHeaders, p.config.AuthConfig, "push", "pull")
 	if err != nil {
 		logrus.Debugf("Error getting v2 registry: %v", err)
 		return true, err
@@ -122,6 +124,17 @@ func (p *v2Pusher) pushV2Tag(tag string) error {
 
 	out := p.config.OutStream
 
+	// The layers of the chain, from the top one. The layers missing from
+	// the registry are uploaded while the next ones are checked.
+	var layers []*pushLayer
+	defer func() {
+		for _, pl := range layers {
+			if pl.upload != nil {
+				pl.upload.release()
+			}
+		}
+	}()
+
 	for ; layer != nil; layer, err = p.graph.GetParent(layer) {
 		if err != nil {
 			return err
@@ -143,21 +156,24 @@ func (p *v2Pusher) pushV2Tag(tag string) error {
 			}
 		}
 
-		var exists bool
+		var (
+			exists bool
+			size   int64
+		)
 		dgst, err := p.graph.getLayerDigestWithLock(layer.ID)
 		switch err {
 		case nil:
-			if p.layersPushed[dgst] {
-				exists = true
+			if size, exists = p.layersPushed[dgst]; exists {
 				// break out of switch, it is already known that
 				// the push is not needed and therefore doing a
 				// stat is unnecessary
 				break
 			}
-			_, err := p.repo.Blobs(context.Background()).Stat(context.Background(), dgst)
+			desc, err := p.repo.Blobs(context.Background()).Stat(context.Background(), dgst)
 			switch err {
 			case nil:
 				exists = true
+				size = desc.Size
 				out.Write(p.sf.FormatProgress(stringid.TruncateID(layer.ID), "Image already exists", nil))
 			case distribution.ErrBlobUnknown:
 				// nop
@@ -171,33 +187,77 @@ func (p *v2Pusher) pushV2Tag(tag string) error {
 			return fmt.Errorf("error getting image checksum: %v", err)
 		}
 
+		pl := &pushLayer{img: layer, digest: dgst, size: size}
 		// if digest was empty or not saved, or if blob does not exist on the remote repository,
-		// then fetch it.
+		// then push it.
 		if !exists {
-			var pushDigest digest.Digest
-			if pushDigest, err = p.pushV2Image(p.repo.Blobs(context.Background()), layer); err != nil {
+			out.Write(p.sf.FormatProgress(stringid.TruncateID(layer.ID), "Preparing", nil))
+			// The pushes of the same layer to the same repository at the
+			// same time share its upload.
+			key := p.endpoint.URL + "/" + p.repo.Name() + "@" + layer.ID
+			pl.upload = p.uploadManager.transfer(key, out, p.upload(layer, dgst))
+		}
+		layers = append(layers, pl)
+		layersSeen[layer.ID] = true
+	}
+
+	// The images of the chain and the descriptors of their layers, from
+	// the top one.
+	var (
+		imgs        []*image.Image
+		descriptors []descriptor
+		blobSums    []digest.Digest
+	)
+	for _, pl := range layers {
+		if pl.upload != nil {
+			result, err := pl.upload.wait()
+			if err != nil {
 				return err
 			}
-			if dgst == "" {
+			pushed := result.(descriptor)
+			if pl.digest == "" {
 				// Cache new checksum
-				if err := p.graph.setLayerDigestWithLock(layer.ID, pushDigest); err != nil {
+				if err := p.graph.setLayerDigestWithLock(pl.img.ID, pushed.Digest); err != nil {
 					return err
 				}
 			}
-			dgst = pushDigest
+			pl.digest, pl.size = pushed.Digest, pushed.Size
 		}
 
 		// read v1Compatibility config, generate new if needed
-		jsonData, err := p.graph.generateV1CompatibilityChain(layer.ID)
+		jsonData, err := p.graph.generateV1CompatibilityChain(pl.img.ID)
 		if err != nil {
 			return err
 		}
 
-		m.FSLayers = append(m.FSLayers, schema1.FSLayer{BlobSum: dgst})
+		m.FSLayers = append(m.FSLayers, schema1.FSLayer{BlobSum: pl.digest})
 		m.History = append(m.History, schema1.History{V1Compatibility: string(jsonData)})
+		imgs = append(imgs, pl.img)
+		descriptors = append(descriptors, descriptor{MediaType: layerMediaType, Size: pl.size, Digest: pl.digest})
 
-		layersSeen[layer.ID] = true
-		p.layersPushed[dgst] = true
+		p.layersPushed[pl.digest] = pl.size
+		blobSums = append(blobSums, pl.digest)
+	}
+
+	// The layers can now be mounted from this repository by the pushes to
+	// other repositories of the registry.
+	if err := p.blobSources.add(blobSource{Registry: p.endpoint.URL, Repository: p.repo.Name()}, blobSums...); err != nil {
+		logrus.Warnf("Failed to record the blobs pushed to %s: %v", p.repo.Name(), err)
+	}
+
+	// Windows base layers may have a parent which isn't pushed, which a
+	// schema2 manifest can't reference.
+	if imgs[len(imgs)-1].Parent == "" {
+		manifestDigest, manifestSize, err := p.pushSchema2Manifest(tag, imgs, descriptors)
+		if err == nil {
+			out.Write(p.sf.FormatStatus("", "%s: digest: %s size: %d", tag, manifestDigest, manifestSize))
+			return nil
+		}
+		if _, ok := err.(errManifestRejected); !ok {
+			return err
+		}
+		// Registries which don't know about schema2 reject it.
+		logrus.Debugf("Pushing a schema1 manifest for %s:%s: %v", p.repo.Name(), tag, err)
 	}
 
 	logrus.Infof("Signed manifest for %s:%s using daemon's key: %s", p.repo.Name(), tag, p.trustKey.KeyID())
@@ -221,25 +281,146 @@ func (p *v2Pusher) pushV2Tag(tag string) error {
 	return manSvc.Put(signed)
 }
 
-func (p *v2Pusher) pushV2Image(bs distribution.BlobService, img *image.Image) (digest.Digest, error) {
-	out := p.config.OutStream
+// pushSchema2Manifest pushes the image configuration of the chain of images
+// imgs, and puts the schema2 manifest referencing it and the layers under
+// tag. imgs and layers are listed from the top image. It returns the digest
+// and the size of the manifest.
+func (p *v2Pusher) pushSchema2Manifest(tag string, imgs []*image.Image, layers []descriptor) (digest.Digest, int, error) {
+	m := schema2Manifest{
+		Versioned: manifest.Versioned{SchemaVersion: 2},
+		MediaType: schema2MediaType,
+	}
+	var (
+		chain   []*image.Image
+		diffIDs []digest.Digest
+	)
+	for i := len(imgs) - 1; i >= 0; i-- {
+		diffID, err := p.graph.getDiffID(imgs[i].ID)
+		if err != nil {
+			return "", 0, err
+		}
+		chain = append(chain, imgs[i])
+		diffIDs = append(diffIDs, diffID)
+		m.Layers = append(m.Layers, layers[i])
+	}
 
-	out.Write(p.sf.FormatProgress(stringid.TruncateID(img.ID), "Preparing", nil))
+	configJSON, err := makeImageConfig(chain, diffIDs)
+	if err != nil {
+		return "", 0, err
+	}
+	configDigest, err := digest.FromBytes(configJSON)
+	if err != nil {
+		return "", 0, err
+	}
+	bs := p.repo.Blobs(context.Background())
+	switch _, err := bs.Stat(context.Background(), configDigest); err {
+	case nil:
+	case distribution.ErrBlobUnknown:
+		if _, err := bs.Put(context.Background(), imageConfigMediaType, configJSON); err != nil {
+			return "", 0, err
+		}
+	default:
+		return "", 0, err
+	}
+	m.Config = descriptor{
+		MediaType: imageConfigMediaType,
+		Size:      int64(len(configJSON)),
+		Digest:    configDigest,
+	}
+
+	raw, err := json.MarshalIndent(&m, "", "   ")
+	if err != nil {
+		return "", 0, err
+	}
+	if err := p.manifests.Put(tag, schema2MediaType, raw); err != nil {
+		return "", 0, err
+	}
+	manifestDigest, err := digest.FromBytes(raw)
+	if err != nil {
+		return "", 0, err
+	}
+	return manifestDigest, len(raw), nil
+}
+
+// pushLayer is a layer of the chain of images being pushed, along with the
+// digest and the size of its blob once it is known to be in the registry.
+type pushLayer struct {
+	img    *image.Image
+	digest digest.Digest
+	size   int64
+	// upload is set when the layer is missing from the registry
+	upload *transfer
+}
+
+// upload returns the transfer uploading the layer of img, whose result is
+// the descriptor of the uploaded blob. If the digest of the layer is known,
+// the blob is first mounted from another repository of the registry it was
+// seen in, when possible.
+func (p *v2Pusher) upload(img *image.Image, known digest.Digest) transferFunc {
+	return func(progress io.Writer) (interface{}, func(), error) {
+		if known != "" {
+			if desc, mounted := p.mountBlob(img, known, progress); mounted {
+				return desc, nil, nil
+			}
+		}
+		dgst, size, err := p.pushV2Image(p.repo.Blobs(context.Background()), img, progress)
+		if err != nil {
+			return nil, nil, err
+		}
+		return descriptor{MediaType: layerMediaType, Size: size, Digest: dgst}, nil, nil
+	}
+}
+
+// mountBlob tries to mount the layer dgst of img from the repositories of the
+// registry it was last seen in, within the limits of the credentials of the
+// push. It returns the descriptor of the blob and whether it was mounted.
+func (p *v2Pusher) mountBlob(img *image.Image, dgst digest.Digest, progress io.Writer) (descriptor, bool) {
+	for _, src := range p.blobSources.get(dgst, p.endpoint.URL) {
+		if src.Repository == p.repo.Name() {
+			continue
+		}
+		mc, err := newV2MountClient(p.repoInfo, p.endpoint, p.config.MetaHeaders, p.config.AuthConfig, src.Repository)
+		if err != nil {
+			logrus.Debugf("Failed to mount %s from %s: %v", dgst, src.Repository, err)
+			continue
+		}
+		mounted, err := mc.MountBlob(dgst, src.Repository)
+		if err != nil {
+			logrus.Debugf("Failed to mount %s from %s: %v", dgst, src.Repository, err)
+			continue
+		}
+		if !mounted {
+			logrus.Debugf("Registry didn't mount %s from %s", dgst, src.Repository)
+			continue
+		}
+		desc, err := p.repo.Blobs(context.Background()).Stat(context.Background(), dgst)
+		if err != nil {
+			logrus.Debugf("Failed to stat %s mounted from %s: %v", dgst, src.Repository, err)
+			continue
+		}
+		progress.Write(p.sf.FormatProgress(stringid.TruncateID(img.ID), fmt.Sprintf("Mounted from %s", src.Repository), nil))
+		return descriptor{MediaType: layerMediaType, Size: desc.Size, Digest: dgst}, true
+	}
+	return descriptor{}, false
+}
 
+// pushV2Image uploads the layer of img to bs, writing the progress of the
+// upload to out. It returns the digest and the size of the uploaded blob.
+func (p *v2Pusher) pushV2Image(bs distribution.BlobService, img *image.Image, out io.Writer) (digest.Digest, int64, error) {
 	image, err := p.graph.Get(img.ID)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 	arch, err := p.graph.tarLayer(image)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 	defer arch.Close()
 
 	// Send the layer
 	layerUpload, err := bs.Create(context.Background())
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 	defer layerUpload.Close()
 
@@ -286,16 +467,16 @@ func (p *v2Pusher) pushV2Image(bs distribution.BlobService, img *image.Image) (d
 	nn, err := layerUpload.ReadFrom(pipeReader)
 	pipeReader.Close()
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 
 	dgst := digester.Digest()
 	if _, err := layerUpload.Commit(context.Background(), distribution.Descriptor{Digest: dgst}); err != nil {
-		return "", err
+		return "", 0, err
 	}
 
 	logrus.Debugf("uploaded layer %s (%s), %d bytes", img.ID, dgst, nn)
 	out.Write(p.sf.FormatProgress(stringid.TruncateID(img.ID), "Pushed", nil))
 
-	return dgst, nil
+	return dgst, nn, nil
 }
@@ -1,7 +1,12 @@
 package graph
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
@@ -13,6 +18,8 @@ import (
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/digest"
 	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/docker/distribution/registry/api/v2"
 	"github.com/docker/distribution/registry/client"
 	"github.com/docker/distribution/registry/client/auth"
 	"github.com/docker/distribution/registry/client/transport"
@@ -29,18 +36,55 @@ func (dcs dumbCredentialStore) Basic(*url.URL) (string, string) {
 	return dcs.auth.Username, dcs.auth.Password
 }
 
-// newV2Repository returns a repository (v2 only). It creates a HTTP transport
-// providing timeout settings and authentication support, and also verifies the
-// remote API version.
-func newV2Repository(repoInfo *registry.RepositoryInfo, endpoint registry.APIEndpoint, metaHeaders http.Header, authConfig *cliconfig.AuthConfig, actions ...string) (distribution.Repository, error) {
-	ctx := context.Background()
+// newV2Repository returns a repository (v2 only), along with a client for the
+// manifests of any schema in it. It creates a HTTP transport providing timeout
+// settings and authentication support, and also verifies the remote API
+// version.
+func newV2Repository(repoInfo *registry.RepositoryInfo, endpoint registry.APIEndpoint, metaHeaders http.Header, authConfig *cliconfig.AuthConfig, actions ...string) (distribution.Repository, *manifestClient, error) {
+	repoName := v2RepositoryName(repoInfo, endpoint)
+	tr, err := newV2Transport(endpoint, metaHeaders, authConfig, repositoryScope{name: repoName, actions: actions})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	repo, err := client.NewRepository(context.Background(), repoName, endpoint.URL, tr)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifests, err := newManifestClient(repoName, endpoint.URL, tr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return repo, manifests, nil
+}
 
-	repoName := repoInfo.CanonicalName
+// newV2MountClient returns a client for the repository of repoInfo (v2
+// only), which is also allowed to pull from the repository from of the same
+// registry, to mount blobs from it.
+func newV2MountClient(repoInfo *registry.RepositoryInfo, endpoint registry.APIEndpoint, metaHeaders http.Header, authConfig *cliconfig.AuthConfig, from string) (*manifestClient, error) {
+	repoName := v2RepositoryName(repoInfo, endpoint)
+	tr, err := newV2Transport(endpoint, metaHeaders, authConfig,
+		repositoryScope{name: repoName, actions: []string{"push", "pull"}},
+		repositoryScope{name: from, actions: []string{"pull"}})
+	if err != nil {
+		return nil, err
+	}
+	return newManifestClient(repoName, endpoint.URL, tr)
+}
+
+// v2RepositoryName returns the name of the repository of repoInfo on the
+// registry endpoint.
+func v2RepositoryName(repoInfo *registry.RepositoryInfo, endpoint registry.APIEndpoint) string {
 	// If endpoint does not support CanonicalName, use the RemoteName instead
 	if endpoint.TrimHostname {
-		repoName = repoInfo.RemoteName
+		return repoInfo.RemoteName
 	}
+	return repoInfo.CanonicalName
+}
 
+// newV2Transport returns a HTTP transport to the registry endpoint, which
+// authenticates for the given scopes.
+func newV2Transport(endpoint registry.APIEndpoint, metaHeaders http.Header, authConfig *cliconfig.AuthConfig, scopes ...repositoryScope) (http.RoundTripper, error) {
 	// TODO(dmcgowan): Call close idle connections when complete, use keep alive
 	base := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
@@ -93,12 +137,228 @@ func newV2Repository(repoInfo *registry.RepositoryInfo, endpoint registry.APIEnd
 	}
 
 	creds := dumbCredentialStore{auth: authConfig}
-	tokenHandler := auth.NewTokenHandler(authTransport, creds, repoName, actions...)
+	tokenHandler := newTokenHandler(authTransport, creds, scopes...)
 	basicHandler := auth.NewBasicHandler(creds)
 	modifiers = append(modifiers, auth.NewAuthorizer(challengeManager, tokenHandler, basicHandler))
-	tr := transport.NewTransport(base, modifiers...)
+	return transport.NewTransport(base, modifiers...), nil
+}
+
+// manifestClient gets and puts the raw manifests of a repository, whatever
+// their schema. The manifest service of the distribution client only handles
+// schema1 manifests. It also resumes the downloads of blobs, which the blob
+// service of the distribution client can't.
+type manifestClient struct {
+	name   string
+	ub     *v2.URLBuilder
+	client *http.Client
+	// blobClient has no timeout, since downloading a blob can take a while.
+	blobClient *http.Client
+}
+
+func newManifestClient(name, baseURL string, tr http.RoundTripper) (*manifestClient, error) {
+	ub, err := v2.NewURLBuilderFromString(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	return &manifestClient{
+		name: name,
+		ub:   ub,
+		client: &http.Client{
+			Transport: tr,
+			Timeout:   1 * time.Minute,
+		},
+		blobClient: &http.Client{
+			Transport:     tr,
+			CheckRedirect: keepRangeOnRedirect,
+		},
+	}, nil
+}
+
+// keepRangeOnRedirect copies the range of a blob request onto its redirects,
+// which the registries use to send the blobs from their storage. The http
+// client doesn't copy the headers of the requests it redirects, so the
+// storage would send the whole blob.
+func keepRangeOnRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if r := via[0].Header.Get("Range"); r != "" {
+		req.Header.Set("Range", r)
+	}
+	return nil
+}
+
+// Get returns the manifest referenced by a tag or a digest, and its media
+// type. The registry is told that schema2 manifests and manifest lists are
+// understood, so old registries answer with a schema1 manifest.
+func (mc *manifestClient) Get(reference string) (string, []byte, error) {
+	u, err := mc.ub.BuildManifestURL(mc.name, reference)
+	if err != nil {
+		return "", nil, err
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, mediaType := range []string{manifestListMediaType, schema2MediaType, schema1.ManifestMediaType, schema1SignedMediaType} {
+		req.Header.Add("Accept", mediaType)
+	}
+
+	resp, err := mc.client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if !client.SuccessStatus(resp.StatusCode) {
+		return "", nil, manifestErrorResponse(resp)
+	}
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, err
+	}
+	return manifestMediaType(resp.Header.Get("Content-Type"), raw), raw, nil
+}
+
+// Put stores the raw manifest of the given media type under tag. It returns
+// errManifestRejected if the registry doesn't accept the manifest, which old
+// registries do for anything but schema1.
+func (mc *manifestClient) Put(tag, mediaType string, raw []byte) error {
+	u, err := mc.ub.BuildManifestURL(mc.name, tag)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("PUT", u, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", mediaType)
+
+	resp, err := mc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if client.SuccessStatus(resp.StatusCode) {
+		return nil
+	}
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnsupportedMediaType {
+		return errManifestRejected{manifestErrorResponse(resp)}
+	}
+	return manifestErrorResponse(resp)
+}
+
+// GetBlob opens the blob dgst from offset, which is requested with a range
+// request. The registries which don't support range requests send the whole
+// blob, in which case the returned offset, where the content starts, is 0.
+func (mc *manifestClient) GetBlob(dgst digest.Digest, offset int64) (io.ReadCloser, int64, error) {
+	u, err := mc.ub.BuildBlobURL(mc.name, dgst)
+	if err != nil {
+		return nil, 0, err
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := mc.blobClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode == http.StatusPartialContent {
+		if offset > 0 && strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+			return resp.Body, offset, nil
+		}
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("unexpected range %q for blob %s from offset %d", resp.Header.Get("Content-Range"), dgst, offset)
+	}
+	if !client.SuccessStatus(resp.StatusCode) {
+		defer resp.Body.Close()
+		return nil, 0, manifestErrorResponse(resp)
+	}
+	return resp.Body, 0, nil
+}
+
+// MountBlob mounts the blob dgst from the repository from of the same
+// registry. It returns false if the registry didn't mount it, which is then
+// to be uploaded.
+func (mc *manifestClient) MountBlob(dgst digest.Digest, from string) (bool, error) {
+	u, err := mc.ub.BuildBlobUploadURL(mc.name, url.Values{"mount": {dgst.String()}, "from": {from}})
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequest("POST", u, nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := mc.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return true, nil
+	case http.StatusAccepted:
+		// The registry started an upload instead, which is canceled
+		// since the blob is uploaded with the blob service.
+		mc.cancelUpload(req.URL, resp.Header.Get("Location"))
+		return false, nil
+	}
+	return false, manifestErrorResponse(resp)
+}
 
-	return client.NewRepository(ctx, repoName, endpoint.URL, tr)
+// cancelUpload cancels the upload at location, relative to base. The upload
+// expires on the registry anyway if it can't be canceled.
+func (mc *manifestClient) cancelUpload(base *url.URL, location string) {
+	if location == "" {
+		return
+	}
+	u, err := base.Parse(location)
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequest("DELETE", u.String(), nil)
+	if err != nil {
+		return
+	}
+	if resp, err := mc.client.Do(req); err == nil {
+		resp.Body.Close()
+	} else {
+		logrus.Debugf("Failed to cancel upload %s: %v", u, err)
+	}
+}
+
+// errManifestRejected is returned when a registry rejects a manifest.
+type errManifestRejected struct {
+	err error
+}
+
+func (e errManifestRejected) Error() string {
+	return fmt.Sprintf("manifest rejected by the registry: %v", e.err)
+}
+
+// manifestErrorResponse returns the error of an unsuccessful response the way
+// the distribution client does.
+func manifestErrorResponse(resp *http.Response) error {
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		var errs errcode.Errors
+		if err := json.Unmarshal(body, &errs); err != nil {
+			if resp.StatusCode == http.StatusUnauthorized {
+				return errcode.ErrorCodeUnauthorized.WithDetail(body)
+			}
+			return &client.UnexpectedHTTPResponseError{ParseErr: err, Response: body}
+		}
+		return errs
+	}
+	return &client.UnexpectedHTTPStatusError{Status: resp.Status}
 }
 
 func digestFromManifest(m *schema1.SignedManifest, localName string) (digest.Digest, int, error) {
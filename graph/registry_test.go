@@ -0,0 +1,138 @@
+package graph
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/cliconfig"
+	"github.com/docker/docker/registry"
+)
+
+func TestMountBlob(t *testing.T) {
+	dgst := digest.Digest("sha256:4b1f0a4c3a3da9ba1b1b6e4d1b1f0a4c3a3da9ba1b1b6e4d1b1f0a4c3a3da9ba")
+
+	var (
+		status   int
+		requests []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		if r.Method == "POST" {
+			w.Header().Set("Location", "/v2/foo/bar/blobs/uploads/1234")
+			w.WriteHeader(status)
+		}
+	}))
+	defer server.Close()
+
+	mc, err := newManifestClient("foo/bar", server.URL, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status = http.StatusCreated
+	if mounted, err := mc.MountBlob(dgst, "foo/base"); err != nil || !mounted {
+		t.Fatalf("Expected the blob to be mounted, got %t, %v", mounted, err)
+	}
+	mountRequest := "POST /v2/foo/bar/blobs/uploads/?" + url.Values{"mount": {dgst.String()}, "from": {"foo/base"}}.Encode()
+	if len(requests) != 1 || requests[0] != mountRequest {
+		t.Fatalf("Expected a mount request, got %v", requests)
+	}
+
+	// the upload the registry starts when it doesn't mount the blob is
+	// canceled
+	requests = nil
+	status = http.StatusAccepted
+	if mounted, err := mc.MountBlob(dgst, "foo/base"); err != nil || mounted {
+		t.Fatalf("Expected the blob not to be mounted, got %t, %v", mounted, err)
+	}
+	if len(requests) != 2 || requests[1] != "DELETE /v2/foo/bar/blobs/uploads/1234" {
+		t.Fatalf("Expected the upload to be canceled, got %v", requests)
+	}
+
+	status = http.StatusUnauthorized
+	if _, err := mc.MountBlob(dgst, "foo/base"); err == nil {
+		t.Fatal("Expected an error when the mount is refused")
+	}
+}
+
+func TestMountClientScopes(t *testing.T) {
+	var scopes []string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			scopes = r.URL.Query()["scope"]
+			w.Write([]byte(`{"token": "secret"}`))
+		case r.Header.Get("Authorization") != "Bearer secret":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer server.Close()
+
+	repoInfo := &registry.RepositoryInfo{RemoteName: "foo/bar"}
+	endpoint := registry.APIEndpoint{URL: server.URL, TrimHostname: true}
+	mc, err := newV2MountClient(repoInfo, endpoint, nil, &cliconfig.AuthConfig{}, "foo/base")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mounted, err := mc.MountBlob(digest.Digest("sha256:4b1f0a4c3a3da9ba1b1b6e4d1b1f0a4c3a3da9ba1b1b6e4d1b1f0a4c3a3da9ba"), "foo/base"); err != nil || !mounted {
+		t.Fatalf("Expected the blob to be mounted, got %t, %v", mounted, err)
+	}
+	expected := []string{"repository:foo/bar:push,pull", "repository:foo/base:pull"}
+	if !reflect.DeepEqual(scopes, expected) {
+		t.Fatalf("Expected the token scopes %v, got %v", expected, scopes)
+	}
+}
+
+func TestGetBlobRangeOnRedirect(t *testing.T) {
+	blob := []byte("0123456789")
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var offset int
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &offset); err != nil {
+			w.Write(blob)
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, len(blob)-1, len(blob)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(blob[offset:])
+	}))
+	defer storage.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, storage.URL+"/blob", http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	mc, err := newManifestClient("foo/bar", server.URL, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, offset, err := mc.GetBlob(digest.Digest("sha256:4b1f0a4c3a3da9ba1b1b6e4d1b1f0a4c3a3da9ba1b1b6e4d1b1f0a4c3a3da9ba"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	content, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != 4 || string(content) != "456789" {
+		t.Fatalf("Expected the blob from offset 4, got %q from offset %d", content, offset)
+	}
+
+	// the client of go 1.5 doesn't copy the headers on redirects itself
+	via, _ := http.NewRequest("GET", server.URL, nil)
+	via.Header.Set("Range", "bytes=4-")
+	req, _ := http.NewRequest("GET", storage.URL+"/blob", nil)
+	if err := keepRangeOnRedirect(req, []*http.Request{via}); err != nil || req.Header.Get("Range") != "bytes=4-" {
+		t.Fatalf("Expected the range to be kept on redirect, got %q, %v", req.Header.Get("Range"), err)
+	}
+}
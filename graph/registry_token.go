@@ -0,0 +1,145 @@
+package graph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/registry/client/auth"
+)
+
+// minimumTokenLifetime is the lifetime of the tokens for which the
+// authorization server gives none, or a shorter one.
+const minimumTokenLifetime = 60 * time.Second
+
+// repositoryScope is the scope of a token for actions on a repository.
+type repositoryScope struct {
+	name    string
+	actions []string
+}
+
+func (s repositoryScope) String() string {
+	return "repository:" + s.name + ":" + strings.Join(s.actions, ",")
+}
+
+// tokenHandler authenticates the requests to a registry with a bearer token
+// for a list of scopes. The token handler of the distribution client only
+// requests a single scope, so a token for mounting blobs can't also allow to
+// pull from the source repository with it.
+type tokenHandler struct {
+	transport http.RoundTripper
+	creds     auth.CredentialStore
+	scopes    []repositoryScope
+
+	sync.Mutex
+	token      string
+	expiration time.Time
+}
+
+type tokenResponse struct {
+	Token       string    `json:"token"`
+	AccessToken string    `json:"access_token"`
+	ExpiresIn   int       `json:"expires_in"`
+	IssuedAt    time.Time `json:"issued_at"`
+}
+
+func newTokenHandler(transport http.RoundTripper, creds auth.CredentialStore, scopes ...repositoryScope) auth.AuthenticationHandler {
+	return &tokenHandler{
+		transport: transport,
+		creds:     creds,
+		scopes:    scopes,
+	}
+}
+
+func (th *tokenHandler) Scheme() string {
+	return "bearer"
+}
+
+func (th *tokenHandler) AuthorizeRequest(req *http.Request, params map[string]string) error {
+	th.Lock()
+	defer th.Unlock()
+	if time.Now().After(th.expiration) {
+		if err := th.fetchToken(params); err != nil {
+			return err
+		}
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", th.token))
+	return nil
+}
+
+// fetchToken gets a token for the scopes of th from the authorization server
+// of the challenge params.
+func (th *tokenHandler) fetchToken(params map[string]string) error {
+	realm, ok := params["realm"]
+	if !ok {
+		return errors.New("no realm specified for token auth challenge")
+	}
+	realmURL, err := url.Parse(realm)
+	if err != nil {
+		return fmt.Errorf("invalid token auth challenge realm: %s", err)
+	}
+
+	req, err := http.NewRequest("GET", realmURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	reqParams := req.URL.Query()
+	if service := params["service"]; service != "" {
+		reqParams.Add("service", service)
+	}
+	for _, scope := range th.scopes {
+		reqParams.Add("scope", scope.String())
+	}
+	if th.creds != nil {
+		username, password := th.creds.Basic(realmURL)
+		if username != "" && password != "" {
+			reqParams.Add("account", username)
+			req.SetBasicAuth(username, password)
+		}
+	}
+	req.URL.RawQuery = reqParams.Encode()
+
+	client := &http.Client{
+		Transport: th.transport,
+		Timeout:   15 * time.Second,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("token auth attempt for registry: %s request failed with status: %d %s", req.URL, resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	var tr tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return fmt.Errorf("unable to decode token response: %s", err)
+	}
+	// access_token is equivalent to token
+	if tr.AccessToken != "" {
+		tr.Token = tr.AccessToken
+	}
+	if tr.Token == "" {
+		return errors.New("authorization server did not include a token in the response")
+	}
+
+	lifetime := time.Duration(tr.ExpiresIn) * time.Second
+	if lifetime < minimumTokenLifetime {
+		logrus.Debugf("Increasing token expiration to: %v", minimumTokenLifetime)
+		lifetime = minimumTokenLifetime
+	}
+	// issued_at is optional in the token response
+	if tr.IssuedAt.IsZero() {
+		tr.IssuedAt = time.Now()
+	}
+	th.token = tr.Token
+	th.expiration = tr.IssuedAt.Add(lifetime)
+	return nil
+}
@@ -0,0 +1,66 @@
+package graph
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/docker/docker/dockerversion"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/runconfig"
+)
+
+// CreateReproducible creates a new image like Create, but such that the same
+// changes committed with the same configurations on the same parent give the
+// same image: the image is created at created, the fields which depend on the
+// container are left empty and the layer is normalized.
+func (graph *Graph) CreateReproducible(layerData io.Reader, containerImage, comment, author string, containerConfig, config *runconfig.Config, created time.Time) (*image.Image, error) {
+	img := &image.Image{
+		Parent:        containerImage,
+		Comment:       comment,
+		Created:       created.UTC(),
+		DockerVersion: dockerversion.Version,
+		Author:        author,
+		Config:        config,
+		Architecture:  runtime.GOARCH,
+		OS:            runtime.GOOS,
+	}
+	if containerConfig != nil {
+		img.ContainerConfig = *containerConfig
+		img.ContainerConfig.Hostname = ""
+	}
+
+	return graph.registerReproducible(img, layerData)
+}
+
+// registerReproducible registers img with layerData normalized, using the
+// creation time of img as the modification time of the files, so the ID of
+// the image, the digest of its configuration which references the digest of
+// the layer, only depends on their content.
+func (graph *Graph) registerReproducible(img *image.Image, layerData io.Reader) (*image.Image, error) {
+	tmp, err := graph.mktemp()
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	layer, err := os.Create(filepath.Join(tmp, "layer.tar"))
+	if err != nil {
+		return nil, err
+	}
+	defer layer.Close()
+
+	if err := archive.Normalize(layer, layerData, img.Created); err != nil {
+		return nil, err
+	}
+	if _, err := layer.Seek(0, 0); err != nil {
+		return nil, err
+	}
+
+	img.ID = ""
+	img.LayerID = ""
+	return graph.Register(v1Descriptor{img}, layer)
+}
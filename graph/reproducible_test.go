@@ -0,0 +1,104 @@
+package graph
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/runconfig"
+)
+
+func TestCreateReproducible(t *testing.T) {
+	graph, _ := tempGraph(t)
+	defer nukeGraph(graph)
+
+	archive, err := fakeTar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := registerTestImage(t, graph, "", "base", archive)
+
+	epoch := time.Unix(1000000000, 0)
+	create := func(layerData io.Reader, hostname string) string {
+		containerConfig := &runconfig.Config{Hostname: hostname, Image: base.ID}
+		img, err := graph.CreateReproducible(layerData, base.ID, "", "", containerConfig, &runconfig.Config{}, epoch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !img.Created.Equal(epoch) || img.Container != "" || img.ContainerConfig.Hostname != "" || img.LayerID == "" {
+			t.Fatalf("Expected a reproducible image, got %+v", img)
+		}
+		return img.ID
+	}
+
+	now := time.Now()
+	first := create(twoFilesTar(t, "a", "b", now), "container1")
+	second := create(twoFilesTar(t, "b", "a", now.Add(time.Hour)), "container2")
+	if first != second {
+		t.Fatalf("Expected the same changes to give the same image, got %s and %s", first, second)
+	}
+	assertNImages(graph, t, 2)
+
+	if other := create(singleFileTar(t, "/a", "other"), "container1"); other == first {
+		t.Fatal("Expected different changes to give a different image")
+	}
+}
+
+func TestSquashReproducible(t *testing.T) {
+	graph, _ := tempGraph(t)
+	defer nukeGraph(graph)
+
+	archive, err := fakeTar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := registerTestImage(t, graph, "", "base", archive)
+	foo := registerTestImage(t, graph, base.ID, "add foo", singleFileTar(t, "/foo", "foo"))
+
+	squashed, err := graph.Squash(foo.ID, base.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !squashed.Created.Equal(foo.Created) {
+		t.Fatalf("Expected the squashed image to be created at %v, got %v", foo.Created, squashed.Created)
+	}
+	again, err := graph.Squash(foo.ID, base.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != squashed.ID {
+		t.Fatalf("Expected squashing twice to give the same image, got %s and %s", squashed.ID, again.ID)
+	}
+	// the copy of the step and the squashed image
+	assertNImages(graph, t, 4)
+}
+
+// twoFilesTar returns an archive of the files first and second, in this
+// order, modified at modTime.
+func twoFilesTar(t *testing.T, first, second string, modTime time.Time) io.Reader {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for _, name := range []string{first, second} {
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Uid:     os.Getuid(),
+			Gid:     os.Getgid(),
+			Size:    int64(len(name)),
+			ModTime: modTime,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
@@ -0,0 +1,312 @@
+package graph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/stringutils"
+)
+
+const (
+	// schema1SignedMediaType is the media type registries use for the
+	// signed schema1 manifests.
+	schema1SignedMediaType = "application/vnd.docker.distribution.manifest.v1+prettyjws"
+	// schema2MediaType is the media type of the schema2 manifests.
+	schema2MediaType = "application/vnd.docker.distribution.manifest.v2+json"
+	// manifestListMediaType is the media type of the manifest lists, which
+	// reference a manifest of the image for each platform.
+	manifestListMediaType = "application/vnd.docker.distribution.manifest.list.v2+json"
+	// imageConfigMediaType is the media type of the configuration blob of a
+	// schema2 manifest.
+	imageConfigMediaType = "application/vnd.docker.container.image.v1+json"
+	// layerMediaType is the media type of the gzipped layers.
+	layerMediaType = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+)
+
+// emptyGzipLayer is a gzipped tar archive holding no files, which stands in
+// the manifests for the images which don't change the filesystem.
+var emptyGzipLayer = []byte{
+	31, 139, 8, 0, 0, 9, 110, 136, 0, 255, 98, 24, 5, 163, 96, 20, 140, 88,
+	0, 8, 0, 0, 255, 255, 46, 175, 181, 239, 0, 4, 0, 0,
+}
+
+// emptyGzipLayerDigest is the digest of emptyGzipLayer.
+const emptyGzipLayerDigest = digest.Digest("sha256:a3ed95caeb02ffe68cdd9fd84406680ae93d633cb16422d00e8a7c22955b46d4")
+
+// descriptor references a blob of a schema2 manifest, or a manifest of a
+// manifest list.
+type descriptor struct {
+	MediaType string        `json:"mediaType"`
+	Size      int64         `json:"size"`
+	Digest    digest.Digest `json:"digest"`
+}
+
+// schema2Manifest is an image manifest whose image configuration is a blob of
+// its own, the layers being listed from the base one.
+type schema2Manifest struct {
+	manifest.Versioned
+	MediaType string       `json:"mediaType"`
+	Config    descriptor   `json:"config"`
+	Layers    []descriptor `json:"layers"`
+}
+
+// platform is the platform the image of a manifest list entry runs on.
+type platform struct {
+	Architecture string   `json:"architecture"`
+	OS           string   `json:"os"`
+	Variant      string   `json:"variant,omitempty"`
+	Features     []string `json:"features,omitempty"`
+}
+
+// manifestDescriptor is an entry of a manifest list.
+type manifestDescriptor struct {
+	descriptor
+	Platform platform `json:"platform"`
+}
+
+// manifestList references the manifests of the same image for several
+// platforms.
+type manifestList struct {
+	manifest.Versioned
+	MediaType string               `json:"mediaType"`
+	Manifests []manifestDescriptor `json:"manifests"`
+}
+
+// imageConfig is the configuration blob of a schema2 manifest: the
+// configuration of the top image, without the fields identifying it in the
+// graph, along with the digests of the uncompressed layers and the history
+// of the images they come from.
+type imageConfig struct {
+	image.Image
+	RootFS  *rootFS   `json:"rootfs,omitempty"`
+	History []history `json:"history,omitempty"`
+}
+
+// rootFS lists the layers of an image configuration from the base one.
+type rootFS struct {
+	Type    string          `json:"type"`
+	DiffIDs []digest.Digest `json:"diff_ids,omitempty"`
+}
+
+// history describes the image a layer comes from. EmptyLayer is set for the
+// images which didn't change the filesystem, and have no layer.
+type history struct {
+	Created    time.Time `json:"created"`
+	Author     string    `json:"author,omitempty"`
+	CreatedBy  string    `json:"created_by,omitempty"`
+	Comment    string    `json:"comment,omitempty"`
+	EmptyLayer bool      `json:"empty_layer,omitempty"`
+}
+
+// manifestMediaType returns the media type of a manifest. Registries don't
+// all set the Content-Type of schema1 manifests, so the manifest itself is
+// looked at when the header doesn't say it is a schema2 one.
+func manifestMediaType(contentType string, raw []byte) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if mediaType == schema2MediaType || mediaType == manifestListMediaType {
+			return mediaType
+		}
+	}
+
+	var m struct {
+		manifest.Versioned
+		MediaType string `json:"mediaType"`
+	}
+	if err := json.Unmarshal(raw, &m); err == nil && m.SchemaVersion == 2 {
+		return m.MediaType
+	}
+	return schema1SignedMediaType
+}
+
+// verifyRawManifest checks that raw is the manifest of the digest reference,
+// if reference is a digest.
+func verifyRawManifest(raw []byte, reference string) error {
+	manifestDigest, err := digest.ParseDigest(reference)
+	if err != nil {
+		return nil
+	}
+	verifier, err := digest.NewDigestVerifier(manifestDigest)
+	if err != nil {
+		return err
+	}
+	if _, err := verifier.Write(raw); err != nil {
+		return err
+	}
+	if !verifier.Verified() {
+		return fmt.Errorf("image verification failed for digest %s", manifestDigest)
+	}
+	return nil
+}
+
+// selectManifest returns the entry of the manifest list for the platform os
+// and arch.
+func selectManifest(list *manifestList, os, arch string) (descriptor, error) {
+	for _, m := range list.Manifests {
+		if m.Platform.OS == os && m.Platform.Architecture == arch {
+			return m.descriptor, nil
+		}
+	}
+	return descriptor{}, fmt.Errorf("no matching manifest for %s/%s in the manifest list entries", os, arch)
+}
+
+// schema2ToSchema1 converts a schema2 manifest and its image configuration to
+// the equivalent schema1 manifest, whose images are then pulled the same way.
+// Each entry of the history gives an image, those without a layer using
+// emptyGzipLayer; their v1 IDs are derived from their layers and parents.
+// The digests of the uncompressed layers the configuration expects are
+// returned by the digests of their blobs, for the layers to be verified.
+func schema2ToSchema1(m *schema2Manifest, configJSON []byte) (*schema1.Manifest, map[digest.Digest]digest.Digest, error) {
+	var config imageConfig
+	if err := json.Unmarshal(configJSON, &config); err != nil {
+		return nil, nil, err
+	}
+	// The top image keeps the whole configuration, except what only
+	// belongs to schema2.
+	var topConfig map[string]*json.RawMessage
+	if err := json.Unmarshal(configJSON, &topConfig); err != nil {
+		return nil, nil, err
+	}
+
+	if config.RootFS == nil || len(config.RootFS.DiffIDs) != len(m.Layers) {
+		return nil, nil, errors.New("the diff IDs of the image configuration don't match the layers of the manifest")
+	}
+	diffIDs := make(map[digest.Digest]digest.Digest)
+	for i, l := range m.Layers {
+		diffID := config.RootFS.DiffIDs[i]
+		if err := diffID.Validate(); err != nil {
+			return nil, nil, err
+		}
+		if expected, ok := diffIDs[l.Digest]; ok && expected != diffID {
+			return nil, nil, fmt.Errorf("the image configuration gives several diff IDs to the layer %s", l.Digest)
+		}
+		diffIDs[l.Digest] = diffID
+	}
+	delete(topConfig, "rootfs")
+	delete(topConfig, "history")
+
+	histories := config.History
+	if len(histories) == 0 {
+		histories = make([]history, len(m.Layers))
+	}
+	var layers int
+	for _, h := range histories {
+		if !h.EmptyLayer {
+			layers++
+		}
+	}
+	if layers != len(m.Layers) {
+		return nil, nil, errors.New("the history of the image configuration doesn't match the layers of the manifest")
+	}
+	if len(histories) == 0 {
+		return nil, nil, errors.New("no layers in the manifest")
+	}
+
+	s1 := &schema1.Manifest{
+		Versioned:    manifest.Versioned{SchemaVersion: 1},
+		Architecture: config.Architecture,
+	}
+	var (
+		parent string
+		layer  int
+	)
+	for i, h := range histories {
+		blobSum := emptyGzipLayerDigest
+		if !h.EmptyLayer {
+			blobSum = m.Layers[layer].Digest
+			layer++
+		}
+		if err := blobSum.Validate(); err != nil {
+			return nil, nil, err
+		}
+
+		var (
+			v1ID string
+			v1   []byte
+			err  error
+		)
+		if i == len(histories)-1 {
+			v1ID, err = v1IDFromBytes(blobSum.Hex() + " " + parent + " " + string(configJSON))
+			if err != nil {
+				return nil, nil, err
+			}
+			topConfig["id"] = rawJSON(v1ID)
+			if parent != "" {
+				topConfig["parent"] = rawJSON(parent)
+			}
+			v1, err = json.Marshal(topConfig)
+		} else {
+			v1ID, err = v1IDFromBytes(blobSum.Hex() + " " + parent)
+			if err != nil {
+				return nil, nil, err
+			}
+			img := image.Image{
+				ID:      v1ID,
+				Parent:  parent,
+				Comment: h.Comment,
+				Created: h.Created,
+				Author:  h.Author,
+			}
+			if h.CreatedBy != "" {
+				img.ContainerConfig.Cmd = stringutils.NewStrSlice(h.CreatedBy)
+			}
+			v1, err = json.Marshal(&img)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		s1.FSLayers = append([]schema1.FSLayer{{BlobSum: blobSum}}, s1.FSLayers...)
+		s1.History = append([]schema1.History{{V1Compatibility: string(v1)}}, s1.History...)
+		parent = v1ID
+	}
+	return s1, diffIDs, nil
+}
+
+// makeImageConfig returns the configuration blob of a schema2 manifest for
+// the chain of images imgs, listed from the base one, and the digests of their
+// uncompressed layers.
+func makeImageConfig(imgs []*image.Image, diffIDs []digest.Digest) ([]byte, error) {
+	config := imageConfig{
+		Image:  *imgs[len(imgs)-1],
+		RootFS: &rootFS{Type: "layers", DiffIDs: diffIDs},
+	}
+	config.ID = ""
+	config.Parent = ""
+	config.Size = 0
+	config.ParentID = ""
+	config.LayerID = ""
+	for _, img := range imgs {
+		h := history{
+			Created: img.Created,
+			Author:  img.Author,
+			Comment: img.Comment,
+		}
+		if img.ContainerConfig.Cmd != nil {
+			h.CreatedBy = strings.Join(img.ContainerConfig.Cmd.Slice(), " ")
+		}
+		config.History = append(config.History, h)
+	}
+	return json.Marshal(&config)
+}
+
+func v1IDFromBytes(s string) (string, error) {
+	dgst, err := digest.FromBytes([]byte(s))
+	if err != nil {
+		return "", err
+	}
+	return dgst.Hex(), nil
+}
+
+func rawJSON(value string) *json.RawMessage {
+	b, _ := json.Marshal(value)
+	raw := json.RawMessage(b)
+	return &raw
+}
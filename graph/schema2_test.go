@@ -0,0 +1,193 @@
+package graph
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/stringutils"
+	"github.com/docker/docker/runconfig"
+)
+
+func TestEmptyGzipLayerDigest(t *testing.T) {
+	dgst, err := digest.FromBytes(emptyGzipLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dgst != emptyGzipLayerDigest {
+		t.Fatalf("Expected the empty layer to have the digest %s, got %s", emptyGzipLayerDigest, dgst)
+	}
+}
+
+func TestManifestMediaType(t *testing.T) {
+	cases := []struct {
+		contentType string
+		raw         string
+		expected    string
+	}{
+		{schema2MediaType, `{}`, schema2MediaType},
+		{manifestListMediaType + "; charset=utf-8", `{}`, manifestListMediaType},
+		{"application/json", `{"schemaVersion": 2, "mediaType": "` + schema2MediaType + `"}`, schema2MediaType},
+		{"application/json", `{"schemaVersion": 1, "name": "foo"}`, schema1SignedMediaType},
+		{"", `not json`, schema1SignedMediaType},
+	}
+	for _, c := range cases {
+		if mediaType := manifestMediaType(c.contentType, []byte(c.raw)); mediaType != c.expected {
+			t.Fatalf("Expected %q with %s to be %q, got %q", c.raw, c.contentType, c.expected, mediaType)
+		}
+	}
+}
+
+func TestVerifyRawManifest(t *testing.T) {
+	raw := []byte(`{"schemaVersion": 2}`)
+	dgst, err := digest.FromBytes(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyRawManifest(raw, dgst.String()); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyRawManifest(raw, "latest"); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyRawManifest([]byte(`{}`), dgst.String()); err == nil {
+		t.Fatal("Expected a manifest of another digest to fail the verification")
+	}
+}
+
+func TestSelectManifest(t *testing.T) {
+	list := &manifestList{
+		Manifests: []manifestDescriptor{
+			{descriptor{Digest: "sha256:1"}, platform{OS: "linux", Architecture: "arm"}},
+			{descriptor{Digest: "sha256:2"}, platform{OS: "linux", Architecture: "amd64"}},
+			{descriptor{Digest: "sha256:3"}, platform{OS: "windows", Architecture: "amd64"}},
+		},
+	}
+	desc, err := selectManifest(list, "linux", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Digest != "sha256:2" {
+		t.Fatalf("Expected the linux/amd64 manifest, got %s", desc.Digest)
+	}
+	if _, err := selectManifest(list, "linux", "ppc64le"); err == nil {
+		t.Fatal("Expected no manifest for linux/ppc64le")
+	}
+}
+
+func TestSchema2ToSchema1(t *testing.T) {
+	created := time.Unix(1000000000, 0).UTC()
+	base := &image.Image{ID: "base", Created: created, Architecture: "amd64", OS: "linux"}
+	env := &image.Image{ID: "env", Parent: "base", Created: created.Add(time.Hour), Author: "me"}
+	env.ContainerConfig.Cmd = stringutils.NewStrSlice("/bin/sh", "-c", "#(nop) ENV FOO=bar")
+	top := &image.Image{
+		ID:           "top",
+		Parent:       "env",
+		Created:      created.Add(2 * time.Hour),
+		Architecture: "amd64",
+		OS:           "linux",
+		Config:       &runconfig.Config{Env: []string{"FOO=bar"}},
+	}
+
+	diffIDs := []digest.Digest{"sha256:1111111111111111111111111111111111111111111111111111111111111111", "sha256:3333333333333333333333333333333333333333333333333333333333333333"}
+	configJSON, err := makeImageConfig([]*image.Image{base, env, top}, diffIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config imageConfig
+	if err := json.Unmarshal(configJSON, &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.ID != "" || config.Parent != "" || len(config.History) != 3 || config.History[1].CreatedBy != "/bin/sh -c #(nop) ENV FOO=bar" {
+		t.Fatalf("Unexpected image configuration %s", configJSON)
+	}
+
+	// The image setting the environment has no layer.
+	config.History[1].EmptyLayer = true
+	configJSON, err = json.Marshal(&config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &schema2Manifest{
+		Layers: []descriptor{
+			{Digest: "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"},
+			{Digest: "sha256:cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc"},
+		},
+	}
+	s1, layerDiffIDs, err := schema2ToSchema1(m, configJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, l := range m.Layers {
+		if layerDiffIDs[l.Digest] != diffIDs[i] {
+			t.Fatalf("Expected the layer %s to have the diff ID %s, got %s", l.Digest, diffIDs[i], layerDiffIDs[l.Digest])
+		}
+	}
+	if err := fixManifestLayers(s1); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []digest.Digest{m.Layers[1].Digest, emptyGzipLayerDigest, m.Layers[0].Digest}
+	if len(s1.FSLayers) != len(expected) || s1.Architecture != "amd64" {
+		t.Fatalf("Unexpected schema1 manifest %+v", s1)
+	}
+	for i, dgst := range expected {
+		if s1.FSLayers[i].BlobSum != dgst {
+			t.Fatalf("Expected layer %d to be %s, got %s", i, dgst, s1.FSLayers[i].BlobSum)
+		}
+	}
+
+	var v1 struct {
+		image.Image
+		RootFS *rootFS `json:"rootfs"`
+	}
+	if err := json.Unmarshal([]byte(s1.History[0].V1Compatibility), &v1); err != nil {
+		t.Fatal(err)
+	}
+	if v1.RootFS != nil || v1.Config == nil || len(v1.Config.Env) != 1 || v1.Parent == "" {
+		t.Fatalf("Expected the top image to keep its configuration, got %s", s1.History[0].V1Compatibility)
+	}
+	emptyLayer, err := image.NewImgJSON([]byte(s1.History[1].V1Compatibility))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if emptyLayer.Author != "me" || emptyLayer.ContainerConfig.Cmd.ToString() != "/bin/sh -c #(nop) ENV FOO=bar" {
+		t.Fatalf("Expected the image without layer to keep its history, got %s", s1.History[1].V1Compatibility)
+	}
+
+	m.Layers = m.Layers[:1]
+	if _, _, err := schema2ToSchema1(m, configJSON); err == nil {
+		t.Fatal("Expected a history not matching the layers to fail")
+	}
+}
+
+func TestSchema2ToSchema1DiffIDs(t *testing.T) {
+	m := &schema2Manifest{
+		Layers: []descriptor{
+			{Digest: "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"},
+			{Digest: "sha256:cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc"},
+		},
+	}
+	cases := []string{
+		// no diff IDs
+		`{"architecture": "amd64"}`,
+		// fewer diff IDs than layers
+		`{"rootfs": {"type": "layers", "diff_ids": ["sha256:1111111111111111111111111111111111111111111111111111111111111111"]}}`,
+		// invalid diff ID
+		`{"rootfs": {"type": "layers", "diff_ids": ["sha256:1111111111111111111111111111111111111111111111111111111111111111", "layer"]}}`,
+	}
+	for _, configJSON := range cases {
+		if _, _, err := schema2ToSchema1(m, []byte(configJSON)); err == nil {
+			t.Fatalf("Expected the configuration %s not to match the layers", configJSON)
+		}
+	}
+
+	// The same blob can't have different diff IDs.
+	m.Layers[1] = m.Layers[0]
+	configJSON := `{"rootfs": {"type": "layers", "diff_ids": ["sha256:1111111111111111111111111111111111111111111111111111111111111111", "sha256:3333333333333333333333333333333333333333333333333333333333333333"]}}`
+	if _, _, err := schema2ToSchema1(m, []byte(configJSON)); err == nil {
+		t.Fatal("Expected a blob with several diff IDs to fail")
+	}
+}
@@ -57,7 +57,11 @@ func (s *TagStore) Lookup(name string) (*types.ImageInspect, error) {
 
 	imageInspect.GraphDriver.Name = s.graph.driver.String()
 
-	graphDriverData, err := s.graph.driver.GetMetadata(image.ID)
+	layerID, err := s.graph.LayerStorageID(image.ID)
+	if err != nil {
+		return nil, err
+	}
+	graphDriverData, err := s.graph.driver.GetMetadata(layerID)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,155 @@
+package graph
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/docker/dockerversion"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/ioutils"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/docker/runconfig"
+)
+
+// Squash creates a new image with the filesystem and the configuration of the
+// image id, whose changes since its ancestor parent are held in a single
+// layer. parent may be empty to squash all the layers of the image.
+//
+// The history of the image is kept: each image between parent and id is
+// copied with an empty layer, and the new image holding the squashed layer
+// is the child of the last copy.
+//
+// If reproducible is set, the images are registered like CreateReproducible
+// does, and the squashed image is created at the creation time of image id.
+func (graph *Graph) Squash(id, parent string, reproducible bool) (*image.Image, error) {
+	img, err := graph.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if parent != "" {
+		p, err := graph.Get(parent)
+		if err != nil {
+			return nil, err
+		}
+		parent = p.ID
+	}
+
+	var history []*image.Image
+	for h := img; h.ID != parent; {
+		history = append(history, h)
+		if h.Parent == "" {
+			if parent != "" {
+				return nil, fmt.Errorf("image %s is not an ancestor of image %s", stringid.TruncateID(parent), stringid.TruncateID(id))
+			}
+			break
+		}
+		if h, err = graph.Get(h.Parent); err != nil {
+			return nil, err
+		}
+	}
+
+	top := parent
+	for i := len(history) - 1; i >= 0; i-- {
+		h := *history[i]
+		h.ID = ""
+		h.Parent = top
+		h.Size = 0
+		h.ParentID = ""
+		h.LayerID = ""
+		// an empty tar archive, so the copies have a layer which can be pushed
+		copied, err := graph.registerImage(&h, bytes.NewReader(emptyTar), reproducible)
+		if err != nil {
+			return nil, err
+		}
+		top = copied.ID
+	}
+
+	layerData, err := graph.diff(id, parent)
+	if err != nil {
+		return nil, err
+	}
+	defer layerData.Close()
+
+	squashed := &image.Image{
+		Parent:        top,
+		Comment:       fmt.Sprintf("merge %s to %s", id, parent),
+		Created:       time.Now().UTC(),
+		DockerVersion: dockerversion.Version,
+		Author:        img.Author,
+		Config:        img.Config,
+		Architecture:  img.Architecture,
+		OS:            img.OS,
+	}
+	if reproducible {
+		squashed.Created = img.Created
+	}
+	if squashed.Config == nil {
+		squashed.Config = &runconfig.Config{}
+	}
+	return graph.registerImage(squashed, layerData, reproducible)
+}
+
+// registerImage registers img with layerData, the way CreateReproducible does
+// if reproducible is set.
+func (graph *Graph) registerImage(img *image.Image, layerData io.Reader, reproducible bool) (*image.Image, error) {
+	if reproducible {
+		return graph.registerReproducible(img, layerData)
+	}
+	return graph.Register(v1Descriptor{img}, layerData)
+}
+
+// diff returns an archive of the changes in the filesystem of the image id
+// since its ancestor parent, which may be empty. The filesystems are released
+// when the archive is closed.
+func (graph *Graph) diff(id, parent string) (_ archive.Archive, err error) {
+	cacheID, err := graph.LayerStorageID(id)
+	if err != nil {
+		return nil, err
+	}
+	var parentCacheID string
+	if parent != "" {
+		if parentCacheID, err = graph.LayerStorageID(parent); err != nil {
+			return nil, err
+		}
+	}
+
+	fs, err := graph.driver.Get(cacheID, "")
+	if err != nil {
+		return nil, err
+	}
+	var parentFs string
+	if parent != "" {
+		if parentFs, err = graph.driver.Get(parentCacheID, ""); err != nil {
+			graph.driver.Put(cacheID)
+			return nil, err
+		}
+	}
+	release := func() {
+		graph.driver.Put(cacheID)
+		if parent != "" {
+			graph.driver.Put(parentCacheID)
+		}
+	}
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
+
+	changes, err := archive.ChangesDirs(fs, parentFs)
+	if err != nil {
+		return nil, err
+	}
+	arch, err := archive.ExportChanges(fs, changes, graph.uidMaps, graph.gidMaps)
+	if err != nil {
+		return nil, err
+	}
+	return ioutils.NewReadCloserWrapper(arch, func() error {
+		err := arch.Close()
+		release()
+		return err
+	}), nil
+}
@@ -0,0 +1,110 @@
+package graph
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/stringid"
+)
+
+func TestSquash(t *testing.T) {
+	graph, _ := tempGraph(t)
+	defer nukeGraph(graph)
+
+	archive, err := fakeTar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := registerTestImage(t, graph, "", "base", archive)
+	foo := registerTestImage(t, graph, base.ID, "add foo", singleFileTar(t, "/foo", "foo"))
+	bar := registerTestImage(t, graph, foo.ID, "add bar", singleFileTar(t, "/bar", "bar"))
+
+	squashed, err := graph.Squash(bar.ID, base.ID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the copies of the two steps and the squashed image
+	assertNImages(graph, t, 6)
+
+	var comments []string
+	for img := squashed; img.ID != base.ID; {
+		comments = append(comments, img.Comment)
+		if img, err = graph.Get(img.Parent); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(comments) != 3 || comments[1] != "add bar" || comments[2] != "add foo" {
+		t.Fatalf("Expected the history of the squashed image to keep the steps, got %q", comments)
+	}
+	if squashed.Size == 0 {
+		t.Fatal("Expected the squashed image to hold the changes")
+	}
+
+	layerID, err := graph.LayerStorageID(squashed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs, err := graph.driver.Get(layerID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer graph.driver.Put(layerID)
+	for _, name := range []string{"foo", "bar", "etc/passwd"} {
+		if _, err := os.Stat(filepath.Join(fs, name)); err != nil {
+			t.Fatalf("Expected %s in the squashed image: %v", name, err)
+		}
+	}
+}
+
+func TestSquashNotAncestor(t *testing.T) {
+	graph, _ := tempGraph(t)
+	defer nukeGraph(graph)
+
+	img := createTestImage(graph, t)
+	other := createTestImage(graph, t)
+	if _, err := graph.Squash(img.ID, other.ID, false); err == nil {
+		t.Fatal("Expected an error squashing onto an image which isn't an ancestor")
+	}
+}
+
+func registerTestImage(t *testing.T, graph *Graph, parent, comment string, layerData io.Reader) *image.Image {
+	img := &image.Image{
+		ID:      stringid.GenerateNonCryptoID(),
+		Parent:  parent,
+		Comment: comment,
+		Created: time.Now(),
+	}
+	img, err := graph.Register(v1Descriptor{img}, layerData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func singleFileTar(t *testing.T, name, content string) io.Reader {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	hdr := &tar.Header{
+		Name: name,
+		Mode: 0644,
+		Uid:  os.Getuid(),
+		Gid:  os.Getgid(),
+		Size: int64(len(content)),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(tw, content); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
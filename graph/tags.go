@@ -41,6 +41,9 @@ type TagStore struct {
 	// to a helper type
 	pullingPool     map[string]*broadcaster.Buffered
 	pushingPool     map[string]*broadcaster.Buffered
+	downloadManager *transferManager
+	uploadManager   *transferManager
+	blobSources     *blobSourceStore
 	registryService *registry.Service
 	eventsService   *events.Events
 }
@@ -59,6 +62,12 @@ type TagStoreConfig struct {
 	Registry *registry.Service
 	// Events is the events service to use for logging.
 	Events *events.Events
+	// MaxConcurrentDownloads is the number of layers pulls download at the
+	// same time. The default is used if it is not set.
+	MaxConcurrentDownloads int
+	// MaxConcurrentUploads is the number of layers pushes upload at the
+	// same time. The default is used if it is not set.
+	MaxConcurrentUploads int
 }
 
 // NewTagStore creates a new TagStore at specified path, using the parameters
@@ -79,6 +88,17 @@ func NewTagStore(path string, cfg *TagStoreConfig) (*TagStore, error) {
 		registryService: cfg.Registry,
 		eventsService:   cfg.Events,
 	}
+	maxDownloads := cfg.MaxConcurrentDownloads
+	if maxDownloads == 0 {
+		maxDownloads = DefaultMaxConcurrentDownloads
+	}
+	maxUploads := cfg.MaxConcurrentUploads
+	if maxUploads == 0 {
+		maxUploads = DefaultMaxConcurrentUploads
+	}
+	store.downloadManager = newTransferManager(store, "pull", maxDownloads)
+	store.uploadManager = newTransferManager(store, "push", maxUploads)
+	store.blobSources = newBlobSourceStore(filepath.Join(filepath.Dir(abspath), blobSourcesFileName))
 	// Load the json file if it exists, otherwise create it.
 	if err := store.reload(); os.IsNotExist(err) {
 		if err := store.save(); err != nil {
@@ -86,10 +106,30 @@ func NewTagStore(path string, cfg *TagStoreConfig) (*TagStore, error) {
 		}
 	} else if err != nil {
 		return nil, err
+	} else if store.migrateIDs() {
+		if err := store.save(); err != nil {
+			return nil, err
+		}
 	}
 	return store, nil
 }
 
+// migrateIDs updates the references to the images which were migrated to
+// the content addressable store with the graph, which are still known by
+// their old IDs. It returns whether any reference was updated.
+func (store *TagStore) migrateIDs() bool {
+	var updated bool
+	for _, repo := range store.Repositories {
+		for ref, id := range repo {
+			if img, err := store.graph.Get(id); err == nil && img.ID != id {
+				repo[ref] = img.ID
+				updated = true
+			}
+		}
+	}
+	return updated
+}
+
 func (store *TagStore) save() error {
 	// Store the json ball
 	jsonData, err := json.Marshal(store)
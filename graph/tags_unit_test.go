@@ -13,18 +13,17 @@ import (
 	_ "github.com/docker/docker/daemon/graphdriver/vfs" // import the vfs driver so it is used in the tests
 	"github.com/docker/docker/graph/tags"
 	"github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/stringid"
 	"github.com/docker/docker/utils"
 )
 
 const (
-	testOfficialImageName    = "myapp"
-	testOfficialImageID      = "1a2d3c4d4e5fa2d2a21acea242a5e2345d3aefc3e7dfa2a2a2a21a2a2ad2d234"
-	testOfficialImageIDShort = "1a2d3c4d4e5f"
-	testPrivateImageName     = "127.0.0.1:8000/privateapp"
-	testPrivateImageID       = "5bc255f8699e4ee89ac4469266c3d11515da88fdcbde45d7b069b636ff4efd81"
-	testPrivateImageIDShort  = "5bc255f8699e"
-	testPrivateImageDigest   = "sha256:bc8813ea7b3603864987522f02a76101c17ad122e1c46d790efc0fca78ca7bfb"
-	testPrivateImageTag      = "sometag"
+	testOfficialImageName  = "myapp"
+	testOfficialImageID    = "1a2d3c4d4e5fa2d2a21acea242a5e2345d3aefc3e7dfa2a2a2a21a2a2ad2d234"
+	testPrivateImageName   = "127.0.0.1:8000/privateapp"
+	testPrivateImageID     = "5bc255f8699e4ee89ac4469266c3d11515da88fdcbde45d7b069b636ff4efd81"
+	testPrivateImageDigest = "sha256:bc8813ea7b3603864987522f02a76101c17ad122e1c46d790efc0fca78ca7bfb"
+	testPrivateImageTag    = "sometag"
 )
 
 func fakeTar() (io.Reader, error) {
@@ -74,8 +73,8 @@ func mkTestTagStore(root string, t *testing.T) *TagStore {
 	if err != nil {
 		t.Fatal(err)
 	}
-	img := &image.Image{ID: testOfficialImageID}
-	if err := graph.Register(v1Descriptor{img}, officialArchive); err != nil {
+	img := &image.Image{ID: testOfficialImageID, Comment: "official"}
+	if _, err := graph.Register(v1Descriptor{img}, officialArchive); err != nil {
 		t.Fatal(err)
 	}
 	if err := store.Tag(testOfficialImageName, "", testOfficialImageID, false); err != nil {
@@ -85,8 +84,8 @@ func mkTestTagStore(root string, t *testing.T) *TagStore {
 	if err != nil {
 		t.Fatal(err)
 	}
-	img = &image.Image{ID: testPrivateImageID}
-	if err := graph.Register(v1Descriptor{img}, privateArchive); err != nil {
+	img = &image.Image{ID: testPrivateImageID, Comment: "private"}
+	if _, err := graph.Register(v1Descriptor{img}, privateArchive); err != nil {
 		t.Fatal(err)
 	}
 	if err := store.Tag(testPrivateImageName, "", testPrivateImageID, false); err != nil {
@@ -107,11 +106,22 @@ func TestLookupImage(t *testing.T) {
 	store := mkTestTagStore(tmp, t)
 	defer store.graph.driver.Cleanup()
 
+	// the images are registered with their v1 IDs
+	official, err := store.graph.Get(testOfficialImageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := store.graph.Get(testPrivateImageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	officialLookups := []string{
 		testOfficialImageID,
-		testOfficialImageIDShort,
-		testOfficialImageName + ":" + testOfficialImageID,
-		testOfficialImageName + ":" + testOfficialImageIDShort,
+		official.ID,
+		stringid.TruncateID(official.ID),
+		testOfficialImageName + ":" + official.ID,
+		testOfficialImageName + ":" + stringid.TruncateID(official.ID),
 		testOfficialImageName,
 		testOfficialImageName + ":" + tags.DefaultTag,
 		"docker.io/" + testOfficialImageName,
@@ -128,9 +138,10 @@ func TestLookupImage(t *testing.T) {
 
 	privateLookups := []string{
 		testPrivateImageID,
-		testPrivateImageIDShort,
-		testPrivateImageName + ":" + testPrivateImageID,
-		testPrivateImageName + ":" + testPrivateImageIDShort,
+		private.ID,
+		stringid.TruncateID(private.ID),
+		testPrivateImageName + ":" + private.ID,
+		testPrivateImageName + ":" + stringid.TruncateID(private.ID),
 		testPrivateImageName,
 		testPrivateImageName + ":" + tags.DefaultTag,
 	}
@@ -149,8 +160,8 @@ func TestLookupImage(t *testing.T) {
 			t.Errorf("Error looking up %s: %s", name, err)
 		} else if img == nil {
 			t.Errorf("Expected 1 image, none found: %s", name)
-		} else if img.ID != testOfficialImageID {
-			t.Errorf("Expected ID '%s' found '%s'", testOfficialImageID, img.ID)
+		} else if img.ID != official.ID {
+			t.Errorf("Expected ID '%s' found '%s'", official.ID, img.ID)
 		}
 	}
 
@@ -159,8 +170,8 @@ func TestLookupImage(t *testing.T) {
 			t.Errorf("Error looking up %s: %s", name, err)
 		} else if img == nil {
 			t.Errorf("Expected 1 image, none found: %s", name)
-		} else if img.ID != testPrivateImageID {
-			t.Errorf("Expected ID '%s' found '%s'", testPrivateImageID, img.ID)
+		} else if img.ID != private.ID {
+			t.Errorf("Expected ID '%s' found '%s'", private.ID, img.ID)
 		}
 	}
 
@@ -177,8 +188,8 @@ func TestLookupImage(t *testing.T) {
 			t.Errorf("Error looking up %s: %s", name, err)
 		} else if img == nil {
 			t.Errorf("Expected 1 image, none found: %s", name)
-		} else if img.ID != testPrivateImageID {
-			t.Errorf("Expected ID '%s' found '%s'", testPrivateImageID, img.ID)
+		} else if img.ID != private.ID {
+			t.Errorf("Expected ID '%s' found '%s'", private.ID, img.ID)
 		}
 	}
 }
@@ -0,0 +1,154 @@
+package graph
+
+import (
+	"io"
+	"sync"
+
+	"github.com/docker/docker/pkg/broadcaster"
+)
+
+const (
+	// DefaultMaxConcurrentDownloads is the number of layers downloaded at
+	// the same time when the daemon doesn't set it.
+	DefaultMaxConcurrentDownloads = 3
+	// DefaultMaxConcurrentUploads is the number of layers uploaded at the
+	// same time when the daemon doesn't set it.
+	DefaultMaxConcurrentUploads = 5
+)
+
+// transferFunc transfers a blob, writing its progress to progress. It
+// returns the result of the transfer, and a function releasing the
+// resources held by the result once nobody uses it anymore, which may be
+// nil.
+type transferFunc func(progress io.Writer) (result interface{}, cleanup func(), err error)
+
+// transferManager runs the transfers of blobs of pulls or pushes, at most
+// a given number at the same time. The operations which transfer the same
+// blob at the same time share a single transfer, and watch its progress
+// through a broadcaster of the pool of the tag store.
+type transferManager struct {
+	store *TagStore
+	// kind is the pool of the progress broadcasters, "pull" or "push"
+	kind  string
+	slots chan struct{}
+
+	mu        sync.Mutex
+	transfers map[string]*transfer
+}
+
+// transfer is a transfer of a blob run by a transferManager, shared by the
+// operations watching it.
+type transfer struct {
+	tm       *transferManager
+	key      string
+	progress *broadcaster.Buffered
+	done     chan struct{}
+	result   interface{}
+	err      error
+	cleanup  func()
+	// watchers is the number of operations which haven't released the
+	// transfer yet
+	watchers int
+}
+
+// newTransferManager returns a transfer manager running at most limit
+// transfers at the same time, whose progress broadcasters are in the kind
+// pool of store.
+func newTransferManager(store *TagStore, kind string, limit int) *transferManager {
+	if limit < 1 {
+		limit = 1
+	}
+	return &transferManager{
+		store:     store,
+		kind:      kind,
+		slots:     make(chan struct{}, limit),
+		transfers: make(map[string]*transfer),
+	}
+}
+
+// transfer starts the transfer of the blob identified by key with do, or
+// joins the transfer of the blob which is already running or finished but
+// not released, and writes its progress to out. The transfer must be
+// released once its result is not used anymore.
+func (tm *transferManager) transfer(key string, out io.Writer, do transferFunc) *transfer {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if t, ok := tm.transfers[key]; ok {
+		t.watchers++
+		// This fails once the transfer is finished, which the watcher
+		// learns when waiting for it.
+		t.progress.Add(out)
+		return t
+	}
+
+	broadcaster, _ := tm.store.poolAdd(tm.kind, tm.poolKey(key))
+	broadcaster.Add(out)
+	t := &transfer{
+		tm:       tm,
+		key:      key,
+		progress: broadcaster,
+		done:     make(chan struct{}),
+		watchers: 1,
+	}
+	tm.transfers[key] = t
+	go tm.run(t, do)
+	return t
+}
+
+func (tm *transferManager) poolKey(key string) string {
+	return "transfer:" + key
+}
+
+func (tm *transferManager) run(t *transfer, do transferFunc) {
+	tm.slots <- struct{}{}
+	result, cleanup, err := do(t.progress)
+	<-tm.slots
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	t.result, t.cleanup, t.err = result, cleanup, err
+	tm.store.poolRemoveWithError(tm.kind, tm.poolKey(t.key), err)
+	close(t.done)
+	// A failed transfer is not shared with the next operations, which
+	// try again.
+	if err != nil || t.watchers == 0 {
+		tm.remove(t)
+	}
+}
+
+// remove forgets the finished transfer t, and releases its result if no
+// operation uses it anymore. It must be called with tm.mu held.
+func (tm *transferManager) remove(t *transfer) {
+	if tm.transfers[t.key] == t {
+		delete(tm.transfers, t.key)
+	}
+	if t.watchers == 0 && t.cleanup != nil {
+		t.cleanup()
+		t.cleanup = nil
+	}
+}
+
+// wait blocks until the transfer is finished, and returns its result.
+func (t *transfer) wait() (interface{}, error) {
+	<-t.done
+	return t.result, t.err
+}
+
+// release tells the transfer manager the result of the transfer isn't used
+// by the operation anymore.
+func (t *transfer) release() {
+	tm := t.tm
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	t.watchers--
+	if t.watchers > 0 {
+		return
+	}
+	select {
+	case <-t.done:
+		tm.remove(t)
+	default:
+		// The transfer removes itself when it finishes.
+	}
+}
@@ -0,0 +1,146 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/pkg/broadcaster"
+)
+
+func newTestTransferManager(limit int) *transferManager {
+	store := &TagStore{
+		pullingPool: make(map[string]*broadcaster.Buffered),
+		pushingPool: make(map[string]*broadcaster.Buffered),
+	}
+	return newTransferManager(store, "pull", limit)
+}
+
+func TestTransferLimit(t *testing.T) {
+	tm := newTestTransferManager(2)
+
+	var (
+		mu            sync.Mutex
+		running, peak int
+		proceed       = make(chan struct{})
+	)
+	do := func(progress io.Writer) (interface{}, func(), error) {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+		<-proceed
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil, nil, nil
+	}
+
+	var transfers []*transfer
+	for i := 0; i < 5; i++ {
+		transfers = append(transfers, tm.transfer(fmt.Sprintf("blob%d", i), ioutil.Discard, do))
+	}
+	// Give the transfers above the limit a chance to start.
+	time.Sleep(100 * time.Millisecond)
+	close(proceed)
+	for _, tr := range transfers {
+		if _, err := tr.wait(); err != nil {
+			t.Fatal(err)
+		}
+		tr.release()
+	}
+	if peak != 2 {
+		t.Fatalf("Expected 2 transfers at the same time, got %d", peak)
+	}
+}
+
+func TestTransferShared(t *testing.T) {
+	tm := newTestTransferManager(1)
+
+	var (
+		calls, cleanups int
+		proceed         = make(chan struct{})
+	)
+	do := func(progress io.Writer) (interface{}, func(), error) {
+		calls++
+		<-proceed
+		progress.Write([]byte("done"))
+		return "result", func() { cleanups++ }, nil
+	}
+
+	first := tm.transfer("blob", ioutil.Discard, do)
+	second := tm.transfer("blob", ioutil.Discard, do)
+	if first != second {
+		t.Fatal("Expected the transfers of the same blob to be shared")
+	}
+	close(proceed)
+
+	for _, tr := range []*transfer{first, second} {
+		result, err := tr.wait()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != "result" {
+			t.Fatalf("Expected the result of the transfer, got %v", result)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("Expected the blob to be transferred once, got %d", calls)
+	}
+
+	first.release()
+	if cleanups != 0 {
+		t.Fatal("Expected the result to be kept while the transfer is used")
+	}
+	// The finished transfer is still shared until it is released.
+	third := tm.transfer("blob", ioutil.Discard, do)
+	if third != first {
+		t.Fatal("Expected the finished transfer to be shared")
+	}
+	second.release()
+	third.release()
+	if cleanups != 1 {
+		t.Fatalf("Expected the result to be released once, got %d", cleanups)
+	}
+
+	// Once released, the blob is transferred again.
+	tr := tm.transfer("blob", ioutil.Discard, do)
+	if _, err := tr.wait(); err != nil {
+		t.Fatal(err)
+	}
+	tr.release()
+	if calls != 2 {
+		t.Fatalf("Expected the blob to be transferred again, got %d transfers", calls)
+	}
+}
+
+func TestTransferFailed(t *testing.T) {
+	tm := newTestTransferManager(1)
+
+	failure := errors.New("transfer failed")
+	tr := tm.transfer("blob", ioutil.Discard, func(progress io.Writer) (interface{}, func(), error) {
+		return nil, nil, failure
+	})
+	if _, err := tr.wait(); err != failure {
+		t.Fatalf("Expected the error of the transfer, got %v", err)
+	}
+
+	// A failed transfer isn't shared, even before it is released.
+	retry := tm.transfer("blob", ioutil.Discard, func(progress io.Writer) (interface{}, func(), error) {
+		return "result", nil, nil
+	})
+	if retry == tr {
+		t.Fatal("Expected the failed transfer not to be shared")
+	}
+	if result, err := retry.wait(); err != nil || result != "result" {
+		t.Fatalf("Expected the transfer to be retried, got %v, %v", result, err)
+	}
+	tr.release()
+	retry.release()
+}
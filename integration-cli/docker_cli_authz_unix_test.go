@@ -0,0 +1,183 @@
+// +build !windows
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+
+	"github.com/docker/docker/pkg/authorization"
+	"github.com/docker/docker/pkg/integration/checker"
+	"github.com/docker/docker/pkg/plugins"
+	"github.com/go-check/check"
+)
+
+const testAuthZPlugin = "authzplugin"
+const unauthorizedMessage = "User unauthorized authz plugin"
+const containerListAPI = "/containers/json"
+
+func init() {
+	check.Suite(&DockerAuthzSuite{
+		ds: &DockerSuite{},
+	})
+}
+
+type DockerAuthzSuite struct {
+	server *httptest.Server
+	ds     *DockerSuite
+	d      *Daemon
+	ctrl   *authorizationController
+}
+
+type authorizationController struct {
+	reqRes        authorization.Response // reqRes holds the plugin response to the initial client request
+	resRes        authorization.Response // resRes holds the plugin response to the daemon response
+	psRequestCnt  int                    // psRequestCnt counts the number of calls to list container request api
+	psResponseCnt int                    // psResponseCnt counts the number of calls to list containers response API
+}
+
+func (s *DockerAuthzSuite) SetUpTest(c *check.C) {
+	s.d = NewDaemon(c)
+	s.ctrl = &authorizationController{}
+}
+
+func (s *DockerAuthzSuite) TearDownTest(c *check.C) {
+	s.d.Stop()
+	s.ds.TearDownTest(c)
+	s.ctrl = nil
+}
+
+func (s *DockerAuthzSuite) SetUpSuite(c *check.C) {
+	mux := http.NewServeMux()
+	s.server = httptest.NewServer(mux)
+
+	send := func(w http.ResponseWriter, data interface{}) {
+		b, err := json.Marshal(data)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.docker.plugins.v1+json")
+		w.Write(b)
+	}
+
+	read := func(r *http.Request) (authorization.Request, error) {
+		defer r.Body.Close()
+		var authReq authorization.Request
+		err := json.NewDecoder(r.Body).Decode(&authReq)
+		return authReq, err
+	}
+
+	mux.HandleFunc("/Plugin.Activate", func(w http.ResponseWriter, r *http.Request) {
+		send(w, plugins.Manifest{Implements: []string{authorization.AuthZAPIImplements}})
+	})
+
+	mux.HandleFunc(fmt.Sprintf("/%s", authorization.AuthZAPIRequest), func(w http.ResponseWriter, r *http.Request) {
+		authReq, err := read(r)
+		if err != nil {
+			send(w, authorization.Response{Err: err.Error()})
+			return
+		}
+		if strings.HasSuffix(authReq.RequestURI, containerListAPI) && authReq.RequestMethod == "GET" {
+			s.ctrl.psRequestCnt++
+		}
+		send(w, s.ctrl.reqRes)
+	})
+
+	mux.HandleFunc(fmt.Sprintf("/%s", authorization.AuthZAPIResponse), func(w http.ResponseWriter, r *http.Request) {
+		authReq, err := read(r)
+		if err != nil {
+			send(w, authorization.Response{Err: err.Error()})
+			return
+		}
+		if strings.HasSuffix(authReq.RequestURI, containerListAPI) && authReq.RequestMethod == "GET" {
+			s.ctrl.psResponseCnt++
+		}
+		send(w, s.ctrl.resRes)
+	})
+
+	err := os.MkdirAll("/etc/docker/plugins", 0755)
+	c.Assert(err, checker.IsNil)
+
+	fileName := fmt.Sprintf("/etc/docker/plugins/%s.spec", testAuthZPlugin)
+	err = ioutil.WriteFile(fileName, []byte(s.server.URL), 0644)
+	c.Assert(err, checker.IsNil)
+}
+
+func (s *DockerAuthzSuite) TearDownSuite(c *check.C) {
+	if s.server == nil {
+		return
+	}
+
+	s.server.Close()
+
+	err := os.RemoveAll("/etc/docker/plugins")
+	c.Assert(err, checker.IsNil)
+}
+
+func (s *DockerAuthzSuite) TestAuthZPluginAllowRequest(c *check.C) {
+	err := s.d.Start("--authorization-plugin=" + testAuthZPlugin)
+	c.Assert(err, checker.IsNil)
+	s.ctrl.reqRes.Allow = true
+	s.ctrl.resRes.Allow = true
+
+	// Ensure command successful
+	out, err := s.d.Cmd("run", "-d", "--name", "container1", "busybox:latest", "top")
+	c.Assert(err, checker.IsNil, check.Commentf(out))
+
+	out, err = s.d.Cmd("ps")
+	c.Assert(err, checker.IsNil, check.Commentf(out))
+	c.Assert(out, checker.Contains, "container1")
+	c.Assert(s.ctrl.psRequestCnt, checker.Equals, 1)
+	c.Assert(s.ctrl.psResponseCnt, checker.Equals, 1)
+}
+
+func (s *DockerAuthzSuite) TestAuthZPluginDenyRequest(c *check.C) {
+	err := s.d.Start("--authorization-plugin=" + testAuthZPlugin)
+	c.Assert(err, checker.IsNil)
+	s.ctrl.reqRes.Allow = false
+	s.ctrl.reqRes.Msg = unauthorizedMessage
+
+	// Ensure command is blocked
+	res, err := s.d.Cmd("ps")
+	c.Assert(err, checker.NotNil)
+	c.Assert(s.ctrl.psRequestCnt, checker.Equals, 1)
+	c.Assert(s.ctrl.psResponseCnt, checker.Equals, 0)
+
+	// Ensure unauthorized message appears in response
+	c.Assert(res, checker.Contains, fmt.Sprintf("authorization denied by plugin %s: %s", testAuthZPlugin, unauthorizedMessage))
+}
+
+func (s *DockerAuthzSuite) TestAuthZPluginDenyResponse(c *check.C) {
+	err := s.d.Start("--authorization-plugin=" + testAuthZPlugin)
+	c.Assert(err, checker.IsNil)
+	s.ctrl.reqRes.Allow = true
+	s.ctrl.resRes.Allow = false
+	s.ctrl.resRes.Msg = unauthorizedMessage
+
+	// Ensure command is blocked
+	res, err := s.d.Cmd("ps")
+	c.Assert(err, checker.NotNil)
+	c.Assert(s.ctrl.psRequestCnt, checker.Equals, 1)
+	c.Assert(s.ctrl.psResponseCnt, checker.Equals, 1)
+
+	// Ensure unauthorized message appears in response
+	c.Assert(res, checker.Contains, fmt.Sprintf("authorization denied by plugin %s: %s", testAuthZPlugin, unauthorizedMessage))
+}
+
+func (s *DockerAuthzSuite) TestAuthZPluginErrorResponse(c *check.C) {
+	err := s.d.Start("--authorization-plugin=" + testAuthZPlugin)
+	c.Assert(err, checker.IsNil)
+	s.ctrl.reqRes.Allow = true
+	s.ctrl.resRes.Err = "an error occurred"
+
+	// Ensure command is blocked
+	res, err := s.d.Cmd("ps")
+	c.Assert(err, checker.NotNil)
+	c.Assert(res, checker.Contains, fmt.Sprintf("plugin %s failed with error: an error occurred", testAuthZPlugin))
+}
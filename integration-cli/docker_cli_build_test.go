@@ -6,6 +6,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -22,7 +23,9 @@ import (
 	"github.com/docker/docker/builder/dockerfile/command"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/integration/checker"
+	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/docker/docker/pkg/stringutils"
+	"github.com/docker/docker/runconfig"
 	"github.com/go-check/check"
 )
 
@@ -6552,3 +6555,384 @@ func (s *DockerSuite) TestBuildCacheRootSource(c *check.C) {
 
 	c.Assert(out, checker.Not(checker.Contains), "Using cache")
 }
+
+func (s *DockerSuite) TestBuildMultiStage(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+	name := "testbuildmultistage"
+	ctx, err := fakeContext(`
+	FROM busybox AS build
+	COPY foo /src/foo
+	RUN cp /src/foo /src/bar
+	FROM scratch
+	COPY --from=build /src/bar /bar
+	COPY --from=0 /src/foo /foo
+	COPY --from=busybox /bin/busybox /bin/busybox`,
+		map[string]string{
+			"foo": "hello",
+		})
+	c.Assert(err, checker.IsNil)
+	defer ctx.Close()
+
+	id, err := buildImageFromContext(name, ctx, true)
+	c.Assert(err, checker.IsNil)
+
+	out, _ := dockerCmd(c, "run", "--rm", id, "/bin/busybox", "cat", "/bar", "/foo")
+	c.Assert(strings.TrimSpace(out), checker.Equals, "hellohello")
+
+	// the copies from the earlier stage come from the cache
+	_, out, err = buildImageFromContextWithOut(name, ctx, true)
+	c.Assert(err, checker.IsNil)
+	c.Assert(strings.Count(out, "Using cache"), checker.Equals, 5)
+}
+
+func (s *DockerSuite) TestBuildMultiStageTarget(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+	name := "testbuildmultistagetarget"
+	dockerfile := `
+	FROM busybox AS base
+	RUN echo base > /stage
+	FROM base AS Final
+	RUN echo final > /stage
+	FROM busybox
+	ARG unused`
+
+	_, err := buildImage(name, dockerfile, true, "--target", "base", "--build-arg", "unused=1")
+	c.Assert(err, checker.IsNil)
+	out, _ := dockerCmd(c, "run", "--rm", name, "cat", "/stage")
+	c.Assert(strings.TrimSpace(out), checker.Equals, "base")
+
+	_, err = buildImage(name, dockerfile, true, "--target", "final")
+	c.Assert(err, checker.IsNil)
+	out, _ = dockerCmd(c, "run", "--rm", name, "cat", "/stage")
+	c.Assert(strings.TrimSpace(out), checker.Equals, "final")
+
+	_, out, err = buildImageWithOut(name, dockerfile, true, "--target", "missing")
+	c.Assert(err, checker.NotNil)
+	c.Assert(out, checker.Contains, "failed to reach build target missing")
+}
+
+func (s *DockerSuite) TestBuildMultiStageDuplicateName(c *check.C) {
+	name := "testbuildmultistageduplicate"
+	_, out, err := buildImageWithOut(name, `
+	FROM busybox AS base
+	FROM busybox AS BASE`, true)
+	c.Assert(err, checker.NotNil)
+	c.Assert(out, checker.Contains, "duplicate name base for build stages")
+}
+
+func (s *DockerSuite) TestBuildSquash(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+	name := "testbuildsquash"
+	_, err := buildImage(name, `
+	FROM busybox
+	RUN dd if=/dev/zero of=/file bs=1024 count=1024
+	RUN rm /file
+	RUN echo hello > /hello`, true, "--squash")
+	c.Assert(err, checker.IsNil)
+
+	out, _ := dockerCmd(c, "run", "--rm", name, "sh", "-c", "cat /hello; ls /file 2>&1 || true")
+	c.Assert(out, checker.Contains, "hello")
+	c.Assert(out, checker.Contains, "No such file or directory")
+
+	// one new layer on top of busybox, and the steps of the build are kept
+	out, _ = dockerCmd(c, "history", "--no-trunc", name)
+	c.Assert(out, checker.Contains, "merge ")
+	c.Assert(out, checker.Contains, "dd if=/dev/zero")
+
+	// the size of the squashed layer
+	out, err = inspectField(name, "Size")
+	c.Assert(err, checker.IsNil)
+	size, err := strconv.Atoi(out)
+	c.Assert(err, checker.IsNil)
+	c.Assert(size < 1024*1024, checker.True, check.Commentf("squashed layer still holds the removed file: %d bytes", size))
+}
+
+func (s *DockerRegistrySuite) TestBuildCacheFrom(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+	repoName := fmt.Sprintf("%v/dockercli/cachefrom", privateRegistryURL)
+	dockerfile := `
+	FROM busybox
+	ENV FOO=bar
+	ADD foo /foo
+	RUN touch /bar`
+	ctx, err := fakeContext(dockerfile, map[string]string{
+		"foo": "foo",
+	})
+	c.Assert(err, checker.IsNil)
+	defer ctx.Close()
+
+	_, err = buildImageFromContext(repoName, ctx, true)
+	c.Assert(err, checker.IsNil)
+	dockerCmd(c, "push", repoName)
+
+	// remove the image and the images of its build, and pull it back
+	deleteImages(repoName)
+	dockerCmd(c, "pull", repoName)
+
+	id2, out, err := buildImageFromContextWithOut("testbuildcachefrom", ctx, true, "--cache-from", repoName)
+	c.Assert(err, checker.IsNil)
+	c.Assert(strings.Count(out, "Using cache"), checker.Equals, 3)
+	pulledID, err := inspectField(repoName, "Id")
+	c.Assert(err, checker.IsNil)
+	c.Assert(id2, checker.Equals, pulledID)
+
+	// a missing cache source is skipped, and a changed file misses the cache
+	c.Assert(ioutil.WriteFile(filepath.Join(ctx.Dir, "foo"), []byte("changed"), 0644), checker.IsNil)
+	_, out, err = buildImageFromContextWithOut("testbuildcachefrom", ctx, true, "--cache-from", "missing,"+repoName)
+	c.Assert(err, checker.IsNil)
+	c.Assert(strings.Count(out, "Using cache"), checker.Equals, 1)
+}
+
+func (s *DockerSuite) TestBuildShell(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+	name := "testbuildshell"
+	_, err := buildImage(name, `
+	FROM busybox
+	SHELL ["/bin/sh", "-x", "-c"]
+	RUN echo $0 $- > /shell
+	CMD echo cmd
+	ENTRYPOINT echo entrypoint`, true)
+	c.Assert(err, checker.IsNil)
+
+	out, _ := dockerCmd(c, "run", "--rm", "--entrypoint", "cat", name, "/shell")
+	c.Assert(strings.TrimSpace(out), checker.Contains, "x")
+
+	res, err := inspectFieldJSON(name, "Config.Shell")
+	c.Assert(err, checker.IsNil)
+	c.Assert(res, checker.Equals, `["/bin/sh","-x","-c"]`)
+	res, err = inspectFieldJSON(name, "Config.Cmd")
+	c.Assert(err, checker.IsNil)
+	c.Assert(res, checker.Equals, `["/bin/sh","-x","-c","echo cmd"]`)
+	res, err = inspectFieldJSON(name, "Config.Entrypoint")
+	c.Assert(err, checker.IsNil)
+	c.Assert(res, checker.Equals, `["/bin/sh","-x","-c","echo entrypoint"]`)
+
+	// the shell is inherited by the child images and their ONBUILD triggers
+	_, err = buildImage("testbuildshellonbuild", fmt.Sprintf(`
+	FROM %s
+	ONBUILD RUN echo onbuild`, name), true)
+	c.Assert(err, checker.IsNil)
+	_, err = buildImage("testbuildshellchild", `
+	FROM testbuildshellonbuild
+	CMD echo child`, true)
+	c.Assert(err, checker.IsNil)
+	res, err = inspectFieldJSON("testbuildshellchild", "Config.Cmd")
+	c.Assert(err, checker.IsNil)
+	c.Assert(res, checker.Equals, `["/bin/sh","-x","-c","echo child"]`)
+}
+
+func (s *DockerSuite) TestBuildShellNotJSON(c *check.C) {
+	_, out, err := buildImageWithOut("testbuildshellnotjson", `
+	FROM busybox
+	SHELL /bin/sh -c`, true)
+	c.Assert(err, checker.NotNil)
+	c.Assert(out, checker.Contains, "SHELL requires the arguments to be in JSON form")
+}
+
+func (s *DockerSuite) TestBuildNetworkMode(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+	_, err := buildImage("testbuildnetworknone", `
+	FROM busybox
+	RUN [ "$(ls /sys/class/net)" = "lo" ]`, false, "--network", "none")
+	c.Assert(err, checker.IsNil)
+
+	_, err = buildImage("testbuildaddhost", `
+	FROM busybox
+	RUN grep "1.2.3.4\sextra" /etc/hosts`, false, "--add-host", "extra:1.2.3.4")
+	c.Assert(err, checker.IsNil)
+
+	// the options are validated as with docker run
+	_, out, err := buildImageWithOut("testbuildnetworkconflict", `
+	FROM busybox
+	RUN true`, false, "--network", "host", "--add-host", "extra:1.2.3.4")
+	c.Assert(err, checker.NotNil)
+	c.Assert(out, checker.Contains, runconfig.ErrConflictNetworkHosts.Error())
+}
+
+func (s *DockerSuite) TestBuildSecret(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+	secretFile, err := ioutil.TempFile("", "build-secret")
+	c.Assert(err, checker.IsNil)
+	defer os.Remove(secretFile.Name())
+	_, err = secretFile.WriteString("s3cr3t")
+	c.Assert(err, checker.IsNil)
+	secretFile.Close()
+
+	name := "testbuildsecret"
+	_, err = buildImage(name, `
+	FROM busybox
+	RUN --secret=token [ "$(cat /run/secrets/token)" = "s3cr3t" ]
+	RUN [ ! -e /run/secrets ]`, true, "--secret", "id=token,src="+secretFile.Name())
+	c.Assert(err, checker.IsNil)
+
+	// neither the secret nor its mountpoint are committed
+	dockerCmd(c, "run", "--rm", name, "[", "!", "-e", "/run/secrets", "]")
+	out, _ := dockerCmd(c, "history", "--no-trunc", name)
+	c.Assert(out, checker.Not(checker.Contains), "s3cr3t")
+
+	_, out, err = buildImageWithOut("testbuildsecretunknown", `
+	FROM busybox
+	RUN --secret=other true`, true, "--secret", "id=token,src="+secretFile.Name())
+	c.Assert(err, checker.NotNil)
+	c.Assert(out, checker.Contains, "secret other was not passed to the build")
+}
+
+func (s *DockerSuite) TestBuildProgressJSON(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+	name := "testbuildprogressjson"
+	dockerfile := "FROM busybox\nRUN echo hello"
+	_, err := buildImage(name, dockerfile, true)
+	c.Assert(err, checker.IsNil)
+
+	id, stdout, _, err := buildImageWithStdoutStderr(name, dockerfile, true, "--progress=json")
+	c.Assert(err, checker.IsNil)
+	events := readBuildEvents(c, stdout)
+	c.Assert(events, checker.HasLen, 4)
+	c.Assert(events[2].Type, checker.Equals, jsonmessage.BuildStepStart)
+	c.Assert(events[2].Instruction, checker.Equals, "RUN echo hello")
+	last := events[3]
+	c.Assert(last.Type, checker.Equals, jsonmessage.BuildStepFinish)
+	c.Assert(last.Step, checker.Equals, 2)
+	c.Assert(last.Line, checker.Equals, 2)
+	c.Assert(last.Cache, checker.Equals, "hit")
+	c.Assert(last.ImageID, checker.Equals, id)
+
+	_, stdout, _, err = buildImageWithStdoutStderr("testbuildprogressjsonerror", "FROM busybox\n\nRUN false", true, "--progress=json")
+	c.Assert(err, checker.NotNil)
+	events = readBuildEvents(c, stdout)
+	last = events[len(events)-1]
+	c.Assert(last.Type, checker.Equals, jsonmessage.BuildStepError)
+	c.Assert(last.Line, checker.Equals, 3)
+	c.Assert(last.ContainerID, checker.Not(checker.Equals), "")
+	c.Assert(last.Error, checker.NotNil)
+}
+
+// readBuildEvents returns the build events of the JSON progress stream out.
+func readBuildEvents(c *check.C, out string) []*jsonmessage.JSONBuildEvent {
+	var events []*jsonmessage.JSONBuildEvent
+	dec := json.NewDecoder(strings.NewReader(out))
+	for {
+		var jm jsonmessage.JSONMessage
+		if err := dec.Decode(&jm); err == io.EOF {
+			return events
+		} else if err != nil {
+			c.Fatalf("Error decoding the build progress %q: %v", out, err)
+		}
+		if jm.BuildEvent != nil {
+			events = append(events, jm.BuildEvent)
+		}
+	}
+}
+
+func (s *DockerSuite) TestBuildCheck(c *check.C) {
+	name := "testbuildcheck"
+	out, exitCode, err := runCommandWithOutput(buildImageCmd(name, "FROM busybox\nRUN echo hello\n", true, "--check"))
+	c.Assert(err, checker.IsNil, check.Commentf(out))
+	c.Assert(exitCode, checker.Equals, 0)
+	c.Assert(strings.TrimSpace(out), checker.Equals, "")
+
+	dockerfile := `FROM busybox
+FOO bar
+RUN --mount=x true
+CMD ['echo', 'hi']
+WORKDIR $version
+ARG version`
+	out, exitCode, _ = runCommandWithOutput(buildImageCmd(name, dockerfile, true, "--check"))
+	c.Assert(exitCode, checker.Equals, 1)
+	c.Assert(out, checker.Contains, "Dockerfile:2: Unknown instruction: FOO")
+	c.Assert(out, checker.Contains, "Dockerfile:3: Unknown flag: mount")
+	c.Assert(out, checker.Contains, `Dockerfile:4: Error parsing "['echo', 'hi']" as a JSON array`)
+	c.Assert(out, checker.Contains, "Dockerfile:5: Undefined variable: version")
+
+	// nothing was built
+	_, err = inspectField(name, "Id")
+	c.Assert(err, checker.NotNil)
+}
+
+func (s *DockerSuite) TestBuildInclude(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+	name := "testbuildinclude"
+	dockerfile := `FROM busybox
+ARG user=nobody
+INCLUDE fragments/setup
+RUN [ "$(cat /version)" = "1.0" ]
+USER $user`
+	ctx, err := fakeContext(dockerfile, map[string]string{
+		"fragments/setup": "ENV version 1.0\nINCLUDE /fragments/version\n",
+		"fragments/version": `# write the version
+RUN echo $version > /version`,
+	})
+	c.Assert(err, checker.IsNil)
+	defer ctx.Close()
+
+	id, out, err := buildImageFromContextWithOut(name, ctx, true)
+	c.Assert(err, checker.IsNil, check.Commentf(out))
+	c.Assert(out, checker.Contains, "Step 3 : ENV version 1.0")
+	c.Assert(out, checker.Contains, "Step 4 : RUN echo $version > /version")
+	c.Assert(out, checker.Not(checker.Contains), "INCLUDE")
+
+	res, err := inspectFieldJSON(name, "Config.Env")
+	c.Assert(err, checker.IsNil)
+	c.Assert(res, checker.Contains, "version=1.0")
+	res, err = inspectField(name, "Config.User")
+	c.Assert(err, checker.IsNil)
+	c.Assert(res, checker.Equals, "nobody")
+
+	// the included instructions are cached like inline ones
+	cachedID, out, err := buildImageFromContextWithOut(name, ctx, true)
+	c.Assert(err, checker.IsNil, check.Commentf(out))
+	c.Assert(cachedID, checker.Equals, id)
+	c.Assert(strings.Count(out, "Using cache"), checker.Equals, 5)
+
+	// a change in a fragment only busts the cache from the changed instruction
+	c.Assert(ctx.Add("fragments/version", "RUN echo $version | tee /version\n"), checker.IsNil)
+	_, out, err = buildImageFromContextWithOut(name, ctx, true)
+	c.Assert(err, checker.IsNil, check.Commentf(out))
+	c.Assert(strings.Count(out, "Using cache"), checker.Equals, 2)
+
+	c.Assert(ctx.Add("fragments/version", "RUN echo $version > /version\nRUN false\n"), checker.IsNil)
+	_, _, err = buildImageFromContextWithOut(name, ctx, true)
+	c.Assert(err, checker.NotNil)
+	c.Assert(err.Error(), checker.Contains, "fragments/version line 2: The command '/bin/sh -c false' returned a non-zero code: 1")
+
+	c.Assert(ctx.Add("fragments/version", "FOO bar\n"), checker.IsNil)
+	_, _, err = buildImageFromContextWithOut(name, ctx, true)
+	c.Assert(err, checker.NotNil)
+	c.Assert(err.Error(), checker.Contains, "fragments/version line 1: Unknown instruction: FOO")
+}
+
+func (s *DockerSuite) TestBuildReproducible(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+	name := "testbuildreproducible"
+	dockerfile := `FROM busybox
+COPY file /file
+RUN echo hello > /hello
+ENV foo bar`
+	ctx, err := fakeContext(dockerfile, map[string]string{"file": "content"})
+	c.Assert(err, checker.IsNil)
+	defer ctx.Close()
+
+	id, out, err := buildImageFromContextWithOut(name, ctx, false, "--reproducible", "--epoch=1000000000")
+	c.Assert(err, checker.IsNil, check.Commentf(out))
+	created, err := inspectField(name, "Created")
+	c.Assert(err, checker.IsNil)
+	c.Assert(created, checker.Equals, "2001-09-09T01:46:40Z")
+	container, err := inspectField(name, "Container")
+	c.Assert(err, checker.IsNil)
+	c.Assert(container, checker.Equals, "")
+
+	// the same inputs give the same image, whatever the times of the files
+	later := time.Now().Add(time.Hour)
+	c.Assert(os.Chtimes(filepath.Join(ctx.Dir, "file"), later, later), checker.IsNil)
+	rebuiltID, out, err := buildImageFromContextWithOut(name, ctx, false, "--reproducible", "--epoch=1000000000")
+	c.Assert(err, checker.IsNil, check.Commentf(out))
+	c.Assert(rebuiltID, checker.Equals, id)
+
+	otherID, out, err := buildImageFromContextWithOut(name, ctx, false, "--reproducible", "--epoch=1000000001")
+	c.Assert(err, checker.IsNil, check.Commentf(out))
+	c.Assert(otherID, checker.Not(checker.Equals), id)
+
+	_, _, err = buildImageFromContextWithOut(name, ctx, false, "--epoch=1000000000")
+	c.Assert(err, checker.NotNil)
+	c.Assert(err.Error(), checker.Contains, "--epoch can only be used with --reproducible")
+}
@@ -14,6 +14,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/docker/docker/pkg/integration/checker"
@@ -404,6 +405,11 @@ func (s *DockerDaemonSuite) TestDaemonLogLevelWrong(c *check.C) {
 	c.Assert(s.d.Start("--log-level=bogus"), check.NotNil, check.Commentf("Daemon shouldn't start with wrong log level"))
 }
 
+func (s *DockerDaemonSuite) TestDaemonMaxConcurrentTransfersWrong(c *check.C) {
+	c.Assert(s.d.Start("--max-concurrent-downloads=-1"), check.NotNil, check.Commentf("Daemon shouldn't start with a negative number of downloads"))
+	c.Assert(s.d.Start("--max-concurrent-uploads=-1"), check.NotNil, check.Commentf("Daemon shouldn't start with a negative number of uploads"))
+}
+
 func (s *DockerSuite) TestDaemonStartWithBackwardCompatibility(c *check.C) {
 
 	var validCommandArgs = [][]string{
@@ -1880,3 +1886,80 @@ func (s *DockerDaemonSuite) TestBridgeIPIsExcludedFromAllocatorPool(c *check.C)
 		cont++
 	}
 }
+
+func (s *DockerDaemonSuite) TestDaemonConfigFileConflictWithFlag(c *check.C) {
+	configFile := filepath.Join(c.MkDir(), "daemon.json")
+	err := ioutil.WriteFile(configFile, []byte(`{"label": ["foo=bar"]}`), 0644)
+	c.Assert(err, checker.IsNil)
+
+	err = s.d.Start("--config-file", configFile, "--label", "foo=baz")
+	c.Assert(err, checker.NotNil)
+
+	content, _ := ioutil.ReadFile(s.d.logFile.Name())
+	c.Assert(string(content), checker.Contains, "the following directives are specified both as a flag and in the configuration file: label")
+}
+
+func (s *DockerDaemonSuite) TestDaemonConfigFileReloadLabels(c *check.C) {
+	configFile := filepath.Join(c.MkDir(), "daemon.json")
+	err := ioutil.WriteFile(configFile, []byte(`{"label": ["foo=bar"]}`), 0644)
+	c.Assert(err, checker.IsNil)
+
+	c.Assert(s.d.Start("--config-file", configFile), checker.IsNil)
+
+	out, err := s.d.Cmd("info")
+	c.Assert(err, checker.IsNil, check.Commentf(out))
+	c.Assert(out, checker.Contains, "foo=bar")
+
+	err = ioutil.WriteFile(configFile, []byte(`{"label": ["foo=baz"]}`), 0644)
+	c.Assert(err, checker.IsNil)
+	c.Assert(s.d.cmd.Process.Signal(syscall.SIGHUP), checker.IsNil)
+
+	// The configuration is reloaded asynchronously
+	for i := 0; ; i++ {
+		out, err = s.d.Cmd("info")
+		c.Assert(err, checker.IsNil, check.Commentf(out))
+		if strings.Contains(out, "foo=baz") {
+			break
+		}
+		if i == 50 {
+			c.Fatalf("labels were not reloaded:\n%s", out)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	c.Assert(out, checker.Not(checker.Contains), "foo=bar")
+
+	out, err = s.d.Cmd("events", "--since=0", "--until", strconv.FormatInt(time.Now().Unix()+1, 10), "--filter", "type=daemon")
+	c.Assert(err, checker.IsNil, check.Commentf(out))
+	c.Assert(out, checker.Contains, "daemon reload")
+}
+
+func (s *DockerDaemonSuite) TestDaemonLiveRestore(c *check.C) {
+	testRequires(c, SameHostDaemon, DaemonIsLinux)
+	c.Assert(s.d.StartWithBusybox("--live-restore"), checker.IsNil)
+
+	out, err := s.d.Cmd("info")
+	c.Assert(err, checker.IsNil, check.Commentf(out))
+	c.Assert(out, checker.Contains, "Live Restore Enabled: true")
+
+	out, err = s.d.Cmd("run", "-d", "--name", "live", "busybox", "sh", "-c", "echo before; trap 'echo after; exit 3' TERM; while true; do sleep 1; done")
+	c.Assert(err, checker.IsNil, check.Commentf(out))
+	pid, err := s.d.Cmd("inspect", "-f", "{{.State.Pid}}", "live")
+	c.Assert(err, checker.IsNil, check.Commentf(pid))
+
+	c.Assert(s.d.Restart("--live-restore"), checker.IsNil)
+
+	// the container kept running with the same process
+	out, err = s.d.Cmd("inspect", "-f", "{{.State.Running}} {{.State.Pid}}", "live")
+	c.Assert(err, checker.IsNil, check.Commentf(out))
+	c.Assert(strings.TrimSpace(out), checker.Equals, "true "+strings.TrimSpace(pid))
+
+	// its output and exit code are collected by the new daemon
+	out, err = s.d.Cmd("stop", "live")
+	c.Assert(err, checker.IsNil, check.Commentf(out))
+	out, err = s.d.Cmd("inspect", "-f", "{{.State.ExitCode}}", "live")
+	c.Assert(err, checker.IsNil, check.Commentf(out))
+	c.Assert(strings.TrimSpace(out), checker.Equals, "3")
+	out, err = s.d.Cmd("logs", "live")
+	c.Assert(err, checker.IsNil, check.Commentf(out))
+	c.Assert(out, checker.Equals, "before\nafter\n")
+}
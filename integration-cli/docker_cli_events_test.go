@@ -640,3 +640,63 @@ func (s *DockerRegistrySuite) TestEventsImageFilterPush(c *check.C) {
 	out, _ = dockerCmd(c, "events", "--since=0", "-f", "image="+repoName, "-f", "event=push", "--until="+strconv.Itoa(int(since)))
 	c.Assert(out, checker.Contains, repoName+": push\n", check.Commentf("Missing 'push' log event"))
 }
+
+func (s *DockerSuite) TestEventsVolumeEvents(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+
+	since := daemonTime(c).Unix()
+	dockerCmd(c, "volume", "create", "--name", "test-event-volume-local")
+	dockerCmd(c, "volume", "rm", "test-event-volume-local")
+
+	out, _ := dockerCmd(c, "events", fmt.Sprintf("--since=%d", since), fmt.Sprintf("--until=%d", daemonTime(c).Unix()), "--filter", "volume=test-event-volume-local")
+	events := strings.Split(strings.TrimSpace(out), "\n")
+	c.Assert(events, checker.HasLen, 2, check.Commentf("out: %s", out))
+	c.Assert(events[0], checker.Contains, "volume create test-event-volume-local (driver=local)")
+	c.Assert(events[1], checker.Contains, "volume destroy test-event-volume-local (driver=local)")
+}
+
+func (s *DockerSuite) TestEventsNetworkEvents(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+
+	since := daemonTime(c).Unix()
+	out, _ := dockerCmd(c, "network", "create", "test-event-network-local")
+	networkID := strings.TrimSpace(out)
+
+	out, _ = dockerCmd(c, "run", "-d", "--name", "test-event-network-container", "busybox", "top")
+	containerID := strings.TrimSpace(out)
+	c.Assert(waitRun(containerID), checker.IsNil)
+
+	dockerCmd(c, "network", "connect", "test-event-network-local", containerID)
+	dockerCmd(c, "network", "disconnect", "test-event-network-local", containerID)
+	dockerCmd(c, "network", "rm", "test-event-network-local")
+
+	until := fmt.Sprintf("--until=%d", daemonTime(c).Unix())
+	out, _ = dockerCmd(c, "events", fmt.Sprintf("--since=%d", since), until, "--filter", "network="+networkID)
+	events := strings.Split(strings.TrimSpace(out), "\n")
+	c.Assert(events, checker.HasLen, 4, check.Commentf("out: %s", out))
+	c.Assert(events[0], checker.Contains, "network create "+networkID)
+	c.Assert(events[1], checker.Contains, "network connect "+networkID+" (container="+containerID)
+	c.Assert(events[2], checker.Contains, "network disconnect "+networkID+" (container="+containerID)
+	c.Assert(events[3], checker.Contains, "network destroy "+networkID)
+
+	// network events referring to the container are included by the container filter
+	out, _ = dockerCmd(c, "events", fmt.Sprintf("--since=%d", since), until, "--filter", "container="+containerID, "--filter", "type=network")
+	events = strings.Split(strings.TrimSpace(out), "\n")
+	c.Assert(events, checker.HasLen, 2, check.Commentf("out: %s", out))
+}
+
+func (s *DockerSuite) TestEventsFilterType(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+
+	since := daemonTime(c).Unix()
+	dockerCmd(c, "volume", "create", "--name", "test-event-type-volume")
+	dockerCmd(c, "run", "--rm", "busybox", "true")
+
+	out, _ := dockerCmd(c, "events", fmt.Sprintf("--since=%d", since), fmt.Sprintf("--until=%d", daemonTime(c).Unix()), "--filter", "type=volume")
+	c.Assert(out, checker.Contains, "volume create test-event-type-volume")
+	c.Assert(out, checker.Not(checker.Contains), "busybox")
+
+	out, _ = dockerCmd(c, "events", fmt.Sprintf("--since=%d", since), fmt.Sprintf("--until=%d", daemonTime(c).Unix()), "--filter", "type=container")
+	c.Assert(out, checker.Contains, "(from busybox) start")
+	c.Assert(out, checker.Not(checker.Contains), "test-event-type-volume")
+}
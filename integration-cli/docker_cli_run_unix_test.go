@@ -371,3 +371,126 @@ func (s *DockerSuite) TestRunInvalidCPUShares(c *check.C) {
 	expected = "The maximum allowed cpu-shares is"
 	c.Assert(out, checker.Contains, expected)
 }
+
+func (s *DockerSuite) TestRunTmpfsMounts(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+
+	out, _ := dockerCmd(c, "run", "--tmpfs", "/run", "busybox", "grep", "/run", "/proc/self/mounts")
+	c.Assert(out, checker.Contains, "tmpfs /run tmpfs")
+	c.Assert(out, checker.Contains, "nosuid,nodev,noexec")
+
+	out, _ = dockerCmd(c, "run", "--tmpfs", "/run:rw,exec,size=1m", "busybox", "grep", "/run", "/proc/self/mounts")
+	c.Assert(out, checker.Contains, "size=1024k")
+	c.Assert(out, checker.Not(checker.Contains), "noexec")
+
+	// a tmpfs is still writable when the root filesystem is read only
+	dockerCmd(c, "run", "--read-only", "--tmpfs", "/run", "busybox", "touch", "/run/somefile")
+
+	_, _, err := dockerCmdWithError("run", "--tmpfs", "/run:foo=bar", "busybox", "true")
+	c.Assert(err, checker.NotNil)
+}
+
+func (s *DockerSuite) TestRunTmpfsMountsInspect(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+
+	name := "test-tmpfs-inspect"
+	dockerCmd(c, "create", "--name", name, "--tmpfs", "/run:size=64m", "busybox", "true")
+
+	out, err := inspectFieldJSON(name, "HostConfig.Tmpfs")
+	c.Assert(err, checker.IsNil)
+	c.Assert(out, checker.Equals, `{"/run":"size=64m"}`)
+}
+
+func (s *DockerSuite) TestRunPidsLimit(c *check.C) {
+	testRequires(c, DaemonIsLinux, pidsLimit)
+
+	file := "/sys/fs/cgroup/pids/pids.max"
+	out, _ := dockerCmd(c, "run", "--name", "skittles", "--pids-limit", "2", "busybox", "cat", file)
+	c.Assert(strings.TrimSpace(out), checker.Equals, "2")
+
+	out, err := inspectField("skittles", "HostConfig.PidsLimit")
+	c.Assert(err, checker.IsNil)
+	c.Assert(out, checker.Equals, "2", check.Commentf("setting the pids limit failed"))
+}
+
+func (s *DockerSuite) TestRunPidsLimitUnlimited(c *check.C) {
+	testRequires(c, DaemonIsLinux, pidsLimit)
+
+	file := "/sys/fs/cgroup/pids/pids.max"
+	out, _ := dockerCmd(c, "run", "--pids-limit", "-1", "busybox", "cat", file)
+	c.Assert(strings.TrimSpace(out), checker.Equals, "max")
+}
+
+func (s *DockerSuite) TestRunSysctls(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+
+	out, _ := dockerCmd(c, "run", "--name", "test-sysctl", "--sysctl", "net.ipv4.ip_forward=1", "busybox", "cat", "/proc/sys/net/ipv4/ip_forward")
+	c.Assert(strings.TrimSpace(out), checker.Equals, "1")
+
+	out, err := inspectFieldJSON("test-sysctl", "HostConfig.Sysctls")
+	c.Assert(err, checker.IsNil)
+	c.Assert(out, checker.Equals, `{"net.ipv4.ip_forward":"1"}`)
+
+	out, _ = dockerCmd(c, "run", "--sysctl", "net.ipv4.ip_forward=0", "busybox", "cat", "/proc/sys/net/ipv4/ip_forward")
+	c.Assert(strings.TrimSpace(out), checker.Equals, "0")
+}
+
+func (s *DockerSuite) TestRunSysctlsInvalid(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+
+	out, _, err := dockerCmdWithError("run", "--sysctl", "kernel.hostname=foo", "busybox", "true")
+	c.Assert(err, checker.NotNil)
+	c.Assert(out, checker.Contains, "sysctl 'kernel.hostname=foo' is not allowed")
+
+	out, _, err = dockerCmdWithError("run", "--net=host", "--sysctl", "net.core.somaxconn=1024", "busybox", "true")
+	c.Assert(err, checker.NotNil)
+	c.Assert(out, checker.Contains, "is not allowed when using the host's network namespace")
+}
+
+func (s *DockerSuite) TestRunSeccompProfileDenyUnshare(c *check.C) {
+	testRequires(c, DaemonIsLinux, seccompEnabled)
+
+	jsonData := `{
+	"defaultAction": "SCMP_ACT_ALLOW",
+	"syscalls": [
+		{
+			"name": "unshare",
+			"action": "SCMP_ACT_ERRNO"
+		}
+	]
+}`
+	tmpFile, err := ioutil.TempFile("", "profile.json")
+	c.Assert(err, checker.IsNil)
+	defer tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write([]byte(jsonData))
+	c.Assert(err, checker.IsNil)
+
+	out, _, err := dockerCmdWithError("run", "--cap-add", "ALL", "--security-opt", "apparmor:unconfined", "--security-opt", "seccomp="+tmpFile.Name(), "debian:jessie", "unshare", "-p", "-m", "-f", "-r", "mount", "-t", "proc", "none", "/proc")
+	c.Assert(err, checker.NotNil, check.Commentf(out))
+	c.Assert(out, checker.Contains, "Operation not permitted")
+}
+
+func (s *DockerSuite) TestRunSeccompDefaultProfile(c *check.C) {
+	testRequires(c, DaemonIsLinux, seccompEnabled)
+
+	// The default profile prevents containers from creating namespaces,
+	// even with all the capabilities.
+	out, _, err := dockerCmdWithError("run", "--cap-add", "ALL", "--security-opt", "apparmor:unconfined", "debian:jessie", "unshare", "--map-root-user", "--user", "sh", "-c", "whoami")
+	c.Assert(err, checker.NotNil, check.Commentf(out))
+	c.Assert(out, checker.Contains, "Operation not permitted")
+
+	// Unless seccomp is disabled for the container
+	dockerCmd(c, "run", "--name", "test-seccomp-unconfined", "--security-opt", "seccomp=unconfined", "busybox", "true")
+	out, err = inspectFieldJSON("test-seccomp-unconfined", "HostConfig.SecurityOpt")
+	c.Assert(err, checker.IsNil)
+	c.Assert(out, checker.Equals, `["seccomp=unconfined"]`)
+}
+
+func (s *DockerSuite) TestRunSeccompInvalidProfile(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+
+	out, _, err := dockerCmdWithError("run", "--security-opt", "seccomp=/non/existent/profile.json", "busybox", "true")
+	c.Assert(err, checker.NotNil)
+	c.Assert(out, checker.Contains, "Opening seccomp profile")
+}
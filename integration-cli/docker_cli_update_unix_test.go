@@ -0,0 +1,156 @@
+// +build !windows
+
+package main
+
+import (
+	"strings"
+
+	"github.com/docker/docker/pkg/integration/checker"
+	"github.com/go-check/check"
+)
+
+func (s *DockerSuite) TestUpdateRunningContainer(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+	testRequires(c, memoryLimitSupport)
+
+	name := "test-update-container"
+	dockerCmd(c, "run", "-d", "--name", name, "-m", "300M", "busybox", "top")
+	dockerCmd(c, "update", "-m", "500M", name)
+
+	memory, err := inspectField(name, "HostConfig.Memory")
+	c.Assert(err, checker.IsNil)
+	c.Assert(memory, checker.Equals, "524288000")
+
+	file := "/sys/fs/cgroup/memory/memory.limit_in_bytes"
+	out, _ := dockerCmd(c, "exec", name, "cat", file)
+	c.Assert(strings.TrimSpace(out), checker.Equals, "524288000")
+}
+
+func (s *DockerSuite) TestUpdateStoppedContainer(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+	testRequires(c, cpuShare)
+
+	name := "test-update-container"
+	file := "/sys/fs/cgroup/cpu/cpu.shares"
+	dockerCmd(c, "run", "--name", name, "--cpu-shares", "512", "busybox", "cat", file)
+	dockerCmd(c, "update", "--cpu-shares", "1024", name)
+
+	shares, err := inspectField(name, "HostConfig.CpuShares")
+	c.Assert(err, checker.IsNil)
+	c.Assert(shares, checker.Equals, "1024")
+
+	out, _ := dockerCmd(c, "start", "-a", name)
+	c.Assert(strings.TrimSpace(out), checker.Equals, "1024")
+}
+
+func (s *DockerSuite) TestUpdatePausedContainer(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+	testRequires(c, cpuShare)
+
+	name := "test-update-container"
+	dockerCmd(c, "run", "-d", "--name", name, "--cpu-shares", "1000", "busybox", "top")
+	dockerCmd(c, "pause", name)
+	dockerCmd(c, "update", "--cpu-shares", "500", name)
+
+	shares, err := inspectField(name, "HostConfig.CpuShares")
+	c.Assert(err, checker.IsNil)
+	c.Assert(shares, checker.Equals, "500")
+
+	dockerCmd(c, "unpause", name)
+	file := "/sys/fs/cgroup/cpu/cpu.shares"
+	out, _ := dockerCmd(c, "exec", name, "cat", file)
+	c.Assert(strings.TrimSpace(out), checker.Equals, "500")
+}
+
+func (s *DockerSuite) TestUpdateWithUntouchedFields(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+	testRequires(c, memoryLimitSupport)
+	testRequires(c, cpuShare)
+
+	name := "test-update-container"
+	dockerCmd(c, "run", "-d", "--name", name, "-m", "300M", "--cpu-shares", "800", "busybox", "top")
+	dockerCmd(c, "update", "-m", "500M", name)
+
+	// Update memory and not touch cpu shares, they should keep the old value
+	out, err := inspectField(name, "HostConfig.CpuShares")
+	c.Assert(err, checker.IsNil)
+	c.Assert(out, checker.Equals, "800")
+
+	file := "/sys/fs/cgroup/cpu/cpu.shares"
+	out, _ = dockerCmd(c, "exec", name, "cat", file)
+	c.Assert(strings.TrimSpace(out), checker.Equals, "800")
+}
+
+func (s *DockerSuite) TestUpdateContainerInvalidValue(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+	testRequires(c, memoryLimitSupport)
+
+	name := "test-update-container"
+	dockerCmd(c, "run", "-d", "--name", name, "-m", "300M", "busybox", "true")
+	out, _, err := dockerCmdWithError("update", "-m", "2M", name)
+	c.Assert(err, checker.NotNil)
+	expected := "Minimum memory limit allowed is 4MB"
+	c.Assert(out, checker.Contains, expected)
+}
+
+func (s *DockerSuite) TestUpdateContainerWithoutFlags(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+	testRequires(c, memoryLimitSupport)
+
+	name := "test-update-container"
+	dockerCmd(c, "run", "-d", "--name", name, "-m", "300M", "busybox", "true")
+	_, _, err := dockerCmdWithError("update", name)
+	c.Assert(err, checker.NotNil)
+}
+
+func (s *DockerSuite) TestUpdateKernelMemory(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+	testRequires(c, kernelMemorySupport)
+
+	name := "test-update-container"
+	dockerCmd(c, "run", "-d", "--name", name, "--kernel-memory", "50M", "busybox", "top")
+	_, _, err := dockerCmdWithError("update", "--kernel-memory", "100M", name)
+	// Update kernel memory to a running container is not allowed.
+	c.Assert(err, checker.NotNil)
+
+	out, err := inspectField(name, "HostConfig.KernelMemory")
+	c.Assert(err, checker.IsNil)
+	// Update kernel memory to a running container with failure should not change HostConfig
+	c.Assert(out, checker.Equals, "52428800")
+
+	dockerCmd(c, "stop", name)
+	dockerCmd(c, "update", "--kernel-memory", "100M", name)
+	dockerCmd(c, "start", name)
+
+	out, err = inspectField(name, "HostConfig.KernelMemory")
+	c.Assert(err, checker.IsNil)
+	c.Assert(out, checker.Equals, "104857600")
+
+	file := "/sys/fs/cgroup/memory/memory.kmem.limit_in_bytes"
+	out, _ = dockerCmd(c, "exec", name, "cat", file)
+	c.Assert(strings.TrimSpace(out), checker.Equals, "104857600")
+}
+
+func (s *DockerSuite) TestUpdateRestartPolicy(c *check.C) {
+	testRequires(c, DaemonIsLinux)
+
+	name := "test-update-container"
+	dockerCmd(c, "run", "-d", "--name", name, "--restart", "no", "busybox", "top")
+	dockerCmd(c, "update", "--restart", "on-failure:3", name)
+
+	out, err := inspectField(name, "HostConfig.RestartPolicy.Name")
+	c.Assert(err, checker.IsNil)
+	c.Assert(out, checker.Equals, "on-failure")
+
+	out, err = inspectField(name, "HostConfig.RestartPolicy.MaximumRetryCount")
+	c.Assert(err, checker.IsNil)
+	c.Assert(out, checker.Equals, "3")
+
+	// the container must not have been restarted by the update
+	out, err = inspectField(name, "RestartCount")
+	c.Assert(err, checker.IsNil)
+	c.Assert(out, checker.Equals, "0")
+
+	_, _, err = dockerCmdWithError("update", "--restart", "always:3", name)
+	c.Assert(err, checker.NotNil)
+}
@@ -3,6 +3,9 @@
 package main
 
 import (
+	"os/exec"
+	"strings"
+
 	"github.com/docker/docker/pkg/sysinfo"
 )
 
@@ -75,6 +78,19 @@ var (
 		},
 		"Test requires an environment that supports cgroup cpuset.",
 	}
+	pidsLimit = testRequirement{
+		func() bool {
+			return SysInfo.PidsLimit
+		},
+		"Test requires an environment that supports pids limit.",
+	}
+	seccompEnabled = testRequirement{
+		func() bool {
+			out, err := exec.Command(dockerBinary, "info").Output()
+			return err == nil && strings.Contains(string(out), "Seccomp: true")
+		},
+		"Test requires a daemon and a kernel with seccomp support.",
+	}
 )
 
 func init() {
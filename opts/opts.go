@@ -355,6 +355,46 @@ func ValidateExtraHost(val string) (string, error) {
 	return val, nil
 }
 
+// validKernelSysctls lists the kernel parameters that are namespaced, and
+// can therefore be set for a single container.
+var validKernelSysctls = map[string]bool{
+	"kernel.msgmax":          true,
+	"kernel.msgmnb":          true,
+	"kernel.msgmni":          true,
+	"kernel.sem":             true,
+	"kernel.shmall":          true,
+	"kernel.shmmax":          true,
+	"kernel.shmmni":          true,
+	"kernel.shm_rmid_forced": true,
+}
+
+// validNamespacedSysctlPrefixes lists the prefixes of the namespaced kernel
+// parameters that do not have a fixed name.
+var validNamespacedSysctlPrefixes = []string{
+	"net.",
+	"fs.mqueue.",
+}
+
+// ValidateSysctl validates that the specified string is a namespaced sysctl
+// in the form of key=value, and returns it.
+// Only sysctls of the IPC and network namespaces are accepted, as the other
+// ones would change the settings of the whole host.
+func ValidateSysctl(val string) (string, error) {
+	arr := strings.SplitN(val, "=", 2)
+	if len(arr) != 2 || len(arr[0]) == 0 {
+		return "", fmt.Errorf("bad format for sysctl: %q", val)
+	}
+	if validKernelSysctls[arr[0]] {
+		return val, nil
+	}
+	for _, prefix := range validNamespacedSysctlPrefixes {
+		if strings.HasPrefix(arr[0], prefix) {
+			return val, nil
+		}
+	}
+	return "", fmt.Errorf("sysctl '%s' is not allowed", val)
+}
+
 // ValidateLabel validates that the specified string is a valid label, and returns it.
 // Labels are in the form on key=value.
 func ValidateLabel(val string) (string, error) {
@@ -355,6 +355,34 @@ func TestValidateEnv(t *testing.T) {
 	}
 }
 
+func TestValidateSysctl(t *testing.T) {
+	valid := []string{
+		"net.core.somaxconn=1024",
+		"net.ipv4.tcp_syncookies=0",
+		"kernel.shmmax=68719476736",
+		"fs.mqueue.msg_max=100",
+		"kernel.sem=250 32000 100 128",
+	}
+	for _, sysctl := range valid {
+		if _, err := ValidateSysctl(sysctl); err != nil {
+			t.Fatalf("ValidateSysctl(`%s`) should succeed: %v", sysctl, err)
+		}
+	}
+
+	invalid := map[string]string{
+		"kernel.hostname=foo": "sysctl 'kernel.hostname=foo' is not allowed",
+		"vm.swappiness=10":    "sysctl 'vm.swappiness=10' is not allowed",
+		"fs.file-max=10":      "sysctl 'fs.file-max=10' is not allowed",
+		"net.core.somaxconn":  "bad format for sysctl: \"net.core.somaxconn\"",
+		"=1024":               "bad format for sysctl: \"=1024\"",
+	}
+	for sysctl, expectedError := range invalid {
+		if _, err := ValidateSysctl(sysctl); err == nil || err.Error() != expectedError {
+			t.Fatalf("ValidateSysctl(`%s`) should have failed with %q, got %v", sysctl, expectedError, err)
+		}
+	}
+}
+
 func TestValidateLabel(t *testing.T) {
 	if _, err := ValidateLabel("label"); err == nil || err.Error() != "bad attribute format: label" {
 		t.Fatalf("Expected an error [bad attribute format: label], go %v", err)
@@ -0,0 +1,60 @@
+package opts
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// validSecretID matches the IDs of the build secrets, which are the names of
+// their files in the RUN containers.
+var validSecretID = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// ValidateSecretID validates that the specified string is a valid ID of a
+// build secret, and returns it.
+func ValidateSecretID(val string) (string, error) {
+	if !validSecretID.MatchString(val) {
+		return "", fmt.Errorf("invalid secret id %q, only [a-zA-Z0-9_.-] are allowed", val)
+	}
+	return val, nil
+}
+
+// ParseSecret parses a build secret in the form of id=<id>,src=<path>, and
+// returns its ID and the path of its file. The ID defaults to the base name
+// of the file.
+func ParseSecret(val string) (id, src string, err error) {
+	for _, field := range strings.Split(val, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return "", "", fmt.Errorf("bad format for secret: %q", val)
+		}
+		switch kv[0] {
+		case "id":
+			id = kv[1]
+		case "src", "source":
+			src = kv[1]
+		default:
+			return "", "", fmt.Errorf("unknown field %q in secret: %q", kv[0], val)
+		}
+	}
+	if src == "" {
+		return "", "", fmt.Errorf("no source file in secret: %q", val)
+	}
+	if id == "" {
+		id = filepath.Base(src)
+	}
+	if _, err := ValidateSecretID(id); err != nil {
+		return "", "", err
+	}
+	return id, src, nil
+}
+
+// ValidateSecret validates that the specified string is a valid build secret,
+// and returns it.
+func ValidateSecret(val string) (string, error) {
+	if _, _, err := ParseSecret(val); err != nil {
+		return "", err
+	}
+	return val, nil
+}
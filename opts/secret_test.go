@@ -0,0 +1,42 @@
+package opts
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSecret(t *testing.T) {
+	valid := []struct {
+		val string
+		id  string
+		src string
+	}{
+		{"src=/tmp/token", "token", "/tmp/token"},
+		{"id=npmrc,src=/home/me/.npmrc", "npmrc", "/home/me/.npmrc"},
+		{"source=key.pem,id=my_key.1", "my_key.1", "key.pem"},
+	}
+	for _, v := range valid {
+		id, src, err := ParseSecret(v.val)
+		if err != nil {
+			t.Fatalf("Expected %q to be valid, got %v", v.val, err)
+		}
+		if id != v.id || src != v.src {
+			t.Fatalf("Expected %q to give %q and %q, got %q and %q", v.val, v.id, v.src, id, src)
+		}
+	}
+
+	invalid := map[string]string{
+		"/tmp/token":           "bad format",
+		"id=token":             "no source file",
+		"id=token,src=":        "no source file",
+		"id=tok/en,src=/token": "invalid secret id",
+		"id=.token,src=/token": "invalid secret id",
+		"src=/":                "invalid secret id",
+		"src=/token,mode=0400": "unknown field",
+	}
+	for val, expected := range invalid {
+		if _, _, err := ParseSecret(val); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected %q to fail with %q, got %v", val, expected, err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package archive
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+)
+
+// normalizedEntry is an entry of an archive being normalized, whose content
+// is spooled at offset in a temporary file.
+type normalizedEntry struct {
+	hdr    *tar.Header
+	offset int64
+}
+
+type entriesByName []*normalizedEntry
+
+func (e entriesByName) Len() int           { return len(e) }
+func (e entriesByName) Less(i, j int) bool { return e[i].hdr.Name < e[j].hdr.Name }
+func (e entriesByName) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+
+// Normalize writes to w the tar archive read from r with its entries sorted
+// by name, the modification times of the entries set to modTime, and without
+// the fields which depend on the host rather than on the files: the access
+// and change times and the names of the owners. Archives of the same files
+// are then identical, whenever and in whatever order the files were written.
+//
+// The content of the entries is spooled to a temporary file to sort them.
+func Normalize(w io.Writer, r io.Reader, modTime time.Time) error {
+	spool, err := ioutil.TempFile("", "docker-normalize")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	var (
+		entries []*normalizedEntry
+		offset  int64
+	)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(spool, tr)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, &normalizedEntry{
+			hdr: &tar.Header{
+				Name:     hdr.Name,
+				Mode:     hdr.Mode,
+				Uid:      hdr.Uid,
+				Gid:      hdr.Gid,
+				Size:     hdr.Size,
+				ModTime:  modTime,
+				Typeflag: hdr.Typeflag,
+				Linkname: hdr.Linkname,
+				Devmajor: hdr.Devmajor,
+				Devminor: hdr.Devminor,
+				Xattrs:   hdr.Xattrs,
+			},
+			offset: offset,
+		})
+		offset += n
+	}
+
+	sort.Sort(entriesByName(entries))
+	reorderHardlinks(entries)
+
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		if err := tw.WriteHeader(e.hdr); err != nil {
+			return err
+		}
+		if e.hdr.Typeflag == tar.TypeLink || e.hdr.Size == 0 {
+			continue
+		}
+		if _, err := io.Copy(tw, io.NewSectionReader(spool, e.offset, e.hdr.Size)); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// reorderHardlinks makes the first of the sorted entries of each set of hard
+// links hold the content of the file, and the others link to it, since a hard
+// link must follow its target in an archive.
+func reorderHardlinks(entries []*normalizedEntry) {
+	byName := make(map[string]*normalizedEntry)
+	links := make(map[string][]*normalizedEntry)
+	for _, e := range entries {
+		byName[e.hdr.Name] = e
+		if e.hdr.Typeflag == tar.TypeLink {
+			links[e.hdr.Linkname] = append(links[e.hdr.Linkname], e)
+		}
+	}
+
+	for target, group := range links {
+		t, ok := byName[target]
+		if !ok {
+			continue
+		}
+		first := t
+		for _, l := range group {
+			if l.hdr.Name < first.hdr.Name {
+				first = l
+			}
+		}
+		if first == t {
+			continue
+		}
+
+		name := first.hdr.Name
+		linkHdr := *first.hdr
+		first.hdr, first.offset = t.hdr, t.offset
+		first.hdr.Name = name
+		t.hdr, t.offset = &linkHdr, 0
+		t.hdr.Name = target
+		for _, l := range append(group, t) {
+			if l != first {
+				l.hdr.Linkname = name
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+type testEntry struct {
+	hdr     tar.Header
+	content string
+}
+
+func writeTestArchive(t *testing.T, entries []testEntry) []byte {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for _, e := range entries {
+		hdr := e.hdr
+		hdr.Size = int64(len(e.content))
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNormalize(t *testing.T) {
+	now := time.Now()
+	first := writeTestArchive(t, []testEntry{
+		{tar.Header{Name: "dir/", Mode: 0755, Typeflag: tar.TypeDir, ModTime: now}, ""},
+		{tar.Header{Name: "dir/b", Mode: 0644, Typeflag: tar.TypeReg, ModTime: now, Uname: "root", Uid: 1}, "b"},
+		{tar.Header{Name: "dir/a", Typeflag: tar.TypeLink, Linkname: "dir/b", ModTime: now}, ""},
+		{tar.Header{Name: "c", Mode: 0600, Typeflag: tar.TypeReg, ModTime: now, AccessTime: now}, "c"},
+	})
+	later := now.Add(time.Hour)
+	second := writeTestArchive(t, []testEntry{
+		{tar.Header{Name: "c", Mode: 0600, Typeflag: tar.TypeReg, ModTime: later}, "c"},
+		{tar.Header{Name: "dir/", Mode: 0755, Typeflag: tar.TypeDir, ModTime: later}, ""},
+		{tar.Header{Name: "dir/a", Mode: 0644, Typeflag: tar.TypeReg, ModTime: later, Uname: "user", Uid: 1}, "b"},
+		{tar.Header{Name: "dir/b", Typeflag: tar.TypeLink, Linkname: "dir/a", ModTime: later}, ""},
+	})
+
+	epoch := time.Unix(1000000000, 0)
+	var normalized [2]bytes.Buffer
+	for i, archive := range [][]byte{first, second} {
+		if err := Normalize(&normalized[i], bytes.NewReader(archive), epoch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(normalized[0].Bytes(), normalized[1].Bytes()) {
+		t.Fatal("Expected the normalized archives of the same files to be identical")
+	}
+
+	expected := []struct {
+		name     string
+		linkname string
+		content  string
+	}{
+		{"c", "", "c"},
+		{"dir/", "", ""},
+		{"dir/a", "", "b"},
+		{"dir/b", "dir/a", ""},
+	}
+	tr := tar.NewReader(&normalized[0])
+	for _, e := range expected {
+		hdr, err := tr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Name != e.name || hdr.Linkname != e.linkname || string(content) != e.content {
+			t.Fatalf("Expected %s linking to %q with %q, got %s linking to %q with %q", e.name, e.linkname, e.content, hdr.Name, hdr.Linkname, content)
+		}
+		if !hdr.ModTime.Equal(epoch) || hdr.Uname != "" || !hdr.AccessTime.IsZero() {
+			t.Fatalf("Expected the header of %s to be normalized, got %+v", hdr.Name, hdr)
+		}
+	}
+	if _, err := tr.Next(); err == nil {
+		t.Fatal("Expected the normalized archive to hold 4 entries")
+	}
+}
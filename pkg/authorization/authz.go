@@ -0,0 +1,173 @@
+package authorization
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/pkg/ioutils"
+)
+
+// maxBodySize is the maximum size of a request or response body sent to
+// authorization plugins. Larger bodies are not sent.
+const maxBodySize = 1048576 // 1MB
+
+// DeniedError is returned when an authorization plugin denies a request or
+// a response.
+type DeniedError struct {
+	// Plugin is the name of the plugin that denied the request
+	Plugin string
+	// Msg is the message returned by the plugin
+	Msg string
+}
+
+// Error returns a human readable description of the denial.
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("authorization denied by plugin %s: %s", e.Plugin, e.Msg)
+}
+
+// Ctx holds the authorization context of a single request, which is sent
+// to the authorization plugins when the request is received and again when
+// the response is ready to be sent.
+type Ctx struct {
+	plugins         []Plugin
+	user            string
+	userAuthNMethod string
+	requestMethod   string
+	requestURI      string
+	authReq         *Request
+}
+
+// NewCtx creates a new authorization context for a request sent by user.
+func NewCtx(authZPlugins []Plugin, user, userAuthNMethod, requestMethod, requestURI string) *Ctx {
+	return &Ctx{
+		plugins:         authZPlugins,
+		user:            user,
+		userAuthNMethod: userAuthNMethod,
+		requestMethod:   requestMethod,
+		requestURI:      requestURI,
+	}
+}
+
+// AuthZRequest asks every authorization plugin, in order, whether the request
+// is allowed. The first plugin to deny the request stops the chain.
+func (ctx *Ctx) AuthZRequest(w http.ResponseWriter, r *http.Request) error {
+	var body []byte
+	if sendBody(ctx.requestURI, r.Header) {
+		var err error
+		body, r.Body, err = drainBody(r.Body)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx.authReq = &Request{
+		User:            ctx.user,
+		UserAuthNMethod: ctx.userAuthNMethod,
+		RequestMethod:   ctx.requestMethod,
+		RequestURI:      ctx.requestURI,
+		RequestBody:     body,
+		RequestHeaders:  headers(r.Header),
+	}
+
+	for _, plugin := range ctx.plugins {
+		logrus.Debugf("AuthZ request using plugin %s", plugin.Name())
+
+		authRes, err := plugin.AuthZRequest(ctx.authReq)
+		if err != nil {
+			return fmt.Errorf("plugin %s failed with error: %s", plugin.Name(), err)
+		}
+		if err := checkResponse(plugin, authRes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AuthZResponse asks every authorization plugin, in order, whether the
+// response is allowed, and sends it to the client if so.
+func (ctx *Ctx) AuthZResponse(rm ResponseModifier, r *http.Request) error {
+	ctx.authReq.ResponseStatusCode = rm.StatusCode()
+	ctx.authReq.ResponseHeaders = headers(rm.Header())
+	if sendBody(ctx.requestURI, rm.Header()) {
+		ctx.authReq.ResponseBody = rm.RawBody()
+	}
+
+	for _, plugin := range ctx.plugins {
+		logrus.Debugf("AuthZ response using plugin %s", plugin.Name())
+
+		authRes, err := plugin.AuthZResponse(ctx.authReq)
+		if err != nil {
+			return fmt.Errorf("plugin %s failed with error: %s", plugin.Name(), err)
+		}
+		if err := checkResponse(plugin, authRes); err != nil {
+			return err
+		}
+	}
+
+	return rm.FlushAll()
+}
+
+// checkResponse converts the response of a plugin into an error if the
+// plugin failed or did not allow the request.
+func checkResponse(plugin Plugin, authRes *Response) error {
+	if authRes.Err != "" {
+		return fmt.Errorf("plugin %s failed with error: %s", plugin.Name(), authRes.Err)
+	}
+	if !authRes.Allow {
+		return &DeniedError{Plugin: plugin.Name(), Msg: authRes.Msg}
+	}
+	return nil
+}
+
+// drainBody dumps the body into a byte slice and returns a new reader
+// holding the same content, closing the original body.
+func drainBody(body io.ReadCloser) ([]byte, io.ReadCloser, error) {
+	bufReader := bufio.NewReaderSize(body, maxBodySize)
+	newBody := ioutils.NewReadCloserWrapper(bufReader, func() error { return body.Close() })
+
+	data, err := bufReader.Peek(maxBodySize)
+	// Body size exceeds max body size
+	if err == nil {
+		logrus.Warnf("Request body is larger than: '%d' skipping body", maxBodySize)
+		return nil, newBody, nil
+	}
+	// Body size is less than maximum size
+	if err == io.EOF {
+		return data, newBody, nil
+	}
+	// Unknown error
+	return nil, newBody, err
+}
+
+// sendBody returns whether the body of a request or response should be
+// sent to the authorization plugins. Only JSON bodies are sent, and never
+// the body of an authentication request.
+func sendBody(url string, header http.Header) bool {
+	// Skip body for auth endpoint
+	if strings.HasSuffix(url, "/auth") {
+		return false
+	}
+
+	// body is sent only for text or json messages
+	return header != nil && strings.HasPrefix(header.Get("Content-Type"), "application/json")
+}
+
+// headers returns the first value of each header, leaving out the
+// registry credentials and the build secrets.
+func headers(header http.Header) map[string]string {
+	v := make(map[string]string)
+	for k, values := range header {
+		// Skip registry auth and build secrets headers
+		if strings.EqualFold(k, "X-Registry-Auth") || strings.EqualFold(k, "X-Build-Secrets") {
+			continue
+		}
+		if len(values) > 0 {
+			v[k] = values[0]
+		}
+	}
+	return v
+}
@@ -0,0 +1,217 @@
+package authorization
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakePlugin records the requests it receives and answers with res.
+type fakePlugin struct {
+	name     string
+	res      Response
+	requests []*Request
+}
+
+func (p *fakePlugin) Name() string {
+	return p.name
+}
+
+func (p *fakePlugin) AuthZRequest(req *Request) (*Response, error) {
+	p.requests = append(p.requests, req)
+	return &p.res, nil
+}
+
+func (p *fakePlugin) AuthZResponse(req *Request) (*Response, error) {
+	p.requests = append(p.requests, req)
+	return &p.res, nil
+}
+
+func newJSONRequest(t *testing.T, body string) *http.Request {
+	r, err := http.NewRequest("POST", "/containers/create", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-Registry-Auth", "secret")
+	return r
+}
+
+func TestAuthZRequestAllow(t *testing.T) {
+	plugin := &fakePlugin{name: "allow", res: Response{Allow: true}}
+	r := newJSONRequest(t, `{"Image":"busybox"}`)
+
+	ctx := NewCtx([]Plugin{plugin}, "user", "TLS", "POST", "/containers/create")
+	if err := ctx.AuthZRequest(httptest.NewRecorder(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plugin.requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(plugin.requests))
+	}
+	req := plugin.requests[0]
+	if req.User != "user" || req.UserAuthNMethod != "TLS" || req.RequestMethod != "POST" || req.RequestURI != "/containers/create" {
+		t.Fatalf("unexpected request: %+v", req)
+	}
+	if string(req.RequestBody) != `{"Image":"busybox"}` {
+		t.Fatalf("unexpected request body: %q", req.RequestBody)
+	}
+	if _, ok := req.RequestHeaders["X-Registry-Auth"]; ok {
+		t.Fatal("registry auth header should not be sent to plugins")
+	}
+
+	// The body must still be readable by the handler
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"Image":"busybox"}` {
+		t.Fatalf("request body was not restored: %q", body)
+	}
+}
+
+func TestAuthZRequestBuildSecrets(t *testing.T) {
+	plugin := &fakePlugin{name: "allow", res: Response{Allow: true}}
+	r, err := http.NewRequest("POST", "/build", bytes.NewBufferString("context"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Content-Type", "application/tar")
+	r.Header.Set("X-Build-Secrets", "secret")
+
+	ctx := NewCtx([]Plugin{plugin}, "user", "TLS", "POST", "/build")
+	if err := ctx.AuthZRequest(httptest.NewRecorder(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	req := plugin.requests[0]
+	if _, ok := req.RequestHeaders["X-Build-Secrets"]; ok {
+		t.Fatal("build secrets header should not be sent to plugins")
+	}
+	if req.RequestHeaders["Content-Type"] != "application/tar" {
+		t.Fatalf("expected the other headers to be sent, got %v", req.RequestHeaders)
+	}
+	if r.Header.Get("X-Build-Secrets") != "secret" {
+		t.Fatal("build secrets header should be kept for the handler")
+	}
+}
+
+func TestAuthZRequestDenyStopsChain(t *testing.T) {
+	deny := &fakePlugin{name: "deny", res: Response{Allow: false, Msg: "not today"}}
+	next := &fakePlugin{name: "next", res: Response{Allow: true}}
+
+	ctx := NewCtx([]Plugin{deny, next}, "user", "TLS", "POST", "/containers/create")
+	err := ctx.AuthZRequest(httptest.NewRecorder(), newJSONRequest(t, "{}"))
+	if err == nil {
+		t.Fatal("expected request to be denied")
+	}
+	denied, ok := err.(*DeniedError)
+	if !ok {
+		t.Fatalf("expected DeniedError, got %T", err)
+	}
+	if denied.Plugin != "deny" || denied.Msg != "not today" {
+		t.Fatalf("unexpected denial: %+v", denied)
+	}
+	if err.Error() != "authorization denied by plugin deny: not today" {
+		t.Fatalf("unexpected error message: %s", err)
+	}
+	if len(next.requests) != 0 {
+		t.Fatal("plugins after a denial should not be called")
+	}
+}
+
+func TestAuthZRequestPluginError(t *testing.T) {
+	plugin := &fakePlugin{name: "broken", res: Response{Err: "boom"}}
+
+	ctx := NewCtx([]Plugin{plugin}, "", "", "GET", "/info")
+	r, _ := http.NewRequest("GET", "/info", nil)
+	err := ctx.AuthZRequest(httptest.NewRecorder(), r)
+	if err == nil || !strings.Contains(err.Error(), "plugin broken failed with error: boom") {
+		t.Fatalf("expected plugin error, got %v", err)
+	}
+	if _, ok := err.(*DeniedError); ok {
+		t.Fatal("a plugin failure should not be reported as a denial")
+	}
+}
+
+func TestAuthZResponse(t *testing.T) {
+	plugin := &fakePlugin{name: "allow", res: Response{Allow: true}}
+	ctx := NewCtx([]Plugin{plugin}, "user", "TLS", "GET", "/info")
+	r, _ := http.NewRequest("GET", "/info", nil)
+	if err := ctx.AuthZRequest(httptest.NewRecorder(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	rm := NewResponseModifier(recorder)
+	rm.Header().Set("Content-Type", "application/json")
+	rm.WriteHeader(http.StatusCreated)
+	rm.Write([]byte(`{"ID":"abc"}`))
+
+	if recorder.Body.Len() != 0 {
+		t.Fatal("response should be buffered until authorized")
+	}
+	if err := ctx.AuthZResponse(rm, r); err != nil {
+		t.Fatal(err)
+	}
+
+	req := plugin.requests[1]
+	if req.ResponseStatusCode != http.StatusCreated || string(req.ResponseBody) != `{"ID":"abc"}` {
+		t.Fatalf("unexpected response sent to plugin: %+v", req)
+	}
+	if recorder.Code != http.StatusCreated || recorder.Body.String() != `{"ID":"abc"}` {
+		t.Fatalf("unexpected response sent to client: %d %q", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestResponseModifierStreamed(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	rm := NewResponseModifier(recorder)
+
+	rm.Write([]byte("first"))
+	rm.Flush()
+	rm.Write([]byte(" second"))
+
+	if !rm.Streamed() {
+		t.Fatal("response should be streamed after a flush")
+	}
+	if recorder.Body.String() != "first second" {
+		t.Fatalf("unexpected streamed body: %q", recorder.Body.String())
+	}
+	if rm.StatusCode() != http.StatusOK {
+		t.Fatalf("expected default status code, got %d", rm.StatusCode())
+	}
+}
+
+func TestResponseModifierMaxBodySize(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	rm := NewResponseModifier(recorder)
+	rm.WriteHeader(http.StatusCreated)
+
+	small := bytes.Repeat([]byte("a"), maxBodySize/2)
+	rm.Write(small)
+	if rm.Streamed() || recorder.Body.Len() != 0 {
+		t.Fatal("response should be buffered while smaller than the maximum body size")
+	}
+
+	// The body goes over the limit without the handler flushing it.
+	rm.Write(bytes.Repeat([]byte("b"), maxBodySize))
+	if !rm.Streamed() {
+		t.Fatal("response should be streamed once larger than the maximum body size")
+	}
+	if len(rm.RawBody()) != 0 {
+		t.Fatalf("response should not be buffered once streamed, got %d bytes", len(rm.RawBody()))
+	}
+	if recorder.Code != http.StatusCreated || recorder.Body.Len() != len(small)+maxBodySize {
+		t.Fatalf("unexpected response sent to client: %d, %d bytes", recorder.Code, recorder.Body.Len())
+	}
+	if err := rm.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Body.Len() != len(small)+maxBodySize {
+		t.Fatalf("response should be sent once, got %d bytes", recorder.Body.Len())
+	}
+}
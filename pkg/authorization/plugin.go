@@ -0,0 +1,86 @@
+package authorization
+
+import (
+	"sync"
+
+	"github.com/docker/docker/pkg/plugins"
+)
+
+// Plugin allows third party plugins to authorize requests and responses
+// in the context of docker API
+type Plugin interface {
+	// Name returns the registered plugin name
+	Name() string
+
+	// AuthZRequest authorize the request from the client to the daemon
+	AuthZRequest(*Request) (*Response, error)
+
+	// AuthZResponse authorize the response from the daemon to the client
+	AuthZResponse(*Request) (*Response, error)
+}
+
+// NewPlugins constructs and initialize the authorization plugins based on plugin names
+func NewPlugins(names []string) []Plugin {
+	plugins := []Plugin{}
+	pluginsMap := make(map[string]struct{})
+	for _, name := range names {
+		if _, ok := pluginsMap[name]; ok {
+			continue
+		}
+		pluginsMap[name] = struct{}{}
+		plugins = append(plugins, newAuthorizationPlugin(name))
+	}
+	return plugins
+}
+
+// authorizationPlugin is an internal adapter to docker plugin system
+type authorizationPlugin struct {
+	plugin *plugins.Plugin
+	name   string
+	mu     sync.Mutex
+}
+
+func newAuthorizationPlugin(name string) Plugin {
+	return &authorizationPlugin{name: name}
+}
+
+func (a *authorizationPlugin) Name() string {
+	return a.name
+}
+
+func (a *authorizationPlugin) AuthZRequest(authReq *Request) (*Response, error) {
+	return a.call(AuthZAPIRequest, authReq)
+}
+
+func (a *authorizationPlugin) AuthZResponse(authReq *Request) (*Response, error) {
+	return a.call(AuthZAPIResponse, authReq)
+}
+
+func (a *authorizationPlugin) call(serviceMethod string, authReq *Request) (*Response, error) {
+	if err := a.initPlugin(); err != nil {
+		return nil, err
+	}
+
+	authRes := &Response{}
+	if err := a.plugin.Client.Call(serviceMethod, authReq, authRes); err != nil {
+		return nil, err
+	}
+	return authRes, nil
+}
+
+// initPlugin initializes the authorization plugin if needed. A plugin which
+// cannot be found is looked up again on the next request.
+func (a *authorizationPlugin) initPlugin() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.plugin != nil {
+		return nil
+	}
+	plugin, err := plugins.Get(a.name, AuthZAPIImplements)
+	if err != nil {
+		return err
+	}
+	a.plugin = plugin
+	return nil
+}
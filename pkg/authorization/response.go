@@ -0,0 +1,178 @@
+package authorization
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// ResponseModifier allows authorization plugins to read the content of the
+// response of the daemon before it is sent to the client. The response is
+// buffered until FlushAll is called, unless the handler streams it (by
+// flushing it), hijacks the connection, or writes more than maxBodySize, in
+// which case it is sent as it is written.
+type ResponseModifier interface {
+	http.ResponseWriter
+	http.Flusher
+	http.CloseNotifier
+	http.Hijacker
+
+	// RawBody returns the buffered body of the response
+	RawBody() []byte
+
+	// StatusCode returns the status code of the response
+	StatusCode() int
+
+	// Streamed returns whether the response has already been sent, at
+	// least partially, to the client
+	Streamed() bool
+
+	// FlushAll sends the buffered response to the client
+	FlushAll() error
+}
+
+// NewResponseModifier creates a wrapper to an http.ResponseWriter to allow
+// inspecting the response before it is sent
+func NewResponseModifier(rw http.ResponseWriter) ResponseModifier {
+	return &responseModifier{rw: rw}
+}
+
+// responseModifier is used as an adapter to http.ResponseWriter in order to
+// manipulate and explore the response before it is sent
+type responseModifier struct {
+	mu sync.Mutex
+	// The original response writer
+	rw http.ResponseWriter
+	// body holds the response body
+	body []byte
+	// statusCode holds the response status code
+	statusCode int
+	// streamed is set once the response is written through to rw
+	streamed bool
+}
+
+// WriteHeader stores the http status code
+func (rm *responseModifier) WriteHeader(s int) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if rm.streamed {
+		rm.rw.WriteHeader(s)
+		return
+	}
+	rm.statusCode = s
+}
+
+// Header returns the internal http header
+func (rm *responseModifier) Header() http.Header {
+	return rm.rw.Header()
+}
+
+// Write stores the byte array inside content, or sends it along with the
+// buffered response once the body is larger than maxBodySize
+func (rm *responseModifier) Write(b []byte) (int, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if !rm.streamed && len(rm.body)+len(b) > maxBodySize {
+		// the body is too large to be sent to the plugins anyway
+		if err := rm.flush(); err != nil {
+			return 0, err
+		}
+	}
+	if rm.streamed {
+		return rm.rw.Write(b)
+	}
+	rm.body = append(rm.body, b...)
+	return len(b), nil
+}
+
+// RawBody returns the response body
+func (rm *responseModifier) RawBody() []byte {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	return rm.body
+}
+
+// StatusCode returns the response status code, http.StatusOK if none was set
+func (rm *responseModifier) StatusCode() int {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if rm.statusCode == 0 {
+		return http.StatusOK
+	}
+	return rm.statusCode
+}
+
+// Streamed returns whether the response was written through to the client
+func (rm *responseModifier) Streamed() bool {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	return rm.streamed
+}
+
+// Hijack returns the internal connection of the wrapped http.ResponseWriter
+func (rm *responseModifier) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	hijacker, ok := rm.rw.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("Internal response writer doesn't support the Hijacker interface")
+	}
+	rm.streamed = true
+	return hijacker.Hijack()
+}
+
+// CloseNotify uses the internal close notify API of the wrapped http.ResponseWriter
+func (rm *responseModifier) CloseNotify() <-chan bool {
+	closeNotifier, ok := rm.rw.(http.CloseNotifier)
+	if !ok {
+		return make(chan bool)
+	}
+	return closeNotifier.CloseNotify()
+}
+
+// Flush sends the buffered response, and switches to writing the rest of
+// it through, as the handler is streaming it
+func (rm *responseModifier) Flush() {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	rm.flush()
+	if flusher, ok := rm.rw.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// FlushAll sends the buffered response to the client
+func (rm *responseModifier) FlushAll() error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	return rm.flush()
+}
+
+// flush writes the buffered status code and body to the original response
+// writer. The caller must hold the lock.
+func (rm *responseModifier) flush() error {
+	if rm.streamed {
+		return nil
+	}
+	rm.streamed = true
+
+	if rm.statusCode != 0 {
+		rm.rw.WriteHeader(rm.statusCode)
+	}
+	if len(rm.body) == 0 {
+		return nil
+	}
+	_, err := rm.rw.Write(rm.body)
+	rm.body = nil
+	return err
+}
@@ -88,20 +88,47 @@ func (p *JSONProgress) String() string {
 	return pbBox + numbersBox + timeLeftBox
 }
 
+// Types of the build events.
+const (
+	BuildStepStart  = "step-start"  // a step of the build starts
+	BuildStepFinish = "step-finish" // a step of the build succeeded
+	BuildStepError  = "step-error"  // a step of the build failed
+)
+
+// JSONBuildEvent describes a step of a build, for clients which need the
+// progress of the build in a structured form. Cache is "hit" or "miss" once
+// the build cache was looked up for the step, ContainerID is the ID of the
+// last intermediate container of the step and Duration is in nanoseconds.
+// File is the Dockerfile fragment the instruction was included from, empty
+// for the Dockerfile itself.
+type JSONBuildEvent struct {
+	Type        string     `json:"type"`
+	Step        int        `json:"step"`
+	Instruction string     `json:"instruction,omitempty"`
+	Line        int        `json:"line,omitempty"`
+	File        string     `json:"file,omitempty"`
+	Cache       string     `json:"cache,omitempty"`
+	ContainerID string     `json:"containerId,omitempty"`
+	ImageID     string     `json:"imageId,omitempty"`
+	Duration    int64      `json:"duration,omitempty"`
+	Error       *JSONError `json:"error,omitempty"`
+}
+
 // JSONMessage defines a message struct. It describes
 // the created time, where it from, status, ID of the
 // message. It's used for docker events.
 type JSONMessage struct {
-	Stream          string        `json:"stream,omitempty"`
-	Status          string        `json:"status,omitempty"`
-	Progress        *JSONProgress `json:"progressDetail,omitempty"`
-	ProgressMessage string        `json:"progress,omitempty"` //deprecated
-	ID              string        `json:"id,omitempty"`
-	From            string        `json:"from,omitempty"`
-	Time            int64         `json:"time,omitempty"`
-	TimeNano        int64         `json:"timeNano,omitempty"`
-	Error           *JSONError    `json:"errorDetail,omitempty"`
-	ErrorMessage    string        `json:"error,omitempty"` //deprecated
+	Stream          string          `json:"stream,omitempty"`
+	Status          string          `json:"status,omitempty"`
+	Progress        *JSONProgress   `json:"progressDetail,omitempty"`
+	ProgressMessage string          `json:"progress,omitempty"` //deprecated
+	ID              string          `json:"id,omitempty"`
+	From            string          `json:"from,omitempty"`
+	Time            int64           `json:"time,omitempty"`
+	TimeNano        int64           `json:"timeNano,omitempty"`
+	Error           *JSONError      `json:"errorDetail,omitempty"`
+	ErrorMessage    string          `json:"error,omitempty"` //deprecated
+	BuildEvent      *JSONBuildEvent `json:"buildEvent,omitempty"`
 }
 
 // Display displays the JSONMessage to `out`. `isTerminal` describes if `out`
@@ -114,6 +141,10 @@ func (jm *JSONMessage) Display(out io.Writer, isTerminal bool) error {
 		}
 		return jm.Error
 	}
+	if jm.BuildEvent != nil {
+		// build events are only meant for the clients asking for them
+		return nil
+	}
 	var endl string
 	if isTerminal && jm.Stream == "" && jm.Progress != nil {
 		// <ESC>[2K = erase entire current line
@@ -145,6 +176,30 @@ func (jm *JSONMessage) Display(out io.Writer, isTerminal bool) error {
 	return nil
 }
 
+// CopyJSONMessagesStream copies a json message stream from `in` to `out`, one
+// message per line. It stops at the first error message, which is returned.
+func CopyJSONMessagesStream(in io.Reader, out io.Writer) error {
+	var (
+		dec = json.NewDecoder(in)
+		enc = json.NewEncoder(out)
+	)
+	for {
+		var jm JSONMessage
+		if err := dec.Decode(&jm); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := enc.Encode(&jm); err != nil {
+			return err
+		}
+		if jm.Error != nil {
+			return jm.Error
+		}
+	}
+}
+
 // DisplayJSONMessagesStream displays a json message stream from `in` to `out`, `isTerminal`
 // describes if `out` is a terminal. If this is the case, it will print `\n` at the end of
 // each line and move the cursor while displaying.
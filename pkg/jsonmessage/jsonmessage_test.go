@@ -198,6 +198,11 @@ func TestDisplayJSONMessagesStream(t *testing.T) {
 			"ID: status ProgressMessage",
 			fmt.Sprintf("\n%c[%dAID: status ProgressMessage%c[%dB", 27, 0, 27, 0),
 		},
+		// Build event
+		"{ \"buildEvent\": { \"type\": \"step-start\", \"step\": 1 } }": {
+			"",
+			"",
+		},
 		// With progressDetail
 		"{ \"id\": \"ID\", \"status\": \"status\", \"progressDetail\": { \"Current\": 1} }": {
 			"", // progressbar is disabled in non-terminal
@@ -229,3 +234,22 @@ func TestDisplayJSONMessagesStream(t *testing.T) {
 	}
 
 }
+
+func TestCopyJSONMessagesStream(t *testing.T) {
+	stream := `{"stream":"Step 1 : FROM busybox\n"}
+{"buildEvent":{"type":"step-start","step":1,"instruction":"FROM busybox","line":1}}
+{"errorDetail":{"message":"failed"},"error":"failed"}
+{"stream":"ignored\n"}`
+	data := bytes.NewBuffer([]byte{})
+	err := CopyJSONMessagesStream(strings.NewReader(stream), data)
+	if err == nil || err.Error() != "failed" {
+		t.Fatalf("Expected the error of the stream, got %v", err)
+	}
+	expected := `{"stream":"Step 1 : FROM busybox\n"}
+{"buildEvent":{"type":"step-start","step":1,"instruction":"FROM busybox","line":1}}
+{"errorDetail":{"message":"failed"},"error":"failed"}
+`
+	if data.String() != expected {
+		t.Fatalf("Expected %q, got %q", expected, data.String())
+	}
+}
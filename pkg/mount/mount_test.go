@@ -22,6 +22,30 @@ func TestMountOptionsParsing(t *testing.T) {
 	}
 }
 
+func TestTmpfsOptionsParsing(t *testing.T) {
+	flag, data, err := ParseTmpfsOptions("rw,noexec,nosuid,size=65536k,mode=1777")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data != "size=65536k,mode=1777" {
+		t.Fatalf("Expected size=65536k,mode=1777 got %s", data)
+	}
+	expectedFlag := NOEXEC | NOSUID
+	if flag != expectedFlag {
+		t.Fatalf("Expected %d got %d", expectedFlag, flag)
+	}
+
+	if _, _, err := ParseTmpfsOptions(""); err != nil {
+		t.Fatalf("Expected empty options to be valid, got %v", err)
+	}
+
+	for _, options := range []string{"size=64m,foo=bar", "bogus"} {
+		if _, _, err := ParseTmpfsOptions(options); err == nil {
+			t.Fatalf("Expected an error for invalid options %q", options)
+		}
+	}
+}
+
 func TestMounted(t *testing.T) {
 	tmp := path.Join(os.TempDir(), "mount-tests")
 	if err := os.MkdirAll(tmp, 0777); err != nil {
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/docker/docker/pkg/jsonmessage"
 )
@@ -68,6 +69,19 @@ func (sf *StreamFormatter) FormatError(err error) []byte {
 	return []byte("Error: " + err.Error() + streamNewline)
 }
 
+// FormatBuildEvent formats the specified build event. Build events are only
+// streamed in JSON.
+func (sf *StreamFormatter) FormatBuildEvent(ev *jsonmessage.JSONBuildEvent) []byte {
+	if !sf.json {
+		return nil
+	}
+	b, err := json.Marshal(&jsonmessage.JSONMessage{BuildEvent: ev, TimeNano: time.Now().UnixNano()})
+	if err != nil {
+		return sf.FormatError(err)
+	}
+	return append(b, streamNewlineBytes...)
+}
+
 // FormatProgress formats the progress information for a specified action.
 func (sf *StreamFormatter) FormatProgress(id, action string, progress *jsonmessage.JSONProgress) []byte {
 	if progress == nil {
@@ -91,3 +91,19 @@ func TestJSONFormatProgress(t *testing.T) {
 		t.Fatal("Original progress not equals progress from FormatProgress")
 	}
 }
+
+func TestFormatBuildEvent(t *testing.T) {
+	ev := &jsonmessage.JSONBuildEvent{Type: jsonmessage.BuildStepFinish, Step: 2, Cache: "hit", ImageID: "abc"}
+	if res := NewStreamFormatter().FormatBuildEvent(ev); res != nil {
+		t.Fatalf("Expected no build event in a text stream, got %q", res)
+	}
+
+	res := NewJSONStreamFormatter().FormatBuildEvent(ev)
+	msg := &jsonmessage.JSONMessage{}
+	if err := json.Unmarshal(res, msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.TimeNano == 0 || !reflect.DeepEqual(msg.BuildEvent, ev) {
+		t.Fatalf("Expected a timed message with the build event, got %q", res)
+	}
+}
@@ -14,10 +14,11 @@ import (
 	flag "github.com/docker/docker/pkg/mflag"
 )
 
-// Options holds command line options.
+// Options holds command line options. The json tags are the names of the
+// options in the daemon configuration file.
 type Options struct {
-	Mirrors            opts.ListOpts
-	InsecureRegistries opts.ListOpts
+	Mirrors            []string `json:"registry-mirror,omitempty"`
+	InsecureRegistries []string `json:"insecure-registry,omitempty"`
 }
 
 const (
@@ -53,10 +54,8 @@ var (
 // InstallFlags adds command-line options to the top-level flag parser for
 // the current process.
 func (options *Options) InstallFlags(cmd *flag.FlagSet, usageFn func(string) string) {
-	options.Mirrors = opts.NewListOpts(ValidateMirror)
-	cmd.Var(&options.Mirrors, []string{"-registry-mirror"}, usageFn("Preferred Docker registry mirror"))
-	options.InsecureRegistries = opts.NewListOpts(ValidateIndexName)
-	cmd.Var(&options.InsecureRegistries, []string{"-insecure-registry"}, usageFn("Enable insecure registry communication"))
+	cmd.Var(opts.NewListOptsRef(&options.Mirrors, ValidateMirror), []string{"-registry-mirror"}, usageFn("Preferred Docker registry mirror"))
+	cmd.Var(opts.NewListOptsRef(&options.InsecureRegistries, ValidateIndexName), []string{"-insecure-registry"}, usageFn("Enable insecure registry communication"))
 	cmd.BoolVar(&V2Only, []string{"-disable-legacy-registry"}, false, "Do not contact legacy registries")
 }
 
@@ -87,10 +86,7 @@ type ServiceConfig struct {
 // NewServiceConfig returns a new instance of ServiceConfig
 func NewServiceConfig(options *Options) *ServiceConfig {
 	if options == nil {
-		options = &Options{
-			Mirrors:            opts.NewListOpts(nil),
-			InsecureRegistries: opts.NewListOpts(nil),
-		}
+		options = &Options{}
 	}
 
 	// Localhost is by default considered as an insecure registry
@@ -98,17 +94,18 @@ func NewServiceConfig(options *Options) *ServiceConfig {
 	//
 	// TODO: should we deprecate this once it is easier for people to set up a TLS registry or change
 	// daemon flags on boot2docker?
-	options.InsecureRegistries.Set("127.0.0.0/8")
+	insecureRegistries := append([]string{}, options.InsecureRegistries...)
+	insecureRegistries = append(insecureRegistries, "127.0.0.0/8")
 
 	config := &ServiceConfig{
 		InsecureRegistryCIDRs: make([]*netIPNet, 0),
 		IndexConfigs:          make(map[string]*IndexInfo, 0),
 		// Hack: Bypass setting the mirrors to IndexConfigs since they are going away
 		// and Mirrors are only for the official registry anyways.
-		Mirrors: options.Mirrors.GetAll(),
+		Mirrors: append([]string{}, options.Mirrors...),
 	}
 	// Split --insecure-registry into CIDR and registry-specific settings.
-	for _, r := range options.InsecureRegistries.GetAll() {
+	for _, r := range insecureRegistries {
 		// Check if CIDR was passed to --insecure-registry
 		_, ipnet, err := net.ParseCIDR(r)
 		if err == nil {
@@ -15,7 +15,6 @@ import (
 	"testing"
 	"time"
 
-	"github.com/docker/docker/opts"
 	"github.com/gorilla/mux"
 
 	"github.com/Sirupsen/logrus"
@@ -174,18 +173,8 @@ func makePublicIndex() *IndexInfo {
 
 func makeServiceConfig(mirrors []string, insecureRegistries []string) *ServiceConfig {
 	options := &Options{
-		Mirrors:            opts.NewListOpts(nil),
-		InsecureRegistries: opts.NewListOpts(nil),
-	}
-	if mirrors != nil {
-		for _, mirror := range mirrors {
-			options.Mirrors.Set(mirror)
-		}
-	}
-	if insecureRegistries != nil {
-		for _, insecureRegistries := range insecureRegistries {
-			options.InsecureRegistries.Set(insecureRegistries)
-		}
+		Mirrors:            mirrors,
+		InsecureRegistries: insecureRegistries,
 	}
 
 	return NewServiceConfig(options)
@@ -4,6 +4,7 @@ import (
 	"crypto/tls"
 	"net/http"
 	"net/url"
+	"sync"
 
 	"github.com/docker/distribution/registry/client/auth"
 	"github.com/docker/docker/cliconfig"
@@ -13,6 +14,7 @@ import (
 // of mirrors.
 type Service struct {
 	Config *ServiceConfig
+	mu     sync.RWMutex
 }
 
 // NewService returns a new instance of Service ready to be
@@ -23,6 +25,24 @@ func NewService(options *Options) *Service {
 	}
 }
 
+// ServiceConfig returns the current configuration of the service.
+func (s *Service) ServiceConfig() *ServiceConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Config
+}
+
+// Reload replaces the configuration of the service with one built from
+// options, so that new registry mirrors and insecure registries are used
+// by the following requests.
+func (s *Service) Reload(options *Options) {
+	config := NewServiceConfig(options)
+
+	s.mu.Lock()
+	s.Config = config
+	s.mu.Unlock()
+}
+
 // Auth contacts the public registry with the provided credentials,
 // and returns OK if authentication was successful.
 // It can be used to verify the validity of a client's credentials.
@@ -76,18 +96,18 @@ func (s *Service) Search(term string, authConfig *cliconfig.AuthConfig, headers
 // ResolveRepository splits a repository name into its components
 // and configuration of the associated registry.
 func (s *Service) ResolveRepository(name string) (*RepositoryInfo, error) {
-	return s.Config.NewRepositoryInfo(name, false)
+	return s.ServiceConfig().NewRepositoryInfo(name, false)
 }
 
 // ResolveRepositoryBySearch splits a repository name into its components
 // and configuration of the associated registry.
 func (s *Service) ResolveRepositoryBySearch(name string) (*RepositoryInfo, error) {
-	return s.Config.NewRepositoryInfo(name, true)
+	return s.ServiceConfig().NewRepositoryInfo(name, true)
 }
 
 // ResolveIndex takes indexName and returns index info
 func (s *Service) ResolveIndex(name string) (*IndexInfo, error) {
-	return s.Config.NewIndexInfo(name)
+	return s.ServiceConfig().NewIndexInfo(name)
 }
 
 // APIEndpoint represents a remote API endpoint
@@ -109,7 +129,7 @@ func (e APIEndpoint) ToV1Endpoint(metaHeaders http.Header) (*Endpoint, error) {
 
 // TLSConfig constructs a client TLS configuration based on server defaults
 func (s *Service) TLSConfig(hostname string) (*tls.Config, error) {
-	return newTLSConfig(hostname, s.Config.isSecureIndex(hostname))
+	return newTLSConfig(hostname, s.ServiceConfig().isSecureIndex(hostname))
 }
 
 func (s *Service) tlsConfigForMirror(mirror string) (*tls.Config, error) {
@@ -39,6 +39,8 @@ func TestCompare(t *testing.T) {
 	cmd1 := stringutils.NewStrSlice("/bin/sh", "-c")
 	cmd2 := stringutils.NewStrSlice("/bin/sh", "-d")
 	cmd3 := stringutils.NewStrSlice("/bin/sh", "-c", "echo")
+	shell1 := stringutils.NewStrSlice("/bin/bash", "-c")
+	shell2 := stringutils.NewStrSlice("/bin/bash", "-e", "-c")
 	labels1 := map[string]string{"LABEL1": "value1", "LABEL2": "value2"}
 	labels2 := map[string]string{"LABEL1": "value1", "LABEL2": "value3"}
 	labels3 := map[string]string{"LABEL1": "value1", "LABEL2": "value2", "LABEL3": "value3"}
@@ -64,6 +66,8 @@ func TestCompare(t *testing.T) {
 		&Config{Env: envs1}: {Env: envs1},
 		// only cmd
 		&Config{Cmd: cmd1}: {Cmd: cmd1},
+		// only shell
+		&Config{Shell: shell1}: {Shell: shell1},
 		// only labels
 		&Config{Labels: labels1}: {Labels: labels1},
 		// only exposedPorts
@@ -107,6 +111,10 @@ func TestCompare(t *testing.T) {
 		&Config{Entrypoint: entrypoint1}: {Entrypoint: entrypoint2},
 		// not the same number of parts
 		&Config{Entrypoint: entrypoint1}: {Entrypoint: entrypoint3},
+		// only shells
+		&Config{Shell: shell1}: {Shell: shell2},
+		// a shell and the default one
+		&Config{Shell: shell1}: {},
 		// only volumes
 		&Config{Volumes: volumes1}: {Volumes: volumes2},
 		// not the same number of labels
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/docker/docker/pkg/nat"
 	"github.com/docker/docker/pkg/stringutils"
@@ -40,6 +41,27 @@ type Config struct {
 	OnBuild         []string              // ONBUILD metadata that were defined on the image Dockerfile
 	Labels          map[string]string     // List of labels set to this container
 	StopSignal      string                `json:",omitempty"` // Signal to stop a container
+	Healthcheck     *HealthConfig         `json:",omitempty"` // Healthcheck describes how to check the container is healthy
+	Shell           *stringutils.StrSlice `json:",omitempty"` // Shell for the shell form of RUN, CMD and ENTRYPOINT
+}
+
+// HealthConfig holds the configuration of the health check of a container.
+type HealthConfig struct {
+	// Test is the test to perform to check that the container is healthy.
+	// An empty slice means to inherit the default. The options are:
+	// {} : inherit the health check
+	// {"NONE"} : disable the health check
+	// {"CMD", args...} : exec the arguments directly
+	// {"CMD-SHELL", command} : run the command with the system's default shell
+	Test []string `json:",omitempty"`
+
+	// Zero means to inherit. Durations are expressed as integer nanoseconds.
+	Interval time.Duration `json:",omitempty"` // Time to wait between two checks
+	Timeout  time.Duration `json:",omitempty"` // Time to wait before considering a check to have hung
+
+	// Retries is the number of consecutive failures needed to consider the
+	// container as unhealthy. Zero means to inherit.
+	Retries int `json:",omitempty"`
 }
 
 // DecodeContainerConfig decodes a json encoded config into a ContainerConfigWrapper
@@ -205,10 +205,13 @@ type HostConfig struct {
 	MemorySwappiness  *int64                // Tuning container memory swappiness behaviour
 	OomKillDisable    bool                  // Whether to disable OOM Killer or not
 	PidMode           PidMode               // PID namespace to use for the container
+	PidsLimit         int64                 // Setting pids limit for a container
 	Privileged        bool                  // Is the container in privileged mode
 	PublishAllPorts   bool                  // Should docker publish all exposed port for the container
 	ReadonlyRootfs    bool                  // Is the container root filesystem in read-only
 	SecurityOpt       []string              // List of string values to customize labels for MLS systems, such as SELinux.
+	Sysctls           map[string]string     `json:",omitempty"` // List of Namespaced sysctls used for the container
+	Tmpfs             map[string]string     `json:",omitempty"` // List of tmpfs (mounts) used for the container
 	Ulimits           []*ulimit.Ulimit      // List of ulimits to be set in the container
 	UTSMode           UTSMode               // UTS namespace to use for the container
 
@@ -217,6 +220,33 @@ type HostConfig struct {
 	Isolation   IsolationLevel // Isolation level of the container (eg default, hyperv)
 }
 
+// UpdateConfig holds the attributes of a HostConfig that can be changed
+// after the container has been created, even while it is running.
+// Fields left to their zero value keep the current setting.
+type UpdateConfig struct {
+	BlkioWeight       uint16        // Block IO weight (relative weight vs. other containers)
+	CPUShares         int64         `json:"CpuShares"` // CPU shares (relative weight vs. other containers)
+	CPUPeriod         int64         `json:"CpuPeriod"` // CPU CFS (Completely Fair Scheduler) period
+	CPUQuota          int64         `json:"CpuQuota"`  // CPU CFS (Completely Fair Scheduler) quota
+	CpusetCpus        string        // CpusetCpus 0-2, 0,1
+	CpusetMems        string        // CpusetMems 0-2, 0,1
+	KernelMemory      int64         // Kernel memory limit (in bytes)
+	Memory            int64         // Memory limit (in bytes)
+	MemoryReservation int64         // Memory soft limit (in bytes)
+	MemorySwap        int64         // Total memory usage (memory + swap); set `-1` to disable swap
+	RestartPolicy     RestartPolicy // Restart policy to be used for the container
+}
+
+// DecodeUpdateConfig creates an UpdateConfig based on the specified Reader.
+// It assumes the content of the reader will be JSON, and decodes it.
+func DecodeUpdateConfig(src io.Reader) (*UpdateConfig, error) {
+	var uc UpdateConfig
+	if err := json.NewDecoder(src).Decode(&uc); err != nil {
+		return nil, err
+	}
+	return &uc, nil
+}
+
 // DecodeHostConfig creates a HostConfig based on the specified Reader.
 // It assumes the content of the reader will be JSON, and decodes it.
 func DecodeHostConfig(src io.Reader) (*HostConfig, error) {
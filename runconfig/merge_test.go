@@ -2,6 +2,7 @@ package runconfig
 
 import (
 	"testing"
+	"time"
 
 	"github.com/docker/docker/pkg/nat"
 )
@@ -81,3 +82,41 @@ func TestMerge(t *testing.T) {
 		}
 	}
 }
+
+func TestMergeHealthcheck(t *testing.T) {
+	imageConf := &Config{
+		Healthcheck: &HealthConfig{
+			Test:     []string{"CMD-SHELL", "/check.sh"},
+			Interval: 30 * time.Second,
+			Retries:  3,
+		},
+	}
+
+	configUser := &Config{}
+	if err := Merge(configUser, imageConf); err != nil {
+		t.Error(err)
+	}
+	if configUser.Healthcheck == nil || len(configUser.Healthcheck.Test) != 2 {
+		t.Fatalf("Expected the health check of the image to be inherited, got %#v", configUser.Healthcheck)
+	}
+
+	configUser = &Config{
+		Healthcheck: &HealthConfig{
+			Timeout: 5 * time.Second,
+			Retries: 1,
+		},
+	}
+	if err := Merge(configUser, imageConf); err != nil {
+		t.Error(err)
+	}
+	health := configUser.Healthcheck
+	if len(health.Test) != 2 || health.Test[1] != "/check.sh" {
+		t.Fatalf("Expected test to be inherited, got %#v", health.Test)
+	}
+	if health.Interval != 30*time.Second {
+		t.Fatalf("Expected interval to be inherited, got %s", health.Interval)
+	}
+	if health.Timeout != 5*time.Second || health.Retries != 1 {
+		t.Fatalf("Expected user settings to be kept, got %#v", health)
+	}
+}
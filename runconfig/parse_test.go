@@ -9,6 +9,7 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	flag "github.com/docker/docker/pkg/mflag"
 	"github.com/docker/docker/pkg/nat"
@@ -561,6 +562,127 @@ func TestParseRestartPolicy(t *testing.T) {
 	}
 }
 
+func TestParseHealth(t *testing.T) {
+	checkOk := func(args ...string) *HealthConfig {
+		config, _, _, err := parseRun(args)
+		if err != nil {
+			t.Fatalf("%#v: %v", args, err)
+		}
+		return config.Healthcheck
+	}
+	checkError := func(expected string, args ...string) {
+		config, _, _, err := parseRun(args)
+		if err == nil {
+			t.Fatalf("Expected error, but got %#v", config)
+		}
+		if err.Error() != expected {
+			t.Fatalf("Expected %#v, got %#v", expected, err)
+		}
+	}
+	health := checkOk("--no-healthcheck", "img", "cmd")
+	if health == nil || len(health.Test) != 1 || health.Test[0] != "NONE" {
+		t.Fatalf("--no-healthcheck failed: %#v", health)
+	}
+
+	health = checkOk("--health-cmd=/check.sh -q", "img", "cmd")
+	if len(health.Test) != 2 || health.Test[0] != "CMD-SHELL" || health.Test[1] != "/check.sh -q" {
+		t.Fatalf("--health-cmd: got %#v", health.Test)
+	}
+	if health.Timeout != 0 {
+		t.Fatalf("--health-cmd: timeout = %s", health.Timeout)
+	}
+
+	checkError("--no-healthcheck conflicts with --health-* options",
+		"--no-healthcheck", "--health-cmd=/check.sh -q", "img", "cmd")
+
+	health = checkOk("--health-timeout=2s", "--health-retries=3", "--health-interval=4.5s", "img", "cmd")
+	if health.Timeout != 2*time.Second || health.Retries != 3 || health.Interval != 4500*time.Millisecond {
+		t.Fatalf("--health-*: got %#v", health)
+	}
+	if len(health.Test) != 0 {
+		t.Fatalf("--health-*: test should be inherited, got %#v", health.Test)
+	}
+
+	checkError("--health-retries cannot be negative", "--health-retries=-1", "img", "cmd")
+
+	if health := checkOk("img", "cmd"); health != nil {
+		t.Fatalf("Expected no health check by default, got %#v", health)
+	}
+}
+
+func TestParseTmpfs(t *testing.T) {
+	_, hostconfig := mustParse(t, "--tmpfs /run:rw,noexec,size=64m --tmpfs /tmp")
+	if len(hostconfig.Tmpfs) != 2 {
+		t.Fatalf("Expected 2 tmpfs mounts, got %v", hostconfig.Tmpfs)
+	}
+	if hostconfig.Tmpfs["/run"] != "rw,noexec,size=64m" {
+		t.Fatalf("Expected the options of /run to be kept, got %q", hostconfig.Tmpfs["/run"])
+	}
+	if options, ok := hostconfig.Tmpfs["/tmp"]; !ok || options != "" {
+		t.Fatalf("Expected /tmp without options, got %v", hostconfig.Tmpfs)
+	}
+
+	if _, _, err := parse(t, "--tmpfs /run:size=64m,foo=bar"); err == nil || !strings.Contains(err.Error(), "Invalid tmpfs option") {
+		t.Fatalf("Expected an error for invalid tmpfs options, got %v", err)
+	}
+}
+
+func TestParsePidsLimit(t *testing.T) {
+	_, hostconfig := mustParse(t, "")
+	if hostconfig.PidsLimit != 0 {
+		t.Fatalf("Expected no pids limit by default, got %d", hostconfig.PidsLimit)
+	}
+	_, hostconfig = mustParse(t, "--pids-limit=100")
+	if hostconfig.PidsLimit != 100 {
+		t.Fatalf("Expected a pids limit of 100, got %d", hostconfig.PidsLimit)
+	}
+	_, hostconfig = mustParse(t, "--pids-limit=-1")
+	if hostconfig.PidsLimit != -1 {
+		t.Fatalf("Expected an unlimited pids limit, got %d", hostconfig.PidsLimit)
+	}
+}
+
+func TestParseSysctls(t *testing.T) {
+	_, hostconfig := mustParse(t, "--sysctl net.core.somaxconn=1024 --sysctl kernel.shmmax=68719476736")
+	if len(hostconfig.Sysctls) != 2 {
+		t.Fatalf("Expected 2 sysctls, got %v", hostconfig.Sysctls)
+	}
+	if hostconfig.Sysctls["net.core.somaxconn"] != "1024" {
+		t.Fatalf("Expected net.core.somaxconn to be 1024, got %q", hostconfig.Sysctls["net.core.somaxconn"])
+	}
+
+	if _, _, err := parse(t, "--sysctl kernel.hostname=foo"); err == nil || !strings.Contains(err.Error(), "is not allowed") {
+		t.Fatalf("Expected an error for a sysctl that is not namespaced, got %v", err)
+	}
+}
+
+func TestParseSecurityOpts(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "seccomp-profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.WriteString("{\n  \"defaultAction\": \"SCMP_ACT_ALLOW\"\n}\n"); err != nil {
+		t.Fatal(err)
+	}
+	tmpFile.Close()
+
+	_, hostconfig := mustParse(t, "--security-opt seccomp="+tmpFile.Name()+" --security-opt seccomp:unconfined --security-opt label:disable")
+	expected := []string{`seccomp={"defaultAction":"SCMP_ACT_ALLOW"}`, "seccomp:unconfined", "label:disable"}
+	if len(hostconfig.SecurityOpt) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, hostconfig.SecurityOpt)
+	}
+	for i := range expected {
+		if hostconfig.SecurityOpt[i] != expected[i] {
+			t.Fatalf("Expected %q, got %q", expected[i], hostconfig.SecurityOpt[i])
+		}
+	}
+
+	if _, _, err := parse(t, "--security-opt seccomp=/non/existent/profile.json"); err == nil || !strings.Contains(err.Error(), "Opening seccomp profile") {
+		t.Fatalf("Expected an error for a missing seccomp profile, got %v", err)
+	}
+}
+
 func TestParseLoggingOpts(t *testing.T) {
 	// logging opts ko
 	if _, _, _, err := parseRun([]string{"--log-driver=none", "--log-opt=anything", "img", "cmd"}); err == nil || err.Error() != "Invalid logging opts for driver none" {
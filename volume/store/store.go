@@ -18,6 +18,23 @@ func New() *VolumeStore {
 	}
 }
 
+// EventLogger is the function the store calls to report that a volume was
+// created or removed.
+type EventLogger func(name, action string, attributes map[string]string)
+
+// SetEventLogger sets the function used to report volume events.
+func (s *VolumeStore) SetEventLogger(logger EventLogger) {
+	s.eventLogger = logger
+}
+
+// logEvent reports an event about the volume if an event logger is set.
+func (s *VolumeStore) logEvent(v volume.Volume, action string) {
+	if s.eventLogger == nil {
+		return
+	}
+	s.eventLogger(v.Name(), action, map[string]string{"driver": v.DriverName()})
+}
+
 func (s *VolumeStore) get(name string) (*volumeCounter, bool) {
 	s.globalLock.Lock()
 	vc, exists := s.vols[name]
@@ -39,9 +56,10 @@ func (s *VolumeStore) remove(name string) {
 
 // VolumeStore is a struct that stores the list of volumes available and keeps track of their usage counts
 type VolumeStore struct {
-	vols       map[string]*volumeCounter
-	locks      *locker.Locker
-	globalLock sync.Mutex
+	vols        map[string]*volumeCounter
+	locks       *locker.Locker
+	globalLock  sync.Mutex
+	eventLogger EventLogger
 }
 
 // volumeCounter keeps track of references to a volume
@@ -89,6 +107,7 @@ func (s *VolumeStore) Create(name, driverName string, opts map[string]string) (v
 	}
 
 	s.set(name, &volumeCounter{v, 0})
+	s.logEvent(v, "create")
 	return v, nil
 }
 
@@ -130,6 +149,7 @@ func (s *VolumeStore) Remove(v volume.Volume) error {
 	}
 
 	s.remove(name)
+	s.logEvent(vc.Volume, "destroy")
 	return nil
 }
 